@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// KeyPrefix starts every generated API key, so keys are recognisable in
+// Authorization headers and secret scanners
+const KeyPrefix = "xik_"
+
+// APIKeyHeader is the header API keys are sent in. Keys are also accepted as
+// bearer tokens.
+const APIKeyHeader = "X-API-Key"
+
+// Key is an API key known by the SHA-256 hash of its secret
+type Key struct {
+	Name   string
+	Hash   string // hex SHA-256 of the key, see HashKey
+	Scopes []Scope
+}
+
+// KeyLookup finds a key by hash and returns nil if there is none
+type KeyLookup func(hash string) (*Key, error)
+
+// APIKeyAuthenticator authenticates requests carrying an API key. Keys from
+// configuration are checked first, then Lookup if it is set.
+type APIKeyAuthenticator struct {
+	static map[string]Key
+	lookup KeyLookup
+}
+
+// NewAPIKeyAuthenticator creates an authenticator for static keys and keys found by lookup
+func NewAPIKeyAuthenticator(static []Key, lookup KeyLookup) *APIKeyAuthenticator {
+	a := &APIKeyAuthenticator{static: make(map[string]Key), lookup: lookup}
+	for _, key := range static {
+		a.static[key.Hash] = key
+	}
+	return a
+}
+
+// Authenticate implements Authenticator
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	secret := r.Header.Get(APIKeyHeader)
+	if secret == "" {
+		if token := bearerToken(r); strings.HasPrefix(token, KeyPrefix) {
+			secret = token
+		}
+	}
+	if secret == "" {
+		return nil, ErrNoCredentials
+	}
+
+	hash := HashKey(secret)
+	key, ok := a.static[hash]
+	if !ok && a.lookup != nil {
+		found, err := a.lookup(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up API key: %w", err)
+		}
+		if found != nil {
+			key, ok = *found, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: key.Name, Method: MethodAPIKey, Scopes: key.Scopes}, nil
+}
+
+// GenerateKey returns a new random API key
+func GenerateKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return KeyPrefix + hex.EncodeToString(b), nil
+}
+
+// HashKey returns the hex SHA-256 of an API key. Keys are long and random, so
+// a fast hash is enough to make a leaked hash useless.
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseStaticKeys parses keys from configuration, written as name:hash:scopes
+// entries separated by semicolons, e.g. "ci:9f86d0...:write,import;dashboard:2c26b4...:read"
+func ParseStaticKeys(value string) ([]Key, error) {
+	var keys []Key
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid API key entry %q, use name:sha256:scopes", entry)
+		}
+		hash := strings.ToLower(parts[1])
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("API key %q: hash must be a hex SHA-256 of the key", parts[0])
+		}
+		scopes, err := ParseScopes(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("API key %q: %w", parts[0], err)
+		}
+		keys = append(keys, Key{Name: parts[0], Hash: hash, Scopes: scopes})
+	}
+	return keys, nil
+}
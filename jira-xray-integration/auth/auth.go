@@ -0,0 +1,117 @@
+// Package auth authenticates callers of the integration API. An Authenticator
+// turns the credentials sent with a request into a Principal, and the scopes
+// of the principal decide which routes it may call.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Scope is a permission granted to a caller
+type Scope string
+
+// Scopes
+const (
+	ScopeRead   Scope = "read"   // read test cases, executions, reports and status
+	ScopeWrite  Scope = "write"  // create issues and record results; implies read
+	ScopeImport Scope = "import" // import test cases and results from files
+	ScopeAdmin  Scope = "admin"  // manage API keys and the outbox; implies every scope
+)
+
+// AllScopes lists the known scopes
+var AllScopes = []Scope{ScopeRead, ScopeWrite, ScopeImport, ScopeAdmin}
+
+// Authentication methods
+const (
+	MethodAPIKey = "apikey"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request carries
+	// none of the credentials it understands
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned for unknown, expired or malformed credentials
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	Subject string  `json:"subject"` // API key name or JWT subject
+	Method  string  `json:"method"`  // apikey or jwt
+	Scopes  []Scope `json:"scopes"`
+
+	Claims map[string]interface{} `json:"-"` // JWT claims, matched by role bindings
+}
+
+// HasScope reports whether the principal was granted scope. Admin implies
+// every scope and write implies read.
+func (p *Principal) HasScope(scope Scope) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin || (s == ScopeWrite && scope == ScopeRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the caller of a request
+type Authenticator interface {
+	// Authenticate returns the caller, ErrNoCredentials if the request has no
+	// credentials for this authenticator, or an error wrapping ErrInvalidCredentials
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain tries authenticators in order. The first one that finds credentials in
+// the request decides; a request without any credentials gets ErrNoCredentials.
+type Chain []Authenticator
+
+// Authenticate implements Authenticator
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		principal, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// ParseScope validates a scope name
+func ParseScope(name string) (Scope, error) {
+	for _, s := range AllScopes {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scope %q, use read, write, import or admin", name)
+}
+
+// ParseScopes parses a comma or space separated list of scope names
+func ParseScopes(value string) ([]Scope, error) {
+	var scopes []Scope
+	for _, name := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		scope, err := ParseScope(name)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return scopes, nil
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
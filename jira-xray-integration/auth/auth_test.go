@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		granted []Scope
+		scope   Scope
+		want    bool
+	}{
+		{granted: []Scope{ScopeRead}, scope: ScopeRead, want: true},
+		{granted: []Scope{ScopeRead}, scope: ScopeWrite, want: false},
+		{granted: []Scope{ScopeWrite}, scope: ScopeRead, want: true},
+		{granted: []Scope{ScopeWrite}, scope: ScopeImport, want: false},
+		{granted: []Scope{ScopeImport}, scope: ScopeRead, want: false},
+		{granted: []Scope{ScopeAdmin}, scope: ScopeImport, want: true},
+		{granted: nil, scope: ScopeRead, want: false},
+	}
+	for _, tt := range tests {
+		p := &Principal{Scopes: tt.granted}
+		if got := p.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%v has %s: got %v, want %v", tt.granted, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestParseStaticKeys(t *testing.T) {
+	hash := HashKey("xik_secret")
+	keys, err := ParseStaticKeys("ci:" + hash + ":write,import; dashboard:" + strings.ToUpper(hash) + ":read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Name != "ci" || len(keys[0].Scopes) != 2 || keys[1].Hash != hash {
+		t.Errorf("got %+v", keys)
+	}
+
+	for _, value := range []string{
+		"ci:" + hash,             // no scopes
+		"ci:xik_secret:read",     // key instead of its hash
+		"ci:" + hash + ":delete", // unknown scope
+		":" + hash + ":read",     // no name
+	} {
+		if _, err := ParseStaticKeys(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	stored := map[string]*Key{HashKey("xik_stored"): {Name: "stored", Scopes: []Scope{ScopeAdmin}}}
+	a := NewAPIKeyAuthenticator(
+		[]Key{{Name: "ci", Hash: HashKey("xik_static"), Scopes: []Scope{ScopeWrite}}},
+		func(hash string) (*Key, error) { return stored[hash], nil },
+	)
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		subject string
+		err     error
+	}{
+		{name: "static key in header", header: APIKeyHeader, value: "xik_static", subject: "ci"},
+		{name: "stored key as bearer token", header: "Authorization", value: "Bearer xik_stored", subject: "stored"},
+		{name: "unknown key", header: APIKeyHeader, value: "xik_unknown", err: ErrInvalidCredentials},
+		{name: "no key", err: ErrNoCredentials},
+		{name: "JWT bearer token", header: "Authorization", value: "Bearer eyJ.eyJ.sig", err: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			principal, err := a.Authenticate(req)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Subject != tt.subject || principal.Method != MethodAPIKey {
+				t.Errorf("got %+v", principal)
+			}
+		})
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	a, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateKey()
+	if !strings.HasPrefix(a, KeyPrefix) || len(a) != len(KeyPrefix)+48 || a == b {
+		t.Errorf("got keys %q and %q", a, b)
+	}
+}
+
+// testSigner signs JWTs with a generated key published in a JWKS file
+type testSigner struct {
+	alg string
+	kid string
+	key crypto.Signer
+}
+
+func (s *testSigner) jwk() map[string]string {
+	switch pub := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA", "kid": s.kid, "alg": s.alg, "use": "sig",
+			"n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		return map[string]string{
+			"kty": "EC", "kid": s.kid, "crv": "P-256",
+			"x": b64(pub.X.FillBytes(make([]byte, 32))), "y": b64(pub.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	return nil
+}
+
+func (s *testSigner) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": s.alg, "kid": s.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, sig *big.Int
+		r, sig, err = ecdsa.Sign(rand.Reader, key, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), sig.FillBytes(make([]byte, 32))...)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(signature)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func writeJWKS(t *testing.T, signers ...*testSigner) string {
+	t.Helper()
+	var keys []map[string]string
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+	// Keys for other purposes are skipped
+	keys = append(keys, map[string]string{"kty": "oct", "k": "c2VjcmV0"})
+	data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	rs := &testSigner{alg: "RS256", kid: "rsa-1", key: rsaKey}
+	es := &testSigner{alg: "ES256", kid: "ec-1", key: ecKey}
+	forged := &testSigner{alg: "RS256", kid: "rsa-1", key: otherKey}
+
+	a, err := NewJWTAuthenticator(JWTOptions{
+		JWKSFile: writeJWKS(t, rs, es),
+		Issuer:   "https://idp.example.com",
+		Audience: "xray-api",
+		Leeway:   time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":   "ci-pipeline",
+			"iss":   "https://idp.example.com",
+			"aud":   []string{"xray-api", "other"},
+			"exp":   now.Add(time.Hour).Unix(),
+			"scope": "openid read import",
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		scopes []Scope
+		err    error
+	}{
+		{name: "RS256", token: rs.sign(t, claims(nil)), scopes: []Scope{ScopeRead, ScopeImport}},
+		{name: "ES256 with scope list", token: es.sign(t, claims(map[string]interface{}{"scope": []string{"admin"}})), scopes: []Scope{ScopeAdmin}},
+		{name: "expired within leeway", token: rs.sign(t, claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()})), scopes: []Scope{ScopeRead, ScopeImport}},
+		{name: "expired", token: rs.sign(t, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), err: ErrInvalidCredentials},
+		{name: "no expiry", token: rs.sign(t, claims(map[string]interface{}{"exp": nil})), err: ErrInvalidCredentials},
+		{name: "not valid yet", token: rs.sign(t, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), err: ErrInvalidCredentials},
+		{name: "wrong issuer", token: rs.sign(t, claims(map[string]interface{}{"iss": "https://evil.example.com"})), err: ErrInvalidCredentials},
+		{name: "wrong audience", token: rs.sign(t, claims(map[string]interface{}{"aud": "other"})), err: ErrInvalidCredentials},
+		{name: "no subject", token: rs.sign(t, claims(map[string]interface{}{"sub": nil})), err: ErrInvalidCredentials},
+		{name: "signed with another key", token: forged.sign(t, claims(nil)), err: ErrInvalidCredentials},
+		{name: "unknown kid", token: (&testSigner{alg: "RS256", kid: "rsa-2", key: rsaKey}).sign(t, claims(nil)), err: ErrInvalidCredentials},
+		{name: "algorithm does not match key", token: (&testSigner{alg: "RS384", kid: "rsa-1", key: rsaKey}).sign(t, claims(nil)), err: ErrInvalidCredentials},
+		{name: "unsigned", token: unsigned(claims(nil)), err: ErrInvalidCredentials},
+		{name: "malformed", token: "not-a-token", err: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			principal, err := a.Authenticate(req)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Subject != "ci-pipeline" || principal.Method != MethodJWT || !equalScopes(principal.Scopes, tt.scopes) {
+				t.Errorf("got %+v", principal)
+			}
+		})
+	}
+}
+
+func unsigned(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "none"})
+	payload, _ := json.Marshal(claims)
+	return b64(header) + "." + b64(payload) + "."
+}
+
+func equalScopes(a, b []Scope) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestChain(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	signer := &testSigner{alg: "RS256", kid: "rsa-1", key: rsaKey}
+	jwt, err := NewJWTAuthenticator(JWTOptions{JWKSFile: writeJWKS(t, signer)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewAPIKeyAuthenticator([]Key{{Name: "ci", Hash: HashKey("xik_static"), Scopes: []Scope{ScopeRead}}}, nil)
+	chain := Chain{jwt, keys}
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if _, err := chain.Authenticate(req); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("got %v, want ErrNoCredentials", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer xik_static")
+	if p, err := chain.Authenticate(req); err != nil || p.Subject != "ci" {
+		t.Errorf("API key bearer token: got %+v, %v", p, err)
+	}
+
+	token := signer.sign(t, map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix(), "scope": "write"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	if p, err := chain.Authenticate(req); err != nil || p.Subject != "alice" || !p.HasScope(ScopeRead) {
+		t.Errorf("JWT: got %+v, %v", p, err)
+	}
+}
+
+func TestNewJWTAuthenticatorRejectsBadKeySets(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"empty.json":     `{"keys": []}`,
+		"symmetric.json": `{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`,
+		"invalid.json":   `{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`,
+		"garbage.json":   `not json`,
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := NewJWTAuthenticator(JWTOptions{JWKSFile: path}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := NewJWTAuthenticator(JWTOptions{JWKSFile: filepath.Join(dir, "missing.json")}); err == nil {
+		t.Error("missing file: expected an error")
+	}
+}
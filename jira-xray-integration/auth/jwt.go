@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// JWTOptions configures JWT validation
+type JWTOptions struct {
+	JWKSFile   string        // JSON Web Key Set with the public keys tokens are signed with
+	Issuer     string        // required iss claim, empty accepts any issuer
+	Audience   string        // required aud claim, empty accepts any audience
+	ScopeClaim string        // claim holding the scopes, a space separated string or a list; default "scope"
+	Leeway     time.Duration // allowed clock skew for exp and nbf
+}
+
+// JWTAuthenticator authenticates requests carrying a signed JWT as a bearer
+// token. RS256, RS384, RS512, ES256, ES384 and ES512 signatures are accepted.
+type JWTAuthenticator struct {
+	opts JWTOptions
+	keys []jwk
+	now  func() time.Time
+}
+
+type jwk struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// NewJWTAuthenticator loads the key set in opts.JWKSFile
+func NewJWTAuthenticator(opts JWTOptions) (*JWTAuthenticator, error) {
+	if opts.ScopeClaim == "" {
+		opts.ScopeClaim = "scope"
+	}
+	data, err := os.ReadFile(opts.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS file %s: %w", opts.JWKSFile, err)
+	}
+	return &JWTAuthenticator{opts: opts, keys: keys, now: time.Now}, nil
+}
+
+// Authenticate implements Authenticator. Bearer tokens that look like API keys
+// are left to the API key authenticator.
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token := bearerToken(r)
+	if token == "" || strings.HasPrefix(token, KeyPrefix) {
+		return nil, ErrNoCredentials
+	}
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if err := a.checkClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: subject, Method: MethodJWT, Scopes: a.scopes(claims), Claims: claims}, nil
+}
+
+// verify checks the signature of a compact JWT and returns its claims
+func (a *JWTAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+
+	key, err := a.key(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+	return claims, nil
+}
+
+// key picks the verification key by kid. A token without kid is accepted only
+// when the key set holds a single key.
+func (a *JWTAuthenticator) key(kid, alg string) (crypto.PublicKey, error) {
+	for _, k := range a.keys {
+		if (kid == "" && len(a.keys) == 1) || (kid != "" && k.kid == kid) {
+			if k.alg != "" && k.alg != alg {
+				return nil, fmt.Errorf("key %q is for %s, token is signed with %s", k.kid, k.alg, alg)
+			}
+			return k.key, nil
+		}
+	}
+	return nil, fmt.Errorf("no key in the key set matches kid %q", kid)
+}
+
+func (a *JWTAuthenticator) checkClaims(claims map[string]interface{}) error {
+	now := a.now()
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(exp, 0).Add(a.opts.Leeway)) {
+		return fmt.Errorf("token has expired")
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(a.opts.Leeway).Before(time.Unix(nbf, 0)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	if a.opts.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.opts.Issuer {
+			return fmt.Errorf("token issuer %q is not trusted", iss)
+		}
+	}
+	if a.opts.Audience != "" && !containsString(stringList(claims["aud"]), a.opts.Audience) {
+		return fmt.Errorf("token is not meant for audience %q", a.opts.Audience)
+	}
+	return nil
+}
+
+// scopes returns the known scopes in the scope claim. Identity providers add
+// scopes of their own such as openid, which are ignored.
+func (a *JWTAuthenticator) scopes(claims map[string]interface{}) []Scope {
+	var scopes []Scope
+	for _, name := range stringList(claims[a.opts.ScopeClaim]) {
+		if scope, err := ParseScope(name); err == nil {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("RSA key cannot verify %s", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return fmt.Errorf("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// parseJWKS reads the RSA and EC signing keys of a JSON Web Key Set
+func parseJWKS(data []byte) ([]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	var keys []jwk
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("key %d: invalid RSA modulus or exponent", i)
+			}
+			key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, fmt.Errorf("key %d: unsupported curve %q", i, k.Crv)
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				return nil, fmt.Errorf("key %d: invalid EC point", i)
+			}
+			ec := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !curve.IsOnCurve(ec.X, ec.Y) {
+				return nil, fmt.Errorf("key %d: point is not on curve %s", i, k.Crv)
+			}
+			key = ec
+		default:
+			// Symmetric and other key types are never used to accept tokens
+			continue
+		}
+		keys = append(keys, jwk{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RSA or EC signing keys found")
+	}
+	return keys, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func numericClaim(claims map[string]interface{}, name string) (int64, bool) {
+	value, ok := claims[name].(float64)
+	return int64(value), ok
+}
+
+// stringList reads a claim that is either a space separated string or a list of strings
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permission is an operation on a group of routes, written resource:action
+type Permission string
+
+// Permissions
+const (
+	PermInfoRead         Permission = "info:read"
+	PermTestCasesRead    Permission = "testcases:read"
+	PermTestCasesWrite   Permission = "testcases:write"
+	PermTestCasesImport  Permission = "testcases:import"
+	PermExecutionsRead   Permission = "executions:read"
+	PermExecutionsWrite  Permission = "executions:write"
+	PermResultsImport    Permission = "results:import"
+	PermRequirementsRead Permission = "requirements:read"
+	PermSyncRead         Permission = "sync:read"
+	PermSyncRun          Permission = "sync:run"
+	PermOutboxRead       Permission = "outbox:read"
+	PermOutboxRetry      Permission = "outbox:retry"
+	PermOutboxDelete     Permission = "outbox:delete"
+	PermAdminAPIKeys     Permission = "admin:apikeys"
+	PermAdminRoles       Permission = "admin:roles"
+)
+
+// permissionScopes is the scope a credential needs for each permission, so
+// a key limited to read cannot write whatever roles its owner has
+var permissionScopes = map[Permission]Scope{
+	PermInfoRead:         ScopeRead,
+	PermTestCasesRead:    ScopeRead,
+	PermTestCasesWrite:   ScopeWrite,
+	PermTestCasesImport:  ScopeImport,
+	PermExecutionsRead:   ScopeRead,
+	PermExecutionsWrite:  ScopeWrite,
+	PermResultsImport:    ScopeImport,
+	PermRequirementsRead: ScopeRead,
+	PermSyncRead:         ScopeRead,
+	PermSyncRun:          ScopeWrite,
+	PermOutboxRead:       ScopeRead,
+	PermOutboxRetry:      ScopeWrite,
+	PermOutboxDelete:     ScopeAdmin,
+	PermAdminAPIKeys:     ScopeAdmin,
+	PermAdminRoles:       ScopeAdmin,
+}
+
+// Scope returns the scope a credential needs to be granted the permission
+func (p Permission) Scope() Scope {
+	return permissionScopes[p]
+}
+
+// Global reports whether the permission applies to the whole server rather
+// than to a project. Global permissions are only granted by bindings for *.
+func (p Permission) Global() bool {
+	return strings.HasPrefix(string(p), "admin:")
+}
+
+// Role is a named set of permissions
+type Role string
+
+// Roles
+const (
+	RoleViewer Role = "viewer" // reads everything in a project
+	RoleTester Role = "tester" // runs tests: creates executions, records and imports results
+	RoleLead   Role = "lead"   // maintains tests: creates and imports test cases, runs syncs, retries queued writes
+	RoleAdmin  Role = "admin"  // every permission
+)
+
+// AllRoles lists the roles from least to most privileged
+var AllRoles = []Role{RoleViewer, RoleTester, RoleLead, RoleAdmin}
+
+var rolePermissions = func() map[Role][]Permission {
+	viewer := []Permission{PermInfoRead, PermTestCasesRead, PermExecutionsRead, PermRequirementsRead, PermSyncRead, PermOutboxRead}
+	tester := append(append([]Permission{}, viewer...), PermExecutionsWrite, PermResultsImport)
+	lead := append(append([]Permission{}, tester...), PermTestCasesWrite, PermTestCasesImport, PermSyncRun, PermOutboxRetry)
+	admin := append(append([]Permission{}, lead...), PermOutboxDelete, PermAdminAPIKeys, PermAdminRoles)
+	return map[Role][]Permission{RoleViewer: viewer, RoleTester: tester, RoleLead: lead, RoleAdmin: admin}
+}()
+
+// Permissions returns the permissions a role grants
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// Grants reports whether the role grants a permission
+func (r Role) Grants(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRole validates a role name
+func ParseRole(name string) (Role, error) {
+	for _, r := range AllRoles {
+		if string(r) == name {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("unknown role %q, use viewer, tester, lead or admin", name)
+}
+
+// AllProjects is the project of bindings that apply to every project
+const AllProjects = "*"
+
+// Binding grants a role on a project to the principals it matches: either the
+// principal named by Subject, or JWT callers whose Claim contains Value
+type Binding struct {
+	Role    Role
+	Project string // Jira project key, or * for every project
+	Subject string // method:subject, e.g. apikey:nightly-ci or jwt:alice@example.com
+	Claim   string // JWT claim such as groups, matched instead of Subject
+	Value   string // value the claim must hold
+}
+
+// Validate checks that a binding names a known role, a project and exactly one matcher
+func (b Binding) Validate() error {
+	if _, err := ParseRole(string(b.Role)); err != nil {
+		return err
+	}
+	if b.Project == "" {
+		return fmt.Errorf("project is required, use * for every project")
+	}
+	switch {
+	case b.Subject != "" && b.Claim != "":
+		return fmt.Errorf("set either subject or claim, not both")
+	case b.Subject != "":
+		method, name, ok := strings.Cut(b.Subject, ":")
+		if !ok || name == "" || (method != MethodAPIKey && method != MethodJWT) {
+			return fmt.Errorf("subject must be apikey:<key name> or jwt:<subject>")
+		}
+	case b.Claim != "":
+		if b.Value == "" {
+			return fmt.Errorf("value is required with claim")
+		}
+	default:
+		return fmt.Errorf("subject or claim is required")
+	}
+	return nil
+}
+
+// Matches reports whether the binding applies to a principal
+func (b Binding) Matches(p *Principal) bool {
+	if b.Subject != "" {
+		return b.Subject == p.Method+":"+p.Subject
+	}
+	return b.Claim != "" && containsString(stringList(p.Claims[b.Claim]), b.Value)
+}
+
+// AppliesTo reports whether the binding covers a project. Global permissions
+// are requested with an empty project and only match bindings for *.
+func (b Binding) AppliesTo(project string) bool {
+	return b.Project == AllProjects || (project != "" && strings.EqualFold(b.Project, project))
+}
+
+// Authorize reports whether bindings give a principal a permission on a
+// project, and the permission must also be within the scopes of its credential.
+// Credentials with the admin scope are administrators everywhere, so the first
+// bindings can be created with an admin key from configuration.
+func Authorize(p *Principal, bindings []Binding, permission Permission, project string) bool {
+	if !p.HasScope(permission.Scope()) {
+		return false
+	}
+	if p.HasScope(ScopeAdmin) {
+		return true
+	}
+	if permission.Global() {
+		project = ""
+	}
+	for _, b := range bindings {
+		if b.AppliesTo(project) && b.Role.Grants(permission) && b.Matches(p) {
+			return true
+		}
+	}
+	return false
+}
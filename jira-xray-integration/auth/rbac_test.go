@@ -0,0 +1,152 @@
+package auth
+
+import "testing"
+
+func TestRoles(t *testing.T) {
+	for i, role := range AllRoles {
+		if len(role.Permissions()) == 0 {
+			t.Errorf("%s grants nothing", role)
+		}
+		// Every role grants what the less privileged roles grant
+		for _, lower := range AllRoles[:i] {
+			for _, p := range lower.Permissions() {
+				if !role.Grants(p) {
+					t.Errorf("%s does not grant %s although %s does", role, p, lower)
+				}
+			}
+		}
+	}
+	for p := range permissionScopes {
+		if !RoleAdmin.Grants(p) {
+			t.Errorf("admin does not grant %s", p)
+		}
+	}
+	if RoleTester.Grants(PermTestCasesWrite) || !RoleLead.Grants(PermTestCasesWrite) {
+		t.Error("only leads may write test cases")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	bindings := []Binding{
+		{Role: RoleTester, Project: "PAY", Subject: "apikey:pay-ci"},
+		{Role: RoleLead, Project: "PAY", Claim: "groups", Value: "payments-leads"},
+		{Role: RoleViewer, Project: AllProjects, Claim: "groups", Value: "engineering"},
+		{Role: RoleAdmin, Project: "OPS", Subject: "jwt:ops-admin"},
+	}
+	allScopes := []Scope{ScopeWrite, ScopeImport}
+
+	tests := []struct {
+		name       string
+		principal  *Principal
+		permission Permission
+		project    string
+		want       bool
+	}{
+		{
+			name:       "tester records results in own project",
+			principal:  &Principal{Method: MethodAPIKey, Subject: "pay-ci", Scopes: allScopes},
+			permission: PermExecutionsWrite, project: "PAY", want: true,
+		},
+		{
+			name:       "project keys match case insensitively",
+			principal:  &Principal{Method: MethodAPIKey, Subject: "pay-ci", Scopes: allScopes},
+			permission: PermExecutionsWrite, project: "pay", want: true,
+		},
+		{
+			name:       "tester cannot write test cases",
+			principal:  &Principal{Method: MethodAPIKey, Subject: "pay-ci", Scopes: allScopes},
+			permission: PermTestCasesWrite, project: "PAY", want: false,
+		},
+		{
+			name:       "tester cannot write to another project",
+			principal:  &Principal{Method: MethodAPIKey, Subject: "pay-ci", Scopes: allScopes},
+			permission: PermExecutionsWrite, project: "SHOP", want: false,
+		},
+		{
+			name:       "subject needs the same method",
+			principal:  &Principal{Method: MethodJWT, Subject: "pay-ci", Scopes: allScopes},
+			permission: PermExecutionsWrite, project: "PAY", want: false,
+		},
+		{
+			name:       "role from a claim",
+			principal:  &Principal{Method: MethodJWT, Subject: "bob", Scopes: allScopes, Claims: map[string]interface{}{"groups": []interface{}{"engineering", "payments-leads"}}},
+			permission: PermTestCasesWrite, project: "PAY", want: true,
+		},
+		{
+			name:       "role for every project",
+			principal:  &Principal{Method: MethodJWT, Subject: "carol", Scopes: allScopes, Claims: map[string]interface{}{"groups": "engineering"}},
+			permission: PermTestCasesRead, project: "SHOP", want: true,
+		},
+		{
+			name:       "role is capped by the credential scopes",
+			principal:  &Principal{Method: MethodJWT, Subject: "bob", Scopes: []Scope{ScopeRead}, Claims: map[string]interface{}{"groups": "payments-leads"}},
+			permission: PermTestCasesWrite, project: "PAY", want: false,
+		},
+		{
+			name:       "project admin cannot manage global settings",
+			principal:  &Principal{Method: MethodJWT, Subject: "ops-admin", Scopes: []Scope{ScopeWrite}},
+			permission: PermOutboxRetry, project: "OPS", want: true,
+		},
+		{
+			name:       "admin scope is needed for admin permissions",
+			principal:  &Principal{Method: MethodJWT, Subject: "ops-admin", Scopes: []Scope{ScopeWrite}},
+			permission: PermAdminRoles, project: "OPS", want: false,
+		},
+		{
+			name:       "admin credential bypasses bindings",
+			principal:  &Principal{Method: MethodAPIKey, Subject: "bootstrap", Scopes: []Scope{ScopeAdmin}},
+			permission: PermAdminRoles, want: true,
+		},
+		{
+			name:       "no bindings",
+			principal:  &Principal{Method: MethodAPIKey, Subject: "stranger", Scopes: allScopes},
+			permission: PermTestCasesRead, project: "PAY", want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Authorize(tt.principal, bindings, tt.permission, tt.project); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobalPermissionsNeedBindingForAllProjects(t *testing.T) {
+	// A principal whose credential has the admin scope is an administrator
+	// anyway, so check the binding logic directly
+	projectAdmin := Binding{Role: RoleAdmin, Project: "OPS", Subject: "jwt:ops-admin"}
+	if projectAdmin.AppliesTo("") {
+		t.Error("a project binding applies to global permissions")
+	}
+	if !(Binding{Role: RoleAdmin, Project: AllProjects}).AppliesTo("") {
+		t.Error("a binding for * does not apply to global permissions")
+	}
+}
+
+func TestBindingValidate(t *testing.T) {
+	valid := []Binding{
+		{Role: RoleViewer, Project: AllProjects, Subject: "apikey:dashboard"},
+		{Role: RoleLead, Project: "PAY", Claim: "groups", Value: "payments-leads"},
+	}
+	for _, b := range valid {
+		if err := b.Validate(); err != nil {
+			t.Errorf("%+v: %v", b, err)
+		}
+	}
+
+	invalid := []Binding{
+		{Role: "owner", Project: "PAY", Subject: "apikey:ci"},
+		{Role: RoleViewer, Subject: "apikey:ci"},
+		{Role: RoleViewer, Project: "PAY"},
+		{Role: RoleViewer, Project: "PAY", Subject: "ci"},
+		{Role: RoleViewer, Project: "PAY", Subject: "ldap:ci"},
+		{Role: RoleViewer, Project: "PAY", Claim: "groups"},
+		{Role: RoleViewer, Project: "PAY", Subject: "apikey:ci", Claim: "groups", Value: "x"},
+	}
+	for _, b := range invalid {
+		if err := b.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", b)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey holds the authenticated caller in the gin context
+const principalKey = "principal"
+
+// apiKeyTouchInterval limits how often the last use of a stored API key is saved
+const apiKeyTouchInterval = time.Minute
+
+// newAuthenticator creates the authenticator for the configured methods, or
+// nil if authentication is disabled
+func newAuthenticator(c *Config) (auth.Authenticator, error) {
+	var chain auth.Chain
+	for _, method := range c.AuthMethods {
+		switch method {
+		case auth.MethodAPIKey:
+			chain = append(chain, auth.NewAPIKeyAuthenticator(c.APIKeys, lookupStoredAPIKey))
+		case auth.MethodJWT:
+			jwt, err := auth.NewJWTAuthenticator(c.JWT)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, jwt)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// lookupStoredAPIKey finds an API key created through the admin API
+func lookupStoredAPIKey(hash string) (*auth.Key, error) {
+	stored, err := resultStore.APIKeyByHash(hash)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if now := time.Now(); now.Sub(stored.LastUsedAt) > apiKeyTouchInterval {
+		if err := resultStore.TouchAPIKey(stored.ID, now); err != nil {
+			log.Printf("Error recording use of API key %d: %v", stored.ID, err)
+		}
+	}
+
+	key := &auth.Key{Name: stored.Name, Hash: stored.Hash}
+	for _, name := range stored.Scopes {
+		if scope, err := auth.ParseScope(name); err == nil {
+			key.Scopes = append(key.Scopes, scope)
+		}
+	}
+	return key, nil
+}
+
+// requirePermission authenticates the caller and rejects the request unless
+// the caller may perform permission. The credential must carry the scope the
+// permission needs and, with RBAC enabled, a role binding must grant it on the
+// project of the request. An empty permission only requires a valid caller.
+// Every request is allowed while authentication is disabled.
+func requirePermission(permission auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authenticator := currentAuthenticator()
+		if authenticator == nil {
+			c.Next()
+			return
+		}
+
+		principal, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			rejectUnauthenticated(c, err)
+			return
+		}
+		c.Set(principalKey, principal)
+		if permission == "" {
+			c.Next()
+			return
+		}
+
+		if !principal.HasScope(permission.Scope()) {
+			denyPermission(c, principal, permission, "", "Insufficient scope",
+				fmt.Sprintf("%s needs a credential with the %s scope", permission, permission.Scope()))
+			return
+		}
+		if !authorizeRequest(c, permission, requestProject(c)) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// requireScope authenticates the caller and rejects the request unless the
+// credential carries the scope permission needs. It is for routes that only
+// know their project once they load the record they act on, such as an
+// outbox entry; those call authorizeRequest with the project themselves.
+func requireScope(permission auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authenticator := currentAuthenticator()
+		if authenticator == nil {
+			c.Next()
+			return
+		}
+
+		principal, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			rejectUnauthenticated(c, err)
+			return
+		}
+		c.Set(principalKey, principal)
+		if !principal.HasScope(permission.Scope()) {
+			denyPermission(c, principal, permission, "", "Insufficient scope",
+				fmt.Sprintf("%s needs a credential with the %s scope", permission, permission.Scope()))
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizeRequest rejects the request unless the authenticated caller may
+// perform permission on project, and reports whether it may. Every request
+// is authorized while authentication is disabled.
+func authorizeRequest(c *gin.Context, permission auth.Permission, project string) bool {
+	principal := currentPrincipal(c)
+	if principal == nil {
+		return true
+	}
+	allowed, err := authorizeProject(principal, permission, project)
+	if err != nil {
+		log.Printf("Error reading role bindings: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to authorize request",
+			"details": err.Error(),
+		})
+		return false
+	}
+	if !allowed {
+		where := "project " + project
+		if permission.Global() {
+			project, where = auth.AllProjects, "all projects"
+		}
+		denyPermission(c, principal, permission, project, "Insufficient permission",
+			fmt.Sprintf("%s:%s has no role granting %s on %s", principal.Method, principal.Subject, permission, where))
+		return false
+	}
+	return true
+}
+
+// authorizeProject reports whether role bindings give a principal permission
+// on a project. Without RBAC the scope of the credential decides alone.
+func authorizeProject(principal *auth.Principal, permission auth.Permission, project string) (bool, error) {
+	if !currentConfig().RBACEnabled {
+		return principal.HasScope(permission.Scope()), nil
+	}
+	bindings, err := roleBindings()
+	if err != nil {
+		return false, err
+	}
+	return auth.Authorize(principal, bindings, permission, project), nil
+}
+
+func denyPermission(c *gin.Context, principal *auth.Principal, permission auth.Permission, project, message, details string) {
+	log.Printf("Denied %s %s to %s:%s: %s", c.Request.Method, c.FullPath(), principal.Method, principal.Subject, details)
+	body := gin.H{
+		"error":      message,
+		"details":    details,
+		"permission": permission,
+	}
+	if project != "" {
+		body["project"] = project
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, body)
+}
+
+func rejectUnauthenticated(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNoCredentials):
+		c.Header("WWW-Authenticate", `Bearer realm="jira-xray-integration"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error":   "Authentication required",
+			"details": "send an API key in the " + auth.APIKeyHeader + " header or a bearer token",
+		})
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		log.Printf("Rejected %s %s: %v", c.Request.Method, c.FullPath(), err)
+		c.Header("WWW-Authenticate", `Bearer realm="jira-xray-integration", error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error":   "Invalid credentials",
+			"details": err.Error(),
+		})
+	default:
+		log.Printf("Error authenticating request: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to authenticate request",
+			"details": err.Error(),
+		})
+	}
+}
+
+// currentPrincipal returns the authenticated caller, or nil if authentication is disabled
+func currentPrincipal(c *gin.Context) *auth.Principal {
+	if value, ok := c.Get(principalKey); ok {
+		return value.(*auth.Principal)
+	}
+	return nil
+}
+
+// Get the authenticated caller
+func getCurrentPrincipal(c *gin.Context) {
+	log.Println("Handling GET /api/auth/me request")
+
+	principal := currentPrincipal(c)
+	rbacEnabled := currentConfig().RBACEnabled
+	response := gin.H{
+		"authEnabled": currentAuthenticator() != nil,
+		"rbacEnabled": rbacEnabled,
+		"principal":   principal,
+		"message":     "Caller retrieved successfully",
+	}
+	if principal != nil && rbacEnabled {
+		bindings, err := resultStore.ListRoleBindings()
+		if err != nil {
+			log.Printf("Error reading role bindings: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to read role bindings",
+				"details": err.Error(),
+			})
+			return
+		}
+		roles := []store.RoleBinding{}
+		for _, b := range bindings {
+			if toAuthBinding(b).Matches(principal) {
+				roles = append(roles, b)
+			}
+		}
+		response["roles"] = roles
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// createAPIKeyRequest is the body of POST /api/admin/apikeys
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// List API keys
+func getAPIKeys(c *gin.Context) {
+	log.Println("Handling GET /api/admin/apikeys request")
+
+	keys, err := resultStore.ListAPIKeys()
+	if err != nil {
+		log.Printf("Error listing API keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list API keys",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"apiKeys": keys,
+		"count":   len(keys),
+		"message": "API keys retrieved successfully",
+	})
+}
+
+// Create an API key
+func createAPIKey(c *gin.Context) {
+	log.Println("Handling POST /api/admin/apikeys request")
+
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	// A key without scopes could call nothing, so it is rather a mistake
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid scope",
+			"details": "at least one scope is required, use read, write, import or admin",
+		})
+		return
+	}
+	for _, name := range req.Scopes {
+		if _, err := auth.ParseScope(name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid scope",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	secret, err := auth.GenerateKey()
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	key, err := resultStore.CreateAPIKey(store.APIKey{
+		Name:   req.Name,
+		Prefix: secret[:len(auth.KeyPrefix)+6],
+		Hash:   auth.HashKey(secret),
+		Scopes: req.Scopes,
+	})
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create API key",
+			"details": err.Error(),
+		})
+		return
+	}
+	if principal := currentPrincipal(c); principal != nil {
+		log.Printf("API key %d (%s) created by %q", key.ID, key.Name, principal.Subject)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"apiKey":  key,
+		"key":     secret,
+		"message": "API key created successfully, store the key now as it cannot be shown again",
+	})
+}
+
+// Revoke an API key
+func deleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "API key id must be a number",
+		})
+		return
+	}
+	log.Printf("Handling DELETE /api/admin/apikeys/%d request", id)
+
+	if err := resultStore.DeleteAPIKey(id); err != nil {
+		log.Printf("Error deleting API key: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to delete API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key deleted successfully",
+	})
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/auth"
+)
+
+// Static API keys used by the auth tests
+const (
+	readerKey = "xik_reader"
+	writerKey = "xik_writer"
+	ciKey     = "xik_ci"
+	adminKey  = "xik_admin"
+)
+
+// enableAuth requires API keys, with one static key per test role
+func enableAuth(t *testing.T, env *testEnv) {
+	t.Helper()
+	currentConfig().AuthMethods = []string{auth.MethodAPIKey}
+	currentConfig().APIKeys = []auth.Key{
+		{Name: "reader", Hash: auth.HashKey(readerKey), Scopes: []auth.Scope{auth.ScopeRead}},
+		{Name: "writer", Hash: auth.HashKey(writerKey), Scopes: []auth.Scope{auth.ScopeWrite}},
+		{Name: "ci", Hash: auth.HashKey(ciKey), Scopes: []auth.Scope{auth.ScopeImport}},
+		{Name: "admin", Hash: auth.HashKey(adminKey), Scopes: []auth.Scope{auth.ScopeAdmin}},
+	}
+	env.reload(t)
+}
+
+func TestAuthentication(t *testing.T) {
+	expectError := func(message string) func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+		return func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+			if got := decodeBody(t, rec)["error"]; got != message {
+				t.Errorf("got error %q, want %q", got, message)
+			}
+		}
+	}
+
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "no credentials",
+			setup:  enableAuth,
+			method: http.MethodGet, path: "/api/testcases",
+			status: http.StatusUnauthorized,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if rec.Header().Get("WWW-Authenticate") == "" {
+					t.Error("WWW-Authenticate header is missing")
+				}
+				expectError("Authentication required")(t, env, rec)
+			},
+		},
+		{
+			name:   "unknown key",
+			setup:  enableAuth,
+			method: http.MethodGet, path: "/api/testcases",
+			headers: []string{auth.APIKeyHeader, "xik_unknown"},
+			status:  http.StatusUnauthorized,
+			check:   expectError("Invalid credentials"),
+		},
+		{
+			name:   "health stays public",
+			setup:  enableAuth,
+			method: http.MethodGet, path: "/api/health",
+			status: http.StatusOK,
+		},
+		{
+			name:   "read key can read",
+			setup:  enableAuth,
+			method: http.MethodGet, path: "/api/testcases",
+			headers: []string{auth.APIKeyHeader, readerKey},
+			status:  http.StatusOK,
+		},
+		{
+			name:   "read key cannot write",
+			setup:  enableAuth,
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Login works"}`,
+			headers: []string{auth.APIKeyHeader, readerKey},
+			status:  http.StatusForbidden,
+			check:   expectError("Insufficient scope"),
+		},
+		{
+			name:   "write key as bearer token can write",
+			setup:  enableAuth,
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Login works"}`,
+			headers: []string{"Authorization", "Bearer " + writerKey},
+			status:  http.StatusCreated,
+		},
+		{
+			name:   "write key cannot import",
+			setup:  enableAuth,
+			method: http.MethodPost, path: "/api/import/gotest?summary=CI",
+			body:    goTestEvents,
+			headers: []string{auth.APIKeyHeader, writerKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:   "import key can import",
+			setup:  enableAuth,
+			method: http.MethodPost, path: "/api/import/gotest?summary=CI",
+			body:    goTestEvents,
+			headers: []string{auth.APIKeyHeader, ciKey},
+			status:  http.StatusCreated,
+		},
+		{
+			name:   "import key cannot read",
+			setup:  enableAuth,
+			method: http.MethodGet, path: "/api/testexecutions",
+			headers: []string{auth.APIKeyHeader, ciKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:   "write key cannot manage API keys",
+			setup:  enableAuth,
+			method: http.MethodGet, path: "/api/admin/apikeys",
+			headers: []string{auth.APIKeyHeader, writerKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:   "caller",
+			setup:  enableAuth,
+			method: http.MethodGet, path: "/api/auth/me",
+			headers: []string{auth.APIKeyHeader, ciKey},
+			status:  http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				principal, _ := decodeBody(t, rec)["principal"].(map[string]interface{})
+				if principal["subject"] != "ci" || principal["method"] != auth.MethodAPIKey {
+					t.Errorf("got principal %v", principal)
+				}
+			},
+		},
+		{
+			name:   "caller with authentication disabled",
+			method: http.MethodGet, path: "/api/auth/me",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := decodeBody(t, rec)
+				if body["authEnabled"] != false || body["principal"] != nil {
+					t.Errorf("got %v", body)
+				}
+			},
+		},
+		{
+			name:   "create API key with unknown scope",
+			setup:  enableAuth,
+			method: http.MethodPost, path: "/api/admin/apikeys",
+			body:    `{"name":"nightly","scopes":["delete"]}`,
+			headers: []string{auth.APIKeyHeader, adminKey},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:   "create API key without scopes",
+			setup:  enableAuth,
+			method: http.MethodPost, path: "/api/admin/apikeys",
+			body:    `{"name":"nightly","scopes":[]}`,
+			headers: []string{auth.APIKeyHeader, adminKey},
+			status:  http.StatusBadRequest,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if details, _ := decodeBody(t, rec)["details"].(string); !strings.Contains(details, "at least one scope") {
+					t.Errorf("got %s", rec.Body.String())
+				}
+			},
+		},
+		{
+			name:   "create API key with an empty scope",
+			setup:  enableAuth,
+			method: http.MethodPost, path: "/api/admin/apikeys",
+			body:    `{"name":"nightly","scopes":["read",""]}`,
+			headers: []string{auth.APIKeyHeader, adminKey},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:   "delete unknown API key",
+			setup:  enableAuth,
+			method: http.MethodDelete, path: "/api/admin/apikeys/42",
+			headers: []string{auth.APIKeyHeader, adminKey},
+			status:  http.StatusNotFound,
+		},
+	})
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+	enableAuth(t, env)
+
+	created := env.mustDo(t, http.StatusCreated, http.MethodPost, "/api/admin/apikeys",
+		`{"name":"nightly","scopes":["write"]}`, auth.APIKeyHeader, adminKey)
+	key, _ := created["key"].(string)
+	if !strings.HasPrefix(key, auth.KeyPrefix) {
+		t.Fatalf("got key %q", key)
+	}
+	apiKey, _ := created["apiKey"].(map[string]interface{})
+	if _, ok := apiKey["hash"]; ok || !strings.HasPrefix(key, apiKey["prefix"].(string)) {
+		t.Errorf("got API key %v", apiKey)
+	}
+
+	// The new key works and its use is recorded
+	env.mustDo(t, http.StatusCreated, http.MethodPost, "/api/testcases", `{"summary":"Login works"}`, auth.APIKeyHeader, key)
+	listed := env.mustDo(t, http.StatusOK, http.MethodGet, "/api/admin/apikeys", "", auth.APIKeyHeader, adminKey)
+	keys, _ := listed["apiKeys"].([]interface{})
+	if len(keys) != 1 || strings.HasPrefix(fmt.Sprint(keys[0].(map[string]interface{})["lastUsedAt"]), "0001") {
+		t.Fatalf("got keys %v", keys)
+	}
+	if strings.Contains(env.do(http.MethodGet, "/api/admin/apikeys", "", auth.APIKeyHeader, adminKey).Body.String(), auth.HashKey(key)) {
+		t.Error("the key hash is exposed")
+	}
+
+	// A revoked key is rejected
+	env.mustDo(t, http.StatusOK, http.MethodDelete, fmt.Sprintf("/api/admin/apikeys/%v", apiKey["id"]), "", auth.APIKeyHeader, adminKey)
+	env.mustDo(t, http.StatusUnauthorized, http.MethodGet, "/api/testcases", "", auth.APIKeyHeader, key)
+}
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	env := newTestEnv(t)
+	currentConfig().CORSAllowedOrigins = []string{"https://dashboard.example.com"}
+
+	rec := env.do(http.MethodOptions, "/api/testcases", "", "Origin", "https://dashboard.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://dashboard.example.com" || rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("allowed origin: got Access-Control-Allow-Origin %q, Vary %q", got, rec.Header().Get("Vary"))
+	}
+
+	rec = env.do(http.MethodOptions, "/api/testcases", "", "Origin", "https://evil.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("other origin: got Access-Control-Allow-Origin %q", got)
+	}
+}
+
+func TestLoadAuthConfig(t *testing.T) {
+	for _, key := range []string{"API_KEYS", "JWT_ISSUER", "CORS_ALLOWED_ORIGINS"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("AUTH_METHODS", "jwt")
+	t.Setenv("JWT_JWKS_FILE", "jwks.json")
+	t.Setenv("JWT_AUDIENCE", "")
+
+	var config Config
+	if err := loadAuthConfig(&config); err == nil || !strings.Contains(err.Error(), "JWT_AUDIENCE is required") {
+		t.Errorf("got error %v, want JWT_AUDIENCE to be required", err)
+	}
+
+	t.Setenv("JWT_AUDIENCE", "jira-xray-integration")
+	config = Config{}
+	if err := loadAuthConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.CORSAllowedOrigins) != 0 {
+		t.Errorf("got CORS origins %v, want none by default", config.CORSAllowedOrigins)
+	}
+}
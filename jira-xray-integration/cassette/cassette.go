@@ -0,0 +1,344 @@
+// Package cassette records HTTP interactions with Jira to a file and replays
+// them later, so behavior seen against a customer's Jira can be reproduced
+// offline. A Transport wraps the http.RoundTripper of jira.Client.
+//
+// Credentials are never written to a cassette: the Authorization, Cookie and
+// Set-Cookie headers are redacted when recording.
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Mode selects what a Transport does with requests
+type Mode string
+
+// Modes
+const (
+	ModeOff    Mode = "off"    // pass requests through untouched
+	ModeRecord Mode = "record" // pass requests through and save each interaction
+	ModeReplay Mode = "replay" // answer requests from the cassette without network access
+)
+
+// ErrNoInteraction is returned in replay mode for requests the cassette has no answer for
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+const redacted = "REDACTED"
+
+// redactedHeaders are replaced by REDACTED before an interaction is saved
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// Cassette is the file format: the interactions in the order they happened
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one request and the response Jira sent for it
+type Interaction struct {
+	Request    Request   `json:"request"`
+	Response   Response  `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Request is a recorded request
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    Body        `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is a request or response body. Text is stored as is so cassettes stay
+// readable and editable; anything else is stored base64 encoded.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("body must be a string or {\"base64\": ...}: %w", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return fmt.Errorf("invalid base64 body: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// Transport is an http.RoundTripper that records or replays interactions
+type Transport struct {
+	mode Mode
+	path string
+	next http.RoundTripper
+	*recording
+}
+
+// recording is the cassette of a Transport, shared with the transports WithNext returns
+type recording struct {
+	mu       sync.Mutex
+	cassette Cassette
+	played   map[string]int // replayed interactions per request key
+
+	// When recording, interactions are appended to file in place: end is the
+	// offset of the closing brackets and recorded counts the interactions
+	// written, so each recording costs the size of one interaction
+	file     *os.File
+	end      int64
+	recorded int
+}
+
+// Cassette file framing written around appended interactions
+const (
+	cassetteHeader = "{\n  \"interactions\": ["
+	cassetteFooter = "\n  ]\n}\n"
+)
+
+// NewTransport creates a Transport for mode. Recording starts a new cassette
+// at path, replacing any existing file; replaying loads it. next sends the
+// requests that are passed through and defaults to http.DefaultTransport.
+func NewTransport(mode Mode, path string, next http.RoundTripper) (*Transport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &Transport{mode: mode, path: path, next: next, recording: &recording{played: make(map[string]int)}}
+
+	switch mode {
+	case ModeOff:
+	case ModeRecord:
+		if err := t.create(); err != nil {
+			return nil, err
+		}
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid cassette mode %q, use off, record or replay", mode)
+	}
+	return t, nil
+}
+
+// WithNext returns a Transport that sends passed through requests to next
+// and keeps recording to, or replaying from, the same cassette as t
+func (t *Transport) WithNext(next http.RoundTripper) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{mode: t.mode, path: t.path, next: next, recording: t.recording}
+}
+
+// Mode returns the transport's mode
+func (t *Transport) Mode() Mode {
+	return t.mode
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch t.mode {
+	case ModeRecord:
+		return t.record(req)
+	case ModeReplay:
+		return t.replay(req)
+	default:
+		return t.next.RoundTrip(req)
+	}
+}
+
+func (t *Transport) record(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: redact(req.Header),
+			Body:    reqBody,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Headers:    redact(resp.Header),
+			Body:       respBody,
+		},
+		RecordedAt: time.Now().UTC(),
+	}
+
+	// Jira has applied the request by now, so a cassette that cannot be
+	// written must not turn its response into an error
+	if err := t.append(interaction); err != nil {
+		log.Printf("Error recording %s %s to cassette: %v", req.Method, req.URL.Path, err)
+	}
+	return resp, nil
+}
+
+// replay answers a request with the next unplayed interaction that has the
+// same method, path, query and body. Once every match has been played the
+// last one is repeated, so polling keeps working.
+func (t *Transport) replay(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	key := requestKey(req.Method, req.URL, body)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var matches []*Interaction
+	for i := range t.cassette.Interactions {
+		interaction := &t.cassette.Interactions[i]
+		recorded, err := url.Parse(interaction.Request.URL)
+		if err != nil {
+			continue
+		}
+		if requestKey(interaction.Request.Method, recorded, interaction.Request.Body) == key {
+			matches = append(matches, interaction)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL.RequestURI())
+	}
+
+	n := t.played[key]
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	t.played[key]++
+	recorded := matches[n].Response
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Headers.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// create starts an empty cassette at path, replacing any existing file
+func (t *Transport) create() error {
+	if dir := filepath.Dir(t.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(t.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create cassette: %w", err)
+	}
+	if _, err := file.WriteString(cassetteHeader + cassetteFooter); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	t.file = file
+	t.end = int64(len(cassetteHeader))
+	return nil
+}
+
+// append writes an interaction over the closing brackets of the cassette and
+// puts them back after it, so the file stays a valid cassette between requests
+func (t *Transport) append(interaction Interaction) error {
+	data, err := json.MarshalIndent(interaction, "    ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode interaction: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	separator := "\n    "
+	if t.recorded > 0 {
+		separator = "," + separator
+	}
+	entry := append([]byte(separator), data...)
+	if _, err := t.file.WriteAt(append(entry, cassetteFooter...), t.end); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	t.end += int64(len(entry))
+	t.recorded++
+	return nil
+}
+
+// requestKey identifies a request independently of the Jira host, so a
+// cassette recorded against one instance replays with any base URL
+func requestKey(method string, u *url.URL, body []byte) string {
+	return method + " " + u.Path + "?" + u.Query().Encode() + "\n" + string(body)
+}
+
+// readBody reads a body and puts back an unread copy of it
+func readBody(body *io.ReadCloser) (Body, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func redact(headers http.Header) http.Header {
+	headers = headers.Clone()
+	for _, name := range redactedHeaders {
+		if headers.Get(name) != "" {
+			headers.Set(name, redacted)
+		}
+	}
+	return headers
+}
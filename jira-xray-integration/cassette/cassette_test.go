@@ -0,0 +1,194 @@
+package cassette
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/jiratest"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// recordSession records a few calls against a fake Jira and returns the cassette path
+func recordSession(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cassettes", "session.json")
+
+	srv := jiratest.NewServer("TEST")
+	srv.Backend.SeedDemoData()
+	defer srv.Close()
+
+	transport, err := NewTransport(ModeRecord, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := srv.JiraClient()
+	client.HTTPClient.Transport = transport
+
+	if _, err := client.ListTestCases(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTestCase("TEST-1"); err != nil {
+		t.Fatal(err)
+	}
+	tc, err := client.GetTestCase("TEST-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.Summary = "Password reset by email"
+	if _, err := client.UpdateTestCase(tc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTestCase("TEST-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTestCase("TEST-99"); !jira.IsNotFound(err) {
+		t.Fatalf("got %v, want not found", err)
+	}
+	return path
+}
+
+func TestRecordRedactsCredentials(t *testing.T) {
+	path := recordSession(t)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), jiratest.APIToken) || strings.Contains(string(data), "Basic ") {
+		t.Error("cassette contains credentials")
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 6 {
+		t.Fatalf("got %d interactions, want 6", len(cassette.Interactions))
+	}
+	if got := cassette.Interactions[0].Request.Headers.Get("Authorization"); got != "REDACTED" {
+		t.Errorf("got Authorization %q", got)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	path := recordSession(t)
+
+	// Replay against a host that does not exist and without credentials
+	transport, err := NewTransport(ModeReplay, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := jira.NewClient("http://jira.invalid", "", "", "TEST")
+	client.HTTPClient.Transport = transport
+
+	testCases, err := client.ListTestCases()
+	if err != nil || len(testCases) != 3 {
+		t.Fatalf("got %d test cases (%v), want 3", len(testCases), err)
+	}
+
+	// Identical requests are answered in recorded order, then the last answer repeats
+	for _, want := range []string{"Password reset functionality", "Password reset by email", "Password reset by email"} {
+		tc, err := client.GetTestCase("TEST-2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.Summary != want {
+			t.Errorf("got %q, want %q", tc.Summary, want)
+		}
+	}
+
+	if _, err := client.GetTestCase("TEST-99"); !jira.IsNotFound(err) {
+		t.Errorf("got %v, want the recorded 404", err)
+	}
+
+	_, err = client.GetTestCase("TEST-3")
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("got %v, want ErrNoInteraction", err)
+	}
+}
+
+func TestReplayRequiresCassette(t *testing.T) {
+	if _, err := NewTransport(ModeReplay, filepath.Join(t.TempDir(), "missing.json"), nil); err == nil {
+		t.Error("replay started without a cassette")
+	}
+	if _, err := NewTransport("rewind", "cassette.json", nil); err == nil {
+		t.Error("accepted an unknown mode")
+	}
+}
+
+func TestBodyEncoding(t *testing.T) {
+	for _, body := range []Body{Body(`{"key":"TEST-1"}`), Body{0xff, 0x00, 0x10}} {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Body
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if string(decoded) != string(body) {
+			t.Errorf("%s decoded to %q", data, decoded)
+		}
+	}
+}
+
+func TestOffPassesThrough(t *testing.T) {
+	called := false
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		called = true
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+	})
+	transport, err := NewTransport(ModeOff, "", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://jira.invalid/rest/api/3/field", nil)
+	if _, err := transport.RoundTrip(req); err != nil || !called {
+		t.Errorf("request was not passed through: %v", err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRecordKeepsResponseWhenCassetteFails(t *testing.T) {
+	srv := jiratest.NewServer("TEST")
+	srv.Backend.SeedDemoData()
+	defer srv.Close()
+
+	transport, err := NewTransport(ModeRecord, filepath.Join(t.TempDir(), "session.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := srv.JiraClient()
+	client.HTTPClient.Transport = transport
+
+	// Jira applies the update even though it cannot be recorded
+	transport.file.Close()
+	tc, err := client.GetTestCase("TEST-2")
+	if err != nil {
+		t.Fatalf("got %v, want the Jira response", err)
+	}
+	tc.Summary = "Password reset by email"
+	if _, err := client.UpdateTestCase(tc); err != nil {
+		t.Fatalf("got %v, want the Jira response", err)
+	}
+	if got, _ := srv.Backend.GetTestCase("TEST-2"); got.Summary != "Password reset by email" {
+		t.Errorf("got summary %q", got.Summary)
+	}
+}
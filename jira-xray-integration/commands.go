@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// usage describes the server's flags and subcommands
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [-config file] [command]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Without a command, the API server is started.")
+	fmt.Fprintln(out, "\nCommands:")
+	fmt.Fprintln(out, "  config validate   check the configuration file and environment, then exit")
+	fmt.Fprintln(out, "  keyring list      list the names of the secrets in KEYRING_FILE")
+	fmt.Fprintln(out, "  keyring set NAME  store the secret NAME, read from stdin, in KEYRING_FILE")
+	fmt.Fprintln(out, "  keyring delete NAME")
+	fmt.Fprintln(out, "                    remove the secret NAME from KEYRING_FILE")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// runCommand runs a subcommand instead of the server and returns its exit code
+func runCommand(configPath string, args []string) int {
+	if len(args) == 2 && args[0] == "config" && args[1] == "validate" {
+		return validateConfigCommand(configPath, os.Stdout)
+	}
+	if len(args) > 1 && args[0] == "keyring" {
+		return keyringCommand(configPath, args[1:], os.Stdin, os.Stdout)
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", strings.Join(args, " "))
+	flag.Usage()
+	return 2
+}
+
+// validateConfigCommand loads the configuration the way the server does at
+// startup and reports every problem found, without connecting to Jira
+func validateConfigCommand(configPath string, out io.Writer) int {
+	c, err := LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(out, "❌ Invalid configuration: %v\n", err)
+		return 1
+	}
+
+	source := "the environment"
+	if c.ConfigFile != "" {
+		source = c.ConfigFile + " and the environment"
+	}
+	fmt.Fprintf(out, "✅ Configuration from %s is valid\n", source)
+	fmt.Fprintf(out, "   Backend: %s\n", c.Backend)
+	for _, t := range c.AllTenants() {
+		fmt.Fprintf(out, "   Jira tenant %s: projects %s\n", t.Name, strings.Join(projectKeysOf(t), ", "))
+	}
+	for _, line := range c.secretSourceLines() {
+		fmt.Fprintf(out, "   Secret %s\n", line)
+	}
+	return 0
+}
@@ -1,72 +1,915 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/cassette"
+	"jira-xray-integration/jira"
+	"jira-xray-integration/secrets"
+	"jira-xray-integration/tracing"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	JiraBaseURL    string
-	JiraUsername   string
-	JiraAPIToken   string
-	JiraProjectKey string
-	Port           string
+	ConfigFile      string // YAML or TOML file the settings were read from, if any
+	KeyringFile     string // encrypted file of secrets, if any
+	Backend         string // jira or memory
+	JiraTenant      string // name of the Jira connection configured by the JIRA_* variables
+	JiraBaseURL     string
+	JiraUsername    string
+	JiraAPIToken    string
+	JiraAccessToken string // personal access token, sent as a bearer token instead of the username and API token
+	JiraProjectKey  string
+	JiraRateLimit   float64 // requests per second to Jira, 0 for no limit
+	JiraRateBurst   int     // requests sent at once before the rate limit applies
+	Port            string
+	ReportTemplate  string // optional path to a custom html/template for execution reports
+	StorageDriver   string // sqlite or memory
+	StoragePath     string // sqlite database file
+
+	SyncInterval          time.Duration  // background sync interval, 0 disables the cache
+	SyncReconcileInterval time.Duration  // how often a sync also removes issues deleted in Jira
+	SyncTimezone          *time.Location // time zone Jira uses for dates in JQL
+
+	OutboxInterval   time.Duration // how often queued writes are replayed, 0 disables the outbox
+	OutboxMaxBackoff time.Duration // longest wait between replays of a failing write
+
+	CassetteMode cassette.Mode // off, record or replay Jira HTTP interactions
+	CassettePath string        // cassette file for record and replay
+
+	Projects []jira.ProjectConfig // projects served, JIRA_PROJECT_KEY first
+	Tenants  []TenantConfig       // further Jira connections, from JIRA_TENANTS
+
+	JiraDelegation     string        // off, optional or required: act in Jira with the caller's own credentials
+	JiraVerify         string        // off, warn or fail: check at startup that configured names exist in Jira
+	JiraClientCacheTTL time.Duration // how long a client with caller credentials is reused
+
+	AuthMethods        []string        // inbound authentication: apikey and/or jwt; empty leaves the API open
+	APIKeys            []auth.Key      // API keys from configuration, by hash
+	JWT                auth.JWTOptions // JWT validation
+	CORSAllowedOrigins []string        // origins browsers may call the API from, * allows any, none by default
+	RBACEnabled        bool            // enforce role bindings per project on top of scopes
+
+	ConfigWatchInterval time.Duration // how often changes to the configuration files are looked for, 0 reloads on SIGHUP only
+
+	HealthCheckTimeout     time.Duration // longest wait for each dependency checked by the readiness probe
+	HealthSyncMaxLag       time.Duration // issue caches older than this are reported degraded, 0 never
+	HealthOutboxMaxPending int           // more queued writes than this are reported degraded, 0 never
+
+	MetricsEnabled bool // serve Prometheus metrics on /metrics
+
+	TracingExporter    string  // none, otlp or stdout
+	TracingEndpoint    string  // URL of the OTLP collector, empty for the OTEL_EXPORTER_OTLP_* defaults
+	TracingSampleRatio float64 // fraction of traces started here that are recorded
+
+	HTTPReadTimeout  time.Duration // longest time to read a request, including its body
+	HTTPWriteTimeout time.Duration // longest time to handle a request and write the response
+	HTTPIdleTimeout  time.Duration // how long an idle keep-alive connection stays open
+	ShutdownTimeout  time.Duration // how long shutdown waits for requests and background work
+	MaxBodySize      int64         // largest request body in bytes, 0 for no limit
+	MaxUploadSize    int64         // largest file upload in bytes, 0 for no limit
+	TLSCertFile      string        // serve HTTPS with this certificate, with TLSKeyFile
+	TLSKeyFile       string
+	TLSClientCAFile  string // require client certificates signed by these CAs
+	TLSClientAuth    string // require or optional, with TLSClientCAFile
+
+	SecretSources   map[string]string // where each secret setting was found, by name; never the value
+	secretProviders []secrets.Provider
+	settings        settings // what the configuration was read from
 }
 
-// LoadConfig loads configuration from environment variables
-func LoadConfig() (*Config, error) {
-	// Load .env file if it exists
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+// LoadConfig loads configuration from environment variables, falling back to
+// the settings of the configuration file at path, or CONFIG_FILE, if any.
+// Secrets may also come from files, the keyring or a secret provider.
+func LoadConfig(path string) (*Config, error) {
+	s, path, err := loadSettings(path)
+	if err != nil {
+		return nil, err
 	}
 
 	config := &Config{
-		JiraBaseURL:    getEnvOrDefault("JIRA_BASE_URL", ""),
-		JiraUsername:   getEnvOrDefault("JIRA_USERNAME", ""),
-		JiraAPIToken:   getEnvOrDefault("JIRA_API_TOKEN", ""),
-		JiraProjectKey: getEnvOrDefault("JIRA_PROJECT_KEY", ""),
-		Port:           getEnvOrDefault("PORT", "8080"),
+		ConfigFile:     path,
+		settings:       s,
+		Backend:        s.getEnvOrDefault("BACKEND", "jira"),
+		JiraTenant:     s.getEnvOrDefault("JIRA_TENANT", "default"),
+		JiraBaseURL:    s.getEnvOrDefault("JIRA_BASE_URL", ""),
+		JiraUsername:   s.getEnvOrDefault("JIRA_USERNAME", ""),
+		JiraProjectKey: s.getEnvOrDefault("JIRA_PROJECT_KEY", ""),
+		Port:           s.getEnvOrDefault("PORT", "8080"),
+		ReportTemplate: s.getEnvOrDefault("REPORT_TEMPLATE", ""),
+		StorageDriver:  s.getEnvOrDefault("STORAGE_DRIVER", "sqlite"),
+		StoragePath:    s.getEnvOrDefault("STORAGE_PATH", "data/xray.db"),
+		CassetteMode:   cassette.Mode(s.getEnvOrDefault("JIRA_CASSETTE_MODE", "off")),
+		CassettePath:   s.getEnvOrDefault("JIRA_CASSETTE_PATH", "data/jira-cassette.json"),
+		JiraDelegation: s.getEnvOrDefault("JIRA_DELEGATION", delegationOff),
+		JiraVerify:     s.getEnvOrDefault("JIRA_VERIFY", verifyFail),
+		KeyringFile:    s.getEnvOrDefault("KEYRING_FILE", ""),
+	}
+
+	if config.secretProviders, err = loadSecretProviders(s); err != nil {
+		return nil, err
+	}
+	if config.JiraAPIToken, err = config.secret("JIRA_API_TOKEN"); err != nil {
+		return nil, err
+	}
+	if config.JiraAccessToken, err = config.secret("JIRA_ACCESS_TOKEN"); err != nil {
+		return nil, err
+	}
+
+	if config.SyncInterval, err = s.parseDurationEnv("SYNC_INTERVAL", "5m"); err != nil {
+		return nil, err
+	}
+	if config.SyncReconcileInterval, err = s.parseDurationEnv("SYNC_RECONCILE_INTERVAL", "1h"); err != nil {
+		return nil, err
+	}
+	timezone := s.getEnvOrDefault("SYNC_TIMEZONE", "UTC")
+	if config.SyncTimezone, err = time.LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("invalid SYNC_TIMEZONE %q: %w", timezone, err)
+	}
+	if config.OutboxInterval, err = s.parseDurationEnv("OUTBOX_INTERVAL", "30s"); err != nil {
+		return nil, err
+	}
+	if config.OutboxMaxBackoff, err = s.parseDurationEnv("OUTBOX_MAX_BACKOFF", "10m"); err != nil {
+		return nil, err
+	}
+	if config.ConfigWatchInterval, err = s.parseDurationEnv("CONFIG_WATCH_INTERVAL", "10s"); err != nil {
+		return nil, err
+	}
+	if err := loadHealthConfig(config); err != nil {
+		return nil, err
+	}
+	metricsEnabled := s.getEnvOrDefault("METRICS_ENABLED", "true")
+	if config.MetricsEnabled, err = strconv.ParseBool(metricsEnabled); err != nil {
+		return nil, fmt.Errorf("invalid METRICS_ENABLED %q, use true or false", metricsEnabled)
+	}
+	if err := loadTracingConfig(config); err != nil {
+		return nil, err
+	}
+
+	if config.JiraRateLimit, config.JiraRateBurst, err = s.parseRateLimitEnv("JIRA_"); err != nil {
+		return nil, err
+	}
+
+	if config.JiraClientCacheTTL, err = s.parseDurationEnv("JIRA_CLIENT_CACHE_TTL", "5m"); err != nil {
+		return nil, err
+	}
+	switch config.JiraDelegation {
+	case delegationOff, delegationOptional, delegationRequired:
+	default:
+		return nil, fmt.Errorf("invalid JIRA_DELEGATION %q, use off, optional or required", config.JiraDelegation)
+	}
+	if config.JiraDelegation != delegationOff && config.Backend != "jira" {
+		return nil, fmt.Errorf("JIRA_DELEGATION needs BACKEND=jira")
+	}
+	switch config.JiraVerify {
+	case verifyOff, verifyWarn, verifyFail:
+	default:
+		return nil, fmt.Errorf("invalid JIRA_VERIFY %q, use off, warn or fail", config.JiraVerify)
+	}
+
+	if err := loadServerConfig(config); err != nil {
+		return nil, err
+	}
+	if err := loadAuthConfig(config); err != nil {
+		return nil, err
+	}
+
+	switch config.CassetteMode {
+	case cassette.ModeOff, cassette.ModeRecord, cassette.ModeReplay:
+	default:
+		return nil, fmt.Errorf("invalid JIRA_CASSETTE_MODE %q, use off, record or replay", config.CassetteMode)
 	}
 
 	// Validate required configuration
+	if err := validateBackendConfig(config); err != nil {
+		return nil, err
+	}
+	if config.Projects, err = s.loadProjects(config.JiraProjectKey, "JIRA_"); err != nil {
+		return nil, err
+	}
+	if err := loadTenants(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// settings are what a configuration is read from: the environment, the
+// variables of the .env file and the settings of the configuration file.
+// Each load reads new settings and only reads from them, so a reload in
+// progress changes nothing that requests, or the configuration in effect,
+// depend on.
+type settings struct {
+	dotEnv map[string]string // variables of the .env file
+	file   map[string]string // settings of the configuration file, by environment variable name
+}
+
+// loadSettings reads the .env file and the settings of the configuration
+// file at path, or CONFIG_FILE. It also returns the path of the
+// configuration file, if any.
+func loadSettings(path string) (settings, string, error) {
+	s := settings{dotEnv: loadDotEnv()}
+	if path == "" {
+		path = s.getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		file, err := readConfigFile(path, s.lookupEnv)
+		if err != nil {
+			return settings{}, "", err
+		}
+		s.file = file.settings()
+	}
+	return s, path, nil
+}
+
+// loadDotEnv reads the variables of the .env file, if it exists
+func loadDotEnv() map[string]string {
+	values, err := godotenv.Read()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Println("No .env file found, using system environment variables")
+	case err != nil:
+		log.Printf("⚠️  Ignoring .env file: %v", err)
+		return nil
+	}
+	return values
+}
+
+// exportedDotEnv holds the variables exportDotEnv set from the .env file.
+// It is only written at startup, before requests are served.
+var exportedDotEnv map[string]bool
+
+// exportDotEnv sets the variables of the .env file that the environment does
+// not set, once at startup, for what reads the environment itself, such as
+// the OTEL_* variables of tracing and the secret command. Reloads do not
+// change the environment.
+func exportDotEnv(values map[string]string) {
+	exportedDotEnv = make(map[string]bool)
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, value)
+			exportedDotEnv[key] = true
+		}
+	}
+}
+
+// lookupEnv finds a variable in the environment, or else in the .env file.
+// Variables of the real environment win over .env.
+func (s settings) lookupEnv(key string) (string, bool) {
+	if value, set := os.LookupEnv(key); set && !exportedDotEnv[key] {
+		return value, true
+	}
+	value, ok := s.dotEnv[key]
+	return value, ok
+}
+
+// getenv returns a variable of the environment or the .env file
+func (s settings) getenv(key string) string {
+	value, _ := s.lookupEnv(key)
+	return value
+}
+
+// validateBackendConfig checks the settings the selected backend needs
+func validateBackendConfig(config *Config) error {
+	switch config.Backend {
+	case "jira":
+		if config.CassetteMode == cassette.ModeReplay {
+			// Replayed responses need no Jira connection or credentials
+			if config.JiraBaseURL == "" {
+				config.JiraBaseURL = "http://jira.invalid"
+			}
+			if config.JiraProjectKey == "" {
+				return fmt.Errorf("JIRA_PROJECT_KEY is required")
+			}
+			return nil
+		}
+	case "memory":
+		// The in-memory backend needs no Jira connection
+		if config.JiraProjectKey == "" {
+			config.JiraProjectKey = "TEST"
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid BACKEND %q, use jira or memory", config.Backend)
+	}
 	if config.JiraBaseURL == "" {
-		return nil, fmt.Errorf("JIRA_BASE_URL is required")
+		return fmt.Errorf("JIRA_BASE_URL is required")
 	}
-	if config.JiraUsername == "" {
-		return nil, fmt.Errorf("JIRA_USERNAME is required")
+	if config.JiraAccessToken == "" && config.JiraUsername == "" {
+		return fmt.Errorf("JIRA_USERNAME is required, or JIRA_ACCESS_TOKEN")
 	}
-	if config.JiraAPIToken == "" {
-		return nil, fmt.Errorf("JIRA_API_TOKEN is required")
+	if config.JiraAccessToken == "" && config.JiraAPIToken == "" {
+		return fmt.Errorf("JIRA_API_TOKEN is required, or JIRA_ACCESS_TOKEN")
 	}
 	if config.JiraProjectKey == "" {
-		return nil, fmt.Errorf("JIRA_PROJECT_KEY is required")
+		return fmt.Errorf("JIRA_PROJECT_KEY is required")
 	}
 
-	return config, nil
+	return nil
+}
+
+// projectKeyPattern matches Jira project keys
+var projectKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]+$`)
+
+// loadProjects reads the projects served from one Jira connection: the
+// default project, followed by the <prefix>PROJECTS list. The
+// <prefix>PROJECT_<KEY>_* variables set the issue type names, field
+// mappings, link types and status mapping of each project.
+func (s settings) loadProjects(defaultKey, prefix string) ([]jira.ProjectConfig, error) {
+	keys := append([]string{defaultKey}, splitList(s.getEnvOrDefault(prefix+"PROJECTS", ""))...)
+	var projects []jira.ProjectConfig
+	seen := make(map[string]bool)
+	for i, key := range keys {
+		if i > 0 {
+			key = strings.ToUpper(key)
+			if !projectKeyPattern.MatchString(key) {
+				return nil, fmt.Errorf("invalid %sPROJECTS entry %q, use Jira project keys such as PAY", prefix, key)
+			}
+		}
+		if seen[strings.ToUpper(key)] {
+			continue
+		}
+		seen[strings.ToUpper(key)] = true
+
+		projectPrefix := prefix + "PROJECT_" + strings.ToUpper(key) + "_"
+		project := jira.ProjectConfig{
+			Key: key,
+			IssueTypes: jira.IssueTypes{
+				Test:          s.getEnvOrDefault(projectPrefix+"TEST_ISSUE_TYPE", jira.IssueTypeTest),
+				TestExecution: s.getEnvOrDefault(projectPrefix+"EXECUTION_ISSUE_TYPE", jira.IssueTypeTestExecution),
+				TestPlan:      s.getEnvOrDefault(projectPrefix+"TEST_PLAN_ISSUE_TYPE", jira.IssueTypeTestPlan),
+				Requirements:  splitList(s.getEnvOrDefault(projectPrefix+"REQUIREMENT_ISSUE_TYPES", "")),
+				Defects:       splitList(s.getEnvOrDefault(projectPrefix+"DEFECT_ISSUE_TYPES", "")),
+			}.WithDefaults(),
+		}
+		fields, err := jira.ParseFieldMapping(s.getEnvOrDefault(projectPrefix+"FIELDS", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sFIELDS: %w", projectPrefix, err)
+		}
+		if len(fields) > 0 {
+			project.Fields = fields
+		}
+		if project.LinkTypes, err = jira.ParseLinkTypes(s.getEnvOrDefault(projectPrefix+"LINK_TYPES", "")); err != nil {
+			return nil, fmt.Errorf("invalid %sLINK_TYPES: %w", projectPrefix, err)
+		}
+		statuses, err := jira.ParseStatusMapping(s.getEnvOrDefault(projectPrefix+"STATUSES", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sSTATUSES: %w", projectPrefix, err)
+		}
+		if len(statuses) > 0 {
+			project.Statuses = statuses
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+// TenantConfig is a named Jira connection: an instance, the credentials the
+// service signs in with and the projects served from it
+type TenantConfig struct {
+	Name        string
+	BaseURL     string
+	Username    string
+	APIToken    string
+	AccessToken string               // sent as a bearer token instead of Username and APIToken
+	ProjectKey  string               // project of routes without one
+	Projects    []jira.ProjectConfig // projects served, ProjectKey first
+	RateLimit   float64              // requests per second, 0 for no limit
+	RateBurst   int
+}
+
+// tenantNamePattern matches tenant names, which appear in paths and variable names
+var tenantNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// tenantEnvPrefix returns the prefix of the variables configuring a tenant
+func tenantEnvPrefix(name string) string {
+	return "JIRA_TENANT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
 }
 
-// getEnvOrDefault gets environment variable or returns default value
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+// loadTenants reads the Jira connections in JIRA_TENANTS, each configured by
+// JIRA_TENANT_<NAME>_* variables like the JIRA_* ones of the default
+// connection. A project key may only be served by one connection, since
+// stored results and role bindings refer to issues and projects by key.
+func loadTenants(config *Config) error {
+	if !tenantNamePattern.MatchString(config.JiraTenant) {
+		return fmt.Errorf("invalid JIRA_TENANT %q, use lower-case letters, digits and dashes", config.JiraTenant)
+	}
+	names := map[string]bool{config.JiraTenant: true}
+	owners := make(map[string]string)
+	for _, project := range config.Projects {
+		owners[strings.ToUpper(project.Key)] = config.JiraTenant
+	}
+
+	config.Tenants = nil
+	for _, name := range splitList(config.settings.getEnvOrDefault("JIRA_TENANTS", "")) {
+		name = strings.ToLower(name)
+		if !tenantNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid JIRA_TENANTS entry %q, use lower-case letters, digits and dashes", name)
+		}
+		if names[name] {
+			return fmt.Errorf("tenant %s is configured twice", name)
+		}
+		names[name] = true
+
+		tenant, err := loadTenant(config, name)
+		if err != nil {
+			return err
+		}
+		for _, project := range tenant.Projects {
+			key := strings.ToUpper(project.Key)
+			if owner, ok := owners[key]; ok {
+				return fmt.Errorf("project %s is served by tenants %s and %s, a project key can only belong to one tenant", key, owner, name)
+			}
+			owners[key] = name
+		}
+		config.Tenants = append(config.Tenants, tenant)
+	}
+	return nil
+}
+
+// loadTenant reads the JIRA_TENANT_<NAME>_* variables of a tenant
+func loadTenant(config *Config, name string) (TenantConfig, error) {
+	prefix := tenantEnvPrefix(name)
+	tenant := TenantConfig{
+		Name:       name,
+		BaseURL:    config.settings.getEnvOrDefault(prefix+"BASE_URL", ""),
+		Username:   config.settings.getEnvOrDefault(prefix+"USERNAME", ""),
+		ProjectKey: strings.ToUpper(config.settings.getEnvOrDefault(prefix+"PROJECT_KEY", "")),
+	}
+	var err error
+	if tenant.APIToken, err = config.secret(prefix + "API_TOKEN"); err != nil {
+		return tenant, err
+	}
+	if tenant.AccessToken, err = config.secret(prefix + "ACCESS_TOKEN"); err != nil {
+		return tenant, err
+	}
+	if !projectKeyPattern.MatchString(tenant.ProjectKey) {
+		return tenant, fmt.Errorf("%sPROJECT_KEY is required, e.g. PAY", prefix)
+	}
+
+	if config.Backend == "jira" {
+		if tenant.BaseURL == "" && config.CassetteMode == cassette.ModeReplay {
+			tenant.BaseURL = "http://" + name + ".jira.invalid"
+		}
+		if tenant.BaseURL == "" {
+			return tenant, fmt.Errorf("%sBASE_URL is required", prefix)
+		}
+		if tenant.AccessToken == "" && (tenant.Username == "" || tenant.APIToken == "") && config.CassetteMode != cassette.ModeReplay {
+			return tenant, fmt.Errorf("%sUSERNAME and %sAPI_TOKEN are required, or %sACCESS_TOKEN", prefix, prefix, prefix)
+		}
+	}
+
+	if tenant.RateLimit, tenant.RateBurst, err = config.settings.parseRateLimitEnv(prefix); err != nil {
+		return tenant, err
+	}
+	if tenant.Projects, err = config.settings.loadProjects(tenant.ProjectKey, prefix); err != nil {
+		return tenant, err
+	}
+	return tenant, nil
+}
+
+// loadHealthConfig reads the thresholds of the readiness probe. The sync lag
+// defaults to three sync intervals, so one slow or failed sync is tolerated.
+func loadHealthConfig(config *Config) error {
+	var err error
+	if config.HealthCheckTimeout, err = config.settings.parseDurationEnv("HEALTH_CHECK_TIMEOUT", "5s"); err != nil {
+		return err
+	}
+	if config.HealthCheckTimeout <= 0 {
+		return fmt.Errorf("invalid HEALTH_CHECK_TIMEOUT %s: use a positive duration such as 5s", config.HealthCheckTimeout)
+	}
+	if config.HealthSyncMaxLag, err = config.settings.parseDurationEnv("HEALTH_SYNC_MAX_LAG", (3 * config.SyncInterval).String()); err != nil {
+		return err
+	}
+	value := config.settings.getEnvOrDefault("HEALTH_OUTBOX_MAX_PENDING", "100")
+	if config.HealthOutboxMaxPending, err = strconv.Atoi(value); err != nil || config.HealthOutboxMaxPending < 0 {
+		return fmt.Errorf("invalid HEALTH_OUTBOX_MAX_PENDING %q: use a number of writes such as 100, or 0 for no limit", value)
+	}
+	return nil
+}
+
+// loadTracingConfig reads where spans are exported to and how many are
+func loadTracingConfig(config *Config) error {
+	config.TracingExporter = config.settings.getEnvOrDefault("TRACING_EXPORTER", tracing.ExporterNone)
+	switch config.TracingExporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		return fmt.Errorf("invalid TRACING_EXPORTER %q, use none, otlp or stdout", config.TracingExporter)
+	}
+	config.TracingEndpoint = config.settings.getEnvOrDefault("TRACING_ENDPOINT", "")
+	if config.TracingEndpoint != "" {
+		if u, err := url.Parse(config.TracingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid TRACING_ENDPOINT %q: use a URL such as http://localhost:4318", config.TracingEndpoint)
+		}
+	}
+	value := config.settings.getEnvOrDefault("TRACING_SAMPLE_RATIO", "1")
+	ratio, err := strconv.ParseFloat(value, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return fmt.Errorf("invalid TRACING_SAMPLE_RATIO %q: use a fraction from 0 to 1 such as 0.1", value)
+	}
+	config.TracingSampleRatio = ratio
+	return nil
+}
+
+// parseRateLimitEnv reads <prefix>RATE_LIMIT and <prefix>RATE_BURST
+func (s settings) parseRateLimitEnv(prefix string) (float64, int, error) {
+	value := s.getEnvOrDefault(prefix+"RATE_LIMIT", "0")
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		return 0, 0, fmt.Errorf("invalid %sRATE_LIMIT %q: use requests per second such as 10, or 0 for no limit", prefix, value)
+	}
+	value = s.getEnvOrDefault(prefix+"RATE_BURST", "10")
+	burst, err := strconv.Atoi(value)
+	if err != nil || burst < 1 {
+		return 0, 0, fmt.Errorf("invalid %sRATE_BURST %q: use a number of requests such as 10", prefix, value)
+	}
+	return rate, burst, nil
+}
+
+// DefaultTenant returns the Jira connection configured by the JIRA_* variables
+func (c *Config) DefaultTenant() TenantConfig {
+	return TenantConfig{
+		Name:        c.JiraTenant,
+		BaseURL:     c.JiraBaseURL,
+		Username:    c.JiraUsername,
+		APIToken:    c.JiraAPIToken,
+		AccessToken: c.JiraAccessToken,
+		ProjectKey:  c.JiraProjectKey,
+		Projects:    c.Projects,
+		RateLimit:   c.JiraRateLimit,
+		RateBurst:   c.JiraRateBurst,
+	}
+}
+
+// AllTenants returns every Jira connection, the default one first
+func (c *Config) AllTenants() []TenantConfig {
+	return append([]TenantConfig{c.DefaultTenant()}, c.Tenants...)
+}
+
+// Project returns the settings of a served project, matching keys case-insensitively
+func (c TenantConfig) Project(key string) (jira.ProjectConfig, bool) {
+	for _, p := range c.ServedProjects() {
+		if strings.EqualFold(p.Key, key) {
+			return p, true
+		}
+	}
+	return jira.ProjectConfig{}, false
+}
+
+// ServedProjects returns the settings of every served project, the default project first
+func (c TenantConfig) ServedProjects() []jira.ProjectConfig {
+	if len(c.Projects) == 0 {
+		return []jira.ProjectConfig{{Key: c.ProjectKey, IssueTypes: jira.DefaultIssueTypes}}
+	}
+	return c.Projects
+}
+
+// DefaultProject returns the settings of the project of routes without one
+func (c TenantConfig) DefaultProject() jira.ProjectConfig {
+	return c.ServedProjects()[0]
+}
+
+// loadAuthConfig reads the inbound authentication and CORS settings
+func loadAuthConfig(config *Config) error {
+	config.AuthMethods = splitList(config.settings.getEnvOrDefault("AUTH_METHODS", ""))
+	for _, method := range config.AuthMethods {
+		if method != auth.MethodAPIKey && method != auth.MethodJWT {
+			return fmt.Errorf("invalid AUTH_METHODS entry %q, use apikey and/or jwt", method)
+		}
+	}
+
+	keys, err := config.secret("API_KEYS")
+	if err != nil {
+		return err
+	}
+	if config.APIKeys, err = auth.ParseStaticKeys(keys); err != nil {
+		return fmt.Errorf("invalid API_KEYS: %w", err)
+	}
+
+	config.JWT = auth.JWTOptions{
+		JWKSFile:   config.settings.getEnvOrDefault("JWT_JWKS_FILE", ""),
+		Issuer:     config.settings.getEnvOrDefault("JWT_ISSUER", ""),
+		Audience:   config.settings.getEnvOrDefault("JWT_AUDIENCE", ""),
+		ScopeClaim: config.settings.getEnvOrDefault("JWT_SCOPE_CLAIM", "scope"),
+	}
+	if config.JWT.Leeway, err = config.settings.parseDurationEnv("JWT_LEEWAY", "1m"); err != nil {
+		return err
+	}
+	if config.AuthEnabled(auth.MethodJWT) && config.JWT.JWKSFile == "" {
+		return fmt.Errorf("JWT_JWKS_FILE is required when AUTH_METHODS includes jwt")
+	}
+	// Without an audience, tokens the identity provider issues for any other
+	// application would be accepted
+	if config.AuthEnabled(auth.MethodJWT) && config.JWT.Audience == "" {
+		return fmt.Errorf("JWT_AUDIENCE is required when AUTH_METHODS includes jwt")
+	}
+
+	config.CORSAllowedOrigins = splitList(config.settings.getEnvOrDefault("CORS_ALLOWED_ORIGINS", ""))
+
+	rbac := config.settings.getEnvOrDefault("RBAC_ENABLED", "false")
+	if config.RBACEnabled, err = strconv.ParseBool(rbac); err != nil {
+		return fmt.Errorf("invalid RBAC_ENABLED %q, use true or false", rbac)
+	}
+	if config.RBACEnabled && !config.AuthEnabled() {
+		return fmt.Errorf("RBAC_ENABLED requires AUTH_METHODS")
+	}
+	return nil
+}
+
+// loadServerConfig reads the settings of the HTTP server: timeouts, request
+// size limits and TLS
+func loadServerConfig(config *Config) error {
+	var err error
+	for _, d := range []struct {
+		key, defaultValue string
+		value             *time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", "1m", &config.HTTPReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", "5m", &config.HTTPWriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", "2m", &config.HTTPIdleTimeout},
+		{"SHUTDOWN_TIMEOUT", "30s", &config.ShutdownTimeout},
+	} {
+		if *d.value, err = config.settings.parseDurationEnv(d.key, d.defaultValue); err != nil {
+			return err
+		}
+	}
+	if config.MaxBodySize, err = config.settings.parseSizeEnv("MAX_BODY_SIZE", "1MB"); err != nil {
+		return err
+	}
+	if config.MaxUploadSize, err = config.settings.parseSizeEnv("MAX_UPLOAD_SIZE", "32MB"); err != nil {
+		return err
+	}
+
+	config.TLSCertFile = config.settings.getEnvOrDefault("TLS_CERT_FILE", "")
+	config.TLSKeyFile = config.settings.getEnvOrDefault("TLS_KEY_FILE", "")
+	config.TLSClientCAFile = config.settings.getEnvOrDefault("TLS_CLIENT_CA_FILE", "")
+	config.TLSClientAuth = config.settings.getEnvOrDefault("TLS_CLIENT_AUTH", tlsClientAuthRequire)
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if config.TLSClientCAFile != "" && config.TLSCertFile == "" {
+		return fmt.Errorf("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	switch config.TLSClientAuth {
+	case tlsClientAuthRequire, tlsClientAuthOptional:
+	default:
+		return fmt.Errorf("invalid TLS_CLIENT_AUTH %q, use require or optional", config.TLSClientAuth)
+	}
+	return nil
+}
+
+// AuthEnabled reports whether an authentication method is enabled, or any
+// method if none is given
+func (c *Config) AuthEnabled(method ...string) bool {
+	for _, enabled := range c.AuthMethods {
+		if len(method) == 0 || enabled == method[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated setting, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvOrDefault gets environment variable, or the setting of the
+// configuration file standing in for it, or returns default value
+func (s settings) getEnvOrDefault(key, defaultValue string) string {
+	if value := s.getenv(key); value != "" {
+		return value
+	}
+	if value := s.file[key]; value != "" {
 		return value
 	}
 	return defaultValue
 }
 
-// ValidateConfig validates the configuration and logs warnings for demo credentials
+// newTenantBackend creates the test management backend of a Jira connection,
+// sending its requests through transport. On reload the in-memory backend of
+// the previous tenant is kept along with its data.
+func newTenantBackend(c *Config, t TenantConfig, transport jiraTransport, previous *tenant) jira.TestManagementBackend {
+	if c.Backend == "memory" {
+		if memory, ok := previous.memoryBackend(t.ProjectKey); ok {
+			return memory
+		}
+		memory := jira.NewMemoryBackend(t.ProjectKey)
+		if t.Name == c.JiraTenant {
+			memory.SeedDemoData()
+		}
+		return memory
+	}
+
+	client := jira.NewClient(t.BaseURL, t.Username, t.APIToken, t.ProjectKey).ForProject(t.DefaultProject())
+	client.AccessToken = t.AccessToken
+	client.HTTPClient.Transport = transport.roundTripper()
+	return client
+}
+
+// jiraTransport carries the requests to a tenant's Jira: through a rate
+// limiter of their own and, when cassettes are on, a cassette recording or
+// replaying them. It is empty with the in-memory backend.
+type jiraTransport struct {
+	limiter  *jira.RateLimiter
+	cassette *cassette.Transport
+}
+
+// roundTripper returns the transport jira.Client sends requests through
+func (t jiraTransport) roundTripper() http.RoundTripper {
+	if t.cassette != nil {
+		return t.cassette
+	}
+	return t.limiter
+}
+
+// newJiraTransport sets up the transport of a Jira connection. On reload the
+// rate limiter of the previous tenant, along with any Retry-After hold, is
+// kept while the tenant talks to the same Jira at the same rate, and its
+// cassette is kept so that a recording is not started over.
+func newJiraTransport(c *Config, t TenantConfig, previous *tenant) (jiraTransport, error) {
+	if c.Backend == "memory" {
+		return jiraTransport{}, nil
+	}
+	var kept jiraTransport
+	if previous != nil {
+		kept = previous.transport
+	}
+
+	transport := jiraTransport{limiter: kept.limiter, cassette: kept.cassette}
+	if kept.limiter == nil || previous.BaseURL != t.BaseURL || previous.RateLimit != t.RateLimit || previous.RateBurst != t.RateBurst {
+		transport.limiter = jira.NewRateLimiter(nil, t.RateLimit, t.RateBurst)
+		if kept.cassette != nil {
+			transport.cassette = kept.cassette.WithNext(transport.limiter)
+		}
+	}
+	if c.CassetteMode == cassette.ModeOff || transport.cassette != nil {
+		return transport, nil
+	}
+
+	path := c.CassettePath
+	if t.Name != c.JiraTenant {
+		path = tenantCassettePath(path, t.Name)
+	}
+	recorder, err := cassette.NewTransport(c.CassetteMode, path, transport.limiter)
+	if err != nil {
+		return jiraTransport{}, err
+	}
+	transport.cassette = recorder
+	return transport, nil
+}
+
+// tenantCassettePath returns the cassette of a further tenant next to the
+// default one: data/jira-cassette.json becomes data/jira-cassette.dc.json
+func tenantCassettePath(path, tenant string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + tenant + ext
+}
+
+// parseDurationEnv reads a duration such as 30s or 5m from an environment variable
+func (s settings) parseDurationEnv(key, defaultValue string) (time.Duration, error) {
+	value := s.getEnvOrDefault(key, defaultValue)
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: use a duration such as 30s or 5m", key, value)
+	}
+	return d, nil
+}
+
+// parseSizeEnv reads a size in bytes such as 512KB or 10MB from an
+// environment variable. KB, MB and GB are multiples of 1024.
+func (s settings) parseSizeEnv(key, defaultValue string) (int64, error) {
+	value := s.getEnvOrDefault(key, defaultValue)
+	size, err := parseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: use a size such as 512KB or 10MB", key, value)
+	}
+	return size, nil
+}
+
+// parseSize parses a number of bytes with an optional B, KB, MB or GB unit
+func parseSize(value string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		bytes  int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(number, unit.suffix) {
+			number, multiplier = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix)), unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return n * multiplier, nil
+}
+
+// ValidateConfig validates the configuration and logs warnings for demo setups
 func (c *Config) ValidateConfig() {
-	if c.JiraUsername == "demo_user" || c.JiraAPIToken == "demo_token_replace_with_actual" {
-		log.Println("⚠️  WARNING: You are using demo credentials!")
+	if c.Backend == "memory" {
+		log.Println("⚠️  WARNING: Using the in-memory backend with demo data!")
+		log.Println("⚠️  Nothing is sent to Jira and created issues are lost on restart")
+		log.Println("⚠️  Set BACKEND=jira and your Jira credentials in .env to connect to Jira")
+	} else if c.CassetteMode == cassette.ModeReplay {
+		log.Println("⚠️  WARNING: Replaying recorded Jira responses, nothing is sent to Jira")
+	} else if c.JiraAPIToken == "demo_token_replace_with_actual" {
+		log.Println("⚠️  WARNING: You are using the placeholder Jira API token!")
 		log.Println("⚠️  Please copy .env.sample to .env and update with your actual Jira credentials")
-		log.Println("⚠️  The application will not work with real Jira integration until you provide valid credentials")
+		log.Println("⚠️  Jira requests will fail until you provide valid credentials, or set BACKEND=memory to try the API without Jira")
 	}
 
 	log.Printf("✅ Configuration loaded successfully")
+	if c.ConfigFile != "" {
+		log.Printf("   Config file: %s", c.ConfigFile)
+	}
+	log.Printf("   Backend: %s", c.Backend)
 	log.Printf("   Jira Base URL: %s", c.JiraBaseURL)
 	log.Printf("   Jira Project Key: %s", c.JiraProjectKey)
+	if c.JiraRateLimit > 0 {
+		log.Printf("   Jira rate limit: %g requests/s, bursts of %d", c.JiraRateLimit, c.JiraRateBurst)
+	}
+	for _, t := range c.Tenants {
+		log.Printf("   Jira tenant %s: projects %s %s", t.Name, strings.Join(projectKeysOf(t), ", "), t.BaseURL)
+	}
 	log.Printf("   Server Port: %s", c.Port)
+	log.Printf("   HTTP timeouts: read %s, write %s, idle %s; bodies up to %d bytes, uploads up to %d bytes", c.HTTPReadTimeout, c.HTTPWriteTimeout, c.HTTPIdleTimeout, c.MaxBodySize, c.MaxUploadSize)
+	if c.TLSClientCAFile != "" {
+		log.Printf("   TLS: %s, client certificates %s from %s", c.TLSCertFile, c.TLSClientAuth, c.TLSClientCAFile)
+	} else if c.TLSCertFile != "" {
+		log.Printf("   TLS: %s", c.TLSCertFile)
+	}
+	log.Printf("   Storage: %s %s", c.StorageDriver, c.StoragePath)
+	if c.SyncInterval > 0 {
+		log.Printf("   Sync: every %s, reconcile every %s", c.SyncInterval, c.SyncReconcileInterval)
+	} else {
+		log.Printf("   Sync: disabled")
+	}
+	if c.OutboxInterval > 0 {
+		log.Printf("   Outbox: replay every %s, back off up to %s", c.OutboxInterval, c.OutboxMaxBackoff)
+	} else {
+		log.Printf("   Outbox: disabled")
+	}
+	if !c.MetricsEnabled {
+		log.Printf("   Metrics: disabled")
+	}
+	switch c.TracingExporter {
+	case tracing.ExporterOTLP:
+		endpoint := redactURL(c.TracingEndpoint)
+		if endpoint == "" {
+			endpoint = "OTEL_EXPORTER_OTLP_ENDPOINT or http://localhost:4318"
+		}
+		log.Printf("   Tracing: OTLP to %s, sampling %g of traces", endpoint, c.TracingSampleRatio)
+	case tracing.ExporterStdout:
+		log.Printf("   Tracing: stdout, sampling %g of traces", c.TracingSampleRatio)
+	}
+	if c.Backend == "jira" && c.CassetteMode != cassette.ModeOff {
+		log.Printf("   Cassette: %s %s", c.CassetteMode, c.CassettePath)
+	}
+	if c.Backend == "jira" {
+		log.Printf("   Jira verification: %s", c.JiraVerify)
+	}
+	for _, line := range c.secretSourceLines() {
+		log.Printf("   Secret %s", line)
+	}
+	if c.JiraDelegation != delegationOff {
+		log.Printf("   Jira delegation: %s, clients cached for %s", c.JiraDelegation, c.JiraClientCacheTTL)
+	}
+	if c.AuthEnabled() {
+		log.Printf("   Authentication: %s (%d API keys from configuration)", strings.Join(c.AuthMethods, ", "), len(c.APIKeys))
+		if c.RBACEnabled {
+			log.Printf("   Access control: role bindings per project")
+		} else {
+			log.Printf("   Access control: scopes only")
+		}
+	} else {
+		log.Println("⚠️  WARNING: Authentication is disabled, anyone who can reach the server can write to Jira")
+		log.Println("⚠️  Set AUTH_METHODS=apikey and API_KEYS in .env to require API keys")
+	}
+	if len(c.CORSAllowedOrigins) == 0 {
+		log.Println("   CORS allowed origins: none")
+	} else {
+		log.Printf("   CORS allowed origins: %s", strings.Join(c.CORSAllowedOrigins, ", "))
+	}
 }
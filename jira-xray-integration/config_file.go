@@ -0,0 +1,844 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/cassette"
+	"jira-xray-integration/jira"
+	"jira-xray-integration/secrets"
+	"jira-xray-integration/tracing"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the layout of the YAML or TOML configuration file. Each
+// setting stands in for an environment variable, which overrides it when set;
+// see settings for the mapping.
+type configFile struct {
+	Backend string           `json:"backend"`
+	Server  serverSettings   `json:"server"`
+	Storage storageSettings  `json:"storage"`
+	Sync    syncSettings     `json:"sync"`
+	Outbox  outboxSettings   `json:"outbox"`
+	Health  healthSettings   `json:"health"`
+	Metrics metricsSettings  `json:"metrics"`
+	Tracing tracingSettings  `json:"tracing"`
+	Jira    jiraSettings     `json:"jira"`
+	Tenants []tenantSettings `json:"tenants"`
+	Auth    authSettings     `json:"auth"`
+	Secrets secretSettings   `json:"secrets"`
+}
+
+// secretSettings name where secrets not set in the environment or the file
+// are looked up. The keyring passphrase is deliberately not a setting.
+type secretSettings struct {
+	KeyringFile string `json:"keyringFile"`
+	Command     string `json:"command"`
+}
+
+type serverSettings struct {
+	Port                int         `json:"port"`
+	ReportTemplate      string      `json:"reportTemplate"`
+	CORSAllowedOrigins  []string    `json:"corsAllowedOrigins"`
+	ConfigWatchInterval string      `json:"configWatchInterval"`
+	ReadTimeout         string      `json:"readTimeout"`
+	WriteTimeout        string      `json:"writeTimeout"`
+	IdleTimeout         string      `json:"idleTimeout"`
+	ShutdownTimeout     string      `json:"shutdownTimeout"`
+	MaxBodySize         string      `json:"maxBodySize"`
+	MaxUploadSize       string      `json:"maxUploadSize"`
+	TLS                 tlsSettings `json:"tls"`
+}
+
+type tlsSettings struct {
+	CertFile     string `json:"certFile"`
+	KeyFile      string `json:"keyFile"`
+	ClientCAFile string `json:"clientCAFile"`
+	ClientAuth   string `json:"clientAuth"`
+}
+
+type storageSettings struct {
+	Driver string `json:"driver"`
+	Path   string `json:"path"`
+}
+
+type syncSettings struct {
+	Interval          string `json:"interval"`
+	ReconcileInterval string `json:"reconcileInterval"`
+	Timezone          string `json:"timezone"`
+}
+
+type outboxSettings struct {
+	Interval   string `json:"interval"`
+	MaxBackoff string `json:"maxBackoff"`
+}
+
+type healthSettings struct {
+	CheckTimeout     string `json:"checkTimeout"`
+	SyncMaxLag       string `json:"syncMaxLag"`
+	OutboxMaxPending *int   `json:"outboxMaxPending"` // a pointer, as 0 turns the limit off
+}
+
+type metricsSettings struct {
+	Enabled *bool `json:"enabled"` // a pointer, as metrics are on unless turned off
+}
+
+type tracingSettings struct {
+	Exporter    string   `json:"exporter"`
+	Endpoint    string   `json:"endpoint"`
+	SampleRatio *float64 `json:"sampleRatio"` // a pointer, as 0 records no traces
+}
+
+// connectionSettings configure a Jira connection, the default one under jira
+// and further ones under tenants
+type connectionSettings struct {
+	BaseURL     string            `json:"baseUrl"`
+	Username    string            `json:"username"`
+	APIToken    string            `json:"apiToken"`
+	AccessToken string            `json:"accessToken"`
+	ProjectKey  string            `json:"projectKey"`
+	RateLimit   float64           `json:"rateLimit"`
+	RateBurst   int               `json:"rateBurst"`
+	Projects    []projectSettings `json:"projects"`
+}
+
+type jiraSettings struct {
+	Tenant string `json:"tenant"`
+	connectionSettings
+	Delegation     string           `json:"delegation"`
+	ClientCacheTTL string           `json:"clientCacheTtl"`
+	Verify         string           `json:"verify"`
+	Cassette       cassetteSettings `json:"cassette"`
+}
+
+type cassetteSettings struct {
+	Mode string `json:"mode"`
+	Path string `json:"path"`
+}
+
+type tenantSettings struct {
+	Name string `json:"name"`
+	connectionSettings
+}
+
+type projectSettings struct {
+	Key        string            `json:"key"`
+	IssueTypes issueTypeSettings `json:"issueTypes"`
+	Fields     map[string]string `json:"fields"`
+	LinkTypes  map[string]string `json:"linkTypes"`
+	Statuses   map[string]string `json:"statuses"`
+}
+
+type issueTypeSettings struct {
+	Test          string   `json:"test"`
+	TestExecution string   `json:"testExecution"`
+	TestPlan      string   `json:"testPlan"`
+	Requirements  []string `json:"requirements"`
+	Defects       []string `json:"defects"`
+}
+
+type authSettings struct {
+	Methods []string         `json:"methods"`
+	APIKeys []apiKeySettings `json:"apiKeys"`
+	JWT     jwtSettings      `json:"jwt"`
+	RBAC    bool             `json:"rbac"`
+}
+
+type apiKeySettings struct {
+	Name   string   `json:"name"`
+	Hash   string   `json:"hash"` // hex SHA-256 of the key
+	Scopes []string `json:"scopes"`
+}
+
+type jwtSettings struct {
+	JWKSFile   string `json:"jwksFile"`
+	Issuer     string `json:"issuer"`
+	Audience   string `json:"audience"`
+	ScopeClaim string `json:"scopeClaim"`
+	Leeway     string `json:"leeway"`
+}
+
+// configFileError lists every problem found in a configuration file, each
+// with the path of the setting, such as jira.projects[1].fields.testType
+type configFileError struct {
+	File     string
+	Problems []string
+}
+
+func (e *configFileError) Error() string {
+	return fmt.Sprintf("%s: %d problem(s):\n  %s", e.File, len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// readConfigFile reads and validates a configuration file. The format is
+// chosen by extension: .yaml, .yml or .json for YAML, .toml for TOML.
+// ${VAR} and ${VAR:-default} in values are replaced by the variables lookupEnv
+// finds.
+func readConfigFile(path string, lookupEnv func(string) (string, bool)) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		var table map[string]interface{}
+		if err := toml.Unmarshal(data, &table); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		tree = table
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file format, use .yaml, .yml or .toml", path)
+	}
+
+	file := &configFile{}
+	d := &settingDecoder{lookupEnv: lookupEnv, expanded: make(map[string]expandedValue)}
+	d.decode("", tree, reflect.ValueOf(file).Elem())
+	problems := d.problems
+	if len(problems) == 0 {
+		problems = d.hideExpandedValues(file.validate())
+	}
+	if len(problems) > 0 {
+		return nil, &configFileError{File: path, Problems: problems}
+	}
+	return file, nil
+}
+
+// settingDecoder stores values parsed from YAML or TOML in the settings of a
+// configuration file, replacing ${VAR} references with the variables
+// lookupEnv finds. Problems name the path of their setting and show values
+// as written, so the value of a variable, often a secret, is never shown.
+type settingDecoder struct {
+	lookupEnv func(string) (string, bool)
+	problems  []string
+	expanded  map[string]expandedValue // settings that refer to variables, by path
+}
+
+// expandedValue is a setting as written and with its variables replaced
+type expandedValue struct {
+	raw, value string
+}
+
+// decode stores node in v. Each problem is added with the path of its setting.
+func (d *settingDecoder) decode(path string, node interface{}, v reflect.Value) {
+	if node == nil {
+		return
+	}
+	problem := func(format string, args ...interface{}) {
+		d.problems = append(d.problems, fmt.Sprintf("%s: %s", displayPath(path), fmt.Sprintf(format, args...)))
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		table, ok := asTable(node)
+		if !ok {
+			problem("expected a table of settings, got %s", describeNode(node))
+			return
+		}
+		fields := settingFields(v)
+		for _, key := range sortedKeys(table) {
+			field, ok := fields[key]
+			if !ok {
+				d.problems = append(d.problems, fmt.Sprintf("%s: unknown setting", displayPath(joinPath(path, key))))
+				continue
+			}
+			d.decode(joinPath(path, key), table[key], field)
+		}
+
+	case reflect.Map:
+		table, ok := asTable(node)
+		if !ok {
+			problem("expected a table, got %s", describeNode(node))
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(table))
+		for _, key := range sortedKeys(table) {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			d.decode(joinPath(path, key), table[key], elem)
+			m.SetMapIndex(reflect.ValueOf(key), elem)
+		}
+		v.Set(m)
+
+	case reflect.Pointer:
+		// Pointers tell a setting left out from one set to its zero value
+		elem := reflect.New(v.Type().Elem())
+		d.decode(path, node, elem.Elem())
+		v.Set(elem)
+
+	case reflect.Slice:
+		list, ok := node.([]interface{})
+		if !ok {
+			// A string such as ${AUTH_METHODS} may stand for a comma separated list
+			if s, isString := node.(string); isString && v.Type().Elem().Kind() == reflect.String {
+				expanded, ok := d.expand(path, s)
+				if !ok {
+					return
+				}
+				items := splitList(expanded)
+				if expanded != s {
+					for i, item := range items {
+						d.expanded[fmt.Sprintf("%s[%d]", path, i)] = expandedValue{raw: s, value: item}
+					}
+				}
+				v.Set(reflect.ValueOf(items))
+				return
+			}
+			problem("expected a list, got %s", describeNode(node))
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			d.decode(fmt.Sprintf("%s[%d]", path, i), item, s.Index(i))
+		}
+		v.Set(s)
+
+	default:
+		text, ok := scalarText(node)
+		if !ok {
+			problem("expected a single value, got %s", describeNode(node))
+			return
+		}
+		expanded, ok := d.expand(path, text)
+		if !ok {
+			return
+		}
+		switch v.Kind() {
+		case reflect.String:
+			v.SetString(expanded)
+		case reflect.Int:
+			n, err := strconv.Atoi(strings.TrimSpace(expanded))
+			if err != nil {
+				problem("expected a whole number, got %q", text)
+				return
+			}
+			v.SetInt(int64(n))
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(strings.TrimSpace(expanded), 64)
+			if err != nil {
+				problem("expected a number, got %q", text)
+				return
+			}
+			v.SetFloat(f)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(strings.TrimSpace(expanded))
+			if err != nil {
+				problem("expected true or false, got %q", text)
+				return
+			}
+			v.SetBool(b)
+		}
+	}
+}
+
+// expand replaces the variables in text, the value of the setting at path,
+// and remembers the setting if it refers to any
+func (d *settingDecoder) expand(path, text string) (string, bool) {
+	expanded, err := expandVars(text, d.lookupEnv)
+	if err != nil {
+		d.problems = append(d.problems, fmt.Sprintf("%s: %v", displayPath(path), err))
+		return "", false
+	}
+	if expanded != text {
+		d.expanded[path] = expandedValue{raw: text, value: expanded}
+	}
+	return expanded, true
+}
+
+// hideExpandedValues shows the settings that refer to variables as written
+// in the problems found validating them, rather than with their values
+func (d *settingDecoder) hideExpandedValues(problems []string) []string {
+	// Longer values first, so a value that contains another is hidden whole
+	paths := make([]string, 0, len(d.expanded))
+	for path := range d.expanded {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if a, b := d.expanded[paths[i]].value, d.expanded[paths[j]].value; len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return paths[i] < paths[j]
+	})
+
+	for i, problem := range problems {
+		problemPath, message, _ := strings.Cut(problem, ": ")
+		for _, path := range paths {
+			e := d.expanded[path]
+			if e.value != "" && (strings.HasPrefix(path, problemPath) || strings.HasPrefix(problemPath, path)) {
+				message = strings.ReplaceAll(message, e.value, e.raw)
+			}
+		}
+		problems[i] = problemPath + ": " + message
+	}
+	return problems
+}
+
+// settingFields returns the fields of a settings struct by setting name,
+// including those of embedded structs
+func settingFields(v reflect.Value) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Anonymous {
+			for name, f := range settingFields(v.Field(i)) {
+				fields[name] = f
+			}
+			continue
+		}
+		fields[strings.Split(field.Tag.Get("json"), ",")[0]] = v.Field(i)
+	}
+	return fields
+}
+
+// asTable returns node as a table with string keys
+func asTable(node interface{}) (map[string]interface{}, bool) {
+	switch t := node.(type) {
+	case map[string]interface{}:
+		return t, true
+	case map[interface{}]interface{}:
+		table := make(map[string]interface{}, len(t))
+		for key, value := range t {
+			table[fmt.Sprint(key)] = value
+		}
+		return table, true
+	}
+	return nil, false
+}
+
+// scalarText formats a single value, such as a string, number or boolean
+func scalarText(node interface{}) (string, bool) {
+	switch n := node.(type) {
+	case string:
+		return n, true
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(n), true
+	}
+	return "", false
+}
+
+// describeNode names the kind of a parsed value for error messages
+func describeNode(node interface{}) string {
+	switch node.(type) {
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "a table"
+	}
+	if text, ok := scalarText(node); ok {
+		return strconv.Quote(text)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func sortedKeys(table map[string]interface{}) []string {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(top level)"
+	}
+	return path
+}
+
+// expandVars replaces ${VAR} with the variable VAR that lookupEnv finds, and
+// ${VAR:-default} with default when VAR is unset or empty. $$ stands for a
+// literal $. A variable that is unset without a default is an error, so a
+// missing secret is not silently configured as empty.
+func expandVars(s string, lookupEnv func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			out.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			out.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			expr := s[i+2 : i+end]
+			name, defaultValue, hasDefault := strings.Cut(expr, ":-")
+			if name == "" {
+				return "", fmt.Errorf("empty variable name in %q", s)
+			}
+			value, set := lookupEnv(name)
+			if !set && !hasDefault {
+				return "", fmt.Errorf("environment variable %s is not set, set it or write ${%s:-default}", name, name)
+			}
+			if value == "" {
+				value = defaultValue
+			}
+			out.WriteString(value)
+			i += end
+		default:
+			out.WriteByte('$')
+		}
+	}
+	return out.String(), nil
+}
+
+// validate checks the values of the settings, naming the path of each problem
+func (f *configFile) validate() []string {
+	var problems []string
+	check := func(path string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+	oneOf := func(path, value string, allowed ...string) {
+		if value == "" {
+			return
+		}
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		check(path, fmt.Errorf("invalid value %q, use %s", value, strings.Join(allowed, ", ")))
+	}
+	duration := func(path, value string) {
+		if value == "" {
+			return
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			check(path, fmt.Errorf("invalid duration %q, use a duration such as 30s or 5m", value))
+		}
+	}
+	size := func(path, value string) {
+		if value == "" {
+			return
+		}
+		if _, err := parseSize(value); err != nil {
+			check(path, fmt.Errorf("invalid size %q, use a size such as 512KB or 10MB", value))
+		}
+	}
+
+	oneOf("backend", f.Backend, "jira", "memory")
+	if f.Server.Port < 0 || f.Server.Port > 65535 {
+		check("server.port", fmt.Errorf("invalid port %d", f.Server.Port))
+	}
+	duration("server.configWatchInterval", f.Server.ConfigWatchInterval)
+	duration("server.readTimeout", f.Server.ReadTimeout)
+	duration("server.writeTimeout", f.Server.WriteTimeout)
+	duration("server.idleTimeout", f.Server.IdleTimeout)
+	duration("server.shutdownTimeout", f.Server.ShutdownTimeout)
+	size("server.maxBodySize", f.Server.MaxBodySize)
+	size("server.maxUploadSize", f.Server.MaxUploadSize)
+	if (f.Server.TLS.CertFile == "") != (f.Server.TLS.KeyFile == "") {
+		check("server.tls", fmt.Errorf("certFile and keyFile must be set together"))
+	}
+	oneOf("server.tls.clientAuth", f.Server.TLS.ClientAuth, tlsClientAuthRequire, tlsClientAuthOptional)
+	oneOf("storage.driver", f.Storage.Driver, "sqlite", "memory")
+	duration("sync.interval", f.Sync.Interval)
+	duration("sync.reconcileInterval", f.Sync.ReconcileInterval)
+	if f.Sync.Timezone != "" {
+		if _, err := time.LoadLocation(f.Sync.Timezone); err != nil {
+			check("sync.timezone", fmt.Errorf("unknown time zone %q", f.Sync.Timezone))
+		}
+	}
+	duration("outbox.interval", f.Outbox.Interval)
+	duration("outbox.maxBackoff", f.Outbox.MaxBackoff)
+	duration("health.checkTimeout", f.Health.CheckTimeout)
+	duration("health.syncMaxLag", f.Health.SyncMaxLag)
+	if f.Health.OutboxMaxPending != nil && *f.Health.OutboxMaxPending < 0 {
+		check("health.outboxMaxPending", fmt.Errorf("invalid number %d", *f.Health.OutboxMaxPending))
+	}
+	oneOf("tracing.exporter", f.Tracing.Exporter, tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout)
+	if ratio := f.Tracing.SampleRatio; ratio != nil && (*ratio < 0 || *ratio > 1) {
+		check("tracing.sampleRatio", fmt.Errorf("invalid ratio %g, use a fraction from 0 to 1", *ratio))
+	}
+
+	if f.Jira.Tenant != "" && !tenantNamePattern.MatchString(f.Jira.Tenant) {
+		check("jira.tenant", fmt.Errorf("invalid tenant name %q, use lower-case letters, digits and dashes", f.Jira.Tenant))
+	}
+	oneOf("jira.delegation", f.Jira.Delegation, delegationOff, delegationOptional, delegationRequired)
+	duration("jira.clientCacheTtl", f.Jira.ClientCacheTTL)
+	oneOf("jira.verify", f.Jira.Verify, verifyOff, verifyWarn, verifyFail)
+	oneOf("jira.cassette.mode", f.Jira.Cassette.Mode, string(cassette.ModeOff), string(cassette.ModeRecord), string(cassette.ModeReplay))
+
+	// A project key belongs to one connection only
+	owners := make(map[string]string)
+	defaultTenant := f.Jira.Tenant
+	if defaultTenant == "" {
+		defaultTenant = "default"
+	}
+	problems = append(problems, f.Jira.validate("jira", defaultTenant, owners)...)
+	tenantNames := map[string]bool{defaultTenant: true}
+	for i, t := range f.Tenants {
+		path := fmt.Sprintf("tenants[%d]", i)
+		name := strings.ToLower(t.Name)
+		switch {
+		case !tenantNamePattern.MatchString(name):
+			check(path+".name", fmt.Errorf("invalid tenant name %q, use lower-case letters, digits and dashes", t.Name))
+		case tenantNames[name]:
+			check(path+".name", fmt.Errorf("tenant %s is configured twice", name))
+		}
+		tenantNames[name] = true
+		if t.ProjectKey == "" && len(t.Projects) == 0 {
+			check(path+".projectKey", fmt.Errorf("required"))
+		}
+		problems = append(problems, t.validate(path, name, owners)...)
+	}
+
+	for i, method := range f.Auth.Methods {
+		oneOf(fmt.Sprintf("auth.methods[%d]", i), method, auth.MethodAPIKey, auth.MethodJWT)
+	}
+	for i, key := range f.Auth.APIKeys {
+		path := fmt.Sprintf("auth.apiKeys[%d]", i)
+		if key.Name == "" || strings.ContainsAny(key.Name, ":;") {
+			check(path+".name", fmt.Errorf("a name without : or ; is required"))
+			continue
+		}
+		if _, err := auth.ParseStaticKeys(key.Name + ":" + key.Hash + ":" + strings.Join(key.Scopes, ",")); err != nil {
+			check(path, err)
+		}
+	}
+	duration("auth.jwt.leeway", f.Auth.JWT.Leeway)
+	if f.Auth.RBAC && len(f.Auth.Methods) == 0 {
+		check("auth.rbac", fmt.Errorf("role-based access control requires auth.methods"))
+	}
+	if f.Secrets.Command != "" {
+		_, err := secrets.NewCommand(f.Secrets.Command)
+		check("secrets.command", err)
+	}
+	return problems
+}
+
+// validate checks the settings of a Jira connection and records the tenant
+// serving each of its projects in owners
+func (s connectionSettings) validate(path, tenant string, owners map[string]string) []string {
+	var problems []string
+	check := func(path string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+
+	if s.ProjectKey != "" && !projectKeyPattern.MatchString(strings.ToUpper(s.ProjectKey)) {
+		check(path+".projectKey", fmt.Errorf("invalid project key %q", s.ProjectKey))
+	}
+	if s.RateLimit < 0 {
+		check(path+".rateLimit", fmt.Errorf("must not be negative"))
+	}
+	if s.RateBurst < 0 {
+		check(path+".rateBurst", fmt.Errorf("must not be negative"))
+	}
+
+	claim := func(path, key string) {
+		if owner, ok := owners[key]; ok && owner != tenant {
+			check(path, fmt.Errorf("project %s is already served by tenant %s, a project key can only belong to one tenant", key, owner))
+			return
+		}
+		owners[key] = tenant
+	}
+	if key := strings.ToUpper(s.ProjectKey); projectKeyPattern.MatchString(key) {
+		claim(path+".projectKey", key)
+	}
+
+	seen := make(map[string]bool)
+	for i, project := range s.Projects {
+		projectPath := fmt.Sprintf("%s.projects[%d]", path, i)
+		key := strings.ToUpper(project.Key)
+		switch {
+		case key == "":
+			check(projectPath+".key", fmt.Errorf("required"))
+		case !projectKeyPattern.MatchString(key):
+			check(projectPath+".key", fmt.Errorf("invalid project key %q", project.Key))
+		case seen[key]:
+			check(projectPath+".key", fmt.Errorf("project %s is configured twice", key))
+		default:
+			claim(projectPath+".key", key)
+		}
+		seen[key] = true
+		for _, name := range sortedStringKeys(project.Fields) {
+			_, err := jira.ParseFieldMapping(name + "=" + project.Fields[name])
+			check(projectPath+".fields."+name, err)
+		}
+		for _, role := range sortedStringKeys(project.LinkTypes) {
+			_, err := jira.ParseLinkTypes(role + "=" + project.LinkTypes[role])
+			check(projectPath+".linkTypes."+role, err)
+		}
+		for _, status := range sortedStringKeys(project.Statuses) {
+			_, err := jira.ParseStatusMapping(status + "=" + project.Statuses[status])
+			check(projectPath+".statuses."+status, err)
+		}
+	}
+	return problems
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// settings returns the settings of the file by environment variable name
+func (f *configFile) settings() map[string]string {
+	s := make(map[string]string)
+	set := func(key, value string) {
+		if value != "" {
+			s[key] = value
+		}
+	}
+
+	set("BACKEND", f.Backend)
+	if f.Server.Port != 0 {
+		set("PORT", strconv.Itoa(f.Server.Port))
+	}
+	set("REPORT_TEMPLATE", f.Server.ReportTemplate)
+	set("CORS_ALLOWED_ORIGINS", strings.Join(f.Server.CORSAllowedOrigins, ","))
+	set("CONFIG_WATCH_INTERVAL", f.Server.ConfigWatchInterval)
+	set("HTTP_READ_TIMEOUT", f.Server.ReadTimeout)
+	set("HTTP_WRITE_TIMEOUT", f.Server.WriteTimeout)
+	set("HTTP_IDLE_TIMEOUT", f.Server.IdleTimeout)
+	set("SHUTDOWN_TIMEOUT", f.Server.ShutdownTimeout)
+	set("MAX_BODY_SIZE", f.Server.MaxBodySize)
+	set("MAX_UPLOAD_SIZE", f.Server.MaxUploadSize)
+	set("TLS_CERT_FILE", f.Server.TLS.CertFile)
+	set("TLS_KEY_FILE", f.Server.TLS.KeyFile)
+	set("TLS_CLIENT_CA_FILE", f.Server.TLS.ClientCAFile)
+	set("TLS_CLIENT_AUTH", f.Server.TLS.ClientAuth)
+	set("STORAGE_DRIVER", f.Storage.Driver)
+	set("STORAGE_PATH", f.Storage.Path)
+	set("SYNC_INTERVAL", f.Sync.Interval)
+	set("SYNC_RECONCILE_INTERVAL", f.Sync.ReconcileInterval)
+	set("SYNC_TIMEZONE", f.Sync.Timezone)
+	set("OUTBOX_INTERVAL", f.Outbox.Interval)
+	set("OUTBOX_MAX_BACKOFF", f.Outbox.MaxBackoff)
+	set("HEALTH_CHECK_TIMEOUT", f.Health.CheckTimeout)
+	set("HEALTH_SYNC_MAX_LAG", f.Health.SyncMaxLag)
+	if f.Health.OutboxMaxPending != nil {
+		set("HEALTH_OUTBOX_MAX_PENDING", strconv.Itoa(*f.Health.OutboxMaxPending))
+	}
+	if f.Metrics.Enabled != nil {
+		set("METRICS_ENABLED", strconv.FormatBool(*f.Metrics.Enabled))
+	}
+	set("TRACING_EXPORTER", f.Tracing.Exporter)
+	set("TRACING_ENDPOINT", f.Tracing.Endpoint)
+	if f.Tracing.SampleRatio != nil {
+		set("TRACING_SAMPLE_RATIO", strconv.FormatFloat(*f.Tracing.SampleRatio, 'f', -1, 64))
+	}
+
+	set("JIRA_TENANT", strings.ToLower(f.Jira.Tenant))
+	f.Jira.addSettings(s, "JIRA_")
+	set("JIRA_DELEGATION", f.Jira.Delegation)
+	set("JIRA_CLIENT_CACHE_TTL", f.Jira.ClientCacheTTL)
+	set("JIRA_VERIFY", f.Jira.Verify)
+	set("JIRA_CASSETTE_MODE", f.Jira.Cassette.Mode)
+	set("JIRA_CASSETTE_PATH", f.Jira.Cassette.Path)
+
+	var names []string
+	for _, t := range f.Tenants {
+		name := strings.ToLower(t.Name)
+		names = append(names, name)
+		t.addSettings(s, tenantEnvPrefix(name))
+	}
+	set("JIRA_TENANTS", strings.Join(names, ","))
+
+	set("AUTH_METHODS", strings.Join(f.Auth.Methods, ","))
+	var keys []string
+	for _, key := range f.Auth.APIKeys {
+		keys = append(keys, key.Name+":"+key.Hash+":"+strings.Join(key.Scopes, ","))
+	}
+	set("API_KEYS", strings.Join(keys, ";"))
+	set("JWT_JWKS_FILE", f.Auth.JWT.JWKSFile)
+	set("JWT_ISSUER", f.Auth.JWT.Issuer)
+	set("JWT_AUDIENCE", f.Auth.JWT.Audience)
+	set("JWT_SCOPE_CLAIM", f.Auth.JWT.ScopeClaim)
+	set("JWT_LEEWAY", f.Auth.JWT.Leeway)
+	if f.Auth.RBAC {
+		set("RBAC_ENABLED", "true")
+	}
+	set("KEYRING_FILE", f.Secrets.KeyringFile)
+	set("SECRETS_COMMAND", f.Secrets.Command)
+	return s
+}
+
+// addSettings adds the settings of a Jira connection under prefix. Without
+// a projectKey, the first project is the default one.
+func (c connectionSettings) addSettings(s map[string]string, prefix string) {
+	set := func(key, value string) {
+		if value != "" {
+			s[prefix+key] = value
+		}
+	}
+
+	set("BASE_URL", c.BaseURL)
+	set("USERNAME", c.Username)
+	set("API_TOKEN", c.APIToken)
+	set("ACCESS_TOKEN", c.AccessToken)
+	if c.RateLimit != 0 {
+		set("RATE_LIMIT", strconv.FormatFloat(c.RateLimit, 'f', -1, 64))
+	}
+	if c.RateBurst != 0 {
+		set("RATE_BURST", strconv.Itoa(c.RateBurst))
+	}
+
+	defaultKey := strings.ToUpper(c.ProjectKey)
+	if defaultKey == "" && len(c.Projects) > 0 {
+		defaultKey = strings.ToUpper(c.Projects[0].Key)
+	}
+	set("PROJECT_KEY", defaultKey)
+
+	var further []string
+	for _, project := range c.Projects {
+		key := strings.ToUpper(project.Key)
+		if key != defaultKey {
+			further = append(further, key)
+		}
+		projectPrefix := "PROJECT_" + key + "_"
+		set(projectPrefix+"TEST_ISSUE_TYPE", project.IssueTypes.Test)
+		set(projectPrefix+"EXECUTION_ISSUE_TYPE", project.IssueTypes.TestExecution)
+		set(projectPrefix+"TEST_PLAN_ISSUE_TYPE", project.IssueTypes.TestPlan)
+		set(projectPrefix+"REQUIREMENT_ISSUE_TYPES", strings.Join(project.IssueTypes.Requirements, ","))
+		set(projectPrefix+"DEFECT_ISSUE_TYPES", strings.Join(project.IssueTypes.Defects, ","))
+		set(projectPrefix+"FIELDS", jira.FieldMapping(project.Fields).String())
+		set(projectPrefix+"LINK_TYPES", formatSettingPairs(project.LinkTypes))
+		set(projectPrefix+"STATUSES", formatSettingPairs(project.Statuses))
+	}
+	set("PROJECTS", strings.Join(further, ","))
+}
+
+// formatSettingPairs writes a table of settings as sorted key=value pairs
+func formatSettingPairs(m map[string]string) string {
+	var pairs []string
+	for _, key := range sortedStringKeys(m) {
+		pairs = append(pairs, key+"="+m[key])
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/jira"
+)
+
+const testConfigYAML = `
+backend: jira
+server:
+  port: ${TEST_PORT:-9090}
+  corsAllowedOrigins: [https://qa.example.com]
+storage:
+  driver: memory
+sync:
+  interval: 10m
+health:
+  outboxMaxPending: 0
+metrics:
+  enabled: false
+tracing:
+  exporter: otlp
+  endpoint: http://localhost:4318
+  sampleRatio: 0.25
+jira:
+  baseUrl: https://example.atlassian.net
+  username: ci@example.com
+  apiToken: ${TEST_JIRA_TOKEN}
+  rateLimit: 2.5
+  verify: warn
+  projects:
+    - key: TEST
+      issueTypes:
+        test: QA Test
+        testExecution: Test Run
+        requirements: [Requirement, Epic]
+      linkTypes:
+        tests: Tests
+      statuses:
+        PASS: Passed
+        FAIL: Failed
+    - key: PAY
+      fields:
+        testType: customfield_10100
+tenants:
+  - name: dc
+    baseUrl: https://jira.internal.example.com
+    accessToken: pat
+    projectKey: DC
+auth:
+  methods: apikey
+  apiKeys:
+    - name: ci
+      hash: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+      scopes: [read, write]
+`
+
+// writeConfigFile writes a configuration file named name into a temporary directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadConfigFile(t *testing.T) {
+	t.Setenv("TEST_JIRA_TOKEN", "secret-token")
+
+	file, err := readConfigFile(writeConfigFile(t, "config.yaml", testConfigYAML), os.LookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := file.settings()
+	want := map[string]string{
+		"BACKEND":                                   "jira",
+		"PORT":                                      "9090",
+		"CORS_ALLOWED_ORIGINS":                      "https://qa.example.com",
+		"STORAGE_DRIVER":                            "memory",
+		"SYNC_INTERVAL":                             "10m",
+		"HEALTH_OUTBOX_MAX_PENDING":                 "0",
+		"METRICS_ENABLED":                           "false",
+		"TRACING_EXPORTER":                          "otlp",
+		"TRACING_ENDPOINT":                          "http://localhost:4318",
+		"TRACING_SAMPLE_RATIO":                      "0.25",
+		"JIRA_API_TOKEN":                            "secret-token",
+		"JIRA_RATE_LIMIT":                           "2.5",
+		"JIRA_PROJECT_KEY":                          "TEST",
+		"JIRA_PROJECTS":                             "PAY",
+		"JIRA_PROJECT_TEST_TEST_ISSUE_TYPE":         "QA Test",
+		"JIRA_PROJECT_TEST_EXECUTION_ISSUE_TYPE":    "Test Run",
+		"JIRA_PROJECT_TEST_REQUIREMENT_ISSUE_TYPES": "Requirement,Epic",
+		"JIRA_VERIFY":                               "warn",
+		"JIRA_PROJECT_TEST_LINK_TYPES":              "tests=Tests",
+		"JIRA_PROJECT_TEST_STATUSES":                "FAIL=Failed,PASS=Passed",
+		"JIRA_PROJECT_PAY_FIELDS":                   "testType=customfield_10100",
+		"JIRA_TENANTS":                              "dc",
+		"JIRA_TENANT_DC_ACCESS_TOKEN":               "pat",
+		"JIRA_TENANT_DC_PROJECT_KEY":                "DC",
+		"AUTH_METHODS":                              "apikey",
+		"API_KEYS":                                  "ci:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08:read,write",
+	}
+	for key, value := range want {
+		if settings[key] != value {
+			t.Errorf("%s: got %q, want %q", key, settings[key], value)
+		}
+	}
+}
+
+func TestReadConfigFileTOML(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+backend = "memory"
+
+[jira]
+projectKey = "TEST"
+
+[[jira.projects]]
+key = "PAY"
+issueTypes = { test = "QA Test" }
+
+[auth]
+rbac = false
+`)
+	file, err := readConfigFile(path, os.LookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := file.settings()
+	if settings["BACKEND"] != "memory" || settings["JIRA_PROJECTS"] != "PAY" || settings["JIRA_PROJECT_PAY_TEST_ISSUE_TYPE"] != "QA Test" {
+		t.Errorf("got settings %v", settings)
+	}
+}
+
+func TestReadConfigFileProblems(t *testing.T) {
+	path := writeConfigFile(t, "config.yml", `
+backend: jira
+server:
+  port: eighty
+  prot: 8080
+jira:
+  apiToken: ${UNSET_JIRA_TOKEN_FOR_TEST}
+`)
+	_, err := readConfigFile(path, os.LookupEnv)
+	var fileErr *configFileError
+	if !errors.As(err, &fileErr) {
+		t.Fatalf("got error %v, want the problems of the file", err)
+	}
+	for _, want := range []string{
+		"server.port: expected a whole number",
+		"server.prot: unknown setting",
+		"jira.apiToken: environment variable UNSET_JIRA_TOKEN_FOR_TEST is not set",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+
+	// Values are checked once the file decodes
+	path = writeConfigFile(t, "config.yml", `
+sync:
+  interval: often
+jira:
+  projects:
+    - key: PAY
+      fields:
+        testType: summary
+      statuses:
+        DONE: Closed
+tenants:
+  - name: dc
+    projectKey: pay
+auth:
+  rbac: true
+tracing:
+  exporter: jaeger
+  sampleRatio: 2
+`)
+	_, err = readConfigFile(path, os.LookupEnv)
+	for _, want := range []string{
+		"sync.interval: invalid duration",
+		"jira.projects[0].fields.testType: field testType must map to a custom field",
+		"jira.projects[0].statuses.DONE: unknown execution status",
+		"tenants[0].projectKey: project PAY is already served by tenant default",
+		"auth.rbac: role-based access control requires auth.methods",
+		`tracing.exporter: invalid value "jaeger", use none, otlp, stdout`,
+		"tracing.sampleRatio: invalid ratio 2",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestReadConfigFileProblemsHideVariables(t *testing.T) {
+	t.Setenv("TEST_SECRET_PORT", "s3cr3t-port")
+	t.Setenv("TEST_SECRET_INTERVAL", "s3cr3t-interval")
+	t.Setenv("TEST_SECRET_METHODS", "apikey,s3cr3t-method")
+	path := writeConfigFile(t, "config.yml", `
+server:
+  port: ${TEST_SECRET_PORT}
+`)
+	_, err := readConfigFile(path, os.LookupEnv)
+	if err == nil || !strings.Contains(err.Error(), `server.port: expected a whole number, got "${TEST_SECRET_PORT}"`) {
+		t.Errorf("got error %v, want the value as written", err)
+	}
+
+	// Values are checked once the file decodes
+	path = writeConfigFile(t, "config.yml", `
+sync:
+  interval: every ${TEST_SECRET_INTERVAL}
+auth:
+  methods: ${TEST_SECRET_METHODS}
+`)
+	_, err = readConfigFile(path, os.LookupEnv)
+	for _, want := range []string{
+		`sync.interval: invalid duration "every ${TEST_SECRET_INTERVAL}"`,
+		`auth.methods[1]: invalid value "${TEST_SECRET_METHODS}"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("missing problem %q in:\n%v", want, err)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("problems show the value of a variable:\n%v", err)
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	t.Setenv("TEST_HOST", "jira.example.com")
+	t.Setenv("TEST_EMPTY", "")
+	for value, want := range map[string]string{
+		"https://${TEST_HOST}/rest":  "https://jira.example.com/rest",
+		"${TEST_EMPTY:-fallback}":    "fallback",
+		"${TEST_EMPTY}":              "",
+		"${UNSET_FOR_TEST:-5m}":      "5m",
+		"price: $$5 and $HOME as is": "price: $5 and $HOME as is",
+	} {
+		got, err := expandVars(value, os.LookupEnv)
+		if err != nil || got != want {
+			t.Errorf("%q: got %q (%v), want %q", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"${UNSET_FOR_TEST}", "${TEST_HOST", "${}"} {
+		if _, err := expandVars(value, os.LookupEnv); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestEnvironmentOverridesConfigFile(t *testing.T) {
+	s := settings{
+		dotEnv: map[string]string{"JIRA_PROJECT_KEY": "DOTENV", "JIRA_BASE_URL": "https://dotenv.example.com", "PORT": ""},
+		file:   map[string]string{"JIRA_PROJECT_KEY": "FILE", "JIRA_BASE_URL": "https://file.example.com", "PORT": "9090"},
+	}
+	t.Setenv("JIRA_PROJECT_KEY", "ENV")
+
+	if got := s.getEnvOrDefault("JIRA_PROJECT_KEY", ""); got != "ENV" {
+		t.Errorf("got %s, want the environment variable", got)
+	}
+	if got := s.getEnvOrDefault("JIRA_BASE_URL", ""); got != "https://dotenv.example.com" {
+		t.Errorf("got %s, want the .env variable", got)
+	}
+	if got := s.getEnvOrDefault("PORT", "8080"); got != "9090" {
+		t.Errorf("got %s, want the file setting", got)
+	}
+	if got := s.getEnvOrDefault("STORAGE_DRIVER", "sqlite"); got != "sqlite" {
+		t.Errorf("got %s, want the default", got)
+	}
+	if _, set := os.LookupEnv("JIRA_BASE_URL"); set {
+		t.Error("settings of .env should not be set in the environment")
+	}
+}
+
+func TestValidateConfigCommand(t *testing.T) {
+	chdirWithoutDotEnv(t)
+	t.Setenv("TEST_JIRA_TOKEN", "secret-token")
+
+	var out bytes.Buffer
+	if code := validateConfigCommand(writeConfigFile(t, "config.yaml", testConfigYAML), &out); code != 0 {
+		t.Fatalf("got exit code %d: %s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "Jira tenant dc: projects DC") || strings.Contains(out.String(), "secret-token") {
+		t.Errorf("got output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if code := validateConfigCommand(writeConfigFile(t, "config.yaml", "backend: jira\n"), &out); code != 1 {
+		t.Fatalf("got exit code %d, want 1: %s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "JIRA_BASE_URL is required") {
+		t.Errorf("got output:\n%s", out.String())
+	}
+}
+
+func TestProjectLinkTypesAndStatuses(t *testing.T) {
+	t.Setenv("JIRA_PROJECT_TEST_LINK_TYPES", "tests=Tests, defects=Defect")
+	t.Setenv("JIRA_PROJECT_TEST_STATUSES", "pass=Passed,FAIL=Failed")
+	projects, err := (settings{}).loadProjects("TEST", "JIRA_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := projects[0]
+	if project.LinkTypes != (jira.LinkTypes{Tests: "Tests", Defects: "Defect"}) {
+		t.Errorf("got link types %+v", project.LinkTypes)
+	}
+	if project.Statuses.ExecutionStatus("passed") != jira.StatusPass {
+		t.Errorf("got statuses %v", project.Statuses)
+	}
+
+	t.Setenv("JIRA_PROJECT_TEST_TEST_PLAN_ISSUE_TYPE", "Regression Plan")
+	t.Setenv("JIRA_PROJECT_TEST_REQUIREMENT_ISSUE_TYPES", "Requirement, Epic")
+	projects, err = (settings{}).loadProjects("TEST", "JIRA_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := projects[0].IssueTypes
+	if types.TestPlan != "Regression Plan" || strings.Join(types.Requirements, ",") != "Requirement,Epic" || strings.Join(types.Defects, ",") != "Bug,Defect" {
+		t.Errorf("got issue types %+v", types)
+	}
+
+	t.Setenv("JIRA_PROJECT_TEST_LINK_TYPES", "blocks=Blocks")
+	if _, err := (settings{}).loadProjects("TEST", "JIRA_"); err == nil {
+		t.Error("expected an error for an unknown link role")
+	}
+}
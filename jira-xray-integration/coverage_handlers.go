@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/requirements"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Get the coverage status of a single requirement
+func getRequirementCoverage(c *gin.Context) {
+	key := c.Param("key")
+	log.Printf("Handling GET /api/requirements/%s/coverage request", key)
+
+	if !jira.IsIssueKey(key) {
+		rejectInvalidIssueKey(c, key)
+		return
+	}
+	scope, err := scopeFromQuery(c)
+	if err != nil {
+		rejectInvalidScope(c, err)
+		return
+	}
+
+	// Only issues of the project's requirement types have coverage; any
+	// other issue is not found as a requirement
+	types := requestProjectConfig(c).IssueTypes.WithDefaults().Requirements
+	jql, err := requirementsJQL(c, fmt.Sprintf("key = %s AND issuetype in (%s)", key, jqlList(types)))
+	if err != nil {
+		rejectInvalidIssueKey(c, key)
+		return
+	}
+
+	coverage, err := newRequirementsBuilder(c).Coverage(jql, scope)
+	if err != nil {
+		log.Printf("Error computing coverage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute requirement coverage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(coverage) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("Requirement %s not found", key),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"coverage": coverage[0],
+		"scope":    scope,
+		"message":  "Requirement coverage computed successfully",
+	})
+}
+
+// Get the coverage status of many requirements, selected by keys or JQL
+func getRequirementsCoverage(c *gin.Context) {
+	log.Println("Handling GET /api/requirements/coverage request")
+
+	jql := c.Query("jql")
+	if keys := c.Query("keys"); keys != "" {
+		if jql != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Use either keys or jql, not both",
+			})
+			return
+		}
+		var list []string
+		for _, key := range strings.Split(keys, ",") {
+			if key = strings.TrimSpace(key); key == "" {
+				continue
+			}
+			if !jira.IsIssueKey(key) {
+				rejectInvalidIssueKey(c, key)
+				return
+			}
+			list = append(list, key)
+		}
+		jql = fmt.Sprintf("key in (%s)", strings.Join(list, ","))
+	}
+	scope, err := scopeFromQuery(c)
+	if err != nil {
+		rejectInvalidScope(c, err)
+		return
+	}
+	jql, err = requirementsJQL(c, jql)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid JQL",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	coverage, err := newRequirementsBuilder(c).Coverage(jql, scope)
+	if err != nil {
+		log.Printf("Error computing coverage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute requirement coverage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"coverage": coverage,
+		"summary":  requirements.Summarize(coverage),
+		"scope":    scope,
+		"jql":      jql,
+		"message":  "Requirement coverage computed successfully",
+	})
+}
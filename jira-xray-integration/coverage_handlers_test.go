@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequirementCoverage(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "one requirement",
+			setup:  recordPassAndFail,
+			method: http.MethodGet, path: "/api/requirements/TEST-4/coverage",
+			status: http.StatusOK,
+			golden: "coverage_one",
+		},
+		{
+			name:   "unknown requirement",
+			method: http.MethodGet, path: "/api/requirements/TEST-99/coverage",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "test case as a requirement",
+			method: http.MethodGet, path: "/api/requirements/TEST-1/coverage",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "execution as a requirement",
+			method: http.MethodGet, path: "/api/requirements/TEST-9/coverage",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "many by key",
+			setup:  recordPassAndFail,
+			method: http.MethodGet, path: "/api/requirements/coverage?keys=TEST-4,TEST-5",
+			status: http.StatusOK,
+			golden: "coverage_many",
+		},
+		{
+			name:   "all stories",
+			method: http.MethodGet, path: "/api/requirements/coverage",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if coverage, _ := decodeBody(t, rec)["coverage"].([]interface{}); len(coverage) != 3 {
+					t.Errorf("got coverage for %d requirements, want 3", len(coverage))
+				}
+			},
+		},
+		{
+			name:   "requirement key with JQL",
+			method: http.MethodGet, path: "/api/requirements/TEST-4+OR+project+%3D+PAY/coverage",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "keys with JQL",
+			method: http.MethodGet, path: "/api/requirements/coverage?keys=TEST-4,TEST-5)+OR+(project+%3D+PAY",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "test plan with JQL",
+			method: http.MethodGet, path: "/api/requirements/TEST-4/coverage?testPlan=TEST-8+OR+project+%3D+PAY",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "keys and jql",
+			method: http.MethodGet, path: "/api/requirements/coverage?keys=TEST-4&jql=issuetype+%3D+Story",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Jira unavailable",
+			setup:  func(t *testing.T, env *testEnv) { env.jira.FailNext(1, http.StatusServiceUnavailable) },
+			method: http.MethodGet, path: "/api/requirements/coverage?keys=TEST-4",
+			status: http.StatusInternalServerError,
+		},
+	})
+}
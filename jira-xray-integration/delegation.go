@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"jira-xray-integration/jira"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Jira delegation modes
+const (
+	delegationOff      = "off"      // every Jira request uses the service account
+	delegationOptional = "optional" // callers may send their own Jira credentials
+	delegationRequired = "required" // requests that reach Jira must carry the caller's credentials
+)
+
+// Headers callers send their own Jira credentials in. X-Jira-Token with
+// X-Jira-User is an API token used with basic auth; X-Jira-Token alone is an
+// OAuth 2.0 access token or personal access token sent as a bearer token.
+const (
+	jiraUserHeader  = "X-Jira-User"
+	jiraTokenHeader = "X-Jira-Token"
+)
+
+// jiraClientCacheSize is the most callers whose Jira clients a tenant keeps
+// at once
+const jiraClientCacheSize = 1000
+
+// delegatedBackendKey holds the client with the caller's credentials in the gin context
+const delegatedBackendKey = "jiraBackend"
+
+// newJiraClientCache creates the cache of per-caller clients of a tenant. They
+// share the transport of the service account client, so cassettes record them
+// too and they count against the tenant's rate limit.
+func newJiraClientCache(c *Config, t TenantConfig, service jira.TestManagementBackend) *jira.ClientCache {
+	if c.JiraDelegation == delegationOff || c.JiraDelegation == "" {
+		return nil
+	}
+	var transport http.RoundTripper
+	if client, ok := service.(*jira.Client); ok {
+		transport = client.HTTPClient.Transport
+	}
+	return jira.NewClientCache(c.JiraClientCacheTTL, jiraClientCacheSize, func(creds jira.Credentials) *jira.Client {
+		client := jira.NewClient(t.BaseURL, creds.Username, creds.APIToken, t.ProjectKey)
+		client.AccessToken = creds.AccessToken
+		client.HTTPClient.Transport = transport
+		return client
+	})
+}
+
+// delegateJira picks up Jira credentials sent by the caller so the request acts
+// in the tenant's Jira as the caller instead of the service account. With
+// delegation required, requests to routes that use Jira are rejected without them.
+func delegateJira() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := requestTenant(c)
+		if t.jiraClients == nil {
+			c.Next()
+			return
+		}
+
+		creds, ok := jiraCredentials(c)
+		if !ok {
+			if currentConfig().JiraDelegation == delegationRequired && usesJira(c.FullPath()) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error":   "Jira credentials required",
+					"details": "send your Jira API token in the " + jiraTokenHeader + " header, with your Jira email in " + jiraUserHeader + ", or an access token in " + jiraTokenHeader + " alone",
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		log.Printf("Acting in Jira %s as %s for %s %s", t.Name, creds.User(), c.Request.Method, c.FullPath())
+		c.Set(delegatedBackendKey, t.jiraClients.Get(creds))
+		c.Next()
+	}
+}
+
+func jiraCredentials(c *gin.Context) (jira.Credentials, bool) {
+	token := strings.TrimSpace(c.GetHeader(jiraTokenHeader))
+	if token == "" {
+		return jira.Credentials{}, false
+	}
+	if user := strings.TrimSpace(c.GetHeader(jiraUserHeader)); user != "" {
+		return jira.Credentials{Username: user, APIToken: token}, true
+	}
+	return jira.Credentials{AccessToken: token}, true
+}
+
+// usesJira reports whether a route talks to Jira on behalf of the caller.
+// Health, info, auth and admin routes do not, and sync and outbox replays
+// always run as the service account.
+func usesJira(route string) bool {
+	if route == "/api/projects" || route == "/api/tenants" {
+		return false
+	}
+	for _, prefix := range []string{"/api/health", "/api/info", "/api/auth/", "/api/admin/", "/api/sync", "/api/outbox"} {
+		if strings.HasPrefix(route, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// jiraFor returns the backend a request talks to Jira with for its project
+func jiraFor(c *gin.Context) jira.TestManagementBackend {
+	return jiraForProject(c, requestProject(c))
+}
+
+// jiraForProject returns the backend a request talks to a project with: a
+// client with the caller's credentials when they were sent, otherwise the
+// service account
+func jiraForProject(c *gin.Context, project string) jira.TestManagementBackend {
+	t := requestTenant(c)
+	if value, ok := c.Get(delegatedBackendKey); ok {
+		client := value.(*jira.Client).WithContext(c.Request.Context())
+		if settings, ok := t.Project(project); ok {
+			return client.ForProject(settings)
+		}
+		return client
+	}
+	return withContext(c.Request.Context(), t.backendFor(project))
+}
+
+// withContext binds a Jira client to ctx, so that its requests to Jira are
+// traced as part of ctx. Other backends are returned as they are.
+func withContext(ctx context.Context, backend jira.TestManagementBackend) jira.TestManagementBackend {
+	if client, ok := backend.(*jira.Client); ok {
+		return client.WithContext(ctx)
+	}
+	return backend
+}
+
+// delegated reports whether a request acts in Jira with the caller's credentials
+func delegated(c *gin.Context) bool {
+	_, ok := c.Get(delegatedBackendKey)
+	return ok
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"jira-xray-integration/jiratest"
+)
+
+// Jira accounts callers act as in the delegation tests
+const (
+	aliceUser   = "alice@example.com"
+	aliceToken  = "alice-api-token"
+	bobUser     = "bob@example.com"
+	bobOAuthKey = "bob-oauth-token"
+)
+
+// enableDelegation lets callers act in the fake Jira as alice, with an API
+// token, or as bob, with an OAuth access token
+func enableDelegation(mode string) func(t *testing.T, env *testEnv) {
+	return func(t *testing.T, env *testEnv) {
+		env.jira.Users[aliceUser] = aliceToken
+		env.jira.AccessTokens[bobOAuthKey] = bobUser
+		currentConfig().JiraDelegation = mode
+		currentConfig().JiraClientCacheTTL = time.Minute
+		env.reload(t)
+		env.jira.ResetRequests()
+	}
+}
+
+// expectJiraUser checks that every request that reached Jira was made as user
+func expectJiraUser(user string) func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+		requests := env.jira.Requests()
+		if len(requests) == 0 {
+			t.Fatal("no request reached Jira")
+		}
+		for _, r := range requests {
+			if r.User != user {
+				t.Errorf("%s %s was made as %q, want %q", r.Method, r.Path, r.User, user)
+			}
+		}
+	}
+}
+
+func TestJiraDelegation(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "caller API token",
+			setup:  enableDelegation(delegationOptional),
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Login works"}`,
+			headers: []string{jiraUserHeader, aliceUser, jiraTokenHeader, aliceToken},
+			status:  http.StatusCreated,
+			check:   expectJiraUser(aliceUser),
+		},
+		{
+			name:   "caller access token",
+			setup:  enableDelegation(delegationOptional),
+			method: http.MethodGet, path: "/api/testcases/TEST-1",
+			headers: []string{jiraTokenHeader, bobOAuthKey},
+			status:  http.StatusOK,
+			check:   expectJiraUser(bobUser),
+		},
+		{
+			name:   "service account without caller credentials",
+			setup:  enableDelegation(delegationOptional),
+			method: http.MethodGet, path: "/api/testcases",
+			status: http.StatusOK,
+			check:  expectJiraUser(jiratest.Username),
+		},
+		{
+			name:   "caller credentials ignored when delegation is off",
+			setup:  enableDelegation(delegationOff),
+			method: http.MethodGet, path: "/api/testcases",
+			headers: []string{jiraTokenHeader, bobOAuthKey},
+			status:  http.StatusOK,
+			check:   expectJiraUser(jiratest.Username),
+		},
+		{
+			name:   "caller credentials required",
+			setup:  enableDelegation(delegationRequired),
+			method: http.MethodGet, path: "/api/testcases",
+			status: http.StatusUnauthorized,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if n := len(env.jira.Requests()); n != 0 {
+					t.Errorf("%d requests reached Jira", n)
+				}
+			},
+		},
+		{
+			name:   "health needs no caller credentials",
+			setup:  enableDelegation(delegationRequired),
+			method: http.MethodGet, path: "/api/health",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Jira rejects the caller",
+			setup:  enableDelegation(delegationRequired),
+			method: http.MethodGet, path: "/api/testcases/TEST-1",
+			headers: []string{jiraUserHeader, aliceUser, jiraTokenHeader, "wrong"},
+			status:  http.StatusInternalServerError,
+		},
+		{
+			name: "caller writes are not queued",
+			setup: func(t *testing.T, env *testEnv) {
+				enableDelegation(delegationOptional)(t, env)
+				env.jira.InjectFault(jiratestFault(http.MethodPost, "/issue", http.StatusServiceUnavailable))
+			},
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Login works"}`,
+			headers: []string{jiraUserHeader, aliceUser, jiraTokenHeader, aliceToken},
+			status:  http.StatusInternalServerError,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if entries, _ := resultStore.ListOutbox(""); len(entries) != 0 {
+					t.Errorf("got %d outbox entries", len(entries))
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health of a component and of the service as a whole. Any component down
+// makes the service unready; degraded components are reported but still
+// serve requests.
+const (
+	healthUp       = "up"
+	healthDegraded = "degraded"
+	healthDown     = "down"
+)
+
+// healthCacheTTL is how long a readiness report is reused, so frequent probes
+// from several load balancers do not each call Jira
+const healthCacheTTL = 5 * time.Second
+
+// componentHealth is the result of checking one dependency
+type componentHealth struct {
+	Component string `json:"component"`
+	Tenant    string `json:"tenant,omitempty"`
+	Status    string `json:"status"`
+	Details   string `json:"details,omitempty"`
+	Latency   string `json:"latency,omitempty"` // how long the check took
+}
+
+// healthReport is the readiness of the service and its components
+type healthReport struct {
+	Status     string            `json:"status"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Components []componentHealth `json:"components"`
+}
+
+// readiness caches the last readiness report of a server state
+var readiness struct {
+	sync.Mutex
+	state   *serverState
+	checked time.Time
+	report  *healthReport
+}
+
+// startedAt is when the process started, for the liveness check
+var startedAt = time.Now()
+
+// Liveness: the process is running and serving requests. It checks no
+// dependencies, so an outage of Jira never gets the service restarted.
+func healthLive(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    healthUp,
+		"timestamp": time.Now().UTC(),
+		"uptime":    time.Since(startedAt).Round(time.Second).String(),
+	})
+}
+
+// Readiness: Jira, storage, sync and outbox checked, with 503 while a
+// component is down
+func healthReady(c *gin.Context) {
+	report := checkReadiness(freshHealthCheck(c))
+	c.JSON(report.httpStatus(), report)
+}
+
+// Health check endpoint: readiness with the Jira connection in use
+func healthCheck(c *gin.Context) {
+	config := currentConfig()
+	report := checkReadiness(freshHealthCheck(c))
+	c.JSON(report.httpStatus(), gin.H{
+		"status":     report.Status,
+		"timestamp":  report.Timestamp,
+		"components": report.Components,
+		"jira": gin.H{
+			"backend":     config.Backend,
+			"base_url":    redactURL(config.JiraBaseURL),
+			"project_key": config.JiraProjectKey,
+			"demo_mode":   config.Backend == "memory",
+		},
+	})
+}
+
+// freshHealthCheck reports whether a health request asks for a new check
+// rather than the cached report. Only authenticated callers may ask, so
+// anonymous probes cannot make the service call Jira on every request.
+func freshHealthCheck(c *gin.Context) bool {
+	if c.Query("fresh") != "true" {
+		return false
+	}
+	authenticator := currentAuthenticator()
+	if authenticator == nil {
+		return false
+	}
+	_, err := authenticator.Authenticate(c.Request)
+	return err == nil
+}
+
+// httpStatus is 503 when the service is not ready
+func (r *healthReport) httpStatus() int {
+	if r.Status == healthDown {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// checkReadiness returns the readiness report, checked again if the cached
+// one is older than healthCacheTTL, the configuration was reloaded since, or
+// fresh is set
+func checkReadiness(fresh bool) *healthReport {
+	readiness.Lock()
+	defer readiness.Unlock()
+	current := state.Load()
+	if !fresh && readiness.state == current && time.Since(readiness.checked) < healthCacheTTL {
+		return readiness.report
+	}
+
+	report := checkComponents(current)
+	readiness.state, readiness.checked, readiness.report = current, time.Now(), report
+	return report
+}
+
+// componentCheck checks one dependency and returns its status and details
+type componentCheck struct {
+	component, tenant string
+	check             func() (status, details string)
+	timeoutStatus     string // status when the check does not answer in time, down if empty
+}
+
+// checkComponents checks every dependency of a server state in parallel
+func checkComponents(s *serverState) *healthReport {
+	var checks []componentCheck
+	for _, t := range s.tenants {
+		t := t
+		checks = append(checks, componentCheck{"jira", t.Name, func() (string, string) { return checkJira(t) }, jiraOutageStatus()})
+	}
+	checks = append(checks, componentCheck{"storage", "", checkStorage, ""})
+	for _, t := range s.tenants {
+		t := t
+		checks = append(checks, componentCheck{"sync", t.Name, func() (string, string) { return checkSync(t, s.config.HealthSyncMaxLag) }, ""})
+	}
+	checks = append(checks, componentCheck{"outbox", "", func() (string, string) { return checkOutbox(s.config.HealthOutboxMaxPending) }, ""})
+
+	report := &healthReport{Status: healthUp, Timestamp: time.Now().UTC(), Components: make([]componentHealth, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check componentCheck) {
+			defer wg.Done()
+			report.Components[i] = check.run(s.config.HealthCheckTimeout)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		switch {
+		case component.Status == healthDown:
+			report.Status = healthDown
+			log.Printf("⚠️  Health: %s %s is down: %s", component.Component, component.Tenant, component.Details)
+		case component.Status == healthDegraded && report.Status == healthUp:
+			report.Status = healthDegraded
+		}
+	}
+	return report
+}
+
+// run runs the check, giving up after timeout, and records its latency
+func (c componentCheck) run(timeout time.Duration) componentHealth {
+	result := componentHealth{Component: c.component, Tenant: c.tenant}
+	started := time.Now()
+	type outcome struct{ status, details string }
+	done := make(chan outcome, 1)
+	go func() {
+		status, details := c.check()
+		done <- outcome{status, details}
+	}()
+
+	select {
+	case o := <-done:
+		result.Status, result.Details = o.status, o.details
+	case <-time.After(timeout):
+		// The check goes on in the background and its result is dropped
+		result.Status, result.Details = healthDown, fmt.Sprintf("no answer within %s", timeout)
+		if c.timeoutStatus != "" {
+			result.Status = c.timeoutStatus
+		}
+	}
+	result.Latency = time.Since(started).Round(time.Millisecond).String()
+	return result
+}
+
+// checkJira checks that the tenant's Jira is reachable and accepts the
+// service account's credentials. The report is public, so Jira's error and
+// the service account are only logged.
+func checkJira(t *tenant) (string, string) {
+	client, ok := t.backend.(*jira.Client)
+	if !ok {
+		return healthUp, "in-memory backend"
+	}
+	_, err := client.Myself()
+	switch {
+	case jira.IsUnauthorized(err):
+		log.Printf("⚠️  Health: Jira of tenant %s rejected the credentials: %v", t.Name, err)
+		return healthDown, "credentials rejected"
+	case err != nil:
+		log.Printf("⚠️  Health: Jira of tenant %s is unreachable: %v", t.Name, err)
+		return jiraOutageStatus(), "unreachable"
+	}
+	return healthUp, "authenticated"
+}
+
+// jiraOutageStatus is the status of a Jira that is unreachable or too slow:
+// degraded while the outbox queues writes for it, otherwise down
+func jiraOutageStatus() string {
+	if outboxWorker.Enabled() {
+		return healthDegraded
+	}
+	return healthDown
+}
+
+// checkStorage checks that the result store can be read
+func checkStorage() (string, string) {
+	if err := resultStore.Ping(); err != nil {
+		return healthDown, err.Error()
+	}
+	return healthUp, currentConfig().StorageDriver
+}
+
+// checkSync reports how far the tenant's issue cache lags behind Jira. A
+// cache older than maxLag, or a failing sync, is degraded: reads fall back to
+// Jira or serve older data, but requests still work.
+func checkSync(t *tenant, maxLag time.Duration) (string, string) {
+	status, err := t.syncEngine.Status()
+	if err != nil {
+		return healthDegraded, err.Error()
+	}
+	if !status.Enabled {
+		return healthUp, "disabled"
+	}
+
+	if status.LastError != "" {
+		return healthDegraded, "last sync failed: " + status.LastError
+	}
+	var oldest time.Time
+	for _, s := range status.IssueTypes {
+		if s.LastSync.IsZero() {
+			oldest = time.Time{}
+			break
+		}
+		if oldest.IsZero() || s.LastSync.Before(oldest) {
+			oldest = s.LastSync
+		}
+	}
+	if oldest.IsZero() {
+		return healthUp, "first sync pending"
+	}
+	lag := time.Since(oldest)
+	if maxLag > 0 && lag > maxLag {
+		return healthDegraded, fmt.Sprintf("lag %s, more than %s", lag.Round(time.Second), maxLag)
+	}
+	return healthUp, fmt.Sprintf("lag %s", lag.Round(time.Second))
+}
+
+// checkOutbox reports the writes waiting for Jira. More than maxPending, or
+// writes that failed for good, is degraded.
+func checkOutbox(maxPending int) (string, string) {
+	if !outboxWorker.Enabled() {
+		return healthUp, "disabled"
+	}
+	pending, err := resultStore.ListOutbox(store.OutboxPending)
+	if err != nil {
+		return healthDown, err.Error()
+	}
+	failed, err := resultStore.ListOutbox(store.OutboxFailed)
+	if err != nil {
+		return healthDown, err.Error()
+	}
+
+	details := fmt.Sprintf("%d pending, %d failed", len(pending), len(failed))
+	if (maxPending > 0 && len(pending) > maxPending) || len(failed) > 0 {
+		return healthDegraded, details
+	}
+	return healthUp, details
+}
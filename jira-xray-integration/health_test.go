@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/jiratest"
+	"jira-xray-integration/outbox"
+	"jira-xray-integration/store"
+)
+
+// readyComponents requests a new readiness report and returns its status
+// code and the status of each component by name
+func readyComponents(t *testing.T, env *testEnv) (int, map[string]string, map[string]interface{}) {
+	t.Helper()
+	expireReadiness()
+	rec := env.do(http.MethodGet, "/api/health/ready", "")
+	body := decodeBody(t, rec)
+	components := make(map[string]string)
+	for _, item := range body["components"].([]interface{}) {
+		c := item.(map[string]interface{})
+		components[c["component"].(string)] = c["status"].(string) + ": " + c["details"].(string)
+	}
+	return rec.Code, components, body
+}
+
+// expireReadiness drops the cached readiness report
+func expireReadiness() {
+	readiness.Lock()
+	defer readiness.Unlock()
+	readiness.state, readiness.report = nil, nil
+}
+
+func TestHealthReady(t *testing.T) {
+	env := newTestEnv(t)
+
+	code, components, body := readyComponents(t, env)
+	if code != http.StatusOK || body["status"] != healthUp {
+		t.Fatalf("got status %d %v: %v", code, body["status"], components)
+	}
+	if got := components["jira"]; got != "up: authenticated" {
+		t.Errorf("got jira %q", got)
+	}
+
+	// Rejected credentials make the service unready
+	env.jira.InjectFault(jiratest.Fault{Path: "/myself", Status: http.StatusUnauthorized})
+	code, components, body = readyComponents(t, env)
+	if code != http.StatusServiceUnavailable || body["status"] != healthDown {
+		t.Errorf("got status %d %v, want 503 down", code, body["status"])
+	}
+	if got := components["jira"]; got != "down: credentials rejected" {
+		t.Errorf("got jira %q, Jira's error should only be logged", got)
+	}
+	if got := components["storage"]; !strings.HasPrefix(got, healthUp) {
+		t.Errorf("other components should still be checked, got storage %q", got)
+	}
+
+	// An unreachable Jira leaves the service ready while the outbox queues
+	// writes for it, and makes it unready otherwise
+	env.jira.ClearFaults()
+	env.jira.InjectFault(jiratest.Fault{Path: "/myself", Status: http.StatusServiceUnavailable})
+	code, components, _ = readyComponents(t, env)
+	if code != http.StatusOK || components["jira"] != "degraded: unreachable" {
+		t.Errorf("got status %d and jira %q with the outbox enabled, want 200 degraded", code, components["jira"])
+	}
+	worker := outboxWorker
+	outboxWorker = outbox.NewWorker(resultStore, outboxHandlers(), outbox.Options{})
+	code, components, _ = readyComponents(t, env)
+	outboxWorker = worker
+	if code != http.StatusServiceUnavailable || components["jira"] != "down: unreachable" {
+		t.Errorf("got status %d and jira %q with the outbox disabled, want 503 down", code, components["jira"])
+	}
+
+	// So is a Jira slower than HEALTH_CHECK_TIMEOUT
+	env.jira.ClearFaults()
+	env.jira.InjectFault(jiratest.Fault{Path: "/myself", Latency: 200 * time.Millisecond, Times: 1})
+	currentConfig().HealthCheckTimeout = 20 * time.Millisecond
+	if _, components, _ = readyComponents(t, env); !strings.HasPrefix(components["jira"], "degraded: no answer within") {
+		t.Errorf("got jira %q", components["jira"])
+	}
+	currentConfig().HealthCheckTimeout = 5 * time.Second
+
+	// Writes that failed for good leave the service ready but degraded
+	entry, _, err := resultStore.EnqueueOutbox(store.OutboxEntry{IdempotencyKey: "k1", Operation: "createTestCase"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Status = store.OutboxFailed
+	if err := resultStore.UpdateOutboxEntry(*entry); err != nil {
+		t.Fatal(err)
+	}
+	code, components, body = readyComponents(t, env)
+	if code != http.StatusOK || body["status"] != healthDegraded {
+		t.Errorf("got status %d %v, want 200 degraded", code, body["status"])
+	}
+	if got := components["outbox"]; got != "degraded: 0 pending, 1 failed" {
+		t.Errorf("got outbox %q", got)
+	}
+}
+
+func TestHealthReadyCache(t *testing.T) {
+	env := newTestEnv(t)
+	if rec := env.do(http.MethodGet, "/api/health/ready", ""); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+
+	// Probes within healthCacheTTL reuse the report, unless the
+	// configuration was reloaded or a fresh check is asked for
+	env.jira.InjectFault(jiratest.Fault{Path: "/myself", Status: http.StatusUnauthorized})
+	if rec := env.do(http.MethodGet, "/api/health/ready", ""); rec.Code != http.StatusOK {
+		t.Errorf("got status %d, want the cached report", rec.Code)
+	}
+	env.reload(t)
+	if rec := env.do(http.MethodGet, "/api/health/ready", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d after a reload, want a new check", rec.Code)
+	}
+
+	// Only authenticated callers can ask for a fresh check
+	env.jira.ClearFaults()
+	enableAuth(t, env)
+	if rec := env.do(http.MethodGet, "/api/health/ready", ""); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	env.jira.InjectFault(jiratest.Fault{Path: "/myself", Status: http.StatusUnauthorized})
+	if rec := env.do(http.MethodGet, "/api/health/ready?fresh=true", ""); rec.Code != http.StatusOK {
+		t.Errorf("got status %d for an anonymous fresh check, want the cached report", rec.Code)
+	}
+	if rec := env.do(http.MethodGet, "/api/health/ready?fresh=true", "", auth.APIKeyHeader, readerKey); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d for an authenticated fresh check, want a new check", rec.Code)
+	}
+}
+
+func TestHealthLive(t *testing.T) {
+	env := newTestEnv(t)
+	env.jira.InjectFault(jiratest.Fault{Status: http.StatusUnauthorized})
+
+	// Liveness does not depend on Jira
+	rec := env.do(http.MethodGet, "/api/health/live", "")
+	if rec.Code != http.StatusOK || decodeBody(t, rec)["status"] != healthUp {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCheckSync(t *testing.T) {
+	env := newTestEnv(t)
+	env.setSyncInterval(t, time.Minute)
+	tenant := defaultTenant()
+
+	if status, details := checkSync(tenant, time.Minute); status != healthUp || details != "first sync pending" {
+		t.Errorf("before the first sync got %s %q", status, details)
+	}
+	if _, err := tenant.syncEngine.Sync(false); err != nil {
+		t.Fatal(err)
+	}
+	if status, details := checkSync(tenant, time.Minute); status != healthUp || !strings.HasPrefix(details, "lag ") {
+		t.Errorf("after a sync got %s %q", status, details)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if status, details := checkSync(tenant, time.Nanosecond); status != healthDegraded || !strings.Contains(details, "more than") {
+		t.Errorf("with a lag over the limit got %s %q", status, details)
+	}
+}
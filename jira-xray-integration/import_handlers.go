@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"jira-xray-integration/importer"
+	"jira-xray-integration/jira"
+	"jira-xray-integration/metrics"
+	"jira-xray-integration/outbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sources of imports, as named in metrics
+const (
+	importSourceTestCases = "testcases"
+	importSourceGoTest    = "gotest"
+)
+
+// observeImport records the duration of an import and how many records had
+// each outcome
+func observeImport(source string, started time.Time, outcomes map[string]int) {
+	metrics.ImportDuration.WithLabelValues(source).Observe(time.Since(started).Seconds())
+	for outcome, n := range outcomes {
+		metrics.ImportedRecords.WithLabelValues(source, outcome).Add(float64(n))
+	}
+}
+
+// Import go test -json results as a new test execution
+func importGoTestResults(c *gin.Context) {
+	log.Println("Handling POST /api/import/gotest request")
+	started := time.Now()
+
+	packages, err := importer.ParseGoTestJSON(c.Request.Body)
+	if err != nil {
+		log.Printf("Error parsing go test output: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid go test -json stream",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(packages) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No test events found in request body",
+		})
+		return
+	}
+
+	testCases, err := jiraFor(c).ListTestCases()
+	if err != nil {
+		log.Printf("Error fetching test cases: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch test cases",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	mapping := importer.MapGoTestResults(packages, testCases, c.Query("executedBy"))
+	if len(mapping.Results) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":    "No go tests could be mapped to test cases",
+			"unmapped": mapping.Unmapped,
+		})
+		return
+	}
+
+	summary := c.Query("summary")
+	if summary == "" {
+		summary = fmt.Sprintf("go test run %s", time.Now().Format("2006-01-02 15:04"))
+	}
+
+	testExecution := jira.TestExecution{
+		Summary:         summary,
+		Description:     c.Query("description"),
+		Environment:     c.Query("environment"),
+		ExecutedBy:      c.Query("executedBy"),
+		ExecutionStatus: mapping.ExecutionStatus(),
+		TestResults:     mapping.Results,
+	}
+	for _, result := range mapping.Results {
+		testExecution.TestCases = append(testExecution.TestCases, result.TestCaseKey)
+	}
+
+	if respondWithQueuedWrite(c, outbox.OpCreateTestExecution, "", testExecution) {
+		return
+	}
+
+	createdTestExecution, err := jiraFor(c).CreateTestExecution(&testExecution)
+	if err != nil {
+		if queueWrite(c, outbox.OpCreateTestExecution, "", testExecution, err, "go test results") {
+			observeImport(importSourceGoTest, started, map[string]int{"queued": len(mapping.Results), "unmapped": len(mapping.Unmapped)})
+			return
+		}
+		log.Printf("Error creating test execution: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create test execution",
+			"details": err.Error(),
+		})
+		return
+	}
+	createdTestExecution.ExecutionStatus = testExecution.ExecutionStatus
+
+	if err := saveTestExecution(requestTenant(c), createdTestExecution); err != nil {
+		log.Printf("Error storing test execution: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":         "Test execution was created in Jira but its results could not be stored",
+			"details":       err.Error(),
+			"testExecution": createdTestExecution,
+		})
+		return
+	}
+
+	requestTenant(c).syncEngine.Trigger()
+	observeImport(importSourceGoTest, started, map[string]int{"recorded": len(mapping.Results), "unmapped": len(mapping.Unmapped)})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"testExecution": createdTestExecution,
+		"packages":      packages,
+		"mapped":        len(mapping.Results),
+		"unmapped":      mapping.Unmapped,
+		"message":       "Go test results imported successfully",
+	})
+}
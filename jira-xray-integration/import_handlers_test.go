@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// goTestEvents is go test -json output for three tests, two of which map to test cases
+var goTestEvents = strings.Join([]string{
+	`{"Action":"run","Package":"example.com/auth","Test":"TestLogin"}`,
+	`{"Action":"run","Package":"example.com/auth","Test":"TestLogin/TEST-1_valid_credentials"}`,
+	`{"Action":"pass","Package":"example.com/auth","Test":"TestLogin/TEST-1_valid_credentials","Elapsed":0.25}`,
+	`{"Action":"pass","Package":"example.com/auth","Test":"TestLogin","Elapsed":0.25}`,
+	`{"Action":"run","Package":"example.com/auth","Test":"TestPasswordResetFunctionality"}`,
+	`{"Action":"output","Package":"example.com/auth","Test":"TestPasswordResetFunctionality","Output":"jira:TEST-2\n"}`,
+	`{"Action":"output","Package":"example.com/auth","Test":"TestPasswordResetFunctionality","Output":"    reset_test.go:12: email not sent\n"}`,
+	`{"Action":"fail","Package":"example.com/auth","Test":"TestPasswordResetFunctionality","Elapsed":1.5}`,
+	`{"Action":"run","Package":"example.com/auth","Test":"TestUnrelated"}`,
+	`{"Action":"pass","Package":"example.com/auth","Test":"TestUnrelated","Elapsed":0}`,
+	`{"Action":"fail","Package":"example.com/auth","Elapsed":2}`,
+}, "\n")
+
+func TestImportGoTestResults(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "import",
+			method: http.MethodPost, path: "/api/import/gotest?summary=CI+run+42&environment=CI&executedBy=ci-bot",
+			body:   goTestEvents,
+			status: http.StatusCreated,
+			golden: "gotest_import",
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases/TEST-2/history", "")
+				if body["count"] != 1.0 {
+					t.Errorf("imported result is missing from the history: %v", body)
+				}
+			},
+		},
+		{
+			name:   "nothing maps",
+			method: http.MethodPost, path: "/api/import/gotest",
+			body:   `{"Action":"pass","Package":"example.com/auth","Test":"TestUnrelated","Elapsed":0}`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "no events",
+			method: http.MethodPost, path: "/api/import/gotest",
+			body:   "\n",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid stream",
+			method: http.MethodPost, path: "/api/import/gotest",
+			body:   "ok  \texample.com/auth\t0.1s\n",
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "queued while Jira is unavailable",
+			setup: func(t *testing.T, env *testEnv) {
+				env.jira.InjectFault(jiratestFault(http.MethodPost, "/issue", http.StatusServiceUnavailable))
+			},
+			method: http.MethodPost, path: "/api/import/gotest?summary=CI+run+42",
+			body:    goTestEvents,
+			headers: []string{"Idempotency-Key", "ci-42"},
+			status:  http.StatusAccepted,
+		},
+	})
+}
@@ -0,0 +1,351 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"jira-xray-integration/jira"
+)
+
+// GoTestEvent represents a single event emitted by `go test -json`
+type GoTestEvent struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"` // in seconds
+	Output  string    `json:"Output"`
+}
+
+// GoTestPackage represents a package and the tests it ran
+type GoTestPackage struct {
+	Name    string        `json:"name"`
+	Status  string        `json:"status"` // pass, fail, skip
+	Elapsed float64       `json:"elapsed"`
+	Output  string        `json:"output,omitempty"`
+	Tests   []*GoTestCase `json:"tests"`
+}
+
+// GoTestCase represents a test or subtest reconstructed from the event stream
+type GoTestCase struct {
+	Name     string        `json:"name"` // full name, e.g. TestLogin/valid_credentials
+	Status   string        `json:"status"`
+	Elapsed  float64       `json:"elapsed"`
+	Output   string        `json:"output,omitempty"`
+	Time     *time.Time    `json:"time,omitempty"` // of the last event of the test, when it finished
+	Subtests []*GoTestCase `json:"subtests,omitempty"`
+}
+
+// GoTestMapping is the outcome of mapping go tests to Jira test cases
+type GoTestMapping struct {
+	Results  []jira.TestResult `json:"results"`
+	Unmapped []string          `json:"unmapped"` // full names of tests that matched no test case
+}
+
+// jiraKeyMarker matches "jira:TEST-12" markers, optionally written as a
+// "// jira:TEST-12" comment. Matches are checked with jira.IsIssueKey.
+var jiraKeyMarker = regexp.MustCompile(`(?://\s*)?jira:\s*([A-Z0-9_]+-\d+)`)
+
+// leadingJiraKey matches a subtest name that starts with an issue key, e.g.
+// TEST-12_login. Matches are checked with jira.IsIssueKey.
+var leadingJiraKey = regexp.MustCompile(`^([A-Z0-9_]+-\d+)(?:[_\s:-]|$)`)
+
+// maxCommentOutput bounds the captured output copied into a TestResult comment
+const maxCommentOutput = 4000
+
+// ParseGoTestJSON reads a `go test -json` event stream and reconstructs the
+// package/test/subtest hierarchy
+func ParseGoTestJSON(r io.Reader) ([]*GoTestPackage, error) {
+	packages := make(map[string]*GoTestPackage)
+	tests := make(map[string]*GoTestCase) // keyed by package + "\x00" + full test name
+	outputs := make(map[*GoTestCase]*strings.Builder)
+	var order []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		// go test interleaves plain build output with JSON events; skip anything that isn't JSON
+		if !strings.HasPrefix(raw, "{") {
+			continue
+		}
+
+		var event GoTestEvent
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			return nil, fmt.Errorf("invalid event on line %d: %w", line, err)
+		}
+		if event.Package == "" {
+			continue
+		}
+
+		pkg, ok := packages[event.Package]
+		if !ok {
+			pkg = &GoTestPackage{Name: event.Package}
+			packages[event.Package] = pkg
+			order = append(order, event.Package)
+		}
+
+		if event.Test == "" {
+			switch event.Action {
+			case "pass", "fail", "skip":
+				pkg.Status = event.Action
+				pkg.Elapsed = event.Elapsed
+			case "output":
+				pkg.Output += event.Output
+			}
+			continue
+		}
+
+		tc := findOrCreateTest(pkg, tests, event.Test)
+		if !event.Time.IsZero() {
+			finished := event.Time
+			tc.Time = &finished
+		}
+		switch event.Action {
+		case "pass", "fail", "skip":
+			tc.Status = event.Action
+			tc.Elapsed = event.Elapsed
+		case "output":
+			b, ok := outputs[tc]
+			if !ok {
+				b = &strings.Builder{}
+				outputs[tc] = b
+			}
+			b.WriteString(event.Output)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event stream: %w", err)
+	}
+
+	for tc, b := range outputs {
+		tc.Output = b.String()
+	}
+
+	result := make([]*GoTestPackage, 0, len(order))
+	for _, name := range order {
+		result = append(result, packages[name])
+	}
+	return result, nil
+}
+
+// findOrCreateTest returns the node for a full test name, creating it and any
+// missing parents so subtests hang under their parent test
+func findOrCreateTest(pkg *GoTestPackage, tests map[string]*GoTestCase, name string) *GoTestCase {
+	id := pkg.Name + "\x00" + name
+	if tc, ok := tests[id]; ok {
+		return tc
+	}
+
+	tc := &GoTestCase{Name: name}
+	tests[id] = tc
+
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		parent := findOrCreateTest(pkg, tests, name[:i])
+		parent.Subtests = append(parent.Subtests, tc)
+	} else {
+		pkg.Tests = append(pkg.Tests, tc)
+	}
+	return tc
+}
+
+// MapGoTestResults maps go tests to Jira test cases and builds a TestResult for
+// every test case that matches. A test matches by an explicit jira:KEY marker
+// in its output, a leading issue key in its subtest name, or by name against
+// the test case summary; markers and leading keys must name a known test case.
+// Parents of mapped subtests are not reported on their own. Several go tests
+// mapped to one test case make one result with the worst of their statuses.
+func MapGoTestResults(packages []*GoTestPackage, testCases []jira.TestCase, executedBy string) *GoTestMapping {
+	byKey := make(map[string]jira.TestCase, len(testCases))
+	bySummary := make(map[string]jira.TestCase, len(testCases))
+	for _, tc := range testCases {
+		byKey[tc.Key] = tc
+		bySummary[normalizeTestName(tc.Summary)] = tc
+	}
+
+	mapping := &GoTestMapping{Results: []jira.TestResult{}, Unmapped: []string{}}
+	results := make(map[string]int) // index in mapping.Results by test case key
+
+	var walk func(pkg *GoTestPackage, tc *GoTestCase) bool
+	walk = func(pkg *GoTestPackage, tc *GoTestCase) bool {
+		childMapped := false
+		for _, sub := range tc.Subtests {
+			if walk(pkg, sub) {
+				childMapped = true
+			}
+		}
+
+		if childMapped {
+			return true
+		}
+
+		key := resolveTestCaseKey(tc, byKey, bySummary)
+		if key == "" {
+			if len(tc.Subtests) == 0 {
+				mapping.Unmapped = append(mapping.Unmapped, pkg.Name+"."+tc.Name)
+			}
+			return false
+		}
+
+		status, comment := goTestStatus(tc.Status), goTestComment(pkg.Name, tc)
+		if i, ok := results[key]; ok {
+			result := &mapping.Results[i]
+			if statusSeverity[status] > statusSeverity[result.Status] {
+				result.Status = status
+			}
+			result.Comment += "\n\n" + comment
+			result.ExecutionTime += int(tc.Elapsed * 1000)
+			if executedOn := goTestTime(tc); executedOn.After(result.ExecutedOn) {
+				result.ExecutedOn = executedOn
+			}
+			return true
+		}
+		results[key] = len(mapping.Results)
+		mapping.Results = append(mapping.Results, jira.TestResult{
+			TestCaseKey:   key,
+			Status:        status,
+			Comment:       comment,
+			ExecutionTime: int(tc.Elapsed * 1000),
+			ExecutedBy:    executedBy,
+			ExecutedOn:    goTestTime(tc),
+		})
+		return true
+	}
+
+	for _, pkg := range packages {
+		for _, tc := range pkg.Tests {
+			walk(pkg, tc)
+		}
+	}
+
+	sort.Strings(mapping.Unmapped)
+	return mapping
+}
+
+// statusSeverity orders the statuses of go tests mapped to one test case: a
+// failure outweighs a skip, which outweighs a pass
+var statusSeverity = map[string]int{
+	jira.StatusPass:    0,
+	jira.StatusSkipped: 1,
+	jira.StatusFail:    2,
+}
+
+// ExecutionStatus is the status of a test execution holding the mapped
+// results: FAIL if any test failed, PASS if any passed, and TODO when every
+// test was skipped, as nothing was verified
+func (m *GoTestMapping) ExecutionStatus() string {
+	status := jira.StatusTodo
+	for _, result := range m.Results {
+		switch result.Status {
+		case jira.StatusFail:
+			return jira.StatusFail
+		case jira.StatusPass:
+			status = jira.StatusPass
+		}
+	}
+	return status
+}
+
+// resolveTestCaseKey finds the Jira test case key for a go test, or "" if none
+// matches. A marker or leading key naming no known test case leaves the test
+// unmapped rather than recording a result for an issue that is not a test.
+func resolveTestCaseKey(tc *GoTestCase, byKey, bySummary map[string]jira.TestCase) string {
+	if m := jiraKeyMarker.FindStringSubmatch(tc.Output); m != nil && jira.IsIssueKey(m[1]) {
+		return knownKey(m[1], byKey)
+	}
+
+	segment := tc.Name
+	if i := strings.LastIndex(segment, "/"); i >= 0 {
+		segment = segment[i+1:]
+	}
+	if m := leadingJiraKey.FindStringSubmatch(segment); m != nil && jira.IsIssueKey(m[1]) {
+		return knownKey(m[1], byKey)
+	}
+	if _, ok := byKey[segment]; ok {
+		return segment
+	}
+
+	if match, ok := bySummary[normalizeTestName(tc.Name)]; ok {
+		return match.Key
+	}
+	if match, ok := bySummary[normalizeTestName(segment)]; ok {
+		return match.Key
+	}
+	return ""
+}
+
+// knownKey returns key if it is the key of a test case, otherwise ""
+func knownKey(key string, byKey map[string]jira.TestCase) string {
+	if _, ok := byKey[key]; ok {
+		return key
+	}
+	return ""
+}
+
+// normalizeTestName folds a test name or summary for comparison. go test
+// replaces spaces in subtest names with underscores, so both are treated alike.
+func normalizeTestName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "_", " ")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// goTestStatus converts a go test action into a TestResult status
+func goTestStatus(action string) string {
+	switch action {
+	case "pass":
+		return jira.StatusPass
+	case "fail":
+		return jira.StatusFail
+	case "skip":
+		return jira.StatusSkipped
+	default:
+		// the stream ended before the test finished, e.g. a panic or timeout
+		return jira.StatusFail
+	}
+}
+
+// goTestTime returns when a test finished, from the time of its events, or
+// the current time if the events have none
+func goTestTime(tc *GoTestCase) time.Time {
+	if tc.Time == nil {
+		return time.Now()
+	}
+	return *tc.Time
+}
+
+// goTestComment builds the TestResult comment from the test's captured output
+func goTestComment(pkg string, tc *GoTestCase) string {
+	status := tc.Status
+	if status == "" {
+		status = "incomplete"
+	}
+	comment := fmt.Sprintf("go test %s: %s.%s (%.2fs)", status, pkg, tc.Name, tc.Elapsed)
+
+	output := strings.TrimSpace(tc.Output)
+	if output == "" {
+		return comment
+	}
+	if len(output) > maxCommentOutput {
+		// Keep the end of the output, starting on a whole character
+		cut := len(output) - maxCommentOutput
+		for cut < len(output) && !utf8.RuneStart(output[cut]) {
+			cut++
+		}
+		output = "...\n" + output[cut:]
+	}
+	return comment + "\n\n" + output
+}
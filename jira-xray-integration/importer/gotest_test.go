@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"jira-xray-integration/jira"
+)
+
+// goTestEvents parses a go test -json stream of the given lines
+func goTestEvents(t *testing.T, lines ...string) []*GoTestPackage {
+	t.Helper()
+	packages, err := ParseGoTestJSON(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packages
+}
+
+var mappingTestCases = []jira.TestCase{
+	{Key: "TEST-1", Summary: "Login works"},
+	{Key: "TEST-2", Summary: "Password reset"},
+}
+
+func TestParseGoTestJSON(t *testing.T) {
+	packages := goTestEvents(t,
+		`go: downloading example.com/dep v1.0.0`,
+		`{"Action":"run","Package":"app/auth","Test":"TestLogin"}`,
+		`{"Action":"run","Package":"app/auth","Test":"TestLogin/valid"}`,
+		`{"Action":"output","Package":"app/auth","Test":"TestLogin/valid","Output":"ok\n"}`,
+		`{"Action":"pass","Package":"app/auth","Test":"TestLogin/valid","Elapsed":0.25}`,
+		`{"Action":"pass","Package":"app/auth","Test":"TestLogin","Elapsed":0.5}`,
+		`{"Action":"pass","Package":"app/auth","Elapsed":1}`,
+	)
+	if len(packages) != 1 || packages[0].Status != "pass" || len(packages[0].Tests) != 1 {
+		t.Fatalf("got packages %+v", packages)
+	}
+	login := packages[0].Tests[0]
+	if login.Name != "TestLogin" || len(login.Subtests) != 1 || login.Subtests[0].Output != "ok\n" || login.Subtests[0].Elapsed != 0.25 {
+		t.Errorf("got test %+v", login)
+	}
+
+	if _, err := ParseGoTestJSON(strings.NewReader(`{"Action":`)); err == nil {
+		t.Error("expected an error for an invalid event")
+	}
+}
+
+func TestMapGoTestResults(t *testing.T) {
+	packages := goTestEvents(t,
+		`{"Action":"output","Package":"app","Test":"TestMarker","Output":"// jira:TEST-2\n"}`,
+		`{"Action":"pass","Package":"app","Test":"TestMarker","Elapsed":0.1}`,
+		`{"Action":"pass","Package":"app","Test":"TestLogin/TEST-1_valid","Elapsed":0.1}`,
+		`{"Action":"fail","Package":"app","Test":"TestLogin/login_works","Elapsed":0.2}`,
+		`{"Action":"fail","Package":"app","Test":"TestLogin","Elapsed":0.3}`,
+		`{"Action":"output","Package":"app","Test":"TestUnknownMarker","Output":"jira:TEST-99\n"}`,
+		`{"Action":"pass","Package":"app","Test":"TestUnknownMarker"}`,
+		`{"Action":"pass","Package":"app","Test":"TestOther/TEST-404"}`,
+	)
+	mapping := MapGoTestResults(packages, mappingTestCases, "ci")
+
+	got := make(map[string]jira.TestResult)
+	for _, result := range mapping.Results {
+		got[result.TestCaseKey] = result
+	}
+	if len(mapping.Results) != 2 {
+		t.Fatalf("got results %+v, want one per test case", mapping.Results)
+	}
+	// A failing test mapped to TEST-1 outweighs the passing one
+	if got["TEST-1"].Status != jira.StatusFail || got["TEST-1"].ExecutionTime != 300 || !strings.Contains(got["TEST-1"].Comment, "TestLogin/TEST-1_valid") {
+		t.Errorf("got TEST-1 %+v", got["TEST-1"])
+	}
+	if got["TEST-2"].Status != jira.StatusPass || got["TEST-2"].ExecutedBy != "ci" {
+		t.Errorf("got TEST-2 %+v", got["TEST-2"])
+	}
+	// Keys of no known test case leave their tests unmapped
+	if want := []string{"app.TestOther/TEST-404", "app.TestUnknownMarker"}; strings.Join(mapping.Unmapped, ",") != strings.Join(want, ",") {
+		t.Errorf("got unmapped %v, want %v", mapping.Unmapped, want)
+	}
+	if got := mapping.ExecutionStatus(); got != jira.StatusFail {
+		t.Errorf("got execution status %s, want FAIL", got)
+	}
+}
+
+func TestMapGoTestResultsUsesEventTime(t *testing.T) {
+	packages := goTestEvents(t,
+		`{"Time":"2026-03-01T10:00:00Z","Action":"run","Package":"app","Test":"TestLogin/TEST-1_valid"}`,
+		`{"Time":"2026-03-01T10:00:02Z","Action":"pass","Package":"app","Test":"TestLogin/TEST-1_valid","Elapsed":2}`,
+		`{"Time":"2026-03-01T10:00:05Z","Action":"fail","Package":"app","Test":"TestLogin/login_works","Elapsed":3}`,
+		`{"Time":"2026-03-01T10:00:00Z","Action":"output","Package":"app","Test":"TestMarker","Output":"jira:TEST-2\n"}`,
+		`{"Time":"2026-03-01T10:00:01Z","Action":"pass","Package":"app","Test":"TestMarker","Elapsed":1}`,
+	)
+	mapping := MapGoTestResults(packages, mappingTestCases, "ci")
+	if len(mapping.Results) != 2 {
+		t.Fatalf("got results %+v, want one per test case", mapping.Results)
+	}
+	want := map[string]string{"TEST-1": "2026-03-01T10:00:05Z", "TEST-2": "2026-03-01T10:00:01Z"}
+	for _, result := range mapping.Results {
+		// Several go tests mapped to one test case take the time of the last to finish
+		if got := result.ExecutedOn.Format(time.RFC3339); got != want[result.TestCaseKey] {
+			t.Errorf("%s: got executedOn %s, want %s", result.TestCaseKey, got, want[result.TestCaseKey])
+		}
+	}
+
+	// The same stream maps to the same results, so an import retried as a
+	// queued write is recognized as the same one
+	if again := MapGoTestResults(packages, mappingTestCases, "ci"); !reflect.DeepEqual(again, mapping) {
+		t.Errorf("got %+v mapping the stream again, want %+v", again, mapping)
+	}
+}
+
+func TestGoTestCommentTruncatesOnCharacterBoundary(t *testing.T) {
+	// Each é takes two bytes, so the cut would fall inside one
+	output := "start " + strings.Repeat("é", maxCommentOutput)
+	comment := goTestComment("app", &GoTestCase{Name: "TestLong", Status: "fail", Output: output})
+	if !utf8.ValidString(comment) {
+		t.Fatal("the comment is not valid UTF-8")
+	}
+	kept := comment[strings.Index(comment, "...\n")+len("...\n"):]
+	if len(kept) > maxCommentOutput || !strings.HasSuffix(output, kept) || strings.HasPrefix(kept, "start") {
+		t.Errorf("got %d bytes of output, want the last %d at most", len(kept), maxCommentOutput)
+	}
+}
+
+func TestMapGoTestResultsIgnoresInvalidKeys(t *testing.T) {
+	// A-1 is not an issue key, so the name is matched by summary instead
+	packages := goTestEvents(t,
+		`{"Action":"output","Package":"app","Test":"TestCheckout/A-1_checkout","Output":"jira:A-1\n"}`,
+		`{"Action":"pass","Package":"app","Test":"TestCheckout/A-1_checkout","Elapsed":0.1}`,
+	)
+	mapping := MapGoTestResults(packages, []jira.TestCase{{Key: "TEST-3", Summary: "A-1 checkout"}}, "ci")
+	if len(mapping.Results) != 1 || mapping.Results[0].TestCaseKey != "TEST-3" {
+		t.Errorf("got results %+v, unmapped %v", mapping.Results, mapping.Unmapped)
+	}
+}
+
+func TestGoTestMappingExecutionStatus(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		want     string
+	}{
+		{[]string{jira.StatusPass, jira.StatusSkipped}, jira.StatusPass},
+		{[]string{jira.StatusPass, jira.StatusFail}, jira.StatusFail},
+		{[]string{jira.StatusSkipped, jira.StatusSkipped}, jira.StatusTodo},
+	}
+	for _, tt := range tests {
+		mapping := &GoTestMapping{}
+		for _, status := range tt.statuses {
+			mapping.Results = append(mapping.Results, jira.TestResult{Status: status})
+		}
+		if got := mapping.ExecutionStatus(); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.statuses, got, tt.want)
+		}
+	}
+}
+
+func TestMapGoTestResultsSkipOutweighsPass(t *testing.T) {
+	packages := goTestEvents(t,
+		`{"Action":"pass","Package":"app","Test":"TestA/TEST-1_a"}`,
+		`{"Action":"skip","Package":"app","Test":"TestA/TEST-1_b"}`,
+	)
+	mapping := MapGoTestResults(packages, mappingTestCases, "")
+	if len(mapping.Results) != 1 || mapping.Results[0].Status != jira.StatusSkipped {
+		t.Errorf("got results %+v, want one SKIPPED", mapping.Results)
+	}
+}
@@ -0,0 +1,285 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"jira-xray-integration/jira"
+)
+
+// Test case spreadsheet fields. A ColumnMapping maps these to the column
+// headers used in a particular sheet.
+const (
+	FieldKey          = "key"
+	FieldSummary      = "summary"
+	FieldDescription  = "description"
+	FieldPriority     = "priority"
+	FieldLabels       = "labels"
+	FieldComponents   = "components"
+	FieldTestType     = "testType"
+	FieldStepAction   = "stepAction"
+	FieldStepData     = "stepData"
+	FieldStepExpected = "stepExpectedResult"
+)
+
+// testCaseFields lists the fields in export column order
+var testCaseFields = []string{
+	FieldKey, FieldSummary, FieldDescription, FieldPriority, FieldLabels,
+	FieldComponents, FieldTestType, FieldStepAction, FieldStepData, FieldStepExpected,
+}
+
+// DefaultColumnMapping is used for any field the caller doesn't map
+var DefaultColumnMapping = ColumnMapping{
+	FieldKey:          "Key",
+	FieldSummary:      "Summary",
+	FieldDescription:  "Description",
+	FieldPriority:     "Priority",
+	FieldLabels:       "Labels",
+	FieldComponents:   "Components",
+	FieldTestType:     "Test Type",
+	FieldStepAction:   "Step Action",
+	FieldStepData:     "Step Data",
+	FieldStepExpected: "Step Expected Result",
+}
+
+// ColumnMapping maps test case fields to spreadsheet column headers
+type ColumnMapping map[string]string
+
+// TestCaseRow is a test case parsed from one or more spreadsheet rows
+type TestCaseRow struct {
+	Row      int           `json:"row"` // 1-based sheet row where the test case starts
+	TestCase jira.TestCase `json:"testCase"`
+}
+
+// RowError describes a validation problem in a spreadsheet row
+type RowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("row %d, column %q: %s", e.Row, e.Column, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// Resolve fills unmapped fields from DefaultColumnMapping and rejects unknown fields
+func (m ColumnMapping) Resolve() (ColumnMapping, error) {
+	resolved := make(ColumnMapping, len(DefaultColumnMapping))
+	for field, header := range DefaultColumnMapping {
+		resolved[field] = header
+	}
+	for field, header := range m {
+		if _, ok := DefaultColumnMapping[field]; !ok {
+			return nil, fmt.Errorf("unknown field %q in column mapping", field)
+		}
+		resolved[field] = header
+	}
+	return resolved, nil
+}
+
+// ParseTestCaseRows converts spreadsheet rows into test cases. The first row is
+// the header. A row with a key or summary starts a new test case; following rows
+// with only step columns add further steps to it. Every problem found is
+// reported as a RowError; test cases with errors are left out of the result.
+func ParseTestCaseRows(rows [][]string, mapping ColumnMapping) ([]TestCaseRow, []RowError) {
+	mapping, err := mapping.Resolve()
+	if err != nil {
+		return nil, []RowError{{Row: 1, Message: err.Error()}}
+	}
+	if len(rows) == 0 {
+		return nil, []RowError{{Row: 1, Message: "sheet is empty, a header row is required"}}
+	}
+
+	columns := make(map[string]int)
+	for i, header := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+	index := make(map[string]int)
+	for field, header := range mapping {
+		if i, ok := columns[strings.ToLower(strings.TrimSpace(header))]; ok {
+			index[field] = i
+		}
+	}
+	if _, ok := index[FieldSummary]; !ok {
+		if _, ok := index[FieldKey]; !ok {
+			return nil, []RowError{{Row: 1, Message: fmt.Sprintf("header must contain a %q or %q column", mapping[FieldSummary], mapping[FieldKey])}}
+		}
+	}
+
+	cell := func(row []string, field string) string {
+		i, ok := index[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var (
+		parsed   []TestCaseRow
+		errs     []RowError
+		current  *TestCaseRow
+		invalid  bool
+		seenKeys = make(map[string]int)
+	)
+	flush := func() {
+		if current != nil && !invalid {
+			parsed = append(parsed, *current)
+		}
+		current = nil
+		invalid = false
+	}
+	fail := func(row int, field, message string) {
+		errs = append(errs, RowError{Row: row, Column: mapping[field], Message: message})
+		invalid = true
+	}
+
+	for i, row := range rows[1:] {
+		rowNum := i + 2
+		if isBlankRow(row) {
+			continue
+		}
+
+		key := strings.ToUpper(cell(row, FieldKey))
+		summary := cell(row, FieldSummary)
+		if key != "" || summary != "" {
+			flush()
+			current = &TestCaseRow{
+				Row: rowNum,
+				TestCase: jira.TestCase{
+					Key:         key,
+					Summary:     summary,
+					Description: cell(row, FieldDescription),
+					Priority:    cell(row, FieldPriority),
+					Labels:      splitList(cell(row, FieldLabels)),
+					Components:  splitList(cell(row, FieldComponents)),
+					TestType:    cell(row, FieldTestType),
+				},
+			}
+
+			if key != "" {
+				if !jira.IsIssueKey(key) {
+					fail(rowNum, FieldKey, fmt.Sprintf("%q is not a valid issue key", key))
+				} else if first, ok := seenKeys[key]; ok {
+					fail(rowNum, FieldKey, fmt.Sprintf("duplicate key %s, first seen on row %d", key, first))
+				} else {
+					seenKeys[key] = rowNum
+				}
+			} else if summary == "" {
+				fail(rowNum, FieldSummary, "summary is required for new test cases")
+			}
+		} else if current == nil {
+			errs = append(errs, RowError{Row: rowNum, Message: "step row has no preceding test case row"})
+			continue
+		}
+
+		action := cell(row, FieldStepAction)
+		data := cell(row, FieldStepData)
+		expected := cell(row, FieldStepExpected)
+		if action == "" && (data != "" || expected != "") {
+			fail(rowNum, FieldStepAction, "step action is required when step data or expected result is set")
+		} else if action != "" {
+			current.TestCase.Steps = append(current.TestCase.Steps, jira.TestStep{
+				Action:         action,
+				Data:           data,
+				ExpectedResult: expected,
+			})
+		}
+	}
+	flush()
+
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+	return parsed, errs
+}
+
+// TestCaseSheet converts test cases into spreadsheet rows with a header row.
+// Each test case takes one row per step, with the test case fields on the first.
+func TestCaseSheet(testCases []jira.TestCase, mapping ColumnMapping) ([][]string, error) {
+	mapping, err := mapping.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]string, len(testCaseFields))
+	for i, field := range testCaseFields {
+		header[i] = mapping[field]
+	}
+	rows := [][]string{header}
+
+	for _, tc := range testCases {
+		steps := tc.Steps
+		if len(steps) == 0 {
+			steps = []jira.TestStep{{}}
+		}
+		for i, step := range steps {
+			row := make([]string, len(testCaseFields))
+			if i == 0 {
+				row[0] = tc.Key
+				row[1] = tc.Summary
+				row[2] = tc.Description
+				row[3] = tc.Priority
+				row[4] = strings.Join(tc.Labels, ", ")
+				row[5] = strings.Join(tc.Components, ", ")
+				row[6] = tc.TestType
+			}
+			row[7] = step.Action
+			row[8] = step.Data
+			row[9] = step.ExpectedResult
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// MergeTestCase applies the non-empty fields of an imported test case onto an existing one
+func MergeTestCase(existing, imported jira.TestCase) jira.TestCase {
+	merged := existing
+	if imported.Summary != "" {
+		merged.Summary = imported.Summary
+	}
+	if imported.Description != "" {
+		merged.Description = imported.Description
+	}
+	if imported.Priority != "" {
+		merged.Priority = imported.Priority
+	}
+	if imported.Labels != nil {
+		merged.Labels = imported.Labels
+	}
+	if imported.Components != nil {
+		merged.Components = imported.Components
+	}
+	if imported.TestType != "" {
+		merged.TestType = imported.TestType
+	}
+	if imported.Steps != nil {
+		merged.Steps = imported.Steps
+	}
+	return merged
+}
+
+// splitList splits a comma or semicolon separated cell value
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func isBlankRow(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
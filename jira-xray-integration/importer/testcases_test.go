@@ -0,0 +1,31 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTestCaseRowsKeys(t *testing.T) {
+	rows := [][]string{
+		{"Key", "Summary"},
+		{"test-2", "Lower case key"},
+		{"A-1", "Key of a one letter project"},
+		{"1TEST-3", "Key starting with a digit"},
+		{"TEST-2", "Duplicate key"},
+	}
+	parsed, errs := ParseTestCaseRows(rows, nil)
+	if len(parsed) != 1 || parsed[0].TestCase.Key != "TEST-2" {
+		t.Errorf("got test cases %+v, want TEST-2 only", parsed)
+	}
+
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	got := strings.Join(messages, "\n")
+	for _, want := range []string{`"A-1" is not a valid issue key`, `"1TEST-3" is not a valid issue key`, "duplicate key TEST-2, first seen on row 2"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got errors:\n%s\nwant one containing %s", got, want)
+		}
+	}
+}
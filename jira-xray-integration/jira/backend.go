@@ -0,0 +1,23 @@
+package jira
+
+// TestManagementBackend is the system that holds test cases, test executions and
+// the issues they are linked to. Client talks to Jira; MemoryBackend keeps
+// everything in process for demos and tests.
+type TestManagementBackend interface {
+	// SearchIssues runs a JQL query and returns every matching issue
+	SearchIssues(jql string) ([]JiraIssue, error)
+
+	ListTestCases() ([]TestCase, error)
+	GetTestCase(key string) (*TestCase, error)
+	CreateTestCase(tc *TestCase) (*TestCase, error)
+	UpdateTestCase(tc *TestCase) (*TestCase, error)
+
+	ListTestExecutions() ([]TestExecution, error)
+	GetTestExecution(key string) (*TestExecution, error)
+	CreateTestExecution(te *TestExecution) (*TestExecution, error)
+}
+
+var (
+	_ TestManagementBackend = (*Client)(nil)
+	_ TestManagementBackend = (*MemoryBackend)(nil)
+)
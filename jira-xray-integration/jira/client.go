@@ -2,16 +2,26 @@ package jira
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// searchPageSize is the number of issues requested per search page
+const searchPageSize = 100
+
+// jiraTimeLayout is the format of the created and updated issue fields
+const jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+
 // Client represents a Jira API client
 type Client struct {
 	BaseURL    string
@@ -19,6 +29,20 @@ type Client struct {
 	APIToken   string
 	ProjectKey string
 	HTTPClient *http.Client
+
+	// AccessToken is an OAuth 2.0 access token or personal access token. When
+	// set it is sent as a bearer token instead of Username and APIToken.
+	AccessToken string
+
+	// IssueTypes, Fields, LinkTypes and Statuses describe how ProjectKey is
+	// set up in Jira
+	IssueTypes IssueTypes
+	Fields     FieldMapping
+	LinkTypes  LinkTypes
+	Statuses   StatusMapping
+
+	// ctx is the context requests are sent in, set by WithContext
+	ctx context.Context
 }
 
 // NewClient creates a new Jira API client
@@ -31,6 +55,30 @@ func NewClient(baseURL, username, apiToken, projectKey string) *Client {
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		IssueTypes: DefaultIssueTypes,
+	}
+}
+
+// ForProject returns a copy of the client for another project, sharing its
+// credentials and HTTP client
+func (c *Client) ForProject(project ProjectConfig) *Client {
+	client := *c
+	client.ProjectKey = project.Key
+	client.IssueTypes = project.IssueTypes.withDefaults()
+	client.Fields = project.Fields
+	client.LinkTypes = project.LinkTypes
+	client.Statuses = project.Statuses
+	return &client
+}
+
+// Project returns how the client's project is set up
+func (c *Client) Project() ProjectConfig {
+	return ProjectConfig{
+		Key:        c.ProjectKey,
+		IssueTypes: c.IssueTypes.withDefaults(),
+		Fields:     c.Fields,
+		LinkTypes:  c.LinkTypes,
+		Statuses:   c.Statuses,
 	}
 }
 
@@ -46,7 +94,7 @@ func (c *Client) makeRequest(method, endpoint string, body interface{}) (*http.R
 	}
 
 	url := fmt.Sprintf("%s/rest/api/3/%s", c.BaseURL, endpoint)
-	req, err := http.NewRequest(method, url, reqBody)
+	req, err := http.NewRequestWithContext(c.requestContext(), method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -55,13 +103,21 @@ func (c *Client) makeRequest(method, endpoint string, body interface{}) (*http.R
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	// Set basic authentication
-	auth := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.APIToken))
-	req.Header.Set("Authorization", "Basic "+auth)
+	// Set bearer or basic authentication
+	if c.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	} else {
+		auth := base64.StdEncoding.EncodeToString([]byte(c.Username + ":" + c.APIToken))
+		req.Header.Set("Authorization", "Basic "+auth)
+	}
 
 	log.Printf("Making %s request to: %s", method, url)
 
+	req, span := startRequestSpan(req, endpoint)
+	started := time.Now()
 	resp, err := c.HTTPClient.Do(req)
+	observeRequest(method, endpoint, started, resp, err)
+	endRequestSpan(span, resp, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -69,6 +125,59 @@ func (c *Client) makeRequest(method, endpoint string, body interface{}) (*http.R
 	return resp, nil
 }
 
+// APIError is returned when Jira responds with an HTTP error status
+type APIError struct {
+	StatusCode int
+	Body       string
+	Response   ErrorResponse
+	parsed     bool
+}
+
+func (e *APIError) Error() string {
+	if !e.parsed {
+		return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("Jira API error (HTTP %d): %v, %v", e.StatusCode, e.Response.ErrorMessages, e.Response.Errors)
+}
+
+// IssueTypeError is returned when an issue exists but is not of the type the
+// request asked for, such as a Story fetched as a test case
+type IssueTypeError struct {
+	Key       string
+	IssueType string
+	Want      string
+}
+
+func (e *IssueTypeError) Error() string {
+	return fmt.Sprintf("%s is a %s, not a %s", e.Key, e.IssueType, e.Want)
+}
+
+// IsNotFound reports whether err is a Jira 404 response or an issue of
+// another type than the one asked for
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	var typeErr *IssueTypeError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound || errors.As(err, &typeErr)
+}
+
+// IsUnauthorized reports whether err is a Jira 401 or 403 response: the
+// credentials were rejected or lack permission
+func IsUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// IsRetriable reports whether err is a transient failure worth retrying later:
+// a network error, rate limiting or a Jira server error
+func IsRetriable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
 // handleResponse handles the HTTP response and checks for errors
 func (c *Client) handleResponse(resp *http.Response, target interface{}) error {
 	defer resp.Body.Close()
@@ -81,11 +190,12 @@ func (c *Client) handleResponse(resp *http.Response, target interface{}) error {
 	log.Printf("Response status: %d, body length: %d", resp.StatusCode, len(body))
 
 	if resp.StatusCode >= 400 {
-		var errorResp ErrorResponse
-		if err := json.Unmarshal(body, &errorResp); err != nil {
-			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+		if err := json.Unmarshal(body, &apiErr.Response); err != nil {
+			return apiErr
 		}
-		return fmt.Errorf("Jira API error (HTTP %d): %v, %v", resp.StatusCode, errorResp.ErrorMessages, errorResp.Errors)
+		apiErr.parsed = true
+		return apiErr
 	}
 
 	if target != nil && len(body) > 0 {
@@ -101,39 +211,18 @@ func (c *Client) handleResponse(resp *http.Response, target interface{}) error {
 func (c *Client) ListTestCases() ([]TestCase, error) {
 	log.Println("Fetching test cases from Jira...")
 
-	// JQL query to find test cases (assuming Test issue type exists)
-	jql := fmt.Sprintf("project = %s AND issuetype = Test", c.ProjectKey)
-	endpoint := fmt.Sprintf("search?jql=%s&maxResults=100", jql)
+	jql := fmt.Sprintf("project = %s AND issuetype = %q", c.ProjectKey, c.Project().IssueTypes.Test)
 
-	resp, err := c.makeRequest("GET", endpoint, nil)
+	issues, err := c.SearchIssues(jql)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch test cases: %w", err)
 	}
 
-	var jiraResp JiraResponse
-	if err := c.handleResponse(resp, &jiraResp); err != nil {
-		// If using demo credentials, return mock data
-		if c.isDemoCredentials() {
-			log.Println("Using demo credentials, returning mock test cases")
-			return c.getMockTestCases(), nil
-		}
-		return nil, err
-	}
-
 	// Convert Jira issues to TestCase structs
-	testCases := make([]TestCase, len(jiraResp.Issues))
-	for i, issue := range jiraResp.Issues {
-		testCases[i] = TestCase{
-			ID:          issue.ID,
-			Key:         issue.Key,
-			Summary:     issue.Fields.Summary,
-			Description: issue.Fields.Description,
-			Status:      issue.Fields.Status.Name,
-			Priority:    issue.Fields.Priority.Name,
-			Labels:      issue.Fields.Labels,
-			Reporter:    issue.Fields.Reporter.DisplayName,
-			Assignee:    issue.Fields.Assignee.DisplayName,
-		}
+	project := c.Project()
+	testCases := make([]TestCase, len(issues))
+	for i, issue := range issues {
+		testCases[i] = *project.TestCaseFromIssue(&issue)
 	}
 
 	log.Printf("Successfully fetched %d test cases", len(testCases))
@@ -144,23 +233,19 @@ func (c *Client) ListTestCases() ([]TestCase, error) {
 func (c *Client) CreateTestCase(tc *TestCase) (*TestCase, error) {
 	log.Printf("Creating test case: %s", tc.Summary)
 
-	// If using demo credentials, return mock response
-	if c.isDemoCredentials() {
-		log.Println("Using demo credentials, returning mock test case creation")
-		return c.createMockTestCase(tc), nil
-	}
-
 	createReq := CreateIssueRequest{
 		Fields: IssueFields{
 			Summary:     tc.Summary,
-			Description: tc.Description,
+			Description: formatDescription(tc.Description, tc.Steps),
 			IssueType: IssueType{
-				Name: "Test", // Assuming Test issue type exists
+				Name: c.Project().IssueTypes.Test,
 			},
 			Project: Project{
 				Key: c.ProjectKey,
 			},
-			Labels: tc.Labels,
+			Labels:     tc.Labels,
+			Components: toComponents(tc.Components),
+			Custom:     c.Project().testCaseFields(tc),
 		},
 	}
 
@@ -182,36 +267,130 @@ func (c *Client) CreateTestCase(tc *TestCase) (*TestCase, error) {
 	createdTC := *tc
 	createdTC.ID = createResp.ID
 	createdTC.Key = createResp.Key
-	createdTC.Status = "To Do"
+	createdTC.Status = c.Project().Statuses.JiraStatus(StatusTodo, "To Do")
 	createdTC.CreatedDate = time.Now()
 
 	log.Printf("Successfully created test case: %s", createdTC.Key)
 	return &createdTC, nil
 }
 
+// SearchIssues runs a JQL query and returns every matching issue, following
+// pagination until all results have been fetched
+func (c *Client) SearchIssues(jql string) ([]JiraIssue, error) {
+	log.Printf("Searching issues: %s", jql)
+
+	var issues []JiraIssue
+	for {
+		params := url.Values{}
+		params.Set("jql", jql)
+		params.Set("startAt", strconv.Itoa(len(issues)))
+		params.Set("maxResults", strconv.Itoa(searchPageSize))
+		params.Set("fields", "*navigable,issuelinks")
+
+		resp, err := c.makeRequest("GET", "search?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search issues: %w", err)
+		}
+
+		var page JiraResponse
+		if err := c.handleResponse(resp, &page); err != nil {
+			return nil, err
+		}
+
+		issues = append(issues, page.Issues...)
+		if len(page.Issues) == 0 || len(issues) >= page.Total {
+			break
+		}
+	}
+
+	log.Printf("Search returned %d issues", len(issues))
+	return issues, nil
+}
+
+// GetTestCase retrieves a test case by key
+func (c *Client) GetTestCase(key string) (*TestCase, error) {
+	log.Printf("Fetching test case: %s", key)
+
+	endpoint := fmt.Sprintf("issue/%s", key)
+	resp, err := c.makeRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch test case: %w", err)
+	}
+
+	var issue JiraIssue
+	if err := c.handleResponse(resp, &issue); err != nil {
+		return nil, err
+	}
+
+	if want := c.Project().IssueTypes.withDefaults().Test; issue.Fields.IssueType.Name != want {
+		return nil, &IssueTypeError{Key: key, IssueType: issue.Fields.IssueType.Name, Want: want}
+	}
+	testCase := c.Project().TestCaseFromIssue(&issue)
+	log.Printf("Successfully fetched test case: %s", testCase.Key)
+	return testCase, nil
+}
+
+// UpdateTestCase updates the fields of an existing test case in Jira
+func (c *Client) UpdateTestCase(tc *TestCase) (*TestCase, error) {
+	log.Printf("Updating test case: %s", tc.Key)
+
+	fields := map[string]interface{}{
+		"summary":     tc.Summary,
+		"description": formatDescription(tc.Description, tc.Steps),
+	}
+	if tc.Labels != nil {
+		fields["labels"] = tc.Labels
+	}
+	if tc.Components != nil {
+		fields["components"] = toComponents(tc.Components)
+	}
+	if tc.Priority != "" {
+		fields["priority"] = Priority{Name: tc.Priority}
+	}
+	for id, value := range c.Project().testCaseFields(tc) {
+		fields[id] = value
+	}
+
+	endpoint := fmt.Sprintf("issue/%s", tc.Key)
+	resp, err := c.makeRequest("PUT", endpoint, UpdateIssueRequest{Fields: fields})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update test case: %w", err)
+	}
+
+	if err := c.handleResponse(resp, nil); err != nil {
+		return nil, err
+	}
+
+	updatedTC := *tc
+	updatedTC.UpdatedDate = time.Now()
+
+	log.Printf("Successfully updated test case: %s", updatedTC.Key)
+	return &updatedTC, nil
+}
+
 // CreateTestExecution creates a new test execution in Jira
 func (c *Client) CreateTestExecution(te *TestExecution) (*TestExecution, error) {
 	log.Printf("Creating test execution: %s", te.Summary)
 
-	// If using demo credentials, return mock response
-	if c.isDemoCredentials() {
-		log.Println("Using demo credentials, returning mock test execution creation")
-		return c.createMockTestExecution(te), nil
-	}
-
 	createReq := CreateIssueRequest{
 		Fields: IssueFields{
 			Summary:     te.Summary,
 			Description: te.Description,
 			IssueType: IssueType{
-				Name: "Test Execution", // Assuming Test Execution issue type exists
+				Name: c.Project().IssueTypes.TestExecution,
 			},
 			Project: Project{
 				Key: c.ProjectKey,
 			},
+			Labels: te.Labels,
+			Custom: c.Project().testExecutionFields(te),
 		},
 	}
 
+	if te.FixVersion != "" {
+		createReq.Fields.FixVersions = []Version{{Name: te.FixVersion}}
+	}
+
 	resp, err := c.makeRequest("POST", "issue", createReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create test execution: %w", err)
@@ -226,8 +405,8 @@ func (c *Client) CreateTestExecution(te *TestExecution) (*TestExecution, error)
 	createdTE := *te
 	createdTE.ID = createResp.ID
 	createdTE.Key = createResp.Key
-	createdTE.Status = "To Do"
-	createdTE.ExecutionStatus = "TODO"
+	createdTE.Status = c.Project().Statuses.JiraStatus(StatusTodo, "To Do")
+	createdTE.ExecutionStatus = StatusTodo
 	createdTE.StartDate = time.Now()
 
 	log.Printf("Successfully created test execution: %s", createdTE.Key)
@@ -238,11 +417,6 @@ func (c *Client) CreateTestExecution(te *TestExecution) (*TestExecution, error)
 func (c *Client) GetTestExecution(key string) (*TestExecution, error) {
 	log.Printf("Fetching test execution: %s", key)
 
-	if c.isDemoCredentials() {
-		log.Println("Using demo credentials, returning mock test execution")
-		return c.getMockTestExecution(key), nil
-	}
-
 	endpoint := fmt.Sprintf("issue/%s", key)
 	resp, err := c.makeRequest("GET", endpoint, nil)
 	if err != nil {
@@ -254,116 +428,120 @@ func (c *Client) GetTestExecution(key string) (*TestExecution, error) {
 		return nil, err
 	}
 
-	testExecution := &TestExecution{
+	if want := c.Project().IssueTypes.withDefaults().TestExecution; issue.Fields.IssueType.Name != want {
+		return nil, &IssueTypeError{Key: key, IssueType: issue.Fields.IssueType.Name, Want: want}
+	}
+	testExecution := c.Project().TestExecutionFromIssue(&issue)
+	log.Printf("Successfully fetched test execution: %s", testExecution.Key)
+	return testExecution, nil
+}
+
+// ListTestExecutions retrieves test executions from Jira
+func (c *Client) ListTestExecutions() ([]TestExecution, error) {
+	log.Println("Fetching test executions from Jira...")
+
+	project := c.Project()
+	jql := fmt.Sprintf("project = %s AND issuetype = %q", c.ProjectKey, project.IssueTypes.TestExecution)
+	issues, err := c.SearchIssues(jql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch test executions: %w", err)
+	}
+
+	testExecutions := make([]TestExecution, len(issues))
+	for i, issue := range issues {
+		testExecutions[i] = *project.TestExecutionFromIssue(&issue)
+	}
+
+	log.Printf("Successfully fetched %d test executions", len(testExecutions))
+	return testExecutions, nil
+}
+
+// TestCaseFromIssue converts a Jira issue into a TestCase
+func TestCaseFromIssue(issue *JiraIssue) *TestCase {
+	description, steps := parseDescription(issue.Fields.Description)
+
+	components := make([]string, 0, len(issue.Fields.Components))
+	for _, component := range issue.Fields.Components {
+		components = append(components, component.Name)
+	}
+
+	return &TestCase{
 		ID:          issue.ID,
 		Key:         issue.Key,
 		Summary:     issue.Fields.Summary,
-		Description: issue.Fields.Description,
+		Description: description,
 		Status:      issue.Fields.Status.Name,
+		Priority:    issue.Fields.Priority.Name,
+		Labels:      issue.Fields.Labels,
+		Components:  components,
+		Reporter:    issue.Fields.Reporter.DisplayName,
+		Assignee:    issue.Fields.Assignee.DisplayName,
+		CreatedDate: parseJiraTime(issue.Fields.Created),
+		UpdatedDate: parseJiraTime(issue.Fields.Updated),
+		Steps:       steps,
 	}
-
-	log.Printf("Successfully fetched test execution: %s", testExecution.Key)
-	return testExecution, nil
 }
 
-// isDemoCredentials checks if demo credentials are being used
-func (c *Client) isDemoCredentials() bool {
-	return c.Username == "demo_user" || c.APIToken == "demo_token_replace_with_actual"
+// TestExecutionFromIssue converts a Jira issue into a TestExecution. Tests
+// linked to the execution become its test cases.
+func TestExecutionFromIssue(issue *JiraIssue) *TestExecution {
+	return testExecutionFromIssue(issue, IssueTypeTest, "")
 }
 
-// Mock data methods for demo purposes
-func (c *Client) getMockTestCases() []TestCase {
-	return []TestCase{
-		{
-			ID:          "10001",
-			Key:         "TEST-1",
-			Summary:     "Login functionality test",
-			Description: "Test user login with valid credentials",
-			Status:      "To Do",
-			Priority:    "High",
-			Labels:      []string{"login", "authentication"},
-			TestType:    "Manual",
-			CreatedDate: time.Now().AddDate(0, 0, -7),
-			Reporter:    "Demo User",
-		},
-		{
-			ID:          "10002",
-			Key:         "TEST-2",
-			Summary:     "Password reset functionality",
-			Description: "Test password reset flow",
-			Status:      "In Progress",
-			Priority:    "Medium",
-			Labels:      []string{"password", "reset"},
-			TestType:    "Automated",
-			CreatedDate: time.Now().AddDate(0, 0, -5),
-			Reporter:    "Demo User",
-		},
-		{
-			ID:          "10003",
-			Key:         "TEST-3",
-			Summary:     "User registration validation",
-			Description: "Test user registration with various input validations",
-			Status:      "Done",
-			Priority:    "Medium",
-			Labels:      []string{"registration", "validation"},
-			TestType:    "Manual",
-			CreatedDate: time.Now().AddDate(0, 0, -3),
-			Reporter:    "Demo User",
-		},
+// testExecutionFromIssue converts a Jira issue into a TestExecution whose test
+// cases are the linked issues of testIssueType. A non-empty linkType only
+// follows links of that type.
+func testExecutionFromIssue(issue *JiraIssue, testIssueType, linkType string) *TestExecution {
+	testExecution := &TestExecution{
+		ID:          issue.ID,
+		Key:         issue.Key,
+		Summary:     issue.Fields.Summary,
+		Description: issue.Fields.Description,
+		Status:      issue.Fields.Status.Name,
+		StartDate:   parseJiraTime(issue.Fields.Created),
+	}
+	if len(issue.Fields.FixVersions) > 0 {
+		testExecution.FixVersion = issue.Fields.FixVersions[0].Name
+	}
+	for _, link := range issue.Fields.IssueLinks {
+		if linkType != "" && !strings.EqualFold(link.Type.Name, linkType) {
+			continue
+		}
+		if linked := link.LinkedIssue(); linked != nil && strings.EqualFold(linked.Fields.IssueType.Name, testIssueType) {
+			testExecution.TestCases = append(testExecution.TestCases, linked.Key)
+		}
 	}
+	return testExecution
 }
 
-func (c *Client) createMockTestCase(tc *TestCase) *TestCase {
-	mockTC := *tc
-	mockTC.ID = "10004"
-	mockTC.Key = "TEST-4"
-	mockTC.Status = "To Do"
-	mockTC.CreatedDate = time.Now()
-	mockTC.Reporter = "Demo User"
-	return &mockTC
+// parseJiraTime parses the timestamp format Jira uses for created and updated
+func parseJiraTime(value string) time.Time {
+	t, err := time.Parse(jiraTimeLayout, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
 }
 
-func (c *Client) createMockTestExecution(te *TestExecution) *TestExecution {
-	mockTE := *te
-	mockTE.ID = "10005"
-	mockTE.Key = "EXEC-1"
-	mockTE.Status = "To Do"
-	mockTE.ExecutionStatus = "TODO"
-	mockTE.StartDate = time.Now()
-	mockTE.ExecutedBy = "Demo User"
-	return &mockTE
+// toComponents converts component names into Jira components
+func toComponents(names []string) []Component {
+	components := make([]Component, 0, len(names))
+	for _, name := range names {
+		components = append(components, Component{Name: name})
+	}
+	return components
 }
 
-func (c *Client) getMockTestExecution(key string) *TestExecution {
-	return &TestExecution{
-		ID:              "10005",
-		Key:             key,
-		Summary:         "Demo Test Execution",
-		Description:     "This is a demo test execution",
-		Status:          "In Progress",
-		TestCases:       []string{"TEST-1", "TEST-2"},
-		ExecutionStatus: "EXECUTING",
-		StartDate:       time.Now().AddDate(0, 0, -1),
-		ExecutedBy:      "Demo User",
-		Environment:     "QA",
-		TestResults: []TestResult{
-			{
-				TestCaseKey:   "TEST-1",
-				Status:        "PASS",
-				Comment:       "Test passed successfully",
-				ExecutionTime: 5000,
-				ExecutedBy:    "Demo User",
-				ExecutedOn:    time.Now(),
-			},
-			{
-				TestCaseKey:   "TEST-2",
-				Status:        "FAIL",
-				Comment:       "Test failed due to timeout",
-				ExecutionTime: 10000,
-				ExecutedBy:    "Demo User",
-				ExecutedOn:    time.Now(),
-				Defects:       []string{"BUG-123"},
-			},
-		},
+// Myself returns the user the client authenticates as, which checks both
+// that Jira is reachable and that it accepts the credentials
+func (c *Client) Myself() (*User, error) {
+	resp, err := c.makeRequest("GET", "myself", nil)
+	if err != nil {
+		return nil, err
+	}
+	var user User
+	if err := c.handleResponse(resp, &user); err != nil {
+		return nil, err
 	}
+	return &user, nil
 }
@@ -0,0 +1,327 @@
+package jira_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/jiratest"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newFakeJira starts a fake Jira with demo data and returns a client for it
+func newFakeJira(t *testing.T) (*jiratest.Server, *jira.Client) {
+	t.Helper()
+	srv := jiratest.NewServer("TEST")
+	srv.Backend.SeedDemoData()
+	t.Cleanup(srv.Close)
+	return srv, srv.JiraClient()
+}
+
+func TestClientErrorMapping(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantNotFound  bool
+		wantRetriable bool
+		wantMessage   string
+	}{
+		{
+			name:         "not found",
+			status:       http.StatusNotFound,
+			body:         `{"errorMessages":["Issue does not exist or you do not have permission to see it."],"errors":{}}`,
+			wantNotFound: true,
+			wantMessage:  "Jira API error (HTTP 404): [Issue does not exist or you do not have permission to see it.]",
+		},
+		{
+			name:        "field errors",
+			status:      http.StatusBadRequest,
+			body:        `{"errorMessages":[],"errors":{"summary":"You must specify a summary of the issue."}}`,
+			wantMessage: "map[summary:You must specify a summary of the issue.]",
+		},
+		{
+			name:        "unauthorized",
+			status:      http.StatusUnauthorized,
+			body:        `{"errorMessages":["Client must be authenticated to access this resource."],"errors":{}}`,
+			wantMessage: "HTTP 401",
+		},
+		{
+			name:          "rate limited",
+			status:        http.StatusTooManyRequests,
+			body:          `{"errorMessages":["Rate limit exceeded."]}`,
+			wantRetriable: true,
+			wantMessage:   "HTTP 429",
+		},
+		{
+			name:          "server error with an HTML body",
+			status:        http.StatusBadGateway,
+			body:          `<html><body>Bad Gateway</body></html>`,
+			wantRetriable: true,
+			wantMessage:   "HTTP 502: <html><body>Bad Gateway</body></html>",
+		},
+		{
+			name:          "unavailable",
+			status:        http.StatusServiceUnavailable,
+			body:          ``,
+			wantRetriable: true,
+			wantMessage:   "HTTP 503",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			client := jira.NewClient(srv.URL, "user", "token", "TEST")
+			_, err := client.GetTestCase("TEST-1")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			var apiErr *jira.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Fatalf("got %v, want an APIError with status %d", err, tt.status)
+			}
+			if got := jira.IsNotFound(err); got != tt.wantNotFound {
+				t.Errorf("IsNotFound = %t, want %t", got, tt.wantNotFound)
+			}
+			if got := jira.IsRetriable(err); got != tt.wantRetriable {
+				t.Errorf("IsRetriable = %t, want %t", got, tt.wantRetriable)
+			}
+			if !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("error %q does not contain %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestClientNetworkErrorIsRetriable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	baseURL := srv.URL
+	srv.Close()
+
+	_, err := jira.NewClient(baseURL, "user", "token", "TEST").ListTestCases()
+	if err == nil || !jira.IsRetriable(err) {
+		t.Errorf("got %v, want a retriable error", err)
+	}
+	if jira.IsNotFound(err) {
+		t.Errorf("a network error is reported as not found")
+	}
+}
+
+func TestClientSearchPagination(t *testing.T) {
+	srv, client := newFakeJira(t)
+	for i := 0; i < 230; i++ {
+		_, err := srv.Backend.CreateIssue(jira.IssueFields{
+			Summary:   fmt.Sprintf("Generated test %d", i),
+			IssueType: jira.IssueType{Name: jira.IssueTypeTest},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv.ResetRequests()
+
+	issues, err := client.SearchIssues(`project = TEST AND issuetype = "Test"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 233 {
+		t.Errorf("got %d issues, want 233", len(issues))
+	}
+	seen := make(map[string]bool)
+	for _, issue := range issues {
+		if seen[issue.Key] {
+			t.Errorf("%s returned twice", issue.Key)
+		}
+		seen[issue.Key] = true
+	}
+
+	var startAts []string
+	for _, req := range srv.Requests() {
+		startAts = append(startAts, queryValue(t, req.Query, "startAt"))
+	}
+	if got := strings.Join(startAts, ","); got != "0,100,200" {
+		t.Errorf("requested pages at %s, want 0,100,200", got)
+	}
+}
+
+func TestClientSearchStopsOnShortPage(t *testing.T) {
+	// A server that reports more issues than it returns must not make the client loop forever
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		issues := []jira.JiraIssue{}
+		if r.URL.Query().Get("startAt") == "0" {
+			issues = append(issues, jira.JiraIssue{Key: "TEST-1"})
+		}
+		json.NewEncoder(w).Encode(jira.JiraResponse{Issues: issues, Total: 5})
+	}))
+	defer srv.Close()
+
+	issues, err := jira.NewClient(srv.URL, "user", "token", "TEST").SearchIssues("project = TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || requests != 2 {
+		t.Errorf("got %d issues in %d requests, want 1 in 2", len(issues), requests)
+	}
+}
+
+func TestClientTestCases(t *testing.T) {
+	srv, client := newFakeJira(t)
+
+	created, err := client.CreateTestCase(&jira.TestCase{
+		Summary:     "Checkout with saved card",
+		Description: "Pay with a stored card",
+		Priority:    "High",
+		Labels:      []string{"checkout"},
+		Components:  []string{"payments"},
+		Steps: []jira.TestStep{
+			{Action: "Open the cart", ExpectedResult: "Cart is shown"},
+			{Action: "Pay", Data: "card ending 4242"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Key != "TEST-11" || created.ID == "" {
+		t.Errorf("got key %q and id %q", created.Key, created.ID)
+	}
+
+	got, err := client.GetTestCase(created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Summary != "Checkout with saved card" || got.Description != "Pay with a stored card" || got.Priority != "High" {
+		t.Errorf("got %+v", got)
+	}
+	if len(got.Steps) != 2 || got.Steps[1].Data != "card ending 4242" {
+		t.Errorf("got steps %+v", got.Steps)
+	}
+	if len(got.Components) != 1 || got.Components[0] != "payments" || got.Status != "To Do" {
+		t.Errorf("got components %v and status %q", got.Components, got.Status)
+	}
+
+	got.Summary = "Checkout with a saved card"
+	got.Labels = []string{"checkout", "regression"}
+	if _, err := client.UpdateTestCase(got); err != nil {
+		t.Fatal(err)
+	}
+	issue, err := srv.Backend.Issue(created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Fields.Summary != "Checkout with a saved card" || len(issue.Fields.Labels) != 2 {
+		t.Errorf("update was not applied: %+v", issue.Fields)
+	}
+
+	testCases, err := client.ListTestCases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(testCases) != 4 {
+		t.Errorf("got %d test cases, want 4", len(testCases))
+	}
+
+	if _, err := client.GetTestCase("TEST-999"); !jira.IsNotFound(err) {
+		t.Errorf("got %v, want not found", err)
+	}
+}
+
+func TestClientTestExecutions(t *testing.T) {
+	srv, client := newFakeJira(t)
+
+	created, err := client.CreateTestExecution(&jira.TestExecution{
+		Summary:    "Nightly run",
+		TestCases:  []string{"TEST-1"},
+		FixVersion: "1.0",
+		Labels:     []string{"nightly"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent jira.CreateIssueRequest
+	requests := srv.Requests()
+	if err := json.Unmarshal(requests[len(requests)-1].Body, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Fields.IssueType.Name != jira.IssueTypeTestExecution || sent.Fields.Project.Key != "TEST" || sent.Fields.Labels[0] != "nightly" {
+		t.Errorf("sent %+v", sent.Fields)
+	}
+
+	got, err := client.GetTestExecution(created.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Summary != "Nightly run" || got.FixVersion != "1.0" {
+		t.Errorf("got %+v", got)
+	}
+
+	seeded, err := client.GetTestExecution("TEST-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(seeded.TestCases, ",") != "TEST-1,TEST-2,TEST-3" {
+		t.Errorf("got linked tests %v", seeded.TestCases)
+	}
+
+	executions, err := client.ListTestExecutions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 3 {
+		t.Errorf("got %d executions, want 3", len(executions))
+	}
+}
+
+func TestClientSendsCredentials(t *testing.T) {
+	srv, _ := newFakeJira(t)
+
+	_, err := jira.NewClient(srv.URL, jiratest.Username, "wrong-token", "TEST").ListTestCases()
+	var apiErr *jira.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got %v, want 401", err)
+	}
+}
+
+func TestClientMyself(t *testing.T) {
+	srv, _ := newFakeJira(t)
+
+	user, err := jira.NewClient(srv.URL, jiratest.Username, jiratest.APIToken, "TEST").Myself()
+	if err != nil || user.EmailAddress != jiratest.Username {
+		t.Errorf("got %+v, %v", user, err)
+	}
+	_, err = jira.NewClient(srv.URL, jiratest.Username, "wrong-token", "TEST").Myself()
+	if !jira.IsUnauthorized(err) || jira.IsRetriable(err) {
+		t.Errorf("got %v, want a rejected credential", err)
+	}
+}
+
+func queryValue(t *testing.T, rawQuery, name string) string {
+	t.Helper()
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values.Get(name)
+}
@@ -0,0 +1,108 @@
+package jira
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Credentials identify a Jira user: a username with an API token, or an
+// OAuth 2.0 access token or personal access token
+type Credentials struct {
+	Username    string
+	APIToken    string
+	AccessToken string
+}
+
+// User names the credentials for logs without revealing secrets
+func (c Credentials) User() string {
+	if c.AccessToken != "" {
+		return "access token " + c.key()[:8]
+	}
+	return c.Username
+}
+
+// key identifies credentials by a hash of all their fields, so a cached client
+// is only reused by a caller that presents the same secret
+func (c Credentials) key() string {
+	sum := sha256.Sum256([]byte(c.Username + "\x00" + c.APIToken + "\x00" + c.AccessToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClientCache keeps a Client per set of caller credentials for a short time,
+// so callers acting as themselves do not get a new client on every request.
+// It holds at most size clients, dropping the least recently used to make room.
+type ClientCache struct {
+	ttl       time.Duration
+	size      int
+	newClient func(Credentials) *Client
+	now       func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*list.Element // of *cachedClient, by credentials key
+	recent  *list.List               // most recently used first
+}
+
+type cachedClient struct {
+	key     string
+	client  *Client
+	expires time.Time
+}
+
+// NewClientCache creates a cache of at most size clients, which expire ttl
+// after they were created
+func NewClientCache(ttl time.Duration, size int, newClient func(Credentials) *Client) *ClientCache {
+	return &ClientCache{
+		ttl:       ttl,
+		size:      size,
+		newClient: newClient,
+		now:       time.Now,
+		clients:   make(map[string]*list.Element),
+		recent:    list.New(),
+	}
+}
+
+// Get returns the cached client for creds, creating one if there is none or it expired
+func (cc *ClientCache) Get(creds Credentials) *Client {
+	key := creds.key()
+	now := cc.now()
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if e, ok := cc.clients[key]; ok {
+		if cached := e.Value.(*cachedClient); now.Before(cached.expires) {
+			cc.recent.MoveToFront(e)
+			return cached.client
+		}
+		cc.remove(e)
+	}
+
+	client := cc.newClient(creds)
+	cc.clients[key] = cc.recent.PushFront(&cachedClient{key: key, client: client, expires: now.Add(cc.ttl)})
+	// Drop the least recently used clients while the cache is over its size
+	// or they expired, keeping the one just created
+	for cc.recent.Len() > 1 {
+		e := cc.recent.Back()
+		if cc.recent.Len() <= cc.size && now.Before(e.Value.(*cachedClient).expires) {
+			break
+		}
+		cc.remove(e)
+	}
+	return client
+}
+
+// remove drops a cached client
+func (cc *ClientCache) remove(e *list.Element) {
+	cc.recent.Remove(e)
+	delete(cc.clients, e.Value.(*cachedClient).key)
+}
+
+// Len returns the number of cached clients, including expired ones not yet removed
+func (cc *ClientCache) Len() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.clients)
+}
@@ -0,0 +1,104 @@
+package jira_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/jiratest"
+)
+
+func TestClientCache(t *testing.T) {
+	created := 0
+	newClient := func(creds jira.Credentials) *jira.Client {
+		created++
+		client := jira.NewClient("https://jira.example.com", creds.Username, creds.APIToken, "TEST")
+		client.AccessToken = creds.AccessToken
+		return client
+	}
+	alice := jira.Credentials{Username: "alice@example.com", APIToken: "alice-token"}
+
+	cache := jira.NewClientCache(time.Hour, 10, newClient)
+	first := cache.Get(alice)
+	if cache.Get(alice) != first || created != 1 {
+		t.Errorf("client was not reused, %d created", created)
+	}
+
+	// The same user with another token must not get the cached client
+	if cache.Get(jira.Credentials{Username: alice.Username, APIToken: "guessed"}) == first {
+		t.Error("client was reused for a different token")
+	}
+	if got := cache.Get(jira.Credentials{AccessToken: "oauth-token"}); got.AccessToken != "oauth-token" || cache.Len() != 3 {
+		t.Errorf("got access token %q with %d clients cached", got.AccessToken, cache.Len())
+	}
+
+	// Expired clients are replaced and swept
+	expiring := jira.NewClientCache(0, 10, newClient)
+	first = expiring.Get(alice)
+	if expiring.Get(alice) == first {
+		t.Error("expired client was reused")
+	}
+	expiring.Get(jira.Credentials{AccessToken: "oauth-token"})
+	if n := expiring.Len(); n != 1 {
+		t.Errorf("got %d cached clients after expiry, want 1", n)
+	}
+}
+
+func TestClientCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	newClient := func(creds jira.Credentials) *jira.Client {
+		return jira.NewClient("https://jira.example.com", creds.Username, creds.APIToken, "TEST")
+	}
+	user := func(i int) jira.Credentials {
+		return jira.Credentials{Username: fmt.Sprintf("user%d@example.com", i), APIToken: "token"}
+	}
+
+	cache := jira.NewClientCache(time.Hour, 3, newClient)
+	first := cache.Get(user(0))
+	for i := 1; i < 10; i++ {
+		// user 0 stays recently used while the others fill the cache
+		if cache.Get(user(0)) != first {
+			t.Fatalf("the most recently used client was dropped after %d others", i-1)
+		}
+		cache.Get(user(i))
+		if n := cache.Len(); n > 3 {
+			t.Fatalf("got %d cached clients, want at most 3", n)
+		}
+	}
+
+	// user 8 is now the least recently used, so it is dropped to make room
+	ninth := cache.Get(user(9))
+	cache.Get(user(10))
+	if cache.Get(user(9)) != ninth {
+		t.Error("a recently used client was dropped")
+	}
+	if cache.Len() != 3 {
+		t.Errorf("got %d cached clients, want 3", cache.Len())
+	}
+}
+
+func TestCredentialsUser(t *testing.T) {
+	if got := (jira.Credentials{Username: "alice@example.com", APIToken: "secret"}).User(); got != "alice@example.com" {
+		t.Errorf("got %q", got)
+	}
+	if got := (jira.Credentials{AccessToken: "secret-token"}).User(); got == "" || strings.Contains(got, "secret") {
+		t.Errorf("got %q, want a name that does not reveal the token", got)
+	}
+}
+
+func TestClientAccessToken(t *testing.T) {
+	srv := jiratest.NewServer("TEST")
+	srv.Backend.SeedDemoData()
+	t.Cleanup(srv.Close)
+	srv.AccessTokens["oauth-token"] = "bob@example.com"
+
+	client := jira.NewClient(srv.URL, "", "", "TEST")
+	client.AccessToken = "oauth-token"
+	if _, err := client.GetTestCase("TEST-1"); err != nil {
+		t.Fatalf("GetTestCase: %v", err)
+	}
+	if requests := srv.Requests(); len(requests) != 1 || requests[0].User != "bob@example.com" {
+		t.Errorf("got requests %+v", requests)
+	}
+}
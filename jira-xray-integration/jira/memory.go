@@ -0,0 +1,766 @@
+package jira
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryBackend is a TestManagementBackend that keeps issues in process. It
+// assigns sequential keys, links executions to their tests, keeps links in
+// both directions and answers the subset of JQL this application uses. Data
+// is lost on restart.
+type MemoryBackend struct {
+	ProjectKey string
+	Reporter   string // display name recorded as the reporter of created issues
+
+	mu       sync.RWMutex
+	projects []string // further projects CreateIssue accepts
+	issues   map[string]*JiraIssue
+	order    []string // keys in creation order
+	nextID   int
+}
+
+// NewMemoryBackend creates an empty in-memory backend for a project
+func NewMemoryBackend(projectKey string) *MemoryBackend {
+	return &MemoryBackend{
+		ProjectKey: projectKey,
+		Reporter:   "Demo User",
+		issues:     make(map[string]*JiraIssue),
+		nextID:     10001,
+	}
+}
+
+// AddProject lets CreateIssue create issues in another project, as on a Jira
+// site with several projects. The TestManagementBackend methods keep working
+// on ProjectKey only.
+func (b *MemoryBackend) AddProject(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.projects = append(b.projects, key)
+}
+
+// HasProject reports whether key is the backend's project or one added with
+// AddProject
+func (b *MemoryBackend) HasProject(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.hasProject(key)
+}
+
+// SearchIssues implements TestManagementBackend
+func (b *MemoryBackend) SearchIssues(jql string) ([]JiraIssue, error) {
+	query, err := parseJQL(jql)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var matches []JiraIssue
+	for _, key := range b.order {
+		issue := b.issues[key]
+		ok, err := query.matches(issue)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, b.resolve(issue))
+		}
+	}
+	return matches, nil
+}
+
+// ListTestCases implements TestManagementBackend
+func (b *MemoryBackend) ListTestCases() ([]TestCase, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	testCases := []TestCase{}
+	for _, issue := range b.ofType(IssueTypeTest) {
+		testCases = append(testCases, *TestCaseFromIssue(&issue))
+	}
+	return testCases, nil
+}
+
+// GetTestCase implements TestManagementBackend
+func (b *MemoryBackend) GetTestCase(key string) (*TestCase, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	issue, ok := b.issues[key]
+	if !ok {
+		return nil, issueNotFound(key)
+	}
+	if issue.Fields.IssueType.Name != IssueTypeTest {
+		return nil, &IssueTypeError{Key: key, IssueType: issue.Fields.IssueType.Name, Want: IssueTypeTest}
+	}
+	resolved := b.resolve(issue)
+	return TestCaseFromIssue(&resolved), nil
+}
+
+// CreateTestCase implements TestManagementBackend
+func (b *MemoryBackend) CreateTestCase(tc *TestCase) (*TestCase, error) {
+	if tc.Summary == "" {
+		return nil, summaryRequired()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	issue := b.create(IssueFields{
+		Summary:     tc.Summary,
+		Description: formatDescription(tc.Description, tc.Steps),
+		IssueType:   IssueType{Name: IssueTypeTest},
+		Priority:    Priority{Name: tc.Priority},
+		Labels:      tc.Labels,
+		Components:  toComponents(tc.Components),
+	})
+
+	createdTC := *tc
+	createdTC.ID = issue.ID
+	createdTC.Key = issue.Key
+	createdTC.Status = issue.Fields.Status.Name
+	createdTC.CreatedDate = parseJiraTime(issue.Fields.Created)
+	createdTC.Reporter = b.Reporter
+	return &createdTC, nil
+}
+
+// UpdateTestCase implements TestManagementBackend
+func (b *MemoryBackend) UpdateTestCase(tc *TestCase) (*TestCase, error) {
+	if tc.Summary == "" {
+		return nil, summaryRequired()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	issue, ok := b.issues[tc.Key]
+	if !ok {
+		return nil, issueNotFound(tc.Key)
+	}
+	issue.Fields.Summary = tc.Summary
+	issue.Fields.Description = formatDescription(tc.Description, tc.Steps)
+	if tc.Labels != nil {
+		issue.Fields.Labels = append([]string(nil), tc.Labels...)
+	}
+	if tc.Components != nil {
+		issue.Fields.Components = toComponents(tc.Components)
+	}
+	if tc.Priority != "" {
+		issue.Fields.Priority = Priority{Name: tc.Priority}
+	}
+	now := time.Now()
+	issue.Fields.Updated = now.Format(jiraTimeLayout)
+
+	updatedTC := *tc
+	updatedTC.UpdatedDate = now
+	return &updatedTC, nil
+}
+
+// ListTestExecutions implements TestManagementBackend
+func (b *MemoryBackend) ListTestExecutions() ([]TestExecution, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	testExecutions := []TestExecution{}
+	for _, issue := range b.ofType(IssueTypeTestExecution) {
+		testExecutions = append(testExecutions, *TestExecutionFromIssue(&issue))
+	}
+	return testExecutions, nil
+}
+
+// GetTestExecution implements TestManagementBackend
+func (b *MemoryBackend) GetTestExecution(key string) (*TestExecution, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	issue, ok := b.issues[key]
+	if !ok {
+		return nil, issueNotFound(key)
+	}
+	if issue.Fields.IssueType.Name != IssueTypeTestExecution {
+		return nil, &IssueTypeError{Key: key, IssueType: issue.Fields.IssueType.Name, Want: IssueTypeTestExecution}
+	}
+	resolved := b.resolve(issue)
+	return TestExecutionFromIssue(&resolved), nil
+}
+
+// CreateTestExecution implements TestManagementBackend. The execution is linked
+// to each of its test cases that exists.
+func (b *MemoryBackend) CreateTestExecution(te *TestExecution) (*TestExecution, error) {
+	if te.Summary == "" {
+		return nil, summaryRequired()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	fields := IssueFields{
+		Summary:     te.Summary,
+		Description: te.Description,
+		IssueType:   IssueType{Name: IssueTypeTestExecution},
+		Labels:      te.Labels,
+	}
+	if te.FixVersion != "" {
+		fields.FixVersions = []Version{{Name: te.FixVersion}}
+	}
+	issue := b.create(fields)
+	for _, testKey := range te.TestCases {
+		if _, ok := b.issues[testKey]; ok {
+			b.link(issue.Key, "Test", testKey)
+		}
+	}
+
+	createdTE := *te
+	createdTE.ID = issue.ID
+	createdTE.Key = issue.Key
+	createdTE.Status = issue.Fields.Status.Name
+	createdTE.ExecutionStatus = StatusTodo
+	createdTE.StartDate = parseJiraTime(issue.Fields.Created)
+	return &createdTE, nil
+}
+
+// CreateIssue adds an issue with the next key of its project. The issue type
+// and summary are required; the project, if given, must be the backend's or
+// one added with AddProject.
+func (b *MemoryBackend) CreateIssue(fields IssueFields) (*JiraIssue, error) {
+	if fields.Summary == "" {
+		return nil, summaryRequired()
+	}
+	if fields.IssueType.Name == "" {
+		return nil, fieldError("issuetype", "Specify an issue type")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if fields.Project.Key != "" && !b.hasProject(fields.Project.Key) {
+		projects := strings.Join(append([]string{b.ProjectKey}, b.projects...), ", ")
+		return nil, fieldError("project", fmt.Sprintf("Specify a valid project ID or key, only %s exist", projects))
+	}
+	fields.IssueLinks = nil
+	issue := b.create(fields)
+	resolved := b.resolve(issue)
+	return &resolved, nil
+}
+
+// Issue returns an issue by key
+func (b *MemoryBackend) Issue(key string) (*JiraIssue, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	issue, ok := b.issues[key]
+	if !ok {
+		return nil, issueNotFound(key)
+	}
+	resolved := b.resolve(issue)
+	return &resolved, nil
+}
+
+// UpdateIssue changes the fields of an issue with update and bumps its updated time
+func (b *MemoryBackend) UpdateIssue(key string, update func(fields *IssueFields)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	issue, ok := b.issues[key]
+	if !ok {
+		return issueNotFound(key)
+	}
+	update(&issue.Fields)
+	issue.Fields.Updated = time.Now().Format(jiraTimeLayout)
+	return nil
+}
+
+// DeleteIssue removes an issue and the links other issues have to it
+func (b *MemoryBackend) DeleteIssue(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.issues[key]; !ok {
+		return issueNotFound(key)
+	}
+	delete(b.issues, key)
+	for i, k := range b.order {
+		if k == key {
+			b.order = append(b.order[:i], b.order[i+1:]...)
+			break
+		}
+	}
+	for _, issue := range b.issues {
+		links := issue.Fields.IssueLinks[:0]
+		for _, link := range issue.Fields.IssueLinks {
+			if link.LinkedIssue().Key != key {
+				links = append(links, link)
+			}
+		}
+		issue.Fields.IssueLinks = links
+	}
+	return nil
+}
+
+// LinkIssues links from to to with an outward link of linkType on from and
+// the matching inward link on to. Both issues must exist.
+func (b *MemoryBackend) LinkIssues(from, linkType, to string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, key := range []string{from, to} {
+		if _, ok := b.issues[key]; !ok {
+			return issueNotFound(key)
+		}
+	}
+	b.link(from, linkType, to)
+	return nil
+}
+
+// AddAttachment records attachment metadata on an issue, assigning its ID and creation time
+func (b *MemoryBackend) AddAttachment(key string, attachment Attachment) (*Attachment, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	issue, ok := b.issues[key]
+	if !ok {
+		return nil, issueNotFound(key)
+	}
+	attachment.ID = strconv.Itoa(b.nextID)
+	b.nextID++
+	attachment.Created = time.Now().Format(jiraTimeLayout)
+	issue.Fields.Attachments = append(issue.Fields.Attachments, attachment)
+	return &attachment, nil
+}
+
+// SeedDemoData adds the demo requirements, tests, test plan, executions and defect
+func (b *MemoryBackend) SeedDemoData() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	test := func(key, summary, description, status, priority string, age int, labels []string, steps []TestStep) {
+		b.put(key, IssueFields{
+			Summary:     summary,
+			Description: formatDescription(description, steps),
+			IssueType:   IssueType{Name: IssueTypeTest},
+			Status:      Status{Name: status},
+			Priority:    Priority{Name: priority},
+			Labels:      labels,
+		}, now.AddDate(0, 0, -age))
+	}
+	issue := func(key, summary, issueType, status string) {
+		b.put(key, IssueFields{
+			Summary:   summary,
+			IssueType: IssueType{Name: issueType},
+			Status:    Status{Name: status},
+		}, now.AddDate(0, 0, -1))
+	}
+
+	test("TEST-1", "Login functionality test", "Test user login with valid credentials", "To Do", "High", 7,
+		[]string{"login", "authentication"}, []TestStep{
+			{Action: "Open the login page", ExpectedResult: "Login form is shown"},
+			{Action: "Submit valid credentials", Data: "demo@example.com / secret", ExpectedResult: "User is signed in"},
+		})
+	test("TEST-2", "Password reset functionality", "Test password reset flow", "In Progress", "Medium", 5,
+		[]string{"password", "reset"}, nil)
+	test("TEST-3", "User registration validation", "Test user registration with various input validations", "Done", "Medium", 3,
+		[]string{"registration", "validation"}, nil)
+
+	issue("TEST-4", "User can sign in", "Story", "Done")
+	issue("TEST-5", "User can register", "Story", "In Progress")
+	issue("TEST-6", "User can delete their account", "Story", "To Do")
+	issue("TEST-7", "Password reset email times out", "Bug", "Open")
+	issue("TEST-8", "Release 1.0 regression", IssueTypeTestPlan, "In Progress")
+	issue("TEST-9", "Sprint 1 Test Execution", IssueTypeTestExecution, "In Progress")
+	issue("TEST-10", "Regression Test Execution", IssueTypeTestExecution, "Done")
+
+	b.link("TEST-4", "Test", "TEST-1")
+	b.link("TEST-4", "Test", "TEST-2")
+	b.link("TEST-5", "Test", "TEST-3")
+	b.link("TEST-2", "Blocks", "TEST-7")
+	b.link("TEST-8", "Test", "TEST-1")
+	b.link("TEST-8", "Test", "TEST-3")
+	b.link("TEST-9", "Test", "TEST-1")
+	b.link("TEST-9", "Test", "TEST-2")
+	b.link("TEST-10", "Test", "TEST-1")
+	b.link("TEST-10", "Test", "TEST-2")
+	b.link("TEST-10", "Test", "TEST-3")
+}
+
+// create adds an issue with the next key of its project, the backend's unless fields name another
+func (b *MemoryBackend) create(fields IssueFields) *JiraIssue {
+	if fields.Status.Name == "" {
+		fields.Status = Status{Name: "To Do"}
+	}
+	if fields.Project.Key == "" {
+		fields.Project = Project{Key: b.ProjectKey}
+	}
+	return b.put(b.nextKey(fields.Project.Key), fields, time.Now())
+}
+
+func (b *MemoryBackend) put(key string, fields IssueFields, created time.Time) *JiraIssue {
+	if fields.Project.Key == "" {
+		fields.Project = Project{Key: b.ProjectKey}
+	}
+	fields.Project = Project{Key: strings.ToUpper(fields.Project.Key)}
+	if fields.Reporter.DisplayName == "" {
+		fields.Reporter = User{DisplayName: b.Reporter}
+	}
+	fields.Created = created.Format(jiraTimeLayout)
+	fields.Updated = fields.Created
+
+	issue := &JiraIssue{ID: strconv.Itoa(b.nextID), Key: key, Fields: fields}
+	b.nextID++
+	b.issues[key] = issue
+	b.order = append(b.order, key)
+	return issue
+}
+
+// hasProject reports whether issues can be created in a project
+func (b *MemoryBackend) hasProject(key string) bool {
+	if strings.EqualFold(key, b.ProjectKey) {
+		return true
+	}
+	for _, p := range b.projects {
+		if strings.EqualFold(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextKey returns the project key followed by one more than the highest issue number in use
+func (b *MemoryBackend) nextKey(project string) string {
+	prefix := strings.ToUpper(project) + "-"
+	highest := 0
+	for key := range b.issues {
+		if n, err := strconv.Atoi(strings.TrimPrefix(key, prefix)); err == nil && strings.HasPrefix(key, prefix) && n > highest {
+			highest = n
+		}
+	}
+	return prefix + strconv.Itoa(highest+1)
+}
+
+// link records an outward link on from and the matching inward link on to
+func (b *MemoryBackend) link(from, linkType, to string) {
+	source, ok := b.issues[from]
+	if !ok {
+		return
+	}
+	source.Fields.IssueLinks = append(source.Fields.IssueLinks, IssueLink{
+		Type:         IssueLinkType{Name: linkType},
+		OutwardIssue: &LinkedIssue{Key: to},
+	})
+	if target, ok := b.issues[to]; ok {
+		target.Fields.IssueLinks = append(target.Fields.IssueLinks, IssueLink{
+			Type:        IssueLinkType{Name: linkType},
+			InwardIssue: &LinkedIssue{Key: from},
+		})
+	}
+}
+
+// resolve copies an issue, filling in the current fields of the issues it links to
+func (b *MemoryBackend) resolve(issue *JiraIssue) JiraIssue {
+	resolved := *issue
+	resolved.Fields.Labels = append([]string(nil), issue.Fields.Labels...)
+	resolved.Fields.Components = append([]Component(nil), issue.Fields.Components...)
+	resolved.Fields.FixVersions = append([]Version(nil), issue.Fields.FixVersions...)
+	resolved.Fields.Attachments = append([]Attachment(nil), issue.Fields.Attachments...)
+	if issue.Fields.Custom != nil {
+		resolved.Fields.Custom = make(map[string]interface{}, len(issue.Fields.Custom))
+		for id, value := range issue.Fields.Custom {
+			resolved.Fields.Custom[id] = value
+		}
+	}
+	resolved.Fields.IssueLinks = make([]IssueLink, len(issue.Fields.IssueLinks))
+	for i, link := range issue.Fields.IssueLinks {
+		linked := *link.LinkedIssue()
+		if target, ok := b.issues[linked.Key]; ok {
+			linked.ID = target.ID
+			linked.Fields = LinkedIssueFields{
+				Summary:   target.Fields.Summary,
+				Status:    target.Fields.Status,
+				Priority:  target.Fields.Priority,
+				IssueType: target.Fields.IssueType,
+			}
+		}
+		if link.OutwardIssue != nil {
+			link.OutwardIssue = &linked
+		} else {
+			link.InwardIssue = &linked
+		}
+		resolved.Fields.IssueLinks[i] = link
+	}
+	return resolved
+}
+
+// ofType returns the resolved issues of the backend's project with an issue type in creation order
+func (b *MemoryBackend) ofType(issueType string) []JiraIssue {
+	var issues []JiraIssue
+	for _, key := range b.order {
+		issue := b.issues[key]
+		if strings.EqualFold(issue.Fields.IssueType.Name, issueType) && strings.EqualFold(issue.Fields.Project.Key, b.ProjectKey) {
+			issues = append(issues, b.resolve(issue))
+		}
+	}
+	return issues
+}
+
+func issueNotFound(key string) error {
+	return &APIError{
+		StatusCode: http.StatusNotFound,
+		Body:       fmt.Sprintf("Issue %s does not exist", key),
+		Response:   ErrorResponse{ErrorMessages: []string{"Issue does not exist or you do not have permission to see it."}},
+		parsed:     true,
+	}
+}
+
+func summaryRequired() error {
+	return fieldError("summary", "You must specify a summary of the issue.")
+}
+
+func fieldError(field, message string) error {
+	return &APIError{
+		StatusCode: http.StatusBadRequest,
+		Body:       message,
+		Response:   ErrorResponse{Errors: map[string]string{field: message}},
+		parsed:     true,
+	}
+}
+
+// jqlQuery is a parsed JQL query: clauses joined by AND
+type jqlQuery []jqlClause
+
+type jqlClause struct {
+	field  string
+	op     string
+	values []string
+}
+
+// jqlDateLayouts are the date formats JQL accepts, interpreted in UTC
+var jqlDateLayouts = []string{"2006/01/02 15:04", "2006-01-02 15:04", "2006/01/02", "2006-01-02"}
+
+// parseJQL parses the subset of JQL the in-memory backend supports: clauses on
+// project, issuetype, key, status, labels, fixVersion, summary, created and
+// updated, joined by AND and grouped with parentheses, optionally followed by
+// ORDER BY (which is ignored)
+func parseJQL(jql string) (jqlQuery, error) {
+	if i := indexOutsideQuotes(jql, " order by "); i >= 0 {
+		jql = jql[:i]
+	}
+	if indexOutsideQuotes(jql, " or ") >= 0 {
+		return nil, jqlError("OR is not supported by the in-memory backend")
+	}
+
+	var query jqlQuery
+	for _, part := range splitOutsideQuotes(jql, " and ") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "(") && strings.HasSuffix(part, ")") {
+			group, err := parseJQL(part[1 : len(part)-1])
+			if err != nil {
+				return nil, err
+			}
+			query = append(query, group...)
+			continue
+		}
+		clause, err := parseJQLClause(part)
+		if err != nil {
+			return nil, err
+		}
+		query = append(query, clause)
+	}
+	return query, nil
+}
+
+// jqlOperators are tried longest first at each position of a clause
+var jqlOperators = []string{"not in", "in", "!=", ">=", "<=", "=", ">", "<", "~"}
+
+func parseJQLClause(text string) (jqlClause, error) {
+	field, op, value, ok := splitJQLClause(text)
+	if !ok {
+		return jqlClause{}, jqlError(fmt.Sprintf("cannot parse clause %q", text))
+	}
+
+	clause := jqlClause{field: strings.ToLower(field), op: op}
+	if op == "in" || op == "not in" {
+		if !strings.HasPrefix(value, "(") || !strings.HasSuffix(value, ")") {
+			return clause, jqlError(fmt.Sprintf("expected a list in parentheses after %s in %q", op, text))
+		}
+		for _, v := range splitOutsideQuotes(value[1:len(value)-1], ",") {
+			clause.values = append(clause.values, unquoteJQL(v))
+		}
+	} else {
+		clause.values = []string{unquoteJQL(value)}
+	}
+	return clause, nil
+}
+
+// splitJQLClause finds the first operator in a clause, ignoring the field name and quoted values
+func splitJQLClause(text string) (field, op, value string, ok bool) {
+	lower := strings.ToLower(text)
+	for i := 1; i < len(text); i++ {
+		if text[i] == '"' || text[i] == '\'' {
+			return "", "", "", false
+		}
+		for _, candidate := range jqlOperators {
+			if !strings.HasPrefix(lower[i:], candidate) {
+				continue
+			}
+			end := i + len(candidate)
+			// Word operators must stand on their own
+			if candidate[0] == 'n' || candidate[0] == 'i' {
+				if text[i-1] != ' ' || end >= len(text) || (text[end] != ' ' && text[end] != '(') {
+					continue
+				}
+			}
+			return strings.TrimSpace(text[:i]), candidate, strings.TrimSpace(text[end:]), true
+		}
+	}
+	return "", "", "", false
+}
+
+func (q jqlQuery) matches(issue *JiraIssue) (bool, error) {
+	for _, clause := range q {
+		ok, err := clause.matches(issue)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (c jqlClause) matches(issue *JiraIssue) (bool, error) {
+	switch c.field {
+	case "created", "updated":
+		return c.matchesDate(issue)
+	case "summary":
+		if c.op != "~" {
+			break
+		}
+		return strings.Contains(strings.ToLower(issue.Fields.Summary), strings.ToLower(c.values[0])), nil
+	}
+
+	var actual []string
+	switch c.field {
+	case "project":
+		actual = []string{issue.Fields.Project.Key}
+	case "issuetype", "type":
+		actual = []string{issue.Fields.IssueType.Name}
+	case "key", "issuekey":
+		actual = []string{issue.Key}
+	case "status":
+		actual = []string{issue.Fields.Status.Name}
+	case "labels":
+		actual = issue.Fields.Labels
+	case "fixversion":
+		for _, v := range issue.Fields.FixVersions {
+			actual = append(actual, v.Name)
+		}
+	default:
+		return false, jqlError(fmt.Sprintf("field '%s' is not supported by the in-memory backend", c.field))
+	}
+
+	found := false
+	for _, want := range c.values {
+		for _, have := range actual {
+			if strings.EqualFold(want, have) {
+				found = true
+			}
+		}
+	}
+	switch c.op {
+	case "=", "in":
+		return found, nil
+	case "!=", "not in":
+		return !found, nil
+	default:
+		return false, jqlError(fmt.Sprintf("operator %s is not supported for %s", c.op, c.field))
+	}
+}
+
+func (c jqlClause) matchesDate(issue *JiraIssue) (bool, error) {
+	var want time.Time
+	var err error
+	for _, layout := range jqlDateLayouts {
+		if want, err = time.ParseInLocation(layout, c.values[0], time.UTC); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return false, jqlError(fmt.Sprintf("invalid date %q for %s", c.values[0], c.field))
+	}
+
+	value := issue.Fields.Created
+	if c.field == "updated" {
+		value = issue.Fields.Updated
+	}
+	have := parseJiraTime(value)
+	switch c.op {
+	case ">=":
+		return !have.Before(want), nil
+	case ">":
+		return have.After(want), nil
+	case "<=":
+		return !have.After(want), nil
+	case "<":
+		return have.Before(want), nil
+	default:
+		return false, jqlError(fmt.Sprintf("operator %s is not supported for %s", c.op, c.field))
+	}
+}
+
+func jqlError(message string) error {
+	return &APIError{
+		StatusCode: http.StatusBadRequest,
+		Body:       message,
+		Response:   ErrorResponse{ErrorMessages: []string{message}},
+		parsed:     true,
+	}
+}
+
+func unquoteJQL(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// indexOutsideQuotes finds sep, case-insensitively, outside quoted strings and parentheses
+func indexOutsideQuotes(s, sep string) int {
+	lower := strings.ToLower(s)
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '(':
+			depth++
+		case s[i] == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(lower[i:], sep):
+			return i
+		}
+	}
+	return -1
+}
+
+// splitOutsideQuotes splits s around sep, case-insensitively, ignoring quoted
+// and parenthesized separators
+func splitOutsideQuotes(s, sep string) []string {
+	var parts []string
+	for {
+		i := indexOutsideQuotes(s, sep)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+len(sep):]
+	}
+}
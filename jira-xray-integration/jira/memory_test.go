@@ -0,0 +1,143 @@
+package jira
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemoryBackendJQL(t *testing.T) {
+	b := NewMemoryBackend("TEST")
+	b.SeedDemoData()
+	b.UpdateIssue("TEST-3", func(fields *IssueFields) {
+		fields.FixVersions = []Version{{Name: "1.0"}}
+		fields.Created = "2024-03-01T10:00:00.000+0000"
+	})
+
+	tests := []struct {
+		jql     string
+		want    string
+		wantErr bool
+	}{
+		{jql: `project = TEST AND issuetype = Test`, want: "TEST-1,TEST-2,TEST-3"},
+		{jql: `issuetype = "Test Execution" ORDER BY key`, want: "TEST-9,TEST-10"},
+		{jql: `issuetype in (Story, Epic)`, want: "TEST-4,TEST-5,TEST-6"},
+		{jql: `issuetype not in (Test, Story, "Test Execution")`, want: "TEST-7,TEST-8"},
+		{jql: `key = TEST-2`, want: "TEST-2"},
+		{jql: `key in (TEST-1,TEST-5)`, want: "TEST-1,TEST-5"},
+		{jql: `issuetype = Test AND status != "To Do"`, want: "TEST-2,TEST-3"},
+		{jql: `labels = login`, want: "TEST-1"},
+		{jql: `labels in (reset, validation)`, want: "TEST-2,TEST-3"},
+		{jql: `fixVersion = "1.0"`, want: "TEST-3"},
+		{jql: `summary ~ "password"`, want: "TEST-2,TEST-7"},
+		{jql: `issuetype = Test AND created < "2025/01/01"`, want: "TEST-3"},
+		{jql: `issuetype = Test AND created >= "2025-01-01 00:00"`, want: "TEST-1,TEST-2"},
+		{jql: `summary ~ "in (parens)"`, want: ""},
+		{jql: `project = TEST AND (issuetype = Test AND labels = login)`, want: "TEST-1"},
+		{jql: `project = OPS AND (issuetype = Test)`, want: ""},
+		{jql: `(issuetype = Test`, wantErr: true},
+		{jql: `key = TEST-1 OR key = TEST-2`, wantErr: true},
+		{jql: `key TEST-1`, wantErr: true},
+		{jql: `key in TEST-1`, wantErr: true},
+		{jql: `created > "yesterday"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.jql, func(t *testing.T) {
+			issues, err := b.SearchIssues(tt.jql)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, issue := range issues {
+				keys = append(keys, issue.Key)
+			}
+			if got := strings.Join(keys, ","); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryBackendLinks(t *testing.T) {
+	b := NewMemoryBackend("TEST")
+	b.SeedDemoData()
+
+	te, err := b.CreateTestExecution(&TestExecution{Summary: "Run", TestCases: []string{"TEST-1", "TEST-3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.GetTestExecution(te.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got.TestCases, ",") != "TEST-1,TEST-3" {
+		t.Errorf("got test cases %v", got.TestCases)
+	}
+
+	// The inward link shows up on the test with its current fields
+	test, err := b.Issue("TEST-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, link := range test.Fields.IssueLinks {
+		if link.InwardIssue != nil && link.InwardIssue.Key == te.Key {
+			found = link.InwardIssue.Fields.Summary == "Run"
+		}
+	}
+	if !found {
+		t.Errorf("TEST-1 has no inward link from %s", te.Key)
+	}
+
+	if err := b.DeleteIssue(te.Key); err != nil {
+		t.Fatal(err)
+	}
+	test, _ = b.Issue("TEST-1")
+	for _, link := range test.Fields.IssueLinks {
+		if link.LinkedIssue().Key == te.Key {
+			t.Errorf("link to deleted %s remains on TEST-1", te.Key)
+		}
+	}
+
+	if err := b.LinkIssues("TEST-1", "Test", "TEST-404"); !IsNotFound(err) {
+		t.Errorf("linking to a missing issue: got %v, want not found", err)
+	}
+}
+
+func TestMemoryBackendKeys(t *testing.T) {
+	b := NewMemoryBackend("QA")
+
+	for i, want := range []string{"QA-1", "QA-2"} {
+		tc, err := b.CreateTestCase(&TestCase{Summary: "Test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.Key != want {
+			t.Errorf("test case %d got key %s, want %s", i, tc.Key, want)
+		}
+	}
+
+	if _, err := b.CreateTestCase(&TestCase{}); err == nil {
+		t.Error("created a test case without summary")
+	}
+	if _, err := b.CreateIssue(IssueFields{Summary: "Other", IssueType: IssueType{Name: "Task"}, Project: Project{Key: "OPS"}}); err == nil {
+		t.Error("created an issue in another project")
+	}
+	b.AddProject("OPS")
+	issue, err := b.CreateIssue(IssueFields{Summary: "Other", IssueType: IssueType{Name: "Task"}, Project: Project{Key: "ops"}})
+	if err != nil {
+		t.Fatalf("creating an issue in an added project: %v", err)
+	}
+	if issue.Key != "OPS-1" {
+		t.Errorf("issue in added project got key %s, want OPS-1", issue.Key)
+	}
+	if _, err := b.UpdateTestCase(&TestCase{Key: "QA-9", Summary: "Missing"}); !IsNotFound(err) {
+		t.Errorf("updating a missing test case: got %v, want not found", err)
+	}
+}
@@ -0,0 +1,53 @@
+package jira
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"jira-xray-integration/metrics"
+)
+
+// endpointLabel names a Jira endpoint for metrics without the query and
+// with issue keys, project keys and ids replaced, so that each endpoint is
+// one time series rather than one per issue
+func endpointLabel(endpoint string) string {
+	path, _, _ := strings.Cut(endpoint, "?")
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case i > 0 && (segments[i-1] == "issue" || segments[i-1] == "project"):
+			segments[i] = "{key}"
+		case segment != "" && strings.Trim(segment, "0123456789") == "":
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// observeRequest records a request to Jira, answered with resp or failed with
+// err. Requests the rate limiter held back never reached Jira; they are
+// counted as held rate limit events instead.
+func observeRequest(method, endpoint string, started time.Time, resp *http.Response, err error) {
+	if err == nil && resp.Header.Get(heldHeader) != "" {
+		return
+	}
+	endpoint = endpointLabel(endpoint)
+	metrics.JiraRequestDuration.WithLabelValues(method, endpoint).Observe(time.Since(started).Seconds())
+	if err != nil {
+		metrics.JiraRequests.WithLabelValues(method, endpoint, "error").Inc()
+		metrics.JiraRequestErrors.WithLabelValues(method, endpoint, "network").Inc()
+		return
+	}
+
+	metrics.JiraRequests.WithLabelValues(method, endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		metrics.JiraRequestErrors.WithLabelValues(method, endpoint, "rate_limited").Inc()
+	case resp.StatusCode >= 500:
+		metrics.JiraRequestErrors.WithLabelValues(method, endpoint, "server_error").Inc()
+	case resp.StatusCode >= 400:
+		metrics.JiraRequestErrors.WithLabelValues(method, endpoint, "client_error").Inc()
+	}
+}
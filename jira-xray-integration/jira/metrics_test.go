@@ -0,0 +1,83 @@
+package jira
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"jira-xray-integration/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEndpointLabel(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"search?jql=project%3DTEST&startAt=0": "search",
+		"issue":                               "issue",
+		"issue/TEST-12":                       "issue/{key}",
+		"issue/10042/remotelink":              "issue/{key}/remotelink",
+		"project/TEST/statuses":               "project/{key}/statuses",
+		"issueLinkType":                       "issueLinkType",
+		"attachment/10001":                    "attachment/{id}",
+	} {
+		if got := endpointLabel(endpoint); got != want {
+			t.Errorf("endpointLabel(%q) = %q, want %q", endpoint, got, want)
+		}
+	}
+}
+
+func TestClientMetrics(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"key":"TEST-1","fields":{"issuetype":{"name":"Test"}}}`))
+	}))
+	defer srv.Close()
+	client := NewClient(srv.URL, "user", "token", "TEST")
+	client.HTTPClient.Transport = NewRateLimiter(nil, 0, 0)
+
+	requests := func(status string) float64 {
+		return testutil.ToFloat64(metrics.JiraRequests.WithLabelValues(http.MethodGet, "issue/{key}", status))
+	}
+	errors := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.JiraRequestErrors.WithLabelValues(http.MethodGet, "issue/{key}", reason))
+	}
+	rateLimited := func() float64 {
+		return testutil.ToFloat64(metrics.JiraRateLimitEvents.WithLabelValues(metrics.RateLimitRejected))
+	}
+	held := func() float64 {
+		return testutil.ToFloat64(metrics.JiraRateLimitEvents.WithLabelValues(metrics.RateLimitHeld))
+	}
+
+	ok, serverErrors, rejected := requests("200"), errors("server_error"), rateLimited()
+	if _, err := client.GetTestCase("TEST-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests("200") - ok; got != 1 {
+		t.Errorf("got %v more requests with status 200, want 1", got)
+	}
+
+	status = http.StatusBadGateway
+	client.GetTestCase("TEST-2")
+	if got := errors("server_error") - serverErrors; got != 1 {
+		t.Errorf("got %v more server errors, want 1", got)
+	}
+
+	status = http.StatusTooManyRequests
+	client.GetTestCase("TEST-3")
+	if got := rateLimited() - rejected; got != 1 {
+		t.Errorf("got %v more rate limit rejections, want 1", got)
+	}
+
+	// While Retry-After holds requests back, the rate limiter answers 429
+	// itself; those requests never reached Jira
+	tooMany, rateLimitErrors, heldBefore := requests("429"), errors("rate_limited"), held()
+	client.GetTestCase("TEST-4")
+	if got := held() - heldBefore; got != 1 {
+		t.Errorf("got %v more held requests, want 1", got)
+	}
+	if requests("429") != tooMany || errors("rate_limited") != rateLimitErrors {
+		t.Error("a held request was counted as a Jira response")
+	}
+}
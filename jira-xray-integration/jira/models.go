@@ -1,6 +1,10 @@
 package jira
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 // TestCase represents a test case in Jira
 type TestCase struct {
@@ -17,9 +21,17 @@ type TestCase struct {
 	UpdatedDate time.Time         `json:"updatedDate,omitempty"`
 	Reporter    string            `json:"reporter,omitempty"`
 	Assignee    string            `json:"assignee,omitempty"`
+	Steps       []TestStep        `json:"steps,omitempty"`
 	CustomFields map[string]interface{} `json:"customFields,omitempty"`
 }
 
+// TestStep represents a single step of a manual test case
+type TestStep struct {
+	Action         string `json:"action"`
+	Data           string `json:"data,omitempty"`
+	ExpectedResult string `json:"expectedResult,omitempty"`
+}
+
 // TestExecution represents a test execution in Jira
 type TestExecution struct {
 	ID              string                 `json:"id,omitempty"`
@@ -33,22 +45,63 @@ type TestExecution struct {
 	EndDate         time.Time              `json:"endDate,omitempty"`
 	ExecutedBy      string                 `json:"executedBy,omitempty"`
 	Environment     string                 `json:"environment,omitempty"`
+	FixVersion      string                 `json:"fixVersion,omitempty"`
+	TestPlan        string                 `json:"testPlan,omitempty"` // key of the test plan this execution belongs to
+	Labels          []string               `json:"labels,omitempty"`
 	TestResults     []TestResult           `json:"testResults,omitempty"`
 	CustomFields    map[string]interface{} `json:"customFields,omitempty"`
 }
 
 // TestResult represents the result of a single test case execution
 type TestResult struct {
-	TestCaseKey     string    `json:"testCaseKey"`
-	Status          string    `json:"status"` // PASS, FAIL, TODO, EXECUTING
-	Comment         string    `json:"comment,omitempty"`
-	ExecutionTime   int       `json:"executionTime,omitempty"` // in milliseconds
-	ExecutedBy      string    `json:"executedBy,omitempty"`
-	ExecutedOn      time.Time `json:"executedOn,omitempty"`
-	Defects         []string  `json:"defects,omitempty"` // Array of defect keys
-	Evidence        []string  `json:"evidence,omitempty"` // Array of attachment URLs
+	TestCaseKey   string           `json:"testCaseKey"`
+	Status        string           `json:"status"` // PASS, FAIL, TODO, EXECUTING, SKIPPED
+	Comment       string           `json:"comment,omitempty"`
+	ExecutionTime int              `json:"executionTime,omitempty"` // in milliseconds
+	ExecutedBy    string           `json:"executedBy,omitempty"`
+	ExecutedOn    time.Time        `json:"executedOn,omitempty"`
+	Defects       []string         `json:"defects,omitempty"`  // Array of defect keys
+	Evidence      []string         `json:"evidence,omitempty"` // Array of attachment URLs
+	StepResults   []TestStepResult `json:"stepResults,omitempty"`
 }
 
+// TestStepResult represents the outcome of a single step of a manual test
+type TestStepResult struct {
+	Index        int    `json:"index"` // 1-based step number
+	Status       string `json:"status"`
+	ActualResult string `json:"actualResult,omitempty"`
+	Comment      string `json:"comment,omitempty"`
+}
+
+// Test result statuses used in TestResult.Status and TestExecution.ExecutionStatus
+const (
+	StatusPass      = "PASS"
+	StatusFail      = "FAIL"
+	StatusTodo      = "TODO"
+	StatusExecuting = "EXECUTING"
+	StatusSkipped   = "SKIPPED"
+)
+
+// TestStatuses lists the test result statuses
+var TestStatuses = []string{StatusPass, StatusFail, StatusTodo, StatusExecuting, StatusSkipped}
+
+// IsTestStatus reports whether status is one of TestStatuses
+func IsTestStatus(status string) bool {
+	for _, s := range TestStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Jira issue type names used for test management
+const (
+	IssueTypeTest          = "Test"
+	IssueTypeTestExecution = "Test Execution"
+	IssueTypeTestPlan      = "Test Plan"
+)
+
 // TestPlan represents a test plan in Jira
 type TestPlan struct {
 	ID           string            `json:"id,omitempty"`
@@ -72,16 +125,139 @@ type JiraIssue struct {
 
 // IssueFields represents the fields of a Jira issue
 type IssueFields struct {
-	Summary     string      `json:"summary"`
-	Description string      `json:"description,omitempty"`
-	IssueType   IssueType   `json:"issuetype"`
-	Project     Project     `json:"project"`
-	Priority    Priority    `json:"priority,omitempty"`
-	Status      Status      `json:"status,omitempty"`
-	Reporter    User        `json:"reporter,omitempty"`
-	Assignee    User        `json:"assignee,omitempty"`
-	Labels      []string    `json:"labels,omitempty"`
-	Components  []Component `json:"components,omitempty"`
+	Summary     string       `json:"summary"`
+	Description string       `json:"description,omitempty"`
+	IssueType   IssueType    `json:"issuetype"`
+	Project     Project      `json:"project"`
+	Priority    Priority     `json:"priority,omitempty"`
+	Status      Status       `json:"status,omitempty"`
+	Reporter    User         `json:"reporter,omitempty"`
+	Assignee    User         `json:"assignee,omitempty"`
+	Labels      []string     `json:"labels,omitempty"`
+	Components  []Component  `json:"components,omitempty"`
+	IssueLinks  []IssueLink  `json:"issuelinks,omitempty"`
+	FixVersions []Version    `json:"fixVersions,omitempty"`
+	Created     string       `json:"created,omitempty"`
+	Updated     string       `json:"updated,omitempty"`
+	Attachments []Attachment `json:"attachment,omitempty"`
+
+	// Custom holds custom fields by ID, such as customfield_10100
+	Custom map[string]interface{} `json:"-"`
+}
+
+// customFieldPrefix starts the IDs of Jira custom fields
+const customFieldPrefix = "customfield_"
+
+// issueFieldsJSON has the fields of IssueFields without its JSON methods
+type issueFieldsJSON IssueFields
+
+// MarshalJSON writes the custom fields next to the system fields
+func (f IssueFields) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(issueFieldsJSON(f))
+	if err != nil || len(f.Custom) == 0 {
+		return data, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for id, value := range f.Custom {
+		fields[id] = value
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON reads the system fields and collects custom fields into
+// Custom. Custom fields already set are kept unless the data replaces them.
+func (f *IssueFields) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*issueFieldsJSON)(f)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	custom := make(map[string]interface{}, len(f.Custom))
+	for id, value := range f.Custom {
+		custom[id] = value
+	}
+	for id, raw := range fields {
+		if !strings.HasPrefix(id, customFieldPrefix) {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		if value == nil {
+			// Jira returns every custom field, with null for empty ones
+			delete(custom, id)
+			continue
+		}
+		custom[id] = value
+	}
+	f.Custom = nil
+	if len(custom) > 0 {
+		f.Custom = custom
+	}
+	return nil
+}
+
+// Attachment represents a file attached to a Jira issue
+type Attachment struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType,omitempty"`
+	Content  string `json:"content,omitempty"` // download URL
+	Created  string `json:"created,omitempty"`
+}
+
+// Version represents a Jira project version
+type Version struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// IssueLink represents a link between two Jira issues. Exactly one of
+// InwardIssue and OutwardIssue is set, depending on the link direction.
+type IssueLink struct {
+	ID           string        `json:"id,omitempty"`
+	Type         IssueLinkType `json:"type"`
+	InwardIssue  *LinkedIssue  `json:"inwardIssue,omitempty"`
+	OutwardIssue *LinkedIssue  `json:"outwardIssue,omitempty"`
+}
+
+// LinkedIssue returns the issue at the other end of the link
+func (l IssueLink) LinkedIssue() *LinkedIssue {
+	if l.OutwardIssue != nil {
+		return l.OutwardIssue
+	}
+	return l.InwardIssue
+}
+
+// IssueLinkType represents a Jira issue link type
+type IssueLinkType struct {
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Inward  string `json:"inward,omitempty"`
+	Outward string `json:"outward,omitempty"`
+}
+
+// LinkedIssue represents the summary of an issue returned inside an issue link
+type LinkedIssue struct {
+	ID     string            `json:"id,omitempty"`
+	Key    string            `json:"key"`
+	Fields LinkedIssueFields `json:"fields"`
+}
+
+// LinkedIssueFields represents the fields Jira includes for a linked issue
+type LinkedIssueFields struct {
+	Summary   string    `json:"summary"`
+	Status    Status    `json:"status"`
+	Priority  Priority  `json:"priority,omitempty"`
+	IssueType IssueType `json:"issuetype"`
 }
 
 // IssueType represents a Jira issue type
@@ -111,6 +287,7 @@ type Status struct {
 // User represents a Jira user
 type User struct {
 	AccountID    string `json:"accountId,omitempty"`
+	Name         string `json:"name,omitempty"` // user name on Jira Server and Data Center
 	EmailAddress string `json:"emailAddress,omitempty"`
 	DisplayName  string `json:"displayName,omitempty"`
 }
@@ -140,6 +317,11 @@ type CreateIssueRequest struct {
 	Fields IssueFields `json:"fields"`
 }
 
+// UpdateIssueRequest represents a request to update fields of a Jira issue
+type UpdateIssueRequest struct {
+	Fields map[string]interface{} `json:"fields"`
+}
+
 // CreateIssueResponse represents a response from creating a Jira issue
 type CreateIssueResponse struct {
 	ID   string `json:"id"`
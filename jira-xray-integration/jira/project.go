@@ -0,0 +1,335 @@
+package jira
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// issueKeyPattern matches Jira issue keys such as TEST-12
+var issueKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]+-\d+$`)
+
+// IsIssueKey reports whether key is a Jira issue key, and so safe to put in
+// JQL without quoting. Keys are matched in upper case, as Jira does.
+func IsIssueKey(key string) bool {
+	return issueKeyPattern.MatchString(strings.ToUpper(key))
+}
+
+// IssueTypes names the issue types a project keeps tests, executions and
+// test plans in, and the issue types of its requirements and defects
+type IssueTypes struct {
+	Test          string   `json:"test"`
+	TestExecution string   `json:"testExecution"`
+	TestPlan      string   `json:"testPlan"`
+	Requirements  []string `json:"requirements"`
+	Defects       []string `json:"defects"`
+}
+
+// DefaultIssueTypes are the Xray issue type names and the Jira Software
+// issue types of requirements and defects
+var DefaultIssueTypes = IssueTypes{
+	Test:          IssueTypeTest,
+	TestExecution: IssueTypeTestExecution,
+	TestPlan:      IssueTypeTestPlan,
+	Requirements:  []string{"Story", "Epic"},
+	Defects:       []string{"Bug", "Defect"},
+}
+
+// withDefaults fills in the default names for issue types left empty
+func (t IssueTypes) withDefaults() IssueTypes {
+	if t.Test == "" {
+		t.Test = DefaultIssueTypes.Test
+	}
+	if t.TestExecution == "" {
+		t.TestExecution = DefaultIssueTypes.TestExecution
+	}
+	if t.TestPlan == "" {
+		t.TestPlan = DefaultIssueTypes.TestPlan
+	}
+	if len(t.Requirements) == 0 {
+		t.Requirements = DefaultIssueTypes.Requirements
+	}
+	if len(t.Defects) == 0 {
+		t.Defects = DefaultIssueTypes.Defects
+	}
+	return t
+}
+
+// WithDefaults returns the issue types with the default names filled in
+// for those left empty
+func (t IssueTypes) WithDefaults() IssueTypes {
+	return t.withDefaults()
+}
+
+// Integration fields that can be stored in Jira fields of a project. Any
+// other name in a FieldMapping maps an entry of customFields.
+const (
+	FieldTestType    = "testType"    // TestCase.TestType
+	FieldEnvironment = "environment" // TestExecution.Environment
+)
+
+// FieldMapping maps integration fields to the Jira fields of a project that
+// hold them, such as testType to customfield_10100
+type FieldMapping map[string]string
+
+// ParseFieldMapping parses name=fieldID pairs separated by commas
+func ParseFieldMapping(value string) (FieldMapping, error) {
+	mapping := FieldMapping{}
+	err := parsePairs(value, "name=customfield_10000", func(name, id string) error {
+		if !strings.HasPrefix(id, customFieldPrefix) {
+			return fmt.Errorf("field %s must map to a custom field ID such as customfield_10000, got %q", name, id)
+		}
+		mapping[name] = id
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// String formats the mapping as ParseFieldMapping reads it
+func (m FieldMapping) String() string {
+	return formatPairs(m)
+}
+
+// Roles of issue links followed by the integration
+const (
+	LinkTests          = "tests"          // a requirement to the tests covering it
+	LinkDefects        = "defects"        // a test to the defects raised against it
+	LinkTestPlans      = "testPlans"      // a test plan to the tests it contains
+	LinkTestExecutions = "testExecutions" // a test execution to the tests it runs
+)
+
+// LinkTypes names the issue link types a project connects requirements,
+// tests, test plans, test executions and defects with. An empty name
+// follows links of any type.
+type LinkTypes struct {
+	Tests          string `json:"tests,omitempty"`
+	Defects        string `json:"defects,omitempty"`
+	TestPlans      string `json:"testPlans,omitempty"`
+	TestExecutions string `json:"testExecutions,omitempty"`
+}
+
+// ParseLinkTypes parses role=Link Type pairs separated by commas, such as
+// "tests=Tests,defects=Defect"
+func ParseLinkTypes(value string) (LinkTypes, error) {
+	var types LinkTypes
+	err := parsePairs(value, "tests=Tests", func(role, name string) error {
+		switch role {
+		case LinkTests:
+			types.Tests = name
+		case LinkDefects:
+			types.Defects = name
+		case LinkTestPlans:
+			types.TestPlans = name
+		case LinkTestExecutions:
+			types.TestExecutions = name
+		default:
+			return fmt.Errorf("unknown link role %q, use %s, %s, %s or %s", role, LinkTests, LinkDefects, LinkTestPlans, LinkTestExecutions)
+		}
+		return nil
+	})
+	return types, err
+}
+
+// String formats the link types as ParseLinkTypes reads them
+func (t LinkTypes) String() string {
+	return formatPairs(t.byRole())
+}
+
+// byRole returns the link type names by role
+func (t LinkTypes) byRole() map[string]string {
+	return map[string]string{
+		LinkTests:          t.Tests,
+		LinkDefects:        t.Defects,
+		LinkTestPlans:      t.TestPlans,
+		LinkTestExecutions: t.TestExecutions,
+	}
+}
+
+// StatusMapping maps execution statuses, such as PASS, to the names of the
+// Jira statuses test executions have in that state
+type StatusMapping map[string]string
+
+// ParseStatusMapping parses STATUS=Jira Status pairs separated by commas,
+// such as "PASS=Passed,FAIL=Failed"
+func ParseStatusMapping(value string) (StatusMapping, error) {
+	mapping := StatusMapping{}
+	err := parsePairs(value, "PASS=Passed", func(status, name string) error {
+		status = strings.ToUpper(status)
+		switch status {
+		case StatusPass, StatusFail, StatusTodo, StatusExecuting:
+		default:
+			return fmt.Errorf("unknown execution status %q, use %s, %s, %s or %s", status, StatusPass, StatusFail, StatusTodo, StatusExecuting)
+		}
+		mapping[status] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// String formats the mapping as ParseStatusMapping reads it
+func (m StatusMapping) String() string {
+	return formatPairs(m)
+}
+
+// ExecutionStatus returns the execution status a Jira status is mapped
+// from, or "" if it is not mapped
+func (m StatusMapping) ExecutionStatus(jiraStatus string) string {
+	for status, name := range m {
+		if strings.EqualFold(name, jiraStatus) {
+			return status
+		}
+	}
+	return ""
+}
+
+// JiraStatus returns the Jira status an execution status is mapped to, or
+// defaultName if it is not mapped
+func (m StatusMapping) JiraStatus(status, defaultName string) string {
+	if name, ok := m[status]; ok {
+		return name
+	}
+	return defaultName
+}
+
+// parsePairs calls set for each key=value pair of a comma separated list;
+// format shows a valid pair in errors
+func parsePairs(value, format string, set func(key, value string) error) error {
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" || val == "" {
+			return fmt.Errorf("invalid pair %q, use %s", pair, format)
+		}
+		if err := set(key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatPairs formats the non-empty entries of m as sorted key=value pairs
+func formatPairs(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for key, value := range m {
+		if value != "" {
+			pairs = append(pairs, key+"="+value)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ProjectConfig describes how a Jira project is set up for test management:
+// the issue types that hold tests, the fields integration fields map to, the
+// link types between issues and the statuses of test executions
+type ProjectConfig struct {
+	Key        string        `json:"key"`
+	IssueTypes IssueTypes    `json:"issueTypes"`
+	Fields     FieldMapping  `json:"fields,omitempty"`
+	LinkTypes  LinkTypes     `json:"linkTypes"`
+	Statuses   StatusMapping `json:"statuses,omitempty"`
+}
+
+// TestCaseFromIssue converts an issue of the project into a TestCase,
+// reading mapped fields
+func (p ProjectConfig) TestCaseFromIssue(issue *JiraIssue) *TestCase {
+	testCase := TestCaseFromIssue(issue)
+	for name, id := range p.Fields {
+		value, ok := issue.Fields.Custom[id]
+		if !ok || value == nil {
+			continue
+		}
+		if name == FieldTestType {
+			testCase.TestType = fieldText(value)
+			continue
+		}
+		if name == FieldEnvironment {
+			continue
+		}
+		if testCase.CustomFields == nil {
+			testCase.CustomFields = make(map[string]interface{})
+		}
+		testCase.CustomFields[name] = value
+	}
+	return testCase
+}
+
+// TestExecutionFromIssue converts an issue of the project into a
+// TestExecution, reading mapped fields. Linked issues of the project's test
+// issue type become its test cases.
+func (p ProjectConfig) TestExecutionFromIssue(issue *JiraIssue) *TestExecution {
+	testExecution := testExecutionFromIssue(issue, p.IssueTypes.withDefaults().Test, p.LinkTypes.TestExecutions)
+	testExecution.ExecutionStatus = p.Statuses.ExecutionStatus(testExecution.Status)
+	for name, id := range p.Fields {
+		value, ok := issue.Fields.Custom[id]
+		if !ok || value == nil {
+			continue
+		}
+		if name == FieldEnvironment {
+			testExecution.Environment = fieldText(value)
+			continue
+		}
+		if name == FieldTestType {
+			continue
+		}
+		if testExecution.CustomFields == nil {
+			testExecution.CustomFields = make(map[string]interface{})
+		}
+		testExecution.CustomFields[name] = value
+	}
+	return testExecution
+}
+
+// testCaseFields returns the mapped Jira fields to write for a test case
+func (p ProjectConfig) testCaseFields(tc *TestCase) map[string]interface{} {
+	return p.Fields.values(tc.CustomFields, FieldTestType, tc.TestType)
+}
+
+// testExecutionFields returns the mapped Jira fields to write for a test execution
+func (p ProjectConfig) testExecutionFields(te *TestExecution) map[string]interface{} {
+	return p.Fields.values(te.CustomFields, FieldEnvironment, te.Environment)
+}
+
+// values returns the Jira fields for custom, plus the built-in field name with
+// value if it is set. Entries without a mapping are not sent to Jira.
+func (m FieldMapping) values(custom map[string]interface{}, name, value string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for n, v := range custom {
+		if id, ok := m[n]; ok && n != FieldTestType && n != FieldEnvironment {
+			fields[id] = v
+		}
+	}
+	if id, ok := m[name]; ok && value != "" {
+		fields[id] = value
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// fieldText reads a text field, or the value of a select list option
+func fieldText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if s, ok := v["value"].(string); ok {
+			return s
+		}
+		if s, ok := v["name"].(string); ok {
+			return s
+		}
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,135 @@
+package jira
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseFieldMapping(t *testing.T) {
+	mapping, err := ParseFieldMapping(" testType=customfield_10100, component = customfield_10200 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mapping.String(), "component=customfield_10200,testType=customfield_10100"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	for _, value := range []string{"testType", "testType=", "=customfield_1", "testType=summary"} {
+		if _, err := ParseFieldMapping(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestIssueFieldsCustomJSON(t *testing.T) {
+	var fields IssueFields
+	data := `{"summary":"Login","customfield_10100":{"value":"Manual"},"customfield_10200":"web","customfield_10300":null}`
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields.Summary != "Login" || len(fields.Custom) != 2 {
+		t.Fatalf("got summary %q and custom fields %v", fields.Summary, fields.Custom)
+	}
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var round map[string]interface{}
+	if err := json.Unmarshal(out, &round); err != nil {
+		t.Fatal(err)
+	}
+	if round["customfield_10200"] != "web" || round["summary"] != "Login" {
+		t.Errorf("custom fields were not written back: %s", out)
+	}
+}
+
+func TestProjectConfigFields(t *testing.T) {
+	project := ProjectConfig{
+		Key:        "PAY",
+		IssueTypes: IssueTypes{Test: "QA Test"},
+		Fields:     FieldMapping{FieldTestType: "customfield_10100", FieldEnvironment: "customfield_10101", "component": "customfield_10200"},
+	}
+
+	issue := &JiraIssue{Key: "PAY-1", Fields: IssueFields{
+		Summary: "Pay by card",
+		Custom:  map[string]interface{}{"customfield_10100": map[string]interface{}{"value": "Automated"}, "customfield_10200": "checkout"},
+	}}
+	tc := project.TestCaseFromIssue(issue)
+	if tc.TestType != "Automated" || tc.CustomFields["component"] != "checkout" {
+		t.Errorf("got test type %q and custom fields %v", tc.TestType, tc.CustomFields)
+	}
+
+	fields := project.testExecutionFields(&TestExecution{Environment: "QA", CustomFields: map[string]interface{}{"component": "checkout", "unmapped": 1}})
+	if len(fields) != 2 || fields["customfield_10101"] != "QA" || fields["customfield_10200"] != "checkout" {
+		t.Errorf("got fields %v", fields)
+	}
+
+	if got := project.IssueTypes.withDefaults(); got.Test != "QA Test" || got.TestExecution != IssueTypeTestExecution {
+		t.Errorf("got issue types %+v", got)
+	}
+}
+
+func TestParseLinkTypesAndStatuses(t *testing.T) {
+	types, err := ParseLinkTypes("tests=Tests, defects = Defect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types != (LinkTypes{Tests: "Tests", Defects: "Defect"}) || types.String() != "defects=Defect,tests=Tests" {
+		t.Errorf("got link types %+v", types)
+	}
+	if _, err := ParseLinkTypes("blocks=Blocks"); err == nil {
+		t.Error("expected an error for an unknown link role")
+	}
+	types, err = ParseLinkTypes("testPlans=Test Plan,testExecutions=Test")
+	if err != nil || types != (LinkTypes{TestPlans: "Test Plan", TestExecutions: "Test"}) {
+		t.Errorf("got link types %+v (%v)", types, err)
+	}
+
+	statuses, err := ParseStatusMapping("pass=Passed,FAIL=Failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses.String() != "FAIL=Failed,PASS=Passed" || statuses.JiraStatus(StatusTodo, "To Do") != "To Do" {
+		t.Errorf("got statuses %v", statuses)
+	}
+	if _, err := ParseStatusMapping("DONE=Closed"); err == nil {
+		t.Error("expected an error for an unknown execution status")
+	}
+
+	project := ProjectConfig{Key: "TEST", Statuses: statuses, LinkTypes: types}
+	test := func(key string) *LinkedIssue {
+		return &LinkedIssue{Key: key, Fields: LinkedIssueFields{IssueType: IssueType{Name: IssueTypeTest}}}
+	}
+	te := project.TestExecutionFromIssue(&JiraIssue{Key: "TEST-9", Fields: IssueFields{
+		Status: Status{Name: "failed"},
+		IssueLinks: []IssueLink{
+			{Type: IssueLinkType{Name: "Test"}, OutwardIssue: test("TEST-1")},
+			{Type: IssueLinkType{Name: "Relates"}, OutwardIssue: test("TEST-2")},
+		},
+	}})
+	if te.ExecutionStatus != StatusFail {
+		t.Errorf("got execution status %q, want FAIL", te.ExecutionStatus)
+	}
+	if len(te.TestCases) != 1 || te.TestCases[0] != "TEST-1" {
+		t.Errorf("got test cases %v, want only the test linked by the testExecutions link type", te.TestCases)
+	}
+}
+
+func TestIsIssueKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"TEST-12":                 true,
+		"test-12":                 true,
+		"PAY_2-1":                 true,
+		"T-1":                     false,
+		"TEST":                    false,
+		"TEST-":                   false,
+		"1TEST-1":                 false,
+		"TEST-1 OR project = PAY": false,
+		"TEST-1)":                 false,
+	} {
+		if got := IsIssueKey(key); got != want {
+			t.Errorf("IsIssueKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"jira-xray-integration/metrics"
+)
+
+// RateLimiter is an http.RoundTripper that paces the requests sent to one
+// Jira instance: on average at most rate requests per second, in bursts of up
+// to burst. When Jira answers 429 Too Many Requests with a Retry-After
+// header, requests are answered 429 without reaching Jira until then,
+// whichever client sends them, rather than being held for that long.
+type RateLimiter struct {
+	next  http.RoundTripper
+	rate  float64 // requests per second, 0 for no limit
+	burst float64
+
+	mu        sync.Mutex
+	tokens    float64
+	last      time.Time
+	heldUntil time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter paces requests sent through next, or http.DefaultTransport
+// when next is nil. A rate of 0 only honors Retry-After; burst defaults to 1.
+func NewRateLimiter(next http.RoundTripper, rate float64, burst int) *RateLimiter {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{next: next, rate: rate, burst: float64(burst), tokens: float64(burst), now: time.Now}
+}
+
+// RoundTrip implements http.RoundTripper
+func (l *RateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if hold := l.held(); hold > 0 {
+		metrics.JiraRateLimitEvents.WithLabelValues(metrics.RateLimitHeld).Inc()
+		return tooManyRequests(req, hold), nil
+	}
+	if err := l.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := l.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		metrics.JiraRateLimitEvents.WithLabelValues(metrics.RateLimitRejected).Inc()
+		if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+			l.holdFor(time.Duration(seconds) * time.Second)
+		}
+	}
+	return resp, err
+}
+
+// wait blocks until a request may be sent or ctx is done
+func (l *RateLimiter) wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	metrics.JiraRateLimitEvents.WithLabelValues(metrics.RateLimitThrottled).Inc()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens < 0 {
+		return time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	return 0
+}
+
+// held returns how long Jira asked for no further requests to be sent
+func (l *RateLimiter) held() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.heldUntil.Sub(l.now())
+}
+
+// holdFor stops requests from reaching Jira for d, unless they already are for longer
+func (l *RateLimiter) holdFor(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := l.now().Add(d); until.After(l.heldUntil) {
+		l.heldUntil = until
+	}
+}
+
+// heldHeader marks the 429 responses the rate limiter answers itself, so they
+// are not counted as responses from Jira
+const heldHeader = "X-Rate-Limit-Held"
+
+// tooManyRequests answers a request the way Jira does while it rate limits
+func tooManyRequests(req *http.Request, retryAfter time.Duration) *http.Response {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	body := fmt.Sprintf(`{"errorMessages":["Rate limited by Jira, retry after %d seconds"]}`, seconds)
+	return &http.Response{
+		Status:        "429 Too Many Requests",
+		StatusCode:    http.StatusTooManyRequests,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Retry-After": {strconv.Itoa(seconds)}, "Content-Type": {"application/json"}, heldHeader: {"true"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
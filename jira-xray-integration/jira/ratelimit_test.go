@@ -0,0 +1,73 @@
+package jira
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRateLimiterPacing(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(nil, 2, 3)
+	l.now = func() time.Time { return clock }
+
+	// The burst goes out at once, then requests are spaced 500ms apart
+	for i, want := range []time.Duration{0, 0, 0, 500 * time.Millisecond, time.Second} {
+		if got := l.reserve(); got != want {
+			t.Errorf("request %d: got delay %s, want %s", i, got, want)
+		}
+	}
+
+	// Tokens refill over time, up to the burst
+	clock = clock.Add(10 * time.Second)
+	if got := l.reserve(); got != 0 {
+		t.Errorf("after refill: got delay %s, want 0", got)
+	}
+	if l.tokens != 2 {
+		t.Errorf("got %v tokens, want 2", l.tokens)
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	l := NewRateLimiter(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Retry-After", "30")
+		rec.WriteHeader(http.StatusTooManyRequests)
+		return rec.Result(), nil
+	}), 0, 0)
+	l.now = func() time.Time { return clock }
+
+	send := func() *http.Response {
+		t.Helper()
+		resp, err := l.RoundTrip(httptest.NewRequest(http.MethodGet, "http://jira.test/rest/api/3/myself", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	send()
+	clock = clock.Add(10 * time.Second)
+	resp := send()
+	if calls != 1 {
+		t.Errorf("request sent to Jira while it rate limits: %d calls", calls)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "20" {
+		t.Errorf("got status %d with Retry-After %q, want 429 with 20", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	clock = clock.Add(21 * time.Second)
+	send()
+	if calls != 2 {
+		t.Errorf("request not sent after Retry-After passed: %d calls", calls)
+	}
+}
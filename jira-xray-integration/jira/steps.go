@@ -0,0 +1,66 @@
+package jira
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Jira has no native field for manual test steps, so they are stored in a
+// marked section at the end of the issue description and parsed back on read.
+
+const stepsHeader = "Test Steps:"
+
+// formatDescription appends the test steps section to a description
+func formatDescription(description string, steps []TestStep) string {
+	if len(steps) == 0 {
+		return description
+	}
+
+	var b strings.Builder
+	if description != "" {
+		b.WriteString(strings.TrimRight(description, "\n"))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(stepsHeader)
+	for i, step := range steps {
+		fmt.Fprintf(&b, "\n%d. Action: %s | Data: %s | Expected: %s",
+			i+1, flattenStepText(step.Action), flattenStepText(step.Data), flattenStepText(step.ExpectedResult))
+	}
+	return b.String()
+}
+
+// parseDescription splits a description into its free text and test steps
+func parseDescription(description string) (string, []TestStep) {
+	i := strings.LastIndex(description, stepsHeader)
+	if i < 0 {
+		return description, nil
+	}
+
+	var steps []TestStep
+	for _, line := range strings.Split(description[i+len(stepsHeader):], "\n") {
+		line = strings.TrimSpace(line)
+		dot := strings.Index(line, ". Action: ")
+		if dot < 0 {
+			continue
+		}
+		// Split on the bare separators: trimming the line drops the space after an empty last field
+		parts := strings.SplitN(line[dot+len(". Action: "):], " | Data:", 2)
+		step := TestStep{Action: strings.TrimSpace(parts[0])}
+		if len(parts) == 2 {
+			rest := strings.SplitN(parts[1], " | Expected:", 2)
+			step.Data = strings.TrimSpace(rest[0])
+			if len(rest) == 2 {
+				step.ExpectedResult = strings.TrimSpace(rest[1])
+			}
+		}
+		steps = append(steps, step)
+	}
+	return strings.TrimRight(description[:i], "\n "), steps
+}
+
+// flattenStepText keeps a step on one line so the section stays parseable
+func flattenStepText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", " ")
+	text = strings.ReplaceAll(text, "\n", " ")
+	return strings.ReplaceAll(text, " | ", " / ")
+}
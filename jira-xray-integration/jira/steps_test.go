@@ -0,0 +1,59 @@
+package jira
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStepsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		steps       []TestStep
+		wantSteps   []TestStep
+	}{
+		{
+			name:        "no steps",
+			description: "Just a description",
+		},
+		{
+			name:  "steps without description",
+			steps: []TestStep{{Action: "Open the page", ExpectedResult: "Page is shown"}},
+		},
+		{
+			name:        "empty data and expected result",
+			description: "Login",
+			steps:       []TestStep{{Action: "Click sign in"}, {Action: "Enter password", Data: "secret"}},
+		},
+		{
+			name:        "multi-line text and separators are flattened",
+			description: "Multi\nline description\n",
+			steps:       []TestStep{{Action: "Type\nthis", Data: "a | b", ExpectedResult: "ok"}},
+			wantSteps:   []TestStep{{Action: "Type this", Data: "a / b", ExpectedResult: "ok"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantSteps := tt.wantSteps
+			if wantSteps == nil {
+				wantSteps = tt.steps
+			}
+
+			description, steps := parseDescription(formatDescription(tt.description, tt.steps))
+			if want := trimDescription(tt.description); description != want {
+				t.Errorf("got description %q, want %q", description, want)
+			}
+			if !reflect.DeepEqual(steps, wantSteps) {
+				t.Errorf("got steps %+v, want %+v", steps, wantSteps)
+			}
+		})
+	}
+}
+
+func trimDescription(description string) string {
+	for len(description) > 0 && description[len(description)-1] == '\n' {
+		description = description[:len(description)-1]
+	}
+	return description
+}
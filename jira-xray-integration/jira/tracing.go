@@ -0,0 +1,84 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of requests to Jira
+const tracerName = "jira-xray-integration/jira"
+
+// Attributes of Jira request spans besides the HTTP ones
+const (
+	attrEndpoint = attribute.Key("jira.endpoint")
+	attrRetries  = attribute.Key("jira.retries")
+)
+
+// retriesKey holds the number of earlier attempts of a write in a context
+type retriesKey struct{}
+
+// WithRetries returns a context whose requests to Jira are recorded as the
+// retry of a write that was attempted retries times before, such as an
+// outbox replay
+func WithRetries(ctx context.Context, retries int) context.Context {
+	return context.WithValue(ctx, retriesKey{}, retries)
+}
+
+// WithContext returns a copy of the client whose requests to Jira are traced
+// as part of ctx. They are not canceled with ctx, so a caller that goes away
+// does not cut off a write half way.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	client := *c
+	client.ctx = context.WithoutCancel(ctx)
+	return &client
+}
+
+// requestContext returns the context requests are sent in
+func (c *Client) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// startRequestSpan starts the span of a request to Jira and passes it on to
+// Jira in the traceparent header. Only the trace context is sent: baggage set
+// by callers of the API may carry values that are no business of Jira.
+func startRequestSpan(req *http.Request, endpoint string) (*http.Request, trace.Span) {
+	ctx := req.Context()
+	label := endpointLabel(endpoint)
+	retries, _ := ctx.Value(retriesKey{}).(int)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, fmt.Sprintf("jira %s %s", req.Method, label),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			attrEndpoint.String(label),
+			attrRetries.Int(retries),
+		))
+	req = req.WithContext(ctx)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return req, span
+}
+
+// endRequestSpan records the outcome of a request to Jira and ends its span
+func endRequestSpan(span trace.Span, resp *http.Response, err error) {
+	defer span.End()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+}
@@ -0,0 +1,77 @@
+package jira
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestClientTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	defer func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(propagator)
+	}()
+
+	var traceparent, sentBaggage string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		sentBaggage = r.Header.Get("Baggage")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"key":"TEST-1","fields":{"issuetype":{"name":"Test"}}}`))
+	}))
+	defer srv.Close()
+
+	// Baggage of the caller stays out of requests to Jira
+	member, _ := baggage.NewMember("user.email", "alice@example.com")
+	bag, _ := baggage.New(member)
+	ctx, parent := provider.Tracer("test").Start(baggage.ContextWithBaggage(context.Background(), bag), "parent")
+	client := NewClient(srv.URL, "user", "token", "TEST").WithContext(WithRetries(ctx, 2))
+	if _, err := client.GetTestCase("TEST-1"); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	span := spans[0]
+	if span.Name() != "jira GET issue/{key}" {
+		t.Fatalf("got span %q", span.Name())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Jira span is not a child of the caller's span")
+	}
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("got traceparent %q, want %q", traceparent, want)
+	}
+	if sentBaggage != "" {
+		t.Errorf("got baggage %q sent to Jira", sentBaggage)
+	}
+	attributes := map[string]interface{}{}
+	for _, kv := range span.Attributes() {
+		attributes[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attributes["jira.retries"] != int64(2) || attributes["http.response.status_code"] != int64(http.StatusOK) {
+		t.Errorf("got attributes %v", attributes)
+	}
+
+	status = http.StatusNotFound
+	client.GetTestCase("TEST-2")
+	spans = recorder.Ended()
+	if got := spans[len(spans)-1].Status().Code; got != codes.Error {
+		t.Errorf("got status %v for a 404, want an error", got)
+	}
+}
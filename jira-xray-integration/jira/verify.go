@@ -0,0 +1,158 @@
+package jira
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ProjectMismatchError lists the issue types, link types and statuses a
+// project is configured with that its Jira does not have
+type ProjectMismatchError struct {
+	Project string
+	Missing []string
+}
+
+func (e *ProjectMismatchError) Error() string {
+	return fmt.Sprintf("project %s does not match Jira: %s", e.Project, strings.Join(e.Missing, "; "))
+}
+
+// jiraProject is the part of GET project/{key} the verification reads
+type jiraProject struct {
+	Key        string      `json:"key"`
+	IssueTypes []IssueType `json:"issueTypes"`
+}
+
+// issueTypeStatuses is an entry of GET project/{key}/statuses: the statuses
+// of the project's workflow for one issue type
+type issueTypeStatuses struct {
+	Name     string   `json:"name"`
+	Statuses []Status `json:"statuses"`
+}
+
+// VerifyProject checks that the issue types, link types and statuses the
+// client's project is configured with exist in Jira. Names missing from
+// Jira are reported together in a *ProjectMismatchError; any other error
+// means Jira could not be asked.
+//
+// The test, test execution and test plan issue types must all exist, and
+// at least one of the requirement and of the defect issue types. Mapped
+// statuses must be statuses of the test execution issue type.
+func (c *Client) VerifyProject() error {
+	project := c.Project()
+	log.Printf("Verifying Jira project %s", project.Key)
+
+	resp, err := c.makeRequest("GET", "project/"+project.Key, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch project %s: %w", project.Key, err)
+	}
+	var jp jiraProject
+	if err := c.handleResponse(resp, &jp); err != nil {
+		if IsNotFound(err) {
+			return &ProjectMismatchError{Project: project.Key, Missing: []string{"the project does not exist or is not visible"}}
+		}
+		return err
+	}
+
+	resp, err = c.makeRequest("GET", "issueLinkType", nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch issue link types: %w", err)
+	}
+	var linkTypes struct {
+		IssueLinkTypes []IssueLinkType `json:"issueLinkTypes"`
+	}
+	if err := c.handleResponse(resp, &linkTypes); err != nil {
+		return err
+	}
+
+	resp, err = c.makeRequest("GET", fmt.Sprintf("project/%s/statuses", project.Key), nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch statuses of project %s: %w", project.Key, err)
+	}
+	var statuses []issueTypeStatuses
+	if err := c.handleResponse(resp, &statuses); err != nil {
+		return err
+	}
+
+	missing := project.mismatches(jp.IssueTypes, linkTypes.IssueLinkTypes, statuses)
+	if len(missing) > 0 {
+		return &ProjectMismatchError{Project: project.Key, Missing: missing}
+	}
+	return nil
+}
+
+// mismatches describes each configured name Jira does not have
+func (p ProjectConfig) mismatches(issueTypes []IssueType, linkTypes []IssueLinkType, statuses []issueTypeStatuses) []string {
+	var missing []string
+	hasIssueType := func(name string) bool {
+		for _, t := range issueTypes {
+			if strings.EqualFold(t.Name, name) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range []string{p.IssueTypes.Test, p.IssueTypes.TestExecution, p.IssueTypes.TestPlan} {
+		if !hasIssueType(name) {
+			missing = append(missing, fmt.Sprintf("issue type %q not found", name))
+		}
+	}
+	for _, kind := range []struct {
+		name  string
+		types []string
+	}{{"requirement", p.IssueTypes.Requirements}, {"defect", p.IssueTypes.Defects}} {
+		found := false
+		for _, name := range kind.types {
+			found = found || hasIssueType(name)
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("none of the %s issue types %s found", kind.name, strings.Join(kind.types, ", ")))
+		}
+	}
+
+	roles := p.LinkTypes.byRole()
+	for _, role := range sortedKeys(roles) {
+		name := roles[role]
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, t := range linkTypes {
+			found = found || strings.EqualFold(t.Name, name)
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("link type %q for %s not found", name, role))
+		}
+	}
+
+	// Statuses can only be checked in the workflow of an existing issue type
+	var executionStatuses []Status
+	workflowFound := false
+	for _, s := range statuses {
+		if strings.EqualFold(s.Name, p.IssueTypes.TestExecution) {
+			executionStatuses, workflowFound = s.Statuses, true
+		}
+	}
+	for _, status := range sortedKeys(p.Statuses) {
+		name := p.Statuses[status]
+		found := false
+		for _, s := range executionStatuses {
+			found = found || strings.EqualFold(s.Name, name)
+		}
+		if workflowFound && !found {
+			missing = append(missing, fmt.Sprintf("status %q for %s not found in the %s workflow", name, status, p.IssueTypes.TestExecution))
+		}
+	}
+	return missing
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,60 @@
+package jira_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/jiratest"
+)
+
+func TestClientVerifyProject(t *testing.T) {
+	srv, client := newFakeJira(t)
+	if err := client.VerifyProject(); err != nil {
+		t.Fatalf("default names: %v", err)
+	}
+
+	qa := client.ForProject(jira.ProjectConfig{
+		Key: "TEST",
+		IssueTypes: jira.IssueTypes{
+			Test:    "QA Test",
+			Defects: []string{"Incident"},
+		},
+		LinkTypes: jira.LinkTypes{Tests: "Test", Defects: "Causes"},
+		Statuses:  jira.StatusMapping{jira.StatusPass: "Passed", jira.StatusTodo: "To Do"},
+	})
+	err := qa.VerifyProject()
+	var mismatch *jira.ProjectMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got error %v, want the names missing from Jira", err)
+	}
+	want := []string{
+		`issue type "QA Test" not found`,
+		`none of the defect issue types Incident found`,
+		`link type "Causes" for defects not found`,
+		`status "Passed" for PASS not found in the Test Execution workflow`,
+	}
+	if strings.Join(mismatch.Missing, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got missing names:\n%s\nwant:\n%s", strings.Join(mismatch.Missing, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Once Jira has them the project matches
+	srv.IssueTypes = append(srv.IssueTypes, "QA Test", "Incident")
+	srv.LinkTypes = append(srv.LinkTypes, jira.IssueLinkType{Name: "Causes"})
+	srv.Workflow = append(srv.Workflow, jiratest.Transition{ID: "41", Name: "Pass", To: "Passed"})
+	if err := qa.VerifyProject(); err != nil {
+		t.Errorf("got %v after adding the names to Jira", err)
+	}
+
+	missing := client.ForProject(jira.ProjectConfig{Key: "PAY"})
+	if err := missing.VerifyProject(); !errors.As(err, &mismatch) {
+		t.Errorf("got error %v for a missing project", err)
+	}
+
+	srv.InjectFault(jiratest.Fault{Method: http.MethodGet, Path: "/issueLinkType", Status: http.StatusServiceUnavailable})
+	if err := client.VerifyProject(); err == nil || errors.As(err, &mismatch) {
+		t.Errorf("got error %v, want Jira to be unavailable", err)
+	}
+}
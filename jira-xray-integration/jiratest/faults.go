@@ -0,0 +1,102 @@
+package jiratest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault slows down or fails the requests it matches. Faults are checked in
+// the order they were injected and the first match applies.
+type Fault struct {
+	Method string // HTTP method to match, empty matches any
+	Path   string // path prefix below /rest/api/3 to match, e.g. /search; empty matches any
+
+	Latency    time.Duration // delay before the request is answered
+	Status     int           // status to fail with, e.g. 429 or 503; 0 only adds latency
+	RetryAfter int           // seconds sent in the Retry-After header of failures
+
+	Times int // number of requests to affect, 0 affects every match until ClearFaults
+}
+
+// InjectFault adds a fault to the server
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// FailNext fails the next n requests with status
+func (s *Server) FailNext(n, status int) {
+	s.InjectFault(Fault{Status: status, Times: n})
+}
+
+// RateLimit answers every request with 429 Too Many Requests until ClearFaults
+func (s *Server) RateLimit(retryAfter int) {
+	s.InjectFault(Fault{Status: http.StatusTooManyRequests, RetryAfter: retryAfter})
+}
+
+// SetLatency delays every request until ClearFaults
+func (s *Server) SetLatency(d time.Duration) {
+	s.InjectFault(Fault{Latency: d})
+}
+
+// ClearFaults removes all injected faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// applyFault applies the first fault matching the request and reports
+// whether it answered the request
+func (s *Server) applyFault(w http.ResponseWriter, r *http.Request, path string) bool {
+	fault := s.matchFault(r.Method, path)
+	if fault == nil {
+		return false
+	}
+
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	if fault.Status == 0 {
+		return false
+	}
+
+	if fault.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+	}
+	writeError(w, fault.Status, "Injected fault: "+http.StatusText(fault.Status))
+	return true
+}
+
+// matchFault returns a copy of the first matching fault, using up one of its
+// times and removing it once they are spent
+func (s *Server) matchFault(method, path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if f.Method != "" && !strings.EqualFold(f.Method, method) {
+			continue
+		}
+		if f.Path != "" && !strings.HasPrefix(path, f.Path) {
+			continue
+		}
+
+		matched := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &matched
+	}
+	return nil
+}
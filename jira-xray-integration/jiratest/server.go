@@ -0,0 +1,655 @@
+// Package jiratest provides a fake Jira REST server for integration tests.
+//
+// The server answers the subset of /rest/api/3 this application uses from an
+// in-memory jira.MemoryBackend, so jira.Client and the HTTP routes built on it
+// can be exercised end to end without network access:
+//
+//	srv := jiratest.NewServer("TEST")
+//	defer srv.Close()
+//	client := srv.JiraClient()
+//
+// Faults such as latency, rate limiting and server errors can be injected
+// per method and path to exercise retries and the outbox.
+package jiratest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"jira-xray-integration/jira"
+)
+
+const (
+	apiPrefix = "/rest/api/3"
+
+	defaultMaxResults = 50
+	maxMaxResults     = 100
+	maxAttachmentSize = 10 << 20
+)
+
+// Default credentials accepted by a new server
+const (
+	Username = "fake@example.com"
+	APIToken = "fake-token"
+)
+
+// Transition is a workflow transition offered for every issue
+type Transition struct {
+	ID   string
+	Name string
+	To   string // status the issue moves to
+}
+
+// DefaultWorkflow lets any issue move between To Do, In Progress and Done
+var DefaultWorkflow = []Transition{
+	{ID: "11", Name: "To Do", To: "To Do"},
+	{ID: "21", Name: "In Progress", To: "In Progress"},
+	{ID: "31", Name: "Done", To: "Done"},
+}
+
+// DefaultIssueTypes are the issue types of the project of a new server
+var DefaultIssueTypes = []string{
+	jira.IssueTypeTest, jira.IssueTypeTestExecution, jira.IssueTypeTestPlan, "Story", "Epic", "Bug",
+}
+
+// DefaultLinkTypes are the issue link types of a new server
+var DefaultLinkTypes = []jira.IssueLinkType{
+	{ID: "10000", Name: "Test", Inward: "is tested by", Outward: "tests"},
+	{ID: "10001", Name: "Defect", Inward: "is caused by", Outward: "causes"},
+	{ID: "10002", Name: "Relates", Inward: "relates to", Outward: "relates to"},
+}
+
+// Request is a request received by the server, recorded for assertions
+type Request struct {
+	Method string
+	Path   string // path below /rest/api/3, e.g. /issue/TEST-1
+	Query  string
+	Body   []byte
+	User   string // user the credentials belong to, empty if they were not accepted
+}
+
+// Server is a fake Jira instance. Tests seed and inspect its issues through
+// Backend and talk to it over HTTP at URL.
+type Server struct {
+	URL      string
+	Backend  *jira.MemoryBackend
+	Username string // empty disables authentication
+	APIToken string
+	Workflow []Transition
+
+	// IssueTypes and LinkTypes are reported by GET project/{key} and GET
+	// issueLinkType. Every issue type has the statuses of Workflow.
+	IssueTypes []string
+	LinkTypes  []jira.IssueLinkType
+
+	Users        map[string]string // further accounts accepted with basic auth, API token by username
+	AccessTokens map[string]string // bearer tokens accepted, user they belong to by token
+
+	httpServer *httptest.Server
+
+	mu          sync.Mutex
+	faults      []*Fault
+	requests    []Request
+	attachments map[string][]byte // attachment content by ID
+}
+
+// NewServer starts a fake Jira server for a project with no issues. It
+// accepts the Username and APIToken credentials.
+func NewServer(projectKey string) *Server {
+	s := NewHandler(projectKey)
+	s.httpServer = httptest.NewServer(s)
+	s.URL = s.httpServer.URL
+	return s
+}
+
+// NewHandler creates a fake Jira server without starting it, for mounting
+// the handler in an existing test server or router
+func NewHandler(projectKey string) *Server {
+	return &Server{
+		Backend:      jira.NewMemoryBackend(projectKey),
+		Username:     Username,
+		APIToken:     APIToken,
+		Workflow:     DefaultWorkflow,
+		IssueTypes:   DefaultIssueTypes,
+		LinkTypes:    DefaultLinkTypes,
+		Users:        make(map[string]string),
+		AccessTokens: make(map[string]string),
+		attachments:  make(map[string][]byte),
+	}
+}
+
+// Close shuts down a server started with NewServer
+func (s *Server) Close() {
+	if s.httpServer != nil {
+		s.httpServer.Close()
+	}
+}
+
+// JiraClient returns a client for the server's project using its credentials
+func (s *Server) JiraClient() *jira.Client {
+	return jira.NewClient(s.URL, s.Username, s.APIToken, s.Backend.ProjectKey)
+}
+
+// Requests returns the requests received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ResetRequests forgets the recorded requests
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	if path == r.URL.Path {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAttachmentSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	user, authorized := s.authorize(r)
+	s.record(Request{Method: r.Method, Path: path, Query: r.URL.RawQuery, Body: body, User: user})
+
+	if s.applyFault(w, r, path) {
+		return
+	}
+	if !authorized {
+		writeError(w, http.StatusUnauthorized, "Client must be authenticated to access this resource.")
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == "/search" && (r.Method == http.MethodGet || r.Method == http.MethodPost):
+		s.search(w, r, body)
+	case path == "/issue" && r.Method == http.MethodPost:
+		s.createIssue(w, body)
+	case len(segments) == 2 && segments[0] == "issue":
+		switch r.Method {
+		case http.MethodGet:
+			s.getIssue(w, segments[1])
+		case http.MethodPut:
+			s.updateIssue(w, segments[1], body)
+		case http.MethodDelete:
+			s.deleteIssue(w, segments[1])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case len(segments) == 3 && segments[0] == "issue" && segments[2] == "transitions":
+		switch r.Method {
+		case http.MethodGet:
+			s.getTransitions(w, segments[1])
+		case http.MethodPost:
+			s.transition(w, segments[1], body)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case len(segments) == 3 && segments[0] == "issue" && segments[2] == "attachments" && r.Method == http.MethodPost:
+		s.addAttachments(w, r, segments[1])
+	case len(segments) == 3 && segments[0] == "attachment" && segments[1] == "content" && r.Method == http.MethodGet:
+		s.getAttachmentContent(w, segments[2])
+	case path == "/issueLink" && r.Method == http.MethodPost:
+		s.linkIssues(w, body)
+	case path == "/myself" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]string{"accountId": user, "emailAddress": user, "displayName": user})
+	case path == "/field" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, fields)
+	case path == "/issueLinkType" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"issueLinkTypes": s.LinkTypes})
+	case len(segments) == 2 && segments[0] == "project" && r.Method == http.MethodGet:
+		s.getProject(w, segments[1])
+	case len(segments) == 3 && segments[0] == "project" && segments[2] == "statuses" && r.Method == http.MethodGet:
+		s.getProjectStatuses(w, segments[1])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No resource for %s %s", r.Method, path))
+	}
+}
+
+func (s *Server) record(req Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+// authorize returns the user a request's credentials belong to and whether
+// they were accepted
+func (s *Server) authorize(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		user, ok := s.AccessTokens[strings.TrimPrefix(header, "Bearer ")]
+		return user, ok || s.Username == ""
+	}
+	username, token, ok := r.BasicAuth()
+	if ok && ((username == s.Username && token == s.APIToken) || (s.Users[username] != "" && s.Users[username] == token)) {
+		return username, true
+	}
+	return "", s.Username == ""
+}
+
+// search handles GET /search with query parameters and POST /search with a JSON body
+func (s *Server) search(w http.ResponseWriter, r *http.Request, body []byte) {
+	var req struct {
+		JQL        string `json:"jql"`
+		StartAt    int    `json:"startAt"`
+		MaxResults int    `json:"maxResults"`
+	}
+	if r.Method == http.MethodPost {
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+	} else {
+		query := r.URL.Query()
+		req.JQL = query.Get("jql")
+		req.StartAt, _ = strconv.Atoi(query.Get("startAt"))
+		req.MaxResults, _ = strconv.Atoi(query.Get("maxResults"))
+	}
+	if req.StartAt < 0 {
+		req.StartAt = 0
+	}
+	if req.MaxResults <= 0 {
+		req.MaxResults = defaultMaxResults
+	}
+	if req.MaxResults > maxMaxResults {
+		req.MaxResults = maxMaxResults
+	}
+
+	issues, err := s.Backend.SearchIssues(req.JQL)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	page := []jira.JiraIssue{}
+	if req.StartAt < len(issues) {
+		end := req.StartAt + req.MaxResults
+		if end > len(issues) {
+			end = len(issues)
+		}
+		page = issues[req.StartAt:end]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"startAt":    req.StartAt,
+		"maxResults": req.MaxResults,
+		"total":      len(issues),
+		"issues":     page,
+	})
+}
+
+func (s *Server) createIssue(w http.ResponseWriter, body []byte) {
+	var req jira.CreateIssueRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	issue, err := s.Backend.CreateIssue(req.Fields)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, jira.CreateIssueResponse{
+		ID:   issue.ID,
+		Key:  issue.Key,
+		Self: s.URL + apiPrefix + "/issue/" + issue.ID,
+	})
+}
+
+func (s *Server) getIssue(w http.ResponseWriter, key string) {
+	issue, err := s.Backend.Issue(key)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, issue)
+}
+
+// updateIssue applies the fields present in the request, leaving the others unchanged
+func (s *Server) updateIssue(w http.ResponseWriter, key string, body []byte) {
+	var req struct {
+		Fields map[string]json.RawMessage `json:"fields"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	// Decode the present fields over a copy so a bad value changes nothing
+	issue, err := s.Backend.Issue(key)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	updated := issue.Fields
+	for name, value := range req.Fields {
+		if !updatableFields[name] && !strings.HasPrefix(name, "customfield_") {
+			writeFieldError(w, name, "Field cannot be set. It is not on the appropriate screen, or unknown.")
+			return
+		}
+		partial, _ := json.Marshal(map[string]json.RawMessage{name: value})
+		if err := json.Unmarshal(partial, &updated); err != nil {
+			writeFieldError(w, name, "Invalid value for field")
+			return
+		}
+	}
+	if updated.Summary == "" {
+		writeFieldError(w, "summary", "You must specify a summary of the issue.")
+		return
+	}
+
+	err = s.Backend.UpdateIssue(key, func(fields *jira.IssueFields) {
+		fields.Summary = updated.Summary
+		fields.Description = updated.Description
+		fields.Labels = updated.Labels
+		fields.Components = updated.Components
+		fields.Priority = updated.Priority
+		fields.FixVersions = updated.FixVersions
+		fields.Assignee = updated.Assignee
+		fields.Custom = updated.Custom
+	})
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteIssue(w http.ResponseWriter, key string) {
+	issue, err := s.Backend.Issue(key)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	if err := s.Backend.DeleteIssue(key); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	for _, attachment := range issue.Fields.Attachments {
+		delete(s.attachments, attachment.ID)
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// linkIssues handles POST /issueLink. As in Jira, the request's inwardIssue
+// is the issue that receives the outward link: linking type "Test" with
+// inwardIssue EXEC-1 and outwardIssue TEST-1 shows TEST-1 as an outward
+// link of EXEC-1.
+func (s *Server) linkIssues(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Type         jira.IssueLinkType `json:"type"`
+		InwardIssue  jira.LinkedIssue   `json:"inwardIssue"`
+		OutwardIssue jira.LinkedIssue   `json:"outwardIssue"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Type.Name == "" || req.InwardIssue.Key == "" || req.OutwardIssue.Key == "" {
+		writeError(w, http.StatusBadRequest, "Link type, inwardIssue and outwardIssue are required")
+		return
+	}
+
+	if err := s.Backend.LinkIssues(req.InwardIssue.Key, req.Type.Name, req.OutwardIssue.Key); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// getProject handles GET /project/{key} with the issue types of the project,
+// the same for every project of the server
+func (s *Server) getProject(w http.ResponseWriter, key string) {
+	if !s.Backend.HasProject(key) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No project could be found with key '%s'.", key))
+		return
+	}
+	issueTypes := make([]jira.IssueType, len(s.IssueTypes))
+	for i, name := range s.IssueTypes {
+		issueTypes[i] = jira.IssueType{ID: strconv.Itoa(10000 + i), Name: name}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"key":        strings.ToUpper(key),
+		"issueTypes": issueTypes,
+	})
+}
+
+// getProjectStatuses handles GET /project/{key}/statuses: every issue type
+// has the statuses of the workflow
+func (s *Server) getProjectStatuses(w http.ResponseWriter, key string) {
+	if !s.Backend.HasProject(key) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No project could be found with key '%s'.", key))
+		return
+	}
+	var statuses []jira.Status
+	seen := make(map[string]bool)
+	for _, t := range s.Workflow {
+		if !seen[t.To] {
+			seen[t.To] = true
+			statuses = append(statuses, jira.Status{Name: t.To})
+		}
+	}
+	type issueTypeStatuses struct {
+		Name     string        `json:"name"`
+		Statuses []jira.Status `json:"statuses"`
+	}
+	result := make([]issueTypeStatuses, len(s.IssueTypes))
+	for i, name := range s.IssueTypes {
+		result[i] = issueTypeStatuses{Name: name, Statuses: statuses}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getTransitions(w http.ResponseWriter, key string) {
+	if _, err := s.Backend.Issue(key); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+
+	type status struct {
+		Name string `json:"name"`
+	}
+	type transition struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		To   status `json:"to"`
+	}
+	transitions := make([]transition, len(s.Workflow))
+	for i, t := range s.Workflow {
+		transitions[i] = transition{ID: t.ID, Name: t.Name, To: status{Name: t.To}}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"transitions": transitions})
+}
+
+func (s *Server) transition(w http.ResponseWriter, key string, body []byte) {
+	var req struct {
+		Transition struct {
+			ID string `json:"id"`
+		} `json:"transition"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var target *Transition
+	for i := range s.Workflow {
+		if s.Workflow[i].ID == req.Transition.ID {
+			target = &s.Workflow[i]
+			break
+		}
+	}
+	if target == nil {
+		writeFieldError(w, "transition", fmt.Sprintf("Transition id '%s' is not valid for this issue.", req.Transition.ID))
+		return
+	}
+
+	err := s.Backend.UpdateIssue(key, func(fields *jira.IssueFields) {
+		fields.Status = jira.Status{Name: target.To}
+	})
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addAttachments handles multipart uploads in the "file" field. Like Jira it
+// rejects requests without the X-Atlassian-Token: no-check header.
+func (s *Server) addAttachments(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Header.Get("X-Atlassian-Token") != "no-check" {
+		writeError(w, http.StatusForbidden, "XSRF check failed")
+		return
+	}
+	if err := r.ParseMultipartForm(maxAttachmentSize); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid multipart request")
+		return
+	}
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		writeError(w, http.StatusBadRequest, "No file in the \"file\" field")
+		return
+	}
+
+	attachments := []jira.Attachment{}
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Failed to read attachment")
+			return
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Failed to read attachment")
+			return
+		}
+
+		mimeType := header.Header.Get("Content-Type")
+		if mimeType == "" {
+			mimeType = http.DetectContentType(content)
+		}
+		attachment, err := s.Backend.AddAttachment(key, jira.Attachment{
+			Filename: header.Filename,
+			Size:     int64(len(content)),
+			MimeType: mimeType,
+		})
+		if err != nil {
+			writeBackendError(w, err)
+			return
+		}
+
+		s.mu.Lock()
+		s.attachments[attachment.ID] = content
+		s.mu.Unlock()
+
+		// The URL depends on the server address, so it is filled in on the way out
+		attachment.Content = s.URL + apiPrefix + "/attachment/content/" + attachment.ID
+		attachments = append(attachments, *attachment)
+	}
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+func (s *Server) getAttachmentContent(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	content, ok := s.attachments[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "The attachment with id '"+id+"' does not exist")
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(content))
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
+
+// updatableFields are the fields PUT /issue/{key} accepts
+var updatableFields = map[string]bool{
+	"summary":     true,
+	"description": true,
+	"labels":      true,
+	"components":  true,
+	"priority":    true,
+	"fixVersions": true,
+	"assignee":    true,
+}
+
+type fieldSchema struct {
+	Type  string `json:"type"`
+	Items string `json:"items,omitempty"`
+}
+
+type field struct {
+	ID         string      `json:"id"`
+	Key        string      `json:"key"`
+	Name       string      `json:"name"`
+	Custom     bool        `json:"custom"`
+	Navigable  bool        `json:"navigable"`
+	Searchable bool        `json:"searchable"`
+	Schema     fieldSchema `json:"schema"`
+}
+
+// fields is the metadata returned by GET /field for the system fields the server supports
+var fields = []field{
+	{ID: "summary", Key: "summary", Name: "Summary", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "string"}},
+	{ID: "description", Key: "description", Name: "Description", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "string"}},
+	{ID: "issuetype", Key: "issuetype", Name: "Issue Type", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "issuetype"}},
+	{ID: "project", Key: "project", Name: "Project", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "project"}},
+	{ID: "status", Key: "status", Name: "Status", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "status"}},
+	{ID: "priority", Key: "priority", Name: "Priority", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "priority"}},
+	{ID: "reporter", Key: "reporter", Name: "Reporter", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "user"}},
+	{ID: "assignee", Key: "assignee", Name: "Assignee", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "user"}},
+	{ID: "labels", Key: "labels", Name: "Labels", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "array", Items: "string"}},
+	{ID: "components", Key: "components", Name: "Components", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "array", Items: "component"}},
+	{ID: "fixVersions", Key: "fixVersions", Name: "Fix versions", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "array", Items: "version"}},
+	{ID: "issuelinks", Key: "issuelinks", Name: "Linked Issues", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "array", Items: "issuelinks"}},
+	{ID: "attachment", Key: "attachment", Name: "Attachment", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "array", Items: "attachment"}},
+	{ID: "created", Key: "created", Name: "Created", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "datetime"}},
+	{ID: "updated", Key: "updated", Name: "Updated", Navigable: true, Searchable: true, Schema: fieldSchema{Type: "datetime"}},
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes a Jira style error with a single error message
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, jira.ErrorResponse{ErrorMessages: []string{message}, Errors: map[string]string{}})
+}
+
+// writeFieldError writes a Jira style 400 error for an invalid field
+func writeFieldError(w http.ResponseWriter, field, message string) {
+	writeJSON(w, http.StatusBadRequest, jira.ErrorResponse{ErrorMessages: []string{}, Errors: map[string]string{field: message}})
+}
+
+// writeBackendError forwards an error from the memory backend with its Jira status
+func writeBackendError(w http.ResponseWriter, err error) {
+	var apiErr *jira.APIError
+	if errors.As(err, &apiErr) {
+		writeJSON(w, apiErr.StatusCode, apiErr.Response)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,301 @@
+package jiratest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"jira-xray-integration/jira"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func newSeededServer(t *testing.T) *Server {
+	t.Helper()
+	srv := NewServer("TEST")
+	srv.Backend.SeedDemoData()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// call sends an authenticated JSON request to the server and decodes the response into out
+func call(t *testing.T, srv *Server, method, path string, body interface{}, out interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, srv.URL+"/rest/api/3"+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(Username, APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("failed to decode %s %s response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServerRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+	}{
+		{name: "search", method: http.MethodGet, path: "/search?jql=issuetype%3DTest", status: http.StatusOK},
+		{name: "search by POST", method: http.MethodPost, path: "/search", body: map[string]string{"jql": "issuetype = Test"}, status: http.StatusOK},
+		{name: "search with unsupported JQL", method: http.MethodGet, path: "/search?jql=key%3DA+OR+key%3DB", status: http.StatusBadRequest},
+		{name: "get issue", method: http.MethodGet, path: "/issue/TEST-1", status: http.StatusOK},
+		{name: "get missing issue", method: http.MethodGet, path: "/issue/TEST-99", status: http.StatusNotFound},
+		{name: "create without issue type", method: http.MethodPost, path: "/issue", body: map[string]interface{}{"fields": map[string]string{"summary": "x"}}, status: http.StatusBadRequest},
+		{name: "update unknown field", method: http.MethodPut, path: "/issue/TEST-1", body: map[string]interface{}{"fields": map[string]string{"reporter": "x"}}, status: http.StatusBadRequest},
+		{name: "update with empty summary", method: http.MethodPut, path: "/issue/TEST-1", body: map[string]interface{}{"fields": map[string]string{"summary": ""}}, status: http.StatusBadRequest},
+		{name: "delete", method: http.MethodDelete, path: "/issue/TEST-7", status: http.StatusNoContent},
+		{name: "link to missing issue", method: http.MethodPost, path: "/issueLink", body: map[string]interface{}{"type": map[string]string{"name": "Test"}, "inwardIssue": map[string]string{"key": "TEST-9"}, "outwardIssue": map[string]string{"key": "TEST-99"}}, status: http.StatusNotFound},
+		{name: "invalid transition", method: http.MethodPost, path: "/issue/TEST-1/transitions", body: map[string]interface{}{"transition": map[string]string{"id": "99"}}, status: http.StatusBadRequest},
+		{name: "fields", method: http.MethodGet, path: "/field", status: http.StatusOK},
+		{name: "project", method: http.MethodGet, path: "/project/TEST", status: http.StatusOK},
+		{name: "missing project", method: http.MethodGet, path: "/project/PAY", status: http.StatusNotFound},
+		{name: "project statuses", method: http.MethodGet, path: "/project/TEST/statuses", status: http.StatusOK},
+		{name: "issue link types", method: http.MethodGet, path: "/issueLinkType", status: http.StatusOK},
+		{name: "myself", method: http.MethodGet, path: "/myself", status: http.StatusOK},
+		{name: "unknown resource", method: http.MethodGet, path: "/project", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSeededServer(t)
+			if status := call(t, srv, tt.method, tt.path, tt.body, nil); status != tt.status {
+				t.Errorf("got status %d, want %d", status, tt.status)
+			}
+		})
+	}
+}
+
+func TestServerSearchPages(t *testing.T) {
+	srv := newSeededServer(t)
+
+	var page jira.JiraResponse
+	call(t, srv, http.MethodGet, "/search?jql=project%3DTEST&startAt=7&maxResults=5", nil, &page)
+	if page.Total != 10 || len(page.Issues) != 3 || page.Issues[0].Key != "TEST-8" {
+		t.Errorf("got total %d and %d issues starting at %v", page.Total, len(page.Issues), page.Issues)
+	}
+}
+
+func TestServerIssueLifecycle(t *testing.T) {
+	srv := newSeededServer(t)
+
+	var created jira.CreateIssueResponse
+	status := call(t, srv, http.MethodPost, "/issue", jira.CreateIssueRequest{Fields: jira.IssueFields{
+		Summary:   "New test",
+		IssueType: jira.IssueType{Name: jira.IssueTypeTest},
+		Project:   jira.Project{Key: "TEST"},
+	}}, &created)
+	if status != http.StatusCreated || created.Key != "TEST-11" || !strings.HasSuffix(created.Self, "/issue/"+created.ID) {
+		t.Fatalf("got status %d and %+v", status, created)
+	}
+
+	status = call(t, srv, http.MethodPut, "/issue/TEST-11", map[string]interface{}{
+		"fields": map[string]interface{}{"labels": []string{"smoke"}, "priority": map[string]string{"name": "Low"}},
+	}, nil)
+	if status != http.StatusNoContent {
+		t.Fatalf("update got status %d", status)
+	}
+
+	status = call(t, srv, http.MethodPost, "/issueLink", map[string]interface{}{
+		"type":         map[string]string{"name": "Test"},
+		"inwardIssue":  map[string]string{"key": "TEST-9"},
+		"outwardIssue": map[string]string{"key": "TEST-11"},
+	}, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("link got status %d", status)
+	}
+
+	var transitions struct {
+		Transitions []struct {
+			ID string `json:"id"`
+		} `json:"transitions"`
+	}
+	call(t, srv, http.MethodGet, "/issue/TEST-11/transitions", nil, &transitions)
+	if len(transitions.Transitions) != len(DefaultWorkflow) {
+		t.Fatalf("got %d transitions", len(transitions.Transitions))
+	}
+	if status := call(t, srv, http.MethodPost, "/issue/TEST-11/transitions", map[string]interface{}{"transition": map[string]string{"id": "31"}}, nil); status != http.StatusNoContent {
+		t.Fatalf("transition got status %d", status)
+	}
+
+	var issue jira.JiraIssue
+	call(t, srv, http.MethodGet, "/issue/TEST-11", nil, &issue)
+	if issue.Fields.Summary != "New test" || issue.Fields.Status.Name != "Done" || issue.Fields.Priority.Name != "Low" || len(issue.Fields.Labels) != 1 {
+		t.Errorf("got fields %+v", issue.Fields)
+	}
+	if len(issue.Fields.IssueLinks) != 1 || issue.Fields.IssueLinks[0].InwardIssue.Key != "TEST-9" {
+		t.Errorf("got links %+v", issue.Fields.IssueLinks)
+	}
+
+	execution, err := srv.JiraClient().GetTestExecution("TEST-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(execution.TestCases, ",") != "TEST-1,TEST-2,TEST-11" {
+		t.Errorf("got execution tests %v", execution.TestCases)
+	}
+
+	if status := call(t, srv, http.MethodDelete, "/issue/TEST-11", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete got status %d", status)
+	}
+	if status := call(t, srv, http.MethodGet, "/issue/TEST-11", nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleted issue got status %d", status)
+	}
+}
+
+func TestServerAttachments(t *testing.T) {
+	srv := newSeededServer(t)
+
+	upload := func(token string) *http.Response {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		file, _ := form.CreateFormFile("file", "run.log")
+		file.Write([]byte("all tests passed\n"))
+		form.Close()
+
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/rest/api/3/issue/TEST-1/attachments", &body)
+		req.SetBasicAuth(Username, APIToken)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		if token != "" {
+			req.Header.Set("X-Atlassian-Token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := upload(""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("upload without XSRF header got status %d", resp.StatusCode)
+	}
+
+	resp := upload("no-check")
+	defer resp.Body.Close()
+	var attachments []jira.Attachment
+	if err := json.NewDecoder(resp.Body).Decode(&attachments); err != nil || len(attachments) != 1 {
+		t.Fatalf("got %v (%v)", attachments, err)
+	}
+	if attachments[0].Filename != "run.log" || attachments[0].Size != 17 {
+		t.Errorf("got %+v", attachments[0])
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, attachments[0].Content, nil)
+	req.SetBasicAuth(Username, APIToken)
+	content, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Body.Close()
+	data, _ := io.ReadAll(content.Body)
+	if string(data) != "all tests passed\n" {
+		t.Errorf("got content %q", data)
+	}
+
+	issue, _ := srv.Backend.Issue("TEST-1")
+	if len(issue.Fields.Attachments) != 1 {
+		t.Errorf("attachment is missing from the issue")
+	}
+}
+
+func TestServerAuthentication(t *testing.T) {
+	srv := newSeededServer(t)
+
+	resp, err := http.Get(srv.URL + "/rest/api/3/issue/TEST-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous request got status %d", resp.StatusCode)
+	}
+
+	srv.Username = ""
+	resp, err = http.Get(srv.URL + "/rest/api/3/issue/TEST-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("request with authentication disabled got status %d", resp.StatusCode)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	srv := newSeededServer(t)
+	client := srv.JiraClient()
+
+	srv.InjectFault(Fault{Method: http.MethodPost, Path: "/issue", Status: http.StatusBadGateway, Times: 1})
+	if _, err := client.GetTestCase("TEST-1"); err != nil {
+		t.Errorf("fault for POST /issue affected a GET: %v", err)
+	}
+	if _, err := client.CreateTestCase(&jira.TestCase{Summary: "x"}); !jira.IsRetriable(err) {
+		t.Errorf("got %v, want a retriable 502", err)
+	}
+	if _, err := client.CreateTestCase(&jira.TestCase{Summary: "x"}); err != nil {
+		t.Errorf("fault applied more than once: %v", err)
+	}
+
+	srv.RateLimit(7)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/rest/api/3/field", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "7" {
+		t.Errorf("got status %d and Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	srv.ClearFaults()
+
+	srv.SetLatency(50 * time.Millisecond)
+	start := time.Now()
+	if _, err := client.GetTestCase("TEST-1"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("request took %s, want at least the injected latency", elapsed)
+	}
+
+	// Latency gives up when the caller does
+	srv.ClearFaults()
+	srv.SetLatency(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/rest/api/3/field", nil)
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Error("request did not time out")
+	}
+}
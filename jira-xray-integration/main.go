@@ -1,66 +1,209 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
+	"jira-xray-integration/auth"
 	"jira-xray-integration/jira"
+	"jira-xray-integration/metrics"
+	"jira-xray-integration/outbox"
+	"jira-xray-integration/report"
+	"jira-xray-integration/store"
+	"jira-xray-integration/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
 var (
-	config     *Config
-	jiraClient *jira.Client
+	reportTemplate *template.Template
+	resultStore    store.Store
+	outboxWorker   *outbox.Worker
 )
 
 func main() {
+	configPath := flag.String("config", "", "YAML or TOML configuration file, instead of CONFIG_FILE")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(*configPath, flag.Args()))
+	}
+
 	// Load configuration
-	var err error
-	config, err = LoadConfig()
+	config, err := LoadConfig(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	exportDotEnv(config.settings.dotEnv)
 
 	// Validate and log configuration
 	config.ValidateConfig()
 
-	// Initialize Jira client
-	jiraClient = jira.NewClient(
-		config.JiraBaseURL,
-		config.JiraUsername,
-		config.JiraAPIToken,
-		config.JiraProjectKey,
-	)
+	// Export spans of API requests and Jira calls, and pass trace context on
+	shutdownTracing, err := tracing.Setup(tracing.Options{
+		Exporter:    config.TracingExporter,
+		Endpoint:    config.TracingEndpoint,
+		SampleRatio: config.TracingSampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	// Open local result storage
+	resultStore, err = store.Open(store.Config{
+		Driver: config.StorageDriver,
+		Path:   config.StoragePath,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer resultStore.Close()
+
+	// Authenticate API callers and connect to each Jira tenant, or create the
+	// in-memory backend, with a backend per project and a sync engine keeping
+	// the tenant's issue cache
+	initial, err := newServerState(config, nil)
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+	state.Store(initial)
+
+	// Background work runs until shutdown: configuration reloads, on SIGHUP
+	// and when its files change, and the sync engines of the tenants
+	background, stopBackground := context.WithCancel(context.Background())
+	var backgroundDone sync.WaitGroup
+	reloads := newReloader(*configPath)
+	backgroundDone.Add(1)
+	go func() {
+		defer backgroundDone.Done()
+		reloads.Run(background)
+	}()
+
+	// Start replaying writes queued while Jira was unavailable
+	outboxWorker = outbox.NewWorker(resultStore, outboxHandlers(), outbox.Options{
+		Interval:   config.OutboxInterval,
+		MaxBackoff: config.OutboxMaxBackoff,
+		Retriable:  jira.IsRetriable,
+	})
+	backgroundDone.Add(1)
+	go func() {
+		defer backgroundDone.Done()
+		outboxWorker.Run(background)
+	}()
+
+	// Load execution report template
+	reportTemplate, err = report.LoadTemplate(config.ReportTemplate)
+	if err != nil {
+		log.Fatalf("Failed to load report template: %v", err)
+	}
 
+	router := setupRouter()
+	srv, err := newHTTPServer(config, tenantPaths(router))
+	if err != nil {
+		log.Fatalf("Failed to set up the server: %v", err)
+	}
+
+	// Start server
+	scheme := "http"
+	if srv.TLSConfig != nil {
+		scheme = "https"
+	}
+	log.Printf("🚀 Server starting on port %s", config.Port)
+	log.Printf("📋 API Documentation available at: %s://localhost%s/api/info", scheme, srv.Addr)
+
+	// Serve until SIGINT or SIGTERM, then drain requests in flight and stop the
+	// background work. A second signal stops the server at once.
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	served := make(chan error, 1)
+	go func() { served <- serve(srv) }()
+	select {
+	case err := <-served:
+		log.Fatalf("Failed to start server: %v", err)
+	case <-signals.Done():
+	}
+	stopSignals()
+
+	ctx, cancel := context.WithTimeout(context.Background(), currentConfig().ShutdownTimeout)
+	defer cancel()
+	shutdown(ctx, srv, func() {
+		stopBackground()
+		backgroundDone.Wait()
+		reloads.StopSync()
+	})
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+}
+
+// setupRouter creates the Gin router with middleware and all API routes
+func setupRouter() *gin.Engine {
 	// Initialize Gin router
 	router := gin.Default()
 
 	// Add middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
+	router.Use(tracing.Middleware())
+	router.Use(metrics.Middleware())
 	router.Use(corsMiddleware())
+	router.Use(limitBody())
 
-	// API routes
-	api := router.Group("/api")
+	// API routes. Each route names the permission a caller needs; health checks stay public.
+	// Jira credentials sent by the caller are picked up for every route.
+	api := router.Group("/api", selectTenant(), delegateJira())
 	{
-		// Test Case routes
-		api.GET("/testcases", getTestCases)
-		api.POST("/testcases", createTestCase)
-		api.GET("/testcases/:key", getTestCase)
-
-		// Test Execution routes
-		api.GET("/testexecutions", getTestExecutions)
-		api.POST("/testexecutions", createTestExecution)
-		api.GET("/testexecutions/:key", getTestExecution)
-
-		// Health check
+		// Jira tenants; every other route also exists under /api/tenants/:tenant
+		api.GET("/tenants", requirePermission(auth.PermInfoRead), getTenants)
+
+		// Project routes, for the default project and for each served project
+		registerProjectRoutes(api.Group("", scopeProject()))
+		registerProjectRoutes(api.Group("/projects/:projectKey", scopeProject()))
+
+		// Cross-project routes
+		api.GET("/projects", requireProjectsPermission(auth.PermInfoRead), getProjects)
+		api.GET("/projects/testcases", requireProjectsPermission(auth.PermTestCasesRead), getProjectsTestCases)
+		api.GET("/projects/testexecutions", requireProjectsPermission(auth.PermExecutionsRead), getProjectsTestExecutions)
+
+		// Sync routes, authorized on the project the tenant's sync engine keeps
+		api.GET("/sync", scopeSyncProject(), requirePermission(auth.PermSyncRead), getSyncStatus)
+		api.POST("/sync", scopeSyncProject(), requirePermission(auth.PermSyncRun), runSync)
+
+		// Outbox routes, authorized on the project of each entry
+		api.GET("/outbox", requireScope(auth.PermOutboxRead), getOutbox)
+		api.GET("/outbox/:id", requireScope(auth.PermOutboxRead), getOutboxEntry)
+		api.POST("/outbox/:id/retry", requireScope(auth.PermOutboxRetry), retryOutboxEntry)
+		api.DELETE("/outbox/:id", requireScope(auth.PermOutboxDelete), deleteOutboxEntry)
+
+		// Auth routes
+		api.GET("/auth/me", requirePermission(""), getCurrentPrincipal)
+		api.GET("/admin/apikeys", requirePermission(auth.PermAdminAPIKeys), getAPIKeys)
+		api.POST("/admin/apikeys", requirePermission(auth.PermAdminAPIKeys), createAPIKey)
+		api.DELETE("/admin/apikeys/:id", requirePermission(auth.PermAdminAPIKeys), deleteAPIKey)
+		api.GET("/admin/roles", requirePermission(auth.PermAdminRoles), getRoles)
+		api.POST("/admin/roles/bindings", requirePermission(auth.PermAdminRoles), createRoleBinding)
+		api.DELETE("/admin/roles/bindings/:id", requirePermission(auth.PermAdminRoles), deleteRoleBinding)
+
+		// Health routes, public so load balancers and orchestrators can probe them
 		api.GET("/health", healthCheck)
+		api.GET("/health/live", healthLive)
+		api.GET("/health/ready", healthReady)
 
 		// API info
-		api.GET("/info", getAPIInfo)
+		api.GET("/info", requirePermission(auth.PermInfoRead), getAPIInfo)
 	}
 
+	// Prometheus metrics, readable by callers allowed to read the API info
+	metrics.SetOutboxDepth(outboxDepth)
+	router.GET("/metrics", requirePermission(auth.PermInfoRead), serveMetrics)
+
 	// Root route
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -68,30 +211,60 @@ func main() {
 			"version":     "1.0.0",
 			"description": "A Go application for test management with Jira integration",
 			"endpoints": gin.H{
-				"health":          "/api/health",
-				"info":            "/api/info",
-				"testcases":       "/api/testcases",
-				"testexecutions":  "/api/testexecutions",
+				"health":         "/api/health",
+				"liveness":       "/api/health/live",
+				"readiness":      "/api/health/ready",
+				"info":           "/api/info",
+				"metrics":        "/metrics",
+				"testcases":      "/api/testcases",
+				"testexecutions": "/api/testexecutions",
 			},
 		})
 	})
 
-	// Start server
-	port := ":" + config.Port
-	log.Printf("🚀 Server starting on port %s", config.Port)
-	log.Printf("📋 API Documentation available at: http://localhost%s/api/info", port)
-	
-	if err := router.Run(port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	return router
 }
 
-// corsMiddleware adds CORS headers
+// registerProjectRoutes adds the routes that work on one Jira project to g
+func registerProjectRoutes(g *gin.RouterGroup) {
+	// Test Case routes
+	g.GET("/testcases", requirePermission(auth.PermTestCasesRead), getTestCases)
+	g.POST("/testcases", requirePermission(auth.PermTestCasesWrite), createTestCase)
+	g.GET("/testcases/export", requirePermission(auth.PermTestCasesRead), exportTestCases)
+	g.POST("/testcases/import", requirePermission(auth.PermTestCasesImport), importTestCases)
+	g.GET("/testcases/:key", requirePermission(auth.PermTestCasesRead), getTestCase)
+	g.GET("/testcases/:key/history", requirePermission(auth.PermTestCasesRead), getTestCaseHistory)
+
+	// Test Execution routes
+	g.GET("/testexecutions", requirePermission(auth.PermExecutionsRead), getTestExecutions)
+	g.POST("/testexecutions", requirePermission(auth.PermExecutionsWrite), createTestExecution)
+	g.GET("/testexecutions/:key", requirePermission(auth.PermExecutionsRead), getTestExecution)
+	g.GET("/testexecutions/:key/report", requirePermission(auth.PermExecutionsRead), getTestExecutionReport)
+	g.POST("/testexecutions/:key/results", requirePermission(auth.PermExecutionsWrite), recordTestResults)
+
+	// Result routes
+	g.POST("/results/:id/evidence", requirePermission(auth.PermExecutionsWrite), addResultEvidence)
+
+	// Traceability routes
+	g.GET("/traceability", requirePermission(auth.PermRequirementsRead), getTraceabilityMatrix)
+	g.GET("/requirements/coverage", requirePermission(auth.PermRequirementsRead), getRequirementsCoverage)
+	g.GET("/requirements/:key/coverage", requirePermission(auth.PermRequirementsRead), getRequirementCoverage)
+
+	// Import routes
+	g.POST("/import/gotest", requirePermission(auth.PermResultsImport), importGoTestResults)
+}
+
+// corsMiddleware adds CORS headers for the configured origins
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
+		if origin := allowedOrigin(c.GetHeader("Origin")); origin != "" {
+			c.Header("Access-Control-Allow-Origin", origin)
+			if origin != "*" {
+				c.Header("Vary", "Origin")
+			}
+		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key, X-API-Key, X-Jira-User, X-Jira-Token, X-Jira-Tenant")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -102,40 +275,82 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
-// Health check endpoint
-func healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"timestamp": gin.H{
-			"unix": gin.H{
-				"seconds": gin.H{
-					"value": "current_time",
-				},
-			},
-		},
-		"jira": gin.H{
-			"base_url":    config.JiraBaseURL,
-			"project_key": config.JiraProjectKey,
-			"demo_mode":   config.JiraUsername == "demo_user",
-		},
-	})
+// allowedOrigin returns the Access-Control-Allow-Origin value for a request
+// origin, or "" if browsers on that origin may not read responses
+func allowedOrigin(origin string) string {
+	for _, allowed := range currentConfig().CORSAllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if origin != "" && allowed == origin {
+			return origin
+		}
+	}
+	return ""
 }
 
 // API info endpoint
 func getAPIInfo(c *gin.Context) {
+	config := currentConfig()
 	c.JSON(http.StatusOK, gin.H{
 		"name":        "Jira Xray-like Integration API",
 		"version":     "1.0.0",
 		"description": "A Go application for test management with Jira integration",
 		"endpoints": gin.H{
-			"GET /api/health":                    "Health check",
-			"GET /api/info":                      "API information",
-			"GET /api/testcases":                 "List all test cases",
-			"POST /api/testcases":                "Create a new test case",
-			"GET /api/testcases/:key":            "Get a specific test case",
-			"GET /api/testexecutions":            "List all test executions",
-			"POST /api/testexecutions":           "Create a new test execution",
-			"GET /api/testexecutions/:key":       "Get a specific test execution",
+			"GET /api/health":                                            "Health check: readiness of each component and the Jira connection (?fresh=true checks again for authenticated callers)",
+			"GET /api/health/live":                                       "Liveness probe: 200 while the process serves requests",
+			"GET /api/health/ready":                                      "Readiness probe: Jira, storage, sync lag and outbox depth, 503 while a component is down (?fresh=true checks again for authenticated callers)",
+			"GET /api/info":                                              "API information",
+			"GET /metrics":                                               "Prometheus metrics of requests, Jira calls, imports, the issue cache and the outbox",
+			"GET /api/testcases":                                         "List all test cases (?fresh=true bypasses the cache)",
+			"POST /api/testcases":                                        "Create a new test case",
+			"GET /api/testcases/:key":                                    "Get a specific test case (?fresh=true bypasses the cache)",
+			"GET /api/testcases/:key/history":                            "Run history of a test case (?limit=50)",
+			"GET /api/testcases/export":                                  "Export test cases (?format=csv|xlsx)",
+			"POST /api/testcases/import":                                 "Import test cases from CSV or Excel (?dryRun=true)",
+			"GET /api/testexecutions":                                    "List all test executions (?fresh=true bypasses the cache)",
+			"POST /api/testexecutions":                                   "Create a new test execution",
+			"GET /api/testexecutions/:key":                               "Get a specific test execution (?fresh=true bypasses the cache)",
+			"GET /api/testexecutions/:key/report":                        "Execution sign-off report (?format=html|pdf)",
+			"POST /api/testexecutions/:key/results":                      "Record test results for an execution",
+			"POST /api/results/:id/evidence":                             "Add evidence metadata to a recorded result",
+			"GET /api/traceability":                                      "Requirement traceability matrix (?jql=...&format=json|csv|html)",
+			"GET /api/requirements/:key/coverage":                        "Requirement coverage status (?fixVersion=&testPlan=&environment=)",
+			"GET /api/requirements/coverage":                             "Coverage status for many requirements (?keys=A,B or ?jql=...)",
+			"POST /api/import/gotest":                                    "Import go test -json output as a test execution",
+			"GET /api/tenants":                                           "Configured Jira tenants; prefix any other route with /api/tenants/:tenant, or send X-Jira-Tenant",
+			"GET /api/projects":                                          "Served projects the caller may read",
+			"GET /api/projects/testcases":                                "Test cases across projects (?projects=A,B, default every permitted project)",
+			"GET /api/projects/testexecutions":                           "Test executions across projects (?projects=A,B, default every permitted project)",
+			"GET /api/projects/:projectKey/testcases":                    "List the test cases of a project",
+			"POST /api/projects/:projectKey/testcases":                   "Create a test case in a project",
+			"GET /api/projects/:projectKey/testcases/:key":               "Get a test case of a project",
+			"GET /api/projects/:projectKey/testcases/:key/history":       "Run history of a test case of a project (?limit=50)",
+			"GET /api/projects/:projectKey/testcases/export":             "Export the test cases of a project (?format=csv|xlsx)",
+			"POST /api/projects/:projectKey/testcases/import":            "Import test cases into a project (?dryRun=true)",
+			"GET /api/projects/:projectKey/testexecutions":               "List the test executions of a project",
+			"POST /api/projects/:projectKey/testexecutions":              "Create a test execution in a project",
+			"GET /api/projects/:projectKey/testexecutions/:key":          "Get a test execution of a project",
+			"GET /api/projects/:projectKey/testexecutions/:key/report":   "Execution sign-off report of a project (?format=html|pdf)",
+			"POST /api/projects/:projectKey/testexecutions/:key/results": "Record test results for an execution of a project",
+			"POST /api/projects/:projectKey/results/:id/evidence":        "Add evidence metadata to a result of a project",
+			"GET /api/projects/:projectKey/traceability":                 "Traceability matrix of a project (?jql=...&format=json|csv|html)",
+			"GET /api/projects/:projectKey/requirements/:key/coverage":   "Coverage status of a requirement of a project",
+			"GET /api/projects/:projectKey/requirements/coverage":        "Coverage status for many requirements of a project",
+			"POST /api/projects/:projectKey/import/gotest":               "Import go test -json output into a project",
+			"GET /api/sync":                                              "Jira sync status",
+			"POST /api/sync":                                             "Sync with Jira now (?full=true also removes deleted issues)",
+			"GET /api/outbox":                                            "Writes queued while Jira was unavailable (?status=pending|failed|done)",
+			"GET /api/outbox/:id":                                        "Get a queued write",
+			"POST /api/outbox/:id/retry":                                 "Replay a queued write now",
+			"DELETE /api/outbox/:id":                                     "Discard a queued write",
+			"GET /api/auth/me":                                           "The authenticated caller and its scopes",
+			"GET /api/admin/apikeys":                                     "List API keys",
+			"POST /api/admin/apikeys":                                    "Create an API key; the key is only shown in this response",
+			"DELETE /api/admin/apikeys/:id":                              "Revoke an API key",
+			"GET /api/admin/roles":                                       "Roles, their permissions and the role bindings",
+			"POST /api/admin/roles/bindings":                             "Grant a role on a project to an API key, JWT subject or JWT claim",
+			"DELETE /api/admin/roles/bindings/:id":                       "Revoke a role binding",
 		},
 		"example_requests": gin.H{
 			"create_test_case": gin.H{
@@ -161,9 +376,18 @@ func getAPIInfo(c *gin.Context) {
 			},
 		},
 		"configuration": gin.H{
-			"jira_base_url":  config.JiraBaseURL,
-			"project_key":    config.JiraProjectKey,
-			"demo_mode":      config.JiraUsername == "demo_user",
+			"jira_base_url":   redactURL(config.JiraBaseURL),
+			"project_key":     config.JiraProjectKey,
+			"backend":         config.Backend,
+			"demo_mode":       config.Backend == "memory",
+			"auth_methods":    config.AuthMethods,
+			"rbac_enabled":    config.RBACEnabled,
+			"jira_delegation": config.JiraDelegation,
+			"jira_verify":     config.JiraVerify,
+			"projects":        projectKeysOf(config.DefaultTenant()),
+			"tenants":         tenantNames(),
+			"config_file":     config.ConfigFile,
+			"secret_sources":  config.SecretSources,
 		},
 	})
 }
@@ -172,7 +396,7 @@ func getAPIInfo(c *gin.Context) {
 func getTestCases(c *gin.Context) {
 	log.Println("Handling GET /api/testcases request")
 
-	testCases, err := jiraClient.ListTestCases()
+	testCases, cached, err := listTestCases(c, requestProject(c))
 	if err != nil {
 		log.Printf("Error fetching test cases: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -185,6 +409,7 @@ func getTestCases(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"testCases": testCases,
 		"count":     len(testCases),
+		"source":    responseSource(cached),
 		"message":   "Test cases retrieved successfully",
 	})
 }
@@ -211,8 +436,15 @@ func createTestCase(c *gin.Context) {
 		return
 	}
 
-	createdTestCase, err := jiraClient.CreateTestCase(&testCase)
+	if respondWithQueuedWrite(c, outbox.OpCreateTestCase, "", testCase) {
+		return
+	}
+
+	createdTestCase, err := jiraFor(c).CreateTestCase(&testCase)
 	if err != nil {
+		if queueWrite(c, outbox.OpCreateTestCase, "", testCase, err, "test case") {
+			return
+		}
 		log.Printf("Error creating test case: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create test case",
@@ -221,6 +453,8 @@ func createTestCase(c *gin.Context) {
 		return
 	}
 
+	requestTenant(c).syncEngine.Trigger()
+
 	c.JSON(http.StatusCreated, gin.H{
 		"testCase": createdTestCase,
 		"message":  "Test case created successfully",
@@ -232,18 +466,47 @@ func getTestCase(c *gin.Context) {
 	key := c.Param("key")
 	log.Printf("Handling GET /api/testcases/%s request", key)
 
-	// For demo purposes, return a mock test case
-	// In a real implementation, you would fetch from Jira
+	var testCase *jira.TestCase
+	var err error
+	t, project := requestTenant(c), requestProjectConfig(c)
+	cached := serveFromCache(c, project.IssueTypes.Test)
+	if cached {
+		issue, cacheErr := t.cache.CachedIssue(key)
+		switch {
+		case cacheErr == nil && issue.Fields.IssueType.Name != project.IssueTypes.Test:
+			// Another issue of the project, such as an execution, is not a test case
+			err = &jira.IssueTypeError{Key: key, IssueType: issue.Fields.IssueType.Name, Want: project.IssueTypes.Test}
+		case cacheErr == nil:
+			testCase = project.TestCaseFromIssue(issue)
+		case errors.Is(cacheErr, store.ErrNotFound):
+			// Not synced yet, ask Jira
+			cached = false
+		default:
+			log.Printf("Error reading cached test case: %v", cacheErr)
+			cached = false
+		}
+	}
+
+	if !cached {
+		testCase, err = jiraFor(c).GetTestCase(key)
+	}
+	if err != nil {
+		log.Printf("Error fetching test case: %v", err)
+		status := http.StatusInternalServerError
+		if jira.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to fetch test case",
+			"details": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"testCase": gin.H{
-			"id":          "10001",
-			"key":         key,
-			"summary":     "Sample Test Case",
-			"description": "This is a sample test case",
-			"status":      "To Do",
-			"priority":    "Medium",
-		},
-		"message": "Test case retrieved successfully",
+		"testCase": testCase,
+		"source":   responseSource(cached),
+		"message":  "Test case retrieved successfully",
 	})
 }
 
@@ -251,32 +514,20 @@ func getTestCase(c *gin.Context) {
 func getTestExecutions(c *gin.Context) {
 	log.Println("Handling GET /api/testexecutions request")
 
-	// For demo purposes, return mock test executions
-	// In a real implementation, you would fetch from Jira
-	mockExecutions := []gin.H{
-		{
-			"id":              "10005",
-			"key":             "EXEC-1",
-			"summary":         "Sprint 1 Test Execution",
-			"status":          "In Progress",
-			"executionStatus": "EXECUTING",
-			"testCases":       []string{"TEST-1", "TEST-2"},
-			"environment":     "QA",
-		},
-		{
-			"id":              "10006",
-			"key":             "EXEC-2",
-			"summary":         "Regression Test Execution",
-			"status":          "Done",
-			"executionStatus": "PASS",
-			"testCases":       []string{"TEST-1", "TEST-2", "TEST-3"},
-			"environment":     "Staging",
-		},
+	testExecutions, cached, err := listTestExecutions(c, requestProject(c))
+	if err != nil {
+		log.Printf("Error fetching test executions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch test executions",
+			"details": err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"testExecutions": mockExecutions,
-		"count":          len(mockExecutions),
+		"testExecutions": testExecutions,
+		"count":          len(testExecutions),
+		"source":         responseSource(cached),
 		"message":        "Test executions retrieved successfully",
 	})
 }
@@ -310,8 +561,15 @@ func createTestExecution(c *gin.Context) {
 		return
 	}
 
-	createdTestExecution, err := jiraClient.CreateTestExecution(&testExecution)
+	if respondWithQueuedWrite(c, outbox.OpCreateTestExecution, "", testExecution) {
+		return
+	}
+
+	createdTestExecution, err := jiraFor(c).CreateTestExecution(&testExecution)
 	if err != nil {
+		if queueWrite(c, outbox.OpCreateTestExecution, "", testExecution, err, "test execution") {
+			return
+		}
 		log.Printf("Error creating test execution: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create test execution",
@@ -320,6 +578,18 @@ func createTestExecution(c *gin.Context) {
 		return
 	}
 
+	if err := saveTestExecution(requestTenant(c), createdTestExecution); err != nil {
+		log.Printf("Error storing test execution: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":         "Test execution was created in Jira but its results could not be stored",
+			"details":       err.Error(),
+			"testExecution": createdTestExecution,
+		})
+		return
+	}
+
+	requestTenant(c).syncEngine.Trigger()
+
 	c.JSON(http.StatusCreated, gin.H{
 		"testExecution": createdTestExecution,
 		"message":       "Test execution created successfully",
@@ -331,10 +601,14 @@ func getTestExecution(c *gin.Context) {
 	key := c.Param("key")
 	log.Printf("Handling GET /api/testexecutions/%s request", key)
 
-	testExecution, err := jiraClient.GetTestExecution(key)
+	testExecution, err := loadTestExecution(requestTenant(c), requestProjectConfig(c), jiraFor(c), key, freshRead(c))
 	if err != nil {
 		log.Printf("Error fetching test execution: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if jira.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
 			"error":   "Failed to fetch test execution",
 			"details": err.Error(),
 		})
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"jira-xray-integration/cassette"
+	"jira-xray-integration/jira"
+	"jira-xray-integration/jiratest"
+	"jira-xray-integration/outbox"
+	"jira-xray-integration/report"
+	"jira-xray-integration/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current responses")
+
+// goldenBaseURL replaces the address of the fake Jira server in golden files
+const goldenBaseURL = "http://jira.test"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testEnv is a router wired to a fake Jira with demo data and an in-memory store
+type testEnv struct {
+	router *gin.Engine
+	jira   *jiratest.Server
+}
+
+// newTestEnv points the handlers at a fresh fake Jira and store. Background sync
+// is disabled and the outbox is enabled, but nothing runs in the background.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	srv := jiratest.NewServer("TEST")
+	srv.Backend.SeedDemoData()
+	t.Cleanup(srv.Close)
+
+	config := &Config{
+		Backend:            "jira",
+		JiraTenant:         "default",
+		CassetteMode:       cassette.ModeOff,
+		JiraBaseURL:        srv.URL,
+		JiraUsername:       jiratest.Username,
+		JiraAPIToken:       jiratest.APIToken,
+		JiraProjectKey:     "TEST",
+		StorageDriver:      "memory",
+		OutboxInterval:     time.Hour,
+		OutboxMaxBackoff:   time.Hour,
+		CORSAllowedOrigins: []string{"*"},
+		JiraVerify:         verifyOff,
+		HealthCheckTimeout: 5 * time.Second,
+	}
+	resultStore = store.NewMemoryStore()
+	outboxWorker = outbox.NewWorker(resultStore, outboxHandlers(), outbox.Options{
+		Interval:   config.OutboxInterval,
+		MaxBackoff: config.OutboxMaxBackoff,
+		Retriable:  jira.IsRetriable,
+	})
+
+	var err error
+	if reportTemplate, err = report.LoadTemplate(""); err != nil {
+		t.Fatalf("failed to load report template: %v", err)
+	}
+
+	env := &testEnv{router: setupRouter(), jira: srv}
+	state.Store(&serverState{config: config})
+	env.reload(t)
+	return env
+}
+
+// reload sets up authentication and connects the tenants to Jira again after
+// the current configuration changed. Their issue caches are kept in the store.
+func (e *testEnv) reload(t *testing.T) {
+	t.Helper()
+	next, err := newServerState(currentConfig(), state.Load())
+	if err != nil {
+		t.Fatalf("failed to set up the server: %v", err)
+	}
+	state.Store(next)
+}
+
+// setSyncInterval replaces the sync engines; a non-zero interval lets reads be
+// served from the cache once a sync has run
+func (e *testEnv) setSyncInterval(t *testing.T, interval time.Duration) {
+	t.Helper()
+	currentConfig().SyncInterval = interval
+	e.reload(t)
+}
+
+// do sends a request through the router. headers are name, value pairs.
+func (e *testEnv) do(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	tenantPaths(e.router).ServeHTTP(rec, req)
+	return rec
+}
+
+// mustDo sends a request and fails the test unless it answers with status
+func (e *testEnv) mustDo(t *testing.T, status int, method, path, body string, headers ...string) map[string]interface{} {
+	t.Helper()
+	rec := e.do(method, path, body, headers...)
+	if rec.Code != status {
+		t.Fatalf("%s %s: got status %d, want %d: %s", method, path, rec.Code, status, rec.Body.String())
+	}
+	return decodeBody(t, rec)
+}
+
+// handlerTest is one request to a handler and the response expected from it
+type handlerTest struct {
+	name    string
+	setup   func(t *testing.T, env *testEnv)
+	method  string
+	path    string
+	body    string
+	headers []string
+	status  int
+	golden  string // compare the JSON response with testdata/golden/<golden>.json
+	check   func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder)
+}
+
+// runHandlerTests runs every test against its own fresh environment
+func runHandlerTests(t *testing.T, tests []handlerTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			if tt.setup != nil {
+				tt.setup(t, env)
+			}
+
+			rec := env.do(tt.method, tt.path, tt.body, tt.headers...)
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.golden != "" {
+				assertGolden(t, tt.golden, rec.Body.Bytes(), env.jira.URL)
+			}
+			if tt.check != nil {
+				tt.check(t, env, rec)
+			}
+		})
+	}
+}
+
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not a JSON object: %v: %s", err, rec.Body.String())
+	}
+	return body
+}
+
+// assertGolden compares a JSON response with its golden file after replacing
+// timestamps and the fake Jira address, which change from run to run. Run
+// go test -update to rewrite the golden files.
+func assertGolden(t *testing.T, name string, body []byte, baseURL string) {
+	t.Helper()
+
+	var value interface{}
+	if err := json.Unmarshal(bytes.ReplaceAll(body, []byte(baseURL), []byte(goldenBaseURL)), &value); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, body)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(normalizeTimes(value)); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	got := buf.Bytes()
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run go test -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response does not match %s (run go test -update to accept it):\n%s", path, diffLines(string(want), string(got)))
+	}
+}
+
+// timeLayouts are the timestamp formats found in responses
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.000-0700"}
+
+// normalizeTimes replaces non-zero timestamps and durations with placeholders
+func normalizeTimes(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if key == "lastDuration" || key == "latency" {
+				v[key] = "<duration>"
+				continue
+			}
+			v[key] = normalizeTimes(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeTimes(item)
+		}
+	case string:
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, v); err == nil && !parsed.IsZero() {
+				return "<time>"
+			}
+		}
+	}
+	return value
+}
+
+// diffLines lists the first lines that differ between want and got
+func diffLines(want, got string) string {
+	const maxLines = 20
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	var diff strings.Builder
+	for i, shown := 0, 0; (i < len(wantLines) || i < len(gotLines)) && shown < maxLines; i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			fmt.Fprintf(&diff, "line %d:\n  want: %s\n   got: %s\n", i+1, w, g)
+			shown++
+		}
+	}
+	return diff.String()
+}
+
+func TestTestCaseHandlers(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "list",
+			method: http.MethodGet, path: "/api/testcases",
+			status: http.StatusOK,
+			golden: "testcases_list",
+		},
+		{
+			name: "list from cache",
+			setup: func(t *testing.T, env *testEnv) {
+				env.setSyncInterval(t, time.Hour)
+				env.mustDo(t, http.StatusOK, http.MethodPost, "/api/sync", "")
+			},
+			method: http.MethodGet, path: "/api/testcases",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := decodeBody(t, rec)
+				if body["source"] != "cache" || body["count"] != 3.0 {
+					t.Errorf("got source %v and count %v, want 3 test cases from the cache", body["source"], body["count"])
+				}
+			},
+		},
+		{
+			name: "list fresh bypasses the cache",
+			setup: func(t *testing.T, env *testEnv) {
+				env.setSyncInterval(t, time.Hour)
+				env.mustDo(t, http.StatusOK, http.MethodPost, "/api/sync", "")
+			},
+			method: http.MethodGet, path: "/api/testcases?fresh=true",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if source := decodeBody(t, rec)["source"]; source != "jira" {
+					t.Errorf("got source %v, want jira", source)
+				}
+			},
+		},
+		{
+			name:   "list when Jira fails",
+			setup:  func(t *testing.T, env *testEnv) { env.jira.FailNext(1, http.StatusServiceUnavailable) },
+			method: http.MethodGet, path: "/api/testcases",
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "get",
+			method: http.MethodGet, path: "/api/testcases/TEST-1",
+			status: http.StatusOK,
+			golden: "testcase_get",
+		},
+		{
+			name:   "get unknown",
+			method: http.MethodGet, path: "/api/testcases/TEST-999",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "create",
+			method: http.MethodPost, path: "/api/testcases",
+			body:   `{"summary":"Checkout with saved card","description":"Pay with a stored card","priority":"High","labels":["checkout"],"steps":[{"action":"Open the cart","expectedResult":"Cart is shown"}]}`,
+			status: http.StatusCreated,
+			golden: "testcase_create",
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				issue, err := env.jira.Backend.Issue("TEST-11")
+				if err != nil {
+					t.Fatalf("test case was not created in Jira: %v", err)
+				}
+				if issue.Fields.Summary != "Checkout with saved card" || issue.Fields.IssueType.Name != jira.IssueTypeTest {
+					t.Errorf("got %q of type %q", issue.Fields.Summary, issue.Fields.IssueType.Name)
+				}
+			},
+		},
+		{
+			name:   "create without summary",
+			method: http.MethodPost, path: "/api/testcases",
+			body:   `{"description":"No summary"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create with invalid JSON",
+			method: http.MethodPost, path: "/api/testcases",
+			body:   `{"summary":`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create queued while Jira is unavailable",
+			setup:  func(t *testing.T, env *testEnv) { env.jira.FailNext(1, http.StatusServiceUnavailable) },
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Queued test"}`,
+			headers: []string{"Idempotency-Key", "create-1"},
+			status:  http.StatusAccepted,
+			golden:  "testcase_create_queued",
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				entries, err := resultStore.ListOutbox(store.OutboxPending)
+				if err != nil || len(entries) != 1 {
+					t.Fatalf("got %d pending outbox entries (%v), want 1", len(entries), err)
+				}
+			},
+		},
+		{
+			name: "create retried with a queued idempotency key",
+			setup: func(t *testing.T, env *testEnv) {
+				env.jira.FailNext(1, http.StatusServiceUnavailable)
+				env.mustDo(t, http.StatusAccepted, http.MethodPost, "/api/testcases", `{"summary":"Queued test"}`, "Idempotency-Key", "create-1")
+			},
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Queued test"}`,
+			headers: []string{"Idempotency-Key", "create-1"},
+			status:  http.StatusAccepted,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if _, err := env.jira.Backend.Issue("TEST-11"); !jira.IsNotFound(err) {
+					t.Errorf("retried request created a test case in Jira")
+				}
+				if entries, _ := resultStore.ListOutbox(""); len(entries) != 1 {
+					t.Errorf("got %d outbox entries, want 1", len(entries))
+				}
+			},
+		},
+		{
+			name: "create rejected by Jira",
+			setup: func(t *testing.T, env *testEnv) {
+				env.jira.InjectFault(jiratest.Fault{Method: http.MethodPost, Path: "/issue", Status: http.StatusBadRequest, Times: 1})
+			},
+			method: http.MethodPost, path: "/api/testcases",
+			body:   `{"summary":"Rejected test"}`,
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if entries, _ := resultStore.ListOutbox(""); len(entries) != 0 {
+					t.Errorf("a permanent failure was queued in the outbox")
+				}
+			},
+		},
+	})
+}
+
+func TestTestExecutionHandlers(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "list",
+			method: http.MethodGet, path: "/api/testexecutions",
+			status: http.StatusOK,
+			golden: "testexecutions_list",
+		},
+		{
+			name:   "list when Jira fails",
+			setup:  func(t *testing.T, env *testEnv) { env.jira.FailNext(1, http.StatusBadGateway) },
+			method: http.MethodGet, path: "/api/testexecutions",
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:   "get",
+			method: http.MethodGet, path: "/api/testexecutions/TEST-9",
+			status: http.StatusOK,
+			golden: "testexecution_get",
+		},
+		{
+			name: "get with recorded results",
+			setup: func(t *testing.T, env *testEnv) {
+				env.mustDo(t, http.StatusCreated, http.MethodPost, "/api/testexecutions/TEST-9/results",
+					`{"testResults":[{"testCaseKey":"TEST-1","status":"PASS"},{"testCaseKey":"TEST-2","status":"FAIL"}]}`)
+			},
+			method: http.MethodGet, path: "/api/testexecutions/TEST-9",
+			status: http.StatusOK,
+			golden: "testexecution_get_with_results",
+		},
+		{
+			name: "get from cache",
+			setup: func(t *testing.T, env *testEnv) {
+				env.setSyncInterval(t, time.Hour)
+				env.mustDo(t, http.StatusOK, http.MethodPost, "/api/sync", "")
+				env.jira.FailNext(1, http.StatusServiceUnavailable)
+			},
+			method: http.MethodGet, path: "/api/testexecutions/TEST-10",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if n := len(env.jira.Requests()); n != 3 {
+					t.Errorf("got %d Jira requests, want only the 3 sync searches", n)
+				}
+			},
+		},
+		{
+			name:   "get unknown",
+			method: http.MethodGet, path: "/api/testexecutions/TEST-999",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "get a test case as an execution",
+			method: http.MethodGet, path: "/api/testexecutions/TEST-1",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "create",
+			method: http.MethodPost, path: "/api/testexecutions",
+			body:   `{"summary":"Nightly run","testCases":["TEST-1","TEST-3"],"environment":"QA","fixVersion":"1.0"}`,
+			status: http.StatusCreated,
+			golden: "testexecution_create",
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				stored, err := resultStore.GetExecution("TEST-11")
+				if err != nil {
+					t.Fatalf("execution was not stored: %v", err)
+				}
+				if stored.Environment != "QA" || len(stored.TestCases) != 2 {
+					t.Errorf("got stored execution %+v", stored)
+				}
+			},
+		},
+		{
+			name:   "create without test cases",
+			method: http.MethodPost, path: "/api/testexecutions",
+			body:   `{"summary":"Empty run"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create with an empty test case list",
+			method: http.MethodPost, path: "/api/testexecutions",
+			body:   `{"summary":"Empty run","testCases":[]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "create queued while Jira is rate limiting",
+			setup:  func(t *testing.T, env *testEnv) { env.jira.RateLimit(30) },
+			method: http.MethodPost, path: "/api/testexecutions",
+			body:    `{"summary":"Nightly run","testCases":["TEST-1"]}`,
+			headers: []string{"Idempotency-Key", "execution-1"},
+			status:  http.StatusAccepted,
+			golden:  "testexecution_create_queued",
+		},
+	})
+}
+
+func TestInfoHandlers(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "health",
+			method: http.MethodGet, path: "/api/health",
+			status: http.StatusOK,
+			golden: "health",
+		},
+		{
+			name:   "root",
+			method: http.MethodGet, path: "/",
+			status: http.StatusOK,
+			golden: "root",
+		},
+		{
+			name:   "info lists every API route",
+			method: http.MethodGet, path: "/api/info",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				endpoints, _ := decodeBody(t, rec)["endpoints"].(map[string]interface{})
+				for _, route := range env.router.Routes() {
+					if !strings.HasPrefix(route.Path, "/api/") {
+						continue
+					}
+					if _, ok := endpoints[route.Method+" "+route.Path]; !ok {
+						t.Errorf("%s %s is missing from /api/info", route.Method, route.Path)
+					}
+				}
+				if len(endpoints) != len(env.router.Routes())-1 {
+					t.Errorf("/api/info lists %d endpoints for %d API routes", len(endpoints), len(env.router.Routes())-1)
+				}
+			},
+		},
+		{
+			name:   "CORS preflight",
+			method: http.MethodOptions, path: "/api/testcases",
+			headers: []string{"Origin", "https://example.com"},
+			status:  http.StatusNoContent,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if got := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Idempotency-Key") || !strings.Contains(got, "X-API-Key") {
+					t.Errorf("Idempotency-Key or X-API-Key is not an allowed header: %q", got)
+				}
+				if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+					t.Errorf("got Access-Control-Allow-Origin %q, want *", got)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,213 @@
+// Package metrics holds the Prometheus metrics of the service: API requests,
+// requests to Jira with their errors and rate limiting, outbox replays,
+// imports and issue cache reads. Metrics are registered on a registry of
+// their own, served by Handler.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric
+const namespace = "xray"
+
+// Rate limiting events of requests to Jira
+const (
+	RateLimitThrottled = "throttled" // the request waited for the client side rate limit
+	RateLimitHeld      = "held"      // answered 429 locally while Jira asked for no requests
+	RateLimitRejected  = "rejected"  // Jira answered 429 Too Many Requests
+)
+
+// Results of issue cache reads
+const (
+	CacheHit    = "hit"    // served from the issue cache
+	CacheMiss   = "miss"   // read from Jira as the cache was not ready or lacked the issue
+	CacheBypass = "bypass" // read from Jira as the request asked for fresh data
+)
+
+// Buckets of request durations in seconds, from a cached read to a slow
+// spreadsheet import
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+var (
+	// HTTPRequests counts API requests by method, route and status
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "http", Name: "requests_total",
+		Help: "API requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes how long API requests take
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+		Help:    "Time to handle API requests, by method, route and status code.",
+		Buckets: durationBuckets,
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestsInFlight is the number of API requests being handled
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "http", Name: "requests_in_flight",
+		Help: "API requests being handled.",
+	})
+
+	// JiraRequests counts requests to Jira by method, endpoint and status;
+	// the status is "error" when no response was received
+	JiraRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "jira", Name: "requests_total",
+		Help: "Requests sent to Jira, by method, endpoint and status code.",
+	}, []string{"method", "endpoint", "status"})
+
+	// JiraRequestDuration observes how long Jira takes to answer
+	JiraRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "jira", Name: "request_duration_seconds",
+		Help:    "Time for Jira to answer, by method and endpoint.",
+		Buckets: durationBuckets,
+	}, []string{"method", "endpoint"})
+
+	// JiraRequestErrors counts failed requests to Jira by method, endpoint
+	// and reason: network, client_error, server_error or rate_limited
+	JiraRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "jira", Name: "request_errors_total",
+		Help: "Requests to Jira that failed, by method, endpoint and reason.",
+	}, []string{"method", "endpoint", "reason"})
+
+	// JiraRateLimitEvents counts requests to Jira slowed or refused by rate limiting
+	JiraRateLimitEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "jira", Name: "rate_limit_events_total",
+		Help: "Requests to Jira throttled, held or rejected by rate limiting.",
+	}, []string{"event"})
+
+	// OutboxReplays counts replays of queued writes by operation and outcome:
+	// done, retry when it is tried again later, or failed
+	OutboxReplays = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "outbox", Name: "replays_total",
+		Help: "Replays of queued writes, by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	// ImportedRecords counts imported records by source and outcome
+	ImportedRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "import", Name: "records_total",
+		Help: "Records imported, by source (testcases or gotest) and outcome.",
+	}, []string{"source", "outcome"})
+
+	// ImportDuration observes how long imports take
+	ImportDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "import", Name: "duration_seconds",
+		Help:    "Time to import a spreadsheet or go test output, by source.",
+		Buckets: durationBuckets,
+	}, []string{"source"})
+
+	// CacheReads counts reads of the issue cache by issue type and result
+	CacheReads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "cache", Name: "reads_total",
+		Help: "Reads of the issue cache, by issue type and result: hit, miss or bypass.",
+	}, []string{"issue_type", "result"})
+)
+
+// registry holds the metrics of the service and of the Go runtime
+var registry = prometheus.NewRegistry()
+
+// outbox reports the depth of the outbox at each scrape
+var outbox = &outboxCollector{
+	desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "outbox", "entries"),
+		"Entries in the outbox, by status.", []string{"status"}, nil),
+}
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPRequestDuration, HTTPRequestsInFlight,
+		JiraRequests, JiraRequestDuration, JiraRequestErrors, JiraRateLimitEvents,
+		OutboxReplays, ImportedRecords, ImportDuration, CacheReads,
+		outbox,
+	)
+}
+
+// handler serves the metrics of registry
+var handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return handler
+}
+
+// Middleware records the count, duration and status of API requests by
+// route. Requests to no route are recorded under "unmatched" so scanners
+// cannot create a metric for each path they try.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		HTTPRequestsInFlight.Inc()
+		defer HTTPRequestsInFlight.Dec()
+		started := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := methodLabel(c.Request.Method)
+		status := strconv.Itoa(c.Writer.Status())
+		HTTPRequests.WithLabelValues(method, route, status).Inc()
+		HTTPRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(started).Seconds())
+	}
+}
+
+// methodLabel returns the method of a request for metrics. Methods other than
+// the standard ones are recorded as "other", so clients cannot create a metric
+// for each method they make up.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	default:
+		return "other"
+	}
+}
+
+// SetOutboxDepth sets the function reporting the number of outbox entries
+// by status when metrics are scraped
+func SetOutboxDepth(depth func() (map[string]int, error)) {
+	outbox.mu.Lock()
+	defer outbox.mu.Unlock()
+	outbox.depth = depth
+}
+
+// outboxCollector collects the outbox depth when metrics are scraped, so it
+// is never stale
+type outboxCollector struct {
+	desc  *prometheus.Desc
+	mu    sync.Mutex
+	depth func() (map[string]int, error)
+}
+
+// Describe implements prometheus.Collector
+func (o *outboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- o.desc
+}
+
+// Collect implements prometheus.Collector
+func (o *outboxCollector) Collect(ch chan<- prometheus.Metric) {
+	o.mu.Lock()
+	depth := o.depth
+	o.mu.Unlock()
+	if depth == nil {
+		return
+	}
+	counts, err := depth()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(o.desc, err)
+		return
+	}
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(o.desc, prometheus.GaugeValue, float64(n), status)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+
+	"jira-xray-integration/metrics"
+	"jira-xray-integration/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Prometheus metrics endpoint
+func serveMetrics(c *gin.Context) {
+	if !currentConfig().MetricsEnabled {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Metrics are disabled",
+			"details": "set METRICS_ENABLED=true to serve them",
+		})
+		return
+	}
+	metrics.Handler().ServeHTTP(c.Writer, c.Request)
+}
+
+// outboxDepth counts the outbox entries waiting for Jira and failed for good,
+// for the outbox metrics
+func outboxDepth() (map[string]int, error) {
+	depth := make(map[string]int)
+	for _, status := range []string{store.OutboxPending, store.OutboxFailed} {
+		entries, err := resultStore.ListOutbox(status)
+		if err != nil {
+			return nil, err
+		}
+		depth[status] = len(entries)
+	}
+	return depth, nil
+}
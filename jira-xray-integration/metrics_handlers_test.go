@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/store"
+)
+
+func TestMetrics(t *testing.T) {
+	env := newTestEnv(t)
+	currentConfig().MetricsEnabled = true
+
+	env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases?fresh=true", "")
+	env.do(http.MethodGet, "/no/such/route", "")
+	env.do("BREW", "/api/testcases", "")
+	if _, _, err := resultStore.EnqueueOutbox(store.OutboxEntry{IdempotencyKey: "k1", Operation: "createTestCase"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := env.do(http.MethodGet, "/metrics", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, want := range []string{
+		`xray_http_requests_total{method="GET",route="/api/testcases",status="200"}`,
+		`xray_http_request_duration_seconds_bucket{method="GET",route="/api/testcases",status="200",le="0.005"}`,
+		`xray_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`xray_jira_requests_total{endpoint="search",method="GET",status="200"}`,
+		`xray_jira_request_duration_seconds_count{endpoint="search",method="GET"}`,
+		`xray_cache_reads_total{issue_type="Test",result="bypass"}`,
+		`xray_outbox_entries{status="pending"} 1`,
+		`xray_outbox_entries{status="failed"} 0`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if strings.Contains(rec.Body.String(), "/no/such/route") {
+		t.Error("unmatched paths should not become label values")
+	}
+	if strings.Contains(rec.Body.String(), "BREW") || !strings.Contains(rec.Body.String(), `xray_http_requests_total{method="other"`) {
+		t.Error("unknown methods should be recorded as other")
+	}
+
+	currentConfig().MetricsEnabled = false
+	if rec := env.do(http.MethodGet, "/metrics", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d with metrics disabled, want 404", rec.Code)
+	}
+}
+
+func TestMetricsNeedAuthentication(t *testing.T) {
+	env := newTestEnv(t)
+	enableAuth(t, env)
+	currentConfig().MetricsEnabled = true
+
+	if rec := env.do(http.MethodGet, "/metrics", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d without credentials, want 401", rec.Code)
+	}
+	// Prometheus sends the API key as a bearer token
+	for _, header := range [][]string{{"Authorization", "Bearer " + readerKey}, {auth.APIKeyHeader, readerKey}} {
+		if rec := env.do(http.MethodGet, "/metrics", "", header...); rec.Code != http.StatusOK {
+			t.Errorf("got status %d with %s, want 200", rec.Code, header[0])
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Package outbox replays writes that were accepted while Jira was unavailable.
+// Entries are kept in the local store and replayed oldest first per Jira
+// tenant and project; a retriable failure holds back the later writes of its
+// project only, so they never overtake earlier ones while other projects go on.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"jira-xray-integration/metrics"
+	"jira-xray-integration/store"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Operations that can be queued
+const (
+	OpCreateTestCase      = "create_test_case"
+	OpCreateTestExecution = "create_test_execution"
+	OpRecordResults       = "record_results"
+)
+
+// tracerName names the tracer of replays
+const tracerName = "jira-xray-integration/outbox"
+
+// ErrAlreadyReplayed is returned by Retry for entries that were replayed successfully
+var ErrAlreadyReplayed = errors.New("outbox entry has already been replayed")
+
+// Handler replays one entry and returns the key of the issue it created or
+// updated. ctx carries the trace of the replay.
+type Handler func(ctx context.Context, entry *store.OutboxEntry) (string, error)
+
+// Options configures a Worker
+type Options struct {
+	// Interval between replay attempts. Zero disables the outbox: writes fail
+	// as before instead of being queued.
+	Interval time.Duration
+	// MaxBackoff caps the delay after repeated retriable failures
+	MaxBackoff time.Duration
+	// Retriable reports whether a failed replay should be tried again later
+	Retriable func(error) bool
+}
+
+// Worker queues writes and replays them in order
+type Worker struct {
+	outbox   store.Outbox
+	handlers map[string]Handler
+	opts     Options
+	trigger  chan struct{}
+	running  sync.Mutex // held while replaying
+}
+
+// NewWorker creates a worker that replays entries with handlers, keyed by operation
+func NewWorker(outbox store.Outbox, handlers map[string]Handler, opts Options) *Worker {
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 10 * time.Minute
+	}
+	if opts.Retriable == nil {
+		opts.Retriable = func(error) bool { return true }
+	}
+	return &Worker{
+		outbox:   outbox,
+		handlers: handlers,
+		opts:     opts,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Enabled reports whether writes should be queued when Jira is unavailable
+func (w *Worker) Enabled() bool {
+	return w.opts.Interval > 0
+}
+
+// Enqueue queues a write to a project of a Jira tenant, either empty for the
+// default one. An empty idempotencyKey gets a random one. If the key was used
+// before, the existing entry is returned and created is false.
+func (w *Worker) Enqueue(operation, idempotencyKey, tenant, project, target string, payload interface{}, cause error) (*store.OutboxEntry, bool, error) {
+	if _, ok := w.handlers[operation]; !ok {
+		return nil, false, fmt.Errorf("unknown outbox operation %q", operation)
+	}
+	if idempotencyKey == "" {
+		idempotencyKey = newIdempotencyKey()
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	entry := store.OutboxEntry{
+		IdempotencyKey: idempotencyKey,
+		Operation:      operation,
+		Tenant:         tenant,
+		Project:        project,
+		Target:         target,
+		Payload:        data,
+		NextAttemptAt:  time.Now().Add(w.opts.Interval),
+	}
+	if cause != nil {
+		entry.LastError = cause.Error()
+	}
+	saved, created, err := w.outbox.EnqueueOutbox(entry)
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		log.Printf("Queued %s as outbox entry %d: %s", operation, saved.ID, entry.LastError)
+	}
+	return saved, created, nil
+}
+
+// Retry makes a failed or waiting entry due now and wakes the worker
+func (w *Worker) Retry(id int64) (*store.OutboxEntry, error) {
+	entry, err := w.outbox.GetOutboxEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status == store.OutboxDone {
+		return nil, fmt.Errorf("outbox entry %d: %w", id, ErrAlreadyReplayed)
+	}
+
+	entry.Status = store.OutboxPending
+	entry.NextAttemptAt = time.Now()
+	if err := w.outbox.UpdateOutboxEntry(*entry); err != nil {
+		return nil, err
+	}
+	w.Trigger()
+	return entry, nil
+}
+
+// Trigger asks the background loop to replay soon. It never blocks.
+func (w *Worker) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run replays due entries every Interval, or sooner when triggered, until ctx
+// is cancelled. It returns at once if the outbox is disabled.
+func (w *Worker) Run(ctx context.Context) {
+	if !w.Enabled() {
+		log.Println("Outbox disabled")
+		return
+	}
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Replay(); err != nil {
+			log.Printf("Outbox replay failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.trigger:
+		}
+	}
+}
+
+// Replay sends pending entries to Jira oldest first and returns how many
+// succeeded. Entries are ordered per tenant and project: an entry that is not
+// yet due or fails with a retriable error holds back the later entries of its
+// project, while other projects are replayed. Entries that fail permanently are
+// marked failed and skipped.
+func (w *Worker) Replay() (int, error) {
+	if !w.running.TryLock() {
+		return 0, nil
+	}
+	defer w.running.Unlock()
+
+	pending, err := w.outbox.ListOutbox(store.OutboxPending)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	blocked := make(map[outboxGroup]bool)
+	for i := range pending {
+		entry := &pending[i]
+		group := outboxGroup{tenant: entry.Tenant, project: entry.Project}
+		if blocked[group] {
+			continue
+		}
+		if time.Now().Before(entry.NextAttemptAt) {
+			blocked[group] = true
+			continue
+		}
+
+		handler, ok := w.handlers[entry.Operation]
+		if !ok {
+			entry.Status = store.OutboxFailed
+			entry.LastError = fmt.Sprintf("unknown operation %q", entry.Operation)
+			metrics.OutboxReplays.WithLabelValues(entry.Operation, store.OutboxFailed).Inc()
+			if err := w.outbox.UpdateOutboxEntry(*entry); err != nil {
+				return replayed, err
+			}
+			continue
+		}
+
+		entry.Attempts++
+		resultKey, err := replay(handler, entry)
+		switch {
+		case err == nil:
+			entry.Status = store.OutboxDone
+			entry.ResultKey = resultKey
+			entry.LastError = ""
+			replayed++
+			metrics.OutboxReplays.WithLabelValues(entry.Operation, store.OutboxDone).Inc()
+			log.Printf("Replayed outbox entry %d (%s): %s", entry.ID, entry.Operation, resultKey)
+		case w.opts.Retriable(err):
+			entry.LastError = err.Error()
+			entry.NextAttemptAt = time.Now().Add(w.backoff(entry.Attempts))
+			metrics.OutboxReplays.WithLabelValues(entry.Operation, "retry").Inc()
+			log.Printf("Outbox entry %d failed, retrying at %s: %v", entry.ID, entry.NextAttemptAt.Format(time.RFC3339), err)
+		default:
+			entry.Status = store.OutboxFailed
+			entry.LastError = err.Error()
+			metrics.OutboxReplays.WithLabelValues(entry.Operation, store.OutboxFailed).Inc()
+			log.Printf("Outbox entry %d failed permanently: %v", entry.ID, err)
+		}
+
+		if err := w.outbox.UpdateOutboxEntry(*entry); err != nil {
+			return replayed, err
+		}
+		if entry.Status == store.OutboxPending {
+			// Keep order: later writes to the project wait until this one goes through
+			blocked[group] = true
+		}
+	}
+	return replayed, nil
+}
+
+// outboxGroup is the tenant and project whose entries are replayed in order
+type outboxGroup struct {
+	tenant  string
+	project string
+}
+
+// replay runs the handler of an entry in a span of its own
+func replay(handler Handler, entry *store.OutboxEntry) (string, error) {
+	ctx, span := otel.Tracer(tracerName).Start(context.Background(), "outbox replay "+entry.Operation,
+		trace.WithAttributes(
+			attribute.Int64("outbox.entry_id", entry.ID),
+			attribute.String("outbox.operation", entry.Operation),
+			attribute.Int("outbox.attempt", entry.Attempts),
+		))
+	defer span.End()
+	resultKey, err := handler(ctx, entry)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return resultKey, err
+}
+
+// backoff doubles the retry delay with each attempt, starting at Interval
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.opts.Interval
+	for i := 1; i < attempts && delay < w.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.opts.MaxBackoff {
+		delay = w.opts.MaxBackoff
+	}
+	return delay
+}
+
+// Label returns a Jira label derived from an idempotency key. Replays add it to
+// the issues they create and look for it first, so an issue created just before
+// a crash is not created twice.
+func Label(idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(idempotencyKey))
+	return "outbox-" + hex.EncodeToString(sum[:8])
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
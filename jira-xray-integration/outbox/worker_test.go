@@ -0,0 +1,207 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"jira-xray-integration/store"
+)
+
+var errUnavailable = errors.New("jira unavailable")
+
+// newTestWorker creates a worker on an in-memory store whose entries are due
+// at once. Handlers fail with the errors queued in failures, then succeed.
+func newTestWorker(failures map[string][]error) (*Worker, *store.MemoryStore, *[]string) {
+	s := store.NewMemoryStore()
+	var replayed []string
+	handler := func(ctx context.Context, entry *store.OutboxEntry) (string, error) {
+		if queued := failures[entry.IdempotencyKey]; len(queued) > 0 {
+			failures[entry.IdempotencyKey] = queued[1:]
+			return "", queued[0]
+		}
+		replayed = append(replayed, entry.IdempotencyKey)
+		return "TEST-" + entry.IdempotencyKey, nil
+	}
+	w := NewWorker(s, map[string]Handler{OpCreateTestCase: handler}, Options{
+		Interval:   time.Nanosecond,
+		MaxBackoff: time.Hour,
+		Retriable:  func(err error) bool { return errors.Is(err, errUnavailable) },
+	})
+	return w, s, &replayed
+}
+
+func mustEnqueue(t *testing.T, w *Worker, key string) *store.OutboxEntry {
+	t.Helper()
+	entry, created, err := w.Enqueue(OpCreateTestCase, key, "", "", "", map[string]string{"summary": key}, errUnavailable)
+	if err != nil || !created {
+		t.Fatalf("enqueue %s: created %v, %v", key, created, err)
+	}
+	return entry
+}
+
+func TestEnqueue(t *testing.T) {
+	w, _, _ := newTestWorker(nil)
+	entry := mustEnqueue(t, w, "1")
+	if entry.Status != store.OutboxPending || entry.LastError != errUnavailable.Error() || string(entry.Payload) != `{"summary":"1"}` {
+		t.Errorf("got entry %+v", entry)
+	}
+
+	again, created, err := w.Enqueue(OpCreateTestCase, "1", "", "", "", map[string]string{"summary": "changed"}, nil)
+	if err != nil || created || again.ID != entry.ID {
+		t.Errorf("enqueueing a used key again got entry %d, created %v, %v; want the existing entry", again.ID, created, err)
+	}
+
+	generated, _, err := w.Enqueue(OpCreateTestCase, "", "", "", "", nil, nil)
+	if err != nil || generated.IdempotencyKey == "" || generated.ID == entry.ID {
+		t.Errorf("got entry %+v, %v; want a new entry with a random key", generated, err)
+	}
+
+	if _, _, err := w.Enqueue("delete_everything", "2", "", "", "", nil, nil); err == nil {
+		t.Error("expected an error for an unknown operation")
+	}
+}
+
+func TestReplayKeepsOrder(t *testing.T) {
+	w, s, replayed := newTestWorker(map[string][]error{"1": {errUnavailable}})
+	first := mustEnqueue(t, w, "1")
+	mustEnqueue(t, w, "2")
+
+	// A retriable failure stops the replay so the second write waits
+	if n, err := w.Replay(); err != nil || n != 0 {
+		t.Fatalf("got %d replayed, %v", n, err)
+	}
+	if len(*replayed) != 0 {
+		t.Errorf("got %v replayed after the first write failed", *replayed)
+	}
+	entry, err := s.GetOutboxEntry(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Status != store.OutboxPending || entry.Attempts != 1 || !entry.NextAttemptAt.After(time.Now().Add(-time.Second)) {
+		t.Errorf("got entry %+v, want it pending after one attempt", entry)
+	}
+
+	if _, err := w.Retry(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.Replay(); err != nil || n != 2 {
+		t.Fatalf("got %d replayed, %v; want 2", n, err)
+	}
+	if strings.Join(*replayed, ",") != "1,2" {
+		t.Errorf("got replays %v, want oldest first", *replayed)
+	}
+	done, err := s.ListOutbox(store.OutboxDone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || done[0].ResultKey != "TEST-1" || done[0].LastError != "" || done[0].Attempts != 2 {
+		t.Errorf("got done entries %+v", done)
+	}
+
+	if _, err := w.Retry(first.ID); !errors.Is(err, ErrAlreadyReplayed) {
+		t.Errorf("got %v retrying a replayed entry, want ErrAlreadyReplayed", err)
+	}
+}
+
+func TestReplayKeepsOrderPerProject(t *testing.T) {
+	w, s, replayed := newTestWorker(map[string][]error{"test-1": {errUnavailable}})
+	enqueue := func(key, tenant, project string) {
+		t.Helper()
+		if _, _, err := w.Enqueue(OpCreateTestCase, key, tenant, project, "", nil, errUnavailable); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enqueue("test-1", "", "TEST")
+	enqueue("pay-1", "", "PAY")
+	enqueue("test-2", "", "TEST")
+	enqueue("dc-1", "dc", "TEST")
+	enqueue("later-1", "", "OPS")
+	later, err := s.ListOutbox(store.OutboxPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later[4].NextAttemptAt = time.Now().Add(time.Hour)
+	if err := s.UpdateOutboxEntry(later[4]); err != nil {
+		t.Fatal(err)
+	}
+	enqueue("later-2", "", "OPS")
+
+	// A failing project and one that is not due hold back only their own writes
+	if n, err := w.Replay(); err != nil || n != 2 {
+		t.Fatalf("got %d replayed, %v; want 2", n, err)
+	}
+	if strings.Join(*replayed, ",") != "pay-1,dc-1" {
+		t.Errorf("got replays %v", *replayed)
+	}
+}
+
+func TestReplayPermanentFailure(t *testing.T) {
+	w, s, replayed := newTestWorker(map[string][]error{"1": {errors.New("issue type does not exist")}})
+	first := mustEnqueue(t, w, "1")
+	mustEnqueue(t, w, "2")
+
+	// A permanent failure is set aside and later writes go on
+	if n, err := w.Replay(); err != nil || n != 1 {
+		t.Fatalf("got %d replayed, %v; want 1", n, err)
+	}
+	if strings.Join(*replayed, ",") != "2" {
+		t.Errorf("got replays %v", *replayed)
+	}
+	entry, err := s.GetOutboxEntry(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Status != store.OutboxFailed || entry.LastError != "issue type does not exist" {
+		t.Errorf("got entry %+v, want it failed", entry)
+	}
+
+	// Retrying a failed entry replays it again
+	if _, err := w.Retry(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.Replay(); err != nil || n != 1 {
+		t.Errorf("got %d replayed, %v; want the retried entry", n, err)
+	}
+}
+
+func TestReplayUnknownOperation(t *testing.T) {
+	w, s, _ := newTestWorker(nil)
+	entry, _, err := s.EnqueueOutbox(store.OutboxEntry{IdempotencyKey: "1", Operation: "removed_operation"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Replay(); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ = s.GetOutboxEntry(entry.ID); entry.Status != store.OutboxFailed {
+		t.Errorf("got status %s for an unknown operation, want failed", entry.Status)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	w := NewWorker(store.NewMemoryStore(), nil, Options{Interval: time.Second, MaxBackoff: 10 * time.Second})
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 50: 10 * time.Second} {
+		if got := w.backoff(attempts); got != want {
+			t.Errorf("after %d attempts got %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	if NewWorker(store.NewMemoryStore(), nil, Options{}).Enabled() {
+		t.Error("a worker without an interval should be disabled")
+	}
+}
+
+func TestLabel(t *testing.T) {
+	label := Label("ci-run-42")
+	if label != Label("ci-run-42") || label == Label("ci-run-43") {
+		t.Error("labels should be derived from the idempotency key alone")
+	}
+	if !strings.HasPrefix(label, "outbox-") || len(label) != len("outbox-")+16 {
+		t.Errorf("got label %q", label)
+	}
+}
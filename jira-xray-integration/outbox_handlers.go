@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/jira"
+	"jira-xray-integration/outbox"
+	"jira-xray-integration/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// outboxHandlers replay queued writes, keyed by operation
+func outboxHandlers() map[string]outbox.Handler {
+	return map[string]outbox.Handler{
+		outbox.OpCreateTestCase:      replayCreateTestCase,
+		outbox.OpCreateTestExecution: replayCreateTestExecution,
+		outbox.OpRecordResults:       replayRecordResults,
+	}
+}
+
+// queueWrite accepts a write into the outbox after Jira failed with a retriable
+// error and responds with 202. It returns false, leaving the response to the
+// caller, if the outbox is disabled or err is not retriable. Writes made with the
+// caller's Jira credentials are never queued, as replays run as the service account.
+func queueWrite(c *gin.Context, operation, target string, payload interface{}, cause error, what string) bool {
+	if !outboxWorker.Enabled() || !jira.IsRetriable(cause) || delegated(c) {
+		return false
+	}
+
+	tenantName, project := queuedWriteDestination(c)
+	entry, created, err := outboxWorker.Enqueue(operation, c.GetHeader("Idempotency-Key"), tenantName, project, target, payload, cause)
+	if err != nil {
+		log.Printf("Error queueing write: %v", err)
+		return false
+	}
+	if !created && !sameQueuedWrite(entry, operation, tenantName, project, target, payload) {
+		rejectReusedIdempotencyKey(c)
+		return true
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"outboxEntry": entry,
+		"message":     fmt.Sprintf("Jira is unavailable, %s queued for replay", what),
+	})
+	return true
+}
+
+// respondWithQueuedWrite answers a retried request whose Idempotency-Key is
+// already in the outbox with the existing entry, so the write is not repeated.
+// A key used for a different write, whether another operation, tenant,
+// project, target or payload, is rejected with 409.
+func respondWithQueuedWrite(c *gin.Context, operation, target string, payload interface{}) bool {
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey == "" {
+		return false
+	}
+	entry, err := resultStore.FindOutboxEntry(idempotencyKey)
+	if err != nil {
+		return false
+	}
+	tenantName, project := queuedWriteDestination(c)
+	if !sameQueuedWrite(entry, operation, tenantName, project, target, payload) {
+		rejectReusedIdempotencyKey(c)
+		return true
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"outboxEntry": entry,
+		"message":     "Request was already accepted into the outbox",
+	})
+	return true
+}
+
+// queuedWriteDestination returns the tenant and project a write of the
+// request is queued for, each empty for the default one
+func queuedWriteDestination(c *gin.Context) (string, string) {
+	t, project := requestTenant(c), requestProject(c)
+	if t.isDefaultProject(project) {
+		project = ""
+	}
+	tenantName := t.Name
+	if t == defaultTenant() {
+		tenantName = ""
+	}
+	return tenantName, project
+}
+
+// sameQueuedWrite reports whether entry queued the write described by the
+// other arguments
+func sameQueuedWrite(entry *store.OutboxEntry, operation, tenantName, project, target string, payload interface{}) bool {
+	return entry.Operation == operation && entry.Tenant == tenantName &&
+		strings.EqualFold(entry.Project, project) && entry.Target == target &&
+		samePayload(entry.Payload, payload)
+}
+
+// samePayload reports whether a queued payload holds the same JSON as payload
+func samePayload(queued json.RawMessage, payload interface{}) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+	var want, got interface{}
+	if json.Unmarshal(queued, &want) != nil || json.Unmarshal(data, &got) != nil {
+		return false
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// rejectReusedIdempotencyKey answers 409 for an Idempotency-Key that queued
+// another write. The entry is not shown, as it may belong to another project.
+func rejectReusedIdempotencyKey(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "Idempotency-Key already used",
+		"details": "the key was used to queue a different write, use a new key for this request",
+	})
+}
+
+// outboxDestination returns the tenant and project a queued write goes to.
+// Writes to a tenant that is no longer configured fail.
+func outboxDestination(entry *store.OutboxEntry) (*tenant, string, error) {
+	t := defaultTenant()
+	if entry.Tenant != "" {
+		var ok bool
+		if t, ok = tenantByName(entry.Tenant); !ok {
+			return nil, "", fmt.Errorf("tenant %s is no longer configured", entry.Tenant)
+		}
+	}
+	if entry.Project == "" {
+		return t, t.ProjectKey, nil
+	}
+	return t, entry.Project, nil
+}
+
+// outboxEntryProject returns the project a queued write goes to, the project
+// callers need permission on to see or act on the entry. It is "" for a write
+// to a tenant that is no longer configured, which only bindings on all
+// projects cover.
+func outboxEntryProject(entry *store.OutboxEntry) string {
+	if _, project, err := outboxDestination(entry); err == nil {
+		return project
+	}
+	return entry.Project
+}
+
+// loadOutboxEntry fetches the outbox entry of the :id parameter and checks
+// that the caller may perform permission on its project. It responds and
+// returns nil otherwise.
+func loadOutboxEntry(c *gin.Context, permission auth.Permission, message string) *store.OutboxEntry {
+	id, ok := outboxEntryID(c)
+	if !ok {
+		return nil
+	}
+	log.Printf("Handling %s /api/outbox/%d request", c.Request.Method, id)
+
+	entry, err := resultStore.GetOutboxEntry(id)
+	if err != nil {
+		respondOutboxError(c, message, err)
+		return nil
+	}
+	if !authorizeRequest(c, permission, outboxEntryProject(entry)) {
+		return nil
+	}
+	return entry
+}
+
+// replayBackend returns the backend a queued write is replayed with, tracing
+// its requests to Jira as a retry of the write. Writes are queued after they
+// failed once, so each replay counts as a retry.
+func replayBackend(ctx context.Context, t *tenant, project string, entry *store.OutboxEntry) jira.TestManagementBackend {
+	return withContext(jira.WithRetries(ctx, entry.Attempts), t.backendFor(project))
+}
+
+// findIssueByLabel returns the key of the issue carrying label in a project, or "" if there is none
+func findIssueByLabel(backend jira.TestManagementBackend, project, label string) (string, error) {
+	issues, err := backend.SearchIssues(fmt.Sprintf(`project = %s AND labels = "%s"`, project, label))
+	if err != nil || len(issues) == 0 {
+		return "", err
+	}
+	return issues[0].Key, nil
+}
+
+func replayCreateTestCase(ctx context.Context, entry *store.OutboxEntry) (string, error) {
+	var testCase jira.TestCase
+	if err := json.Unmarshal(entry.Payload, &testCase); err != nil {
+		return "", fmt.Errorf("invalid outbox payload: %w", err)
+	}
+
+	t, project, err := outboxDestination(entry)
+	if err != nil {
+		return "", err
+	}
+	backend := replayBackend(ctx, t, project, entry)
+	label := outbox.Label(entry.IdempotencyKey)
+	if key, err := findIssueByLabel(backend, project, label); err != nil || key != "" {
+		return key, err
+	}
+
+	testCase.Labels = append(testCase.Labels, label)
+	created, err := backend.CreateTestCase(&testCase)
+	if err != nil {
+		return "", err
+	}
+	t.syncEngine.Trigger()
+	return created.Key, nil
+}
+
+func replayCreateTestExecution(ctx context.Context, entry *store.OutboxEntry) (string, error) {
+	var testExecution jira.TestExecution
+	if err := json.Unmarshal(entry.Payload, &testExecution); err != nil {
+		return "", fmt.Errorf("invalid outbox payload: %w", err)
+	}
+
+	t, project, err := outboxDestination(entry)
+	if err != nil {
+		return "", err
+	}
+	backend := replayBackend(ctx, t, project, entry)
+	label := outbox.Label(entry.IdempotencyKey)
+	key, err := findIssueByLabel(backend, project, label)
+	if err != nil {
+		return "", err
+	}
+
+	created := &testExecution
+	if key != "" {
+		// Created by an earlier attempt; only the local results may be missing
+		if _, err := t.results.GetExecution(key); err == nil {
+			return key, nil
+		}
+		created.Key = key
+	} else {
+		testExecution.Labels = append(testExecution.Labels, label)
+		if created, err = backend.CreateTestExecution(&testExecution); err != nil {
+			return "", err
+		}
+		if testExecution.ExecutionStatus != "" {
+			created.ExecutionStatus = testExecution.ExecutionStatus
+		}
+	}
+
+	if err := saveTestExecution(t, created); err != nil {
+		return "", err
+	}
+	t.syncEngine.Trigger()
+	return created.Key, nil
+}
+
+func replayRecordResults(ctx context.Context, entry *store.OutboxEntry) (string, error) {
+	var req recordResultsRequest
+	if err := json.Unmarshal(entry.Payload, &req); err != nil {
+		return "", fmt.Errorf("invalid outbox payload: %w", err)
+	}
+
+	t, project, err := outboxDestination(entry)
+	if err != nil {
+		return "", err
+	}
+	backend := replayBackend(ctx, t, project, entry)
+	if err := ensureStoredExecution(t, t.projectConfig(project), backend, entry.Target); err != nil {
+		return "", err
+	}
+	// Recorded once per entry, should an earlier attempt have stopped after recording them
+	if _, err := t.results.AddResultsOnce(entry.Target, fmt.Sprintf("outbox-%d", entry.ID), req.TestResults); err != nil {
+		return "", err
+	}
+	return entry.Target, nil
+}
+
+// List outbox entries
+func getOutbox(c *gin.Context) {
+	status := c.Query("status")
+	log.Printf("Handling GET /api/outbox request (status=%s)", status)
+
+	switch status {
+	case "", store.OutboxPending, store.OutboxFailed, store.OutboxDone:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "status must be pending, failed or done",
+		})
+		return
+	}
+
+	entries, err := resultStore.ListOutbox(status)
+	if err != nil {
+		log.Printf("Error listing outbox: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list outbox",
+			"details": err.Error(),
+		})
+		return
+	}
+	totals, err := resultStore.CountOutbox()
+	if err != nil {
+		log.Printf("Error counting outbox: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list outbox",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Only entries of projects the caller may read are listed and counted
+	allowed, ok := outboxReadFilter(c)
+	if !ok {
+		return
+	}
+	filtered := []store.OutboxEntry{}
+	for _, entry := range entries {
+		if allowed(&entry) {
+			filtered = append(filtered, entry)
+		}
+	}
+	counts := map[string]int{store.OutboxPending: 0, store.OutboxFailed: 0, store.OutboxDone: 0}
+	for _, total := range totals {
+		if allowed(&store.OutboxEntry{Tenant: total.Tenant, Project: total.Project}) {
+			counts[total.Status] += total.Count
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": filtered,
+		"count":   len(filtered),
+		"counts":  counts,
+		"enabled": outboxWorker.Enabled(),
+		"message": "Outbox retrieved successfully",
+	})
+}
+
+// outboxReadFilter returns whether the caller may read the entries queued
+// for the tenant and project of an entry, as authorizeProject would decide,
+// reading the role bindings once. It responds and returns false if they
+// cannot be read.
+func outboxReadFilter(c *gin.Context) (func(entry *store.OutboxEntry) bool, bool) {
+	principal := currentPrincipal(c)
+	if principal == nil || !currentConfig().RBACEnabled {
+		// Without RBAC the scope was checked by requireScope
+		return func(*store.OutboxEntry) bool { return true }, true
+	}
+	bindings, err := roleBindings()
+	if err != nil {
+		log.Printf("Error reading role bindings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to authorize request",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return func(entry *store.OutboxEntry) bool {
+		return auth.Authorize(principal, bindings, auth.PermOutboxRead, outboxEntryProject(entry))
+	}, true
+}
+
+// Get an outbox entry
+func getOutboxEntry(c *gin.Context) {
+	entry := loadOutboxEntry(c, auth.PermOutboxRead, "Failed to fetch outbox entry")
+	if entry == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"outboxEntry": entry,
+		"message":     "Outbox entry retrieved successfully",
+	})
+}
+
+// Retry an outbox entry now
+func retryOutboxEntry(c *gin.Context) {
+	entry := loadOutboxEntry(c, auth.PermOutboxRetry, "Failed to retry outbox entry")
+	if entry == nil {
+		return
+	}
+
+	entry, err := outboxWorker.Retry(entry.ID)
+	if err != nil {
+		respondOutboxError(c, "Failed to retry outbox entry", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"outboxEntry": entry,
+		"message":     "Outbox entry scheduled for replay",
+	})
+}
+
+// Discard an outbox entry
+func deleteOutboxEntry(c *gin.Context) {
+	entry := loadOutboxEntry(c, auth.PermOutboxDelete, "Failed to delete outbox entry")
+	if entry == nil {
+		return
+	}
+
+	if err := resultStore.DeleteOutboxEntry(entry.ID); err != nil {
+		respondOutboxError(c, "Failed to delete outbox entry", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Outbox entry deleted successfully",
+	})
+}
+
+func outboxEntryID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Outbox entry id must be a number",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func respondOutboxError(c *gin.Context, message string, err error) {
+	log.Printf("%s: %v", message, err)
+	status := http.StatusInternalServerError
+	if errors.Is(err, store.ErrNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, outbox.ErrAlreadyReplayed) {
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
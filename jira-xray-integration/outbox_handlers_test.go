@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/outbox"
+	"jira-xray-integration/store"
+)
+
+// queueTestCase queues a test case creation by failing the request to Jira
+func queueTestCase(t *testing.T, env *testEnv) {
+	t.Helper()
+	env.jira.FailNext(1, http.StatusServiceUnavailable)
+	env.mustDo(t, http.StatusAccepted, http.MethodPost, "/api/testcases", `{"summary":"Queued test"}`, "Idempotency-Key", "queued-1")
+}
+
+// replayOutbox makes every pending entry due and replays the outbox once Jira
+// is reachable again
+func replayOutbox(t *testing.T, env *testEnv) {
+	t.Helper()
+	env.jira.ClearFaults()
+	pending, err := resultStore.ListOutbox(store.OutboxPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range pending {
+		if _, err := outboxWorker.Retry(entry.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := outboxWorker.Replay(); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+}
+
+func TestOutboxHandlers(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "list empty",
+			method: http.MethodGet, path: "/api/outbox",
+			status: http.StatusOK,
+			golden: "outbox_list_empty",
+		},
+		{
+			name:   "list",
+			setup:  queueTestCase,
+			method: http.MethodGet, path: "/api/outbox?status=pending",
+			status: http.StatusOK,
+			golden: "outbox_list",
+		},
+		{
+			name:   "list by another status",
+			setup:  queueTestCase,
+			method: http.MethodGet, path: "/api/outbox?status=done",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if count := decodeBody(t, rec)["count"]; count != 0.0 {
+					t.Errorf("got %v done entries, want 0", count)
+				}
+			},
+		},
+		{
+			name:   "list with an invalid status",
+			method: http.MethodGet, path: "/api/outbox?status=stuck",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "get",
+			setup:  queueTestCase,
+			method: http.MethodGet, path: "/api/outbox/1",
+			status: http.StatusOK,
+		},
+		{
+			name:   "get unknown",
+			method: http.MethodGet, path: "/api/outbox/7",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "get with an invalid id",
+			method: http.MethodGet, path: "/api/outbox/latest",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "retry",
+			setup:  queueTestCase,
+			method: http.MethodPost, path: "/api/outbox/1/retry",
+			status: http.StatusAccepted,
+		},
+		{
+			name: "retry a replayed entry",
+			setup: func(t *testing.T, env *testEnv) {
+				queueTestCase(t, env)
+				replayOutbox(t, env)
+			},
+			method: http.MethodPost, path: "/api/outbox/1/retry",
+			status: http.StatusConflict,
+		},
+		{
+			name:   "delete",
+			setup:  queueTestCase,
+			method: http.MethodDelete, path: "/api/outbox/1",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				env.mustDo(t, http.StatusNotFound, http.MethodGet, "/api/outbox/1", "")
+			},
+		},
+		{
+			name:   "delete unknown",
+			method: http.MethodDelete, path: "/api/outbox/1",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "retry a queued write",
+			setup:  queueTestCase,
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Queued test"}`,
+			headers: []string{"Idempotency-Key", "queued-1"},
+			status:  http.StatusAccepted,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if entries, _ := resultStore.ListOutbox(""); len(entries) != 1 {
+					t.Errorf("got %d outbox entries, want the one queued", len(entries))
+				}
+			},
+		},
+		{
+			name:   "reuse an idempotency key for another write",
+			setup:  queueTestCase,
+			method: http.MethodPost, path: "/api/testexecutions/TEST-10/results",
+			body:    `{"testResults":[{"testCaseKey":"TEST-3","status":"PASS"}]}`,
+			headers: []string{"Idempotency-Key", "queued-1"},
+			status:  http.StatusConflict,
+		},
+		{
+			name:   "reuse an idempotency key with another payload",
+			setup:  queueTestCase,
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Another test"}`,
+			headers: []string{"Idempotency-Key", "queued-1"},
+			status:  http.StatusConflict,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				entries, _ := resultStore.ListOutbox("")
+				if len(entries) != 1 || !strings.Contains(string(entries[0].Payload), "Queued test") {
+					t.Errorf("got outbox entries %+v, want the one queued unchanged", entries)
+				}
+			},
+		},
+		{
+			name: "reuse an idempotency key in another project",
+			setup: func(t *testing.T, env *testEnv) {
+				addPayProject(t, env)
+				queueTestCase(t, env)
+			},
+			method: http.MethodPost, path: "/api/projects/PAY/testcases",
+			body:    `{"summary":"Queued test"}`,
+			headers: []string{"Idempotency-Key", "queued-1"},
+			status:  http.StatusConflict,
+		},
+	})
+}
+
+func TestOutboxReplay(t *testing.T) {
+	env := newTestEnv(t)
+	queueTestCase(t, env)
+	env.jira.FailNext(1, http.StatusServiceUnavailable)
+	env.mustDo(t, http.StatusAccepted, http.MethodPost, "/api/testexecutions/TEST-10/results",
+		`{"testResults":[{"testCaseKey":"TEST-3","status":"PASS"}]}`, "Idempotency-Key", "queued-2")
+
+	replayOutbox(t, env)
+
+	entries, err := resultStore.ListOutbox("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Status != store.OutboxDone {
+			t.Errorf("entry %d is %s: %s", entry.ID, entry.Status, entry.LastError)
+		}
+	}
+
+	issue, err := env.jira.Backend.Issue("TEST-11")
+	if err != nil {
+		t.Fatalf("queued test case was not created: %v", err)
+	}
+	labels := issue.Fields.Labels
+	if len(labels) != 1 || labels[0] != outbox.Label("queued-1") {
+		t.Errorf("got labels %v, want the outbox label", labels)
+	}
+
+	body := env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases/TEST-3/history", "")
+	if body["count"] != 1.0 {
+		t.Errorf("replayed result is missing from the history: %v", body)
+	}
+
+	// A replay that stopped after recording its results does not record them again
+	recorded, err := resultStore.GetOutboxEntry(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replayRecordResults(context.Background(), recorded); err != nil {
+		t.Fatal(err)
+	}
+	if body := env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases/TEST-3/history", ""); body["count"] != 1.0 {
+		t.Errorf("got %v runs after replaying the results again, want 1", body["count"])
+	}
+
+	// Replaying again must not create the test case twice
+	if _, err := outboxWorker.Retry(1); err == nil {
+		t.Errorf("retrying a replayed entry succeeded")
+	}
+	if _, err := env.jira.Backend.Issue("TEST-12"); err == nil {
+		t.Errorf("test case was created twice")
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/jira"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys set by the project middleware
+const (
+	projectContextKey  = "project"  // key of the project a request works on
+	projectsContextKey = "projects" // projects a cross-project request may list
+)
+
+// newProjectBackends creates a backend for each project of a tenant. Jira
+// clients share the credentials and transport of the default project's
+// client; with the in-memory backend every other project starts empty, or
+// keeps the backend it had in previous, the tenant before a reload.
+func newProjectBackends(t TenantConfig, defaultBackend jira.TestManagementBackend, previous *tenant) map[string]jira.TestManagementBackend {
+	backends := map[string]jira.TestManagementBackend{strings.ToUpper(t.ProjectKey): defaultBackend}
+	for _, project := range t.Projects {
+		key := strings.ToUpper(project.Key)
+		if _, ok := backends[key]; ok {
+			continue
+		}
+		if client, ok := defaultBackend.(*jira.Client); ok {
+			backends[key] = client.ForProject(project)
+		} else if memory, ok := previous.memoryBackend(key); ok {
+			backends[key] = memory
+		} else {
+			backends[key] = jira.NewMemoryBackend(project.Key)
+		}
+	}
+	return backends
+}
+
+// backendFor returns the service account backend of a project of the tenant
+func (t *tenant) backendFor(project string) jira.TestManagementBackend {
+	if b, ok := t.projectBackends[strings.ToUpper(project)]; ok {
+		return b
+	}
+	return t.backend
+}
+
+// syncIssueTypes returns the issue types of a project kept in the issue cache
+func syncIssueTypes(project jira.ProjectConfig) []string {
+	types := project.IssueTypes.WithDefaults()
+	return []string{types.Test, types.TestExecution, types.TestPlan}
+}
+
+// isDefaultProject reports whether project is the tenant's default project,
+// the one served by routes without a project and held in its issue cache
+func (t *tenant) isDefaultProject(project string) bool {
+	return strings.EqualFold(project, t.ProjectKey)
+}
+
+// projectKeysOf returns the keys of the projects a tenant serves
+func projectKeysOf(t TenantConfig) []string {
+	var keys []string
+	for _, project := range t.ServedProjects() {
+		keys = append(keys, project.Key)
+	}
+	return keys
+}
+
+// scopeProject resolves the project of a request: the :projectKey of routes
+// under /api/projects, otherwise the default project of the tenant. Projects
+// the tenant does not serve are rejected, and so are issue keys of another
+// served project, so that role bindings on one project do not give access to
+// the issues of another.
+func scopeProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := requestTenant(c)
+		key := c.Param("projectKey")
+		scoped := key != ""
+		if !scoped {
+			key = t.ProjectKey
+		}
+		project, ok := t.Project(key)
+		if !ok {
+			rejectUnknownProject(c, t, key)
+			return
+		}
+		c.Set(projectContextKey, project.Key)
+
+		if issueKey := c.Param("key"); issueKey != "" {
+			if !jira.IsIssueKey(issueKey) {
+				rejectInvalidIssueKey(c, issueKey)
+				return
+			}
+			if !issueInProject(c, issueKey) {
+				rejectIssueOfOtherProject(c, issueKey)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// issueInProject reports whether an issue belongs to the project of the
+// request. Role bindings are per project, so no route takes the issues of
+// another project, even one no tenant serves.
+func issueInProject(c *gin.Context, issueKey string) bool {
+	prefix := issueKey
+	if i := strings.LastIndex(issueKey, "-"); i > 0 {
+		prefix = issueKey[:i]
+	}
+	return strings.EqualFold(prefix, requestProject(c))
+}
+
+// rejectIssueOfOtherProject answers 404 for an issue of another project
+func rejectIssueOfOtherProject(c *gin.Context, issueKey string) {
+	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+		"error":   "Issue not found in project",
+		"details": fmt.Sprintf("%s is not an issue of project %s", issueKey, requestProject(c)),
+	})
+}
+
+// rejectUnknownProject answers 404 for a project the tenant does not serve,
+// naming the tenant that does if there is one
+func rejectUnknownProject(c *gin.Context, t *tenant, key string) {
+	details := fmt.Sprintf("project %s is not served, add it to JIRA_PROJECTS", key)
+	if other, ok := projectTenant(key); ok && other != t {
+		details = fmt.Sprintf("project %s is served by tenant %s, select it with the %s header", key, other.Name, tenantHeader)
+	}
+	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+		"error":   "Unknown project",
+		"details": details,
+	})
+}
+
+// rejectInvalidIssueKey answers 400 for a value that is not an issue key,
+// before it can reach JQL
+func rejectInvalidIssueKey(c *gin.Context, key string) {
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+		"error":   "Invalid issue key",
+		"details": fmt.Sprintf("%q is not an issue key such as TEST-12", key),
+	})
+}
+
+// requestProject returns the Jira project a request operates on
+func requestProject(c *gin.Context) string {
+	if key := c.GetString(projectContextKey); key != "" {
+		return key
+	}
+	if key := c.Param("projectKey"); key != "" {
+		return key
+	}
+	return requestTenant(c).ProjectKey
+}
+
+// requestProjectConfig returns the settings of the project a request operates on
+func requestProjectConfig(c *gin.Context) jira.ProjectConfig {
+	return requestTenant(c).projectConfig(requestProject(c))
+}
+
+// projectConfig returns the settings of a project the tenant serves, or of
+// its default project for any other key
+func (t *tenant) projectConfig(key string) jira.ProjectConfig {
+	if project, ok := t.Project(key); ok {
+		return project
+	}
+	return t.DefaultProject()
+}
+
+// requireProjectsPermission authenticates the caller of a cross-project
+// request and decides which projects of the tenant it covers: those named in
+// the projects query parameter, or every project the tenant serves. Projects named explicitly must
+// all be permitted; otherwise the projects the caller lacks permission on
+// are left out.
+func requireProjectsPermission(permission auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := requestTenant(c)
+		var requested []string
+		explicit := c.Query("projects") != "" && c.Query("projects") != "*"
+		if explicit {
+			for _, key := range splitList(c.Query("projects")) {
+				project, ok := t.Project(key)
+				if !ok {
+					rejectUnknownProject(c, t, key)
+					return
+				}
+				requested = append(requested, project.Key)
+			}
+		} else {
+			requested = projectKeysOf(t.TenantConfig)
+		}
+
+		authenticator := currentAuthenticator()
+		if authenticator == nil {
+			c.Set(projectsContextKey, requested)
+			c.Next()
+			return
+		}
+		principal, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			rejectUnauthenticated(c, err)
+			return
+		}
+		c.Set(principalKey, principal)
+		if !principal.HasScope(permission.Scope()) {
+			denyPermission(c, principal, permission, "", "Insufficient scope",
+				fmt.Sprintf("%s needs a credential with the %s scope", permission, permission.Scope()))
+			return
+		}
+
+		var permitted []string
+		for _, project := range requested {
+			ok, err := authorizeProject(principal, permission, project)
+			if err != nil {
+				log.Printf("Error reading role bindings: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to authorize request",
+					"details": err.Error(),
+				})
+				return
+			}
+			if ok {
+				permitted = append(permitted, project)
+			} else if explicit {
+				denyPermission(c, principal, permission, project, "Insufficient permission",
+					fmt.Sprintf("%s:%s has no role granting %s on project %s", principal.Method, principal.Subject, permission, project))
+				return
+			}
+		}
+		c.Set(projectsContextKey, permitted)
+		c.Next()
+	}
+}
+
+// requestProjects returns the projects a cross-project request covers
+func requestProjects(c *gin.Context) []string {
+	projects, _ := c.Get(projectsContextKey)
+	keys, _ := projects.([]string)
+	return keys
+}
+
+// List the served projects the caller may see
+func getProjects(c *gin.Context) {
+	log.Println("Handling GET /api/projects request")
+
+	t := requestTenant(c)
+	projects := []jira.ProjectConfig{}
+	for _, key := range requestProjects(c) {
+		if project, ok := t.Project(key); ok {
+			projects = append(projects, project)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects":       projects,
+		"count":          len(projects),
+		"defaultProject": t.ProjectKey,
+		"tenant":         t.Name,
+		"message":        "Projects retrieved successfully",
+	})
+}
+
+// projectTestCase is a test case listed across projects
+type projectTestCase struct {
+	Project string `json:"project"`
+	jira.TestCase
+}
+
+// projectTestExecution is a test execution listed across projects
+type projectTestExecution struct {
+	Project string `json:"project"`
+	jira.TestExecution
+}
+
+// List test cases across the projects the caller may read
+func getProjectsTestCases(c *gin.Context) {
+	projects := requestProjects(c)
+	log.Printf("Handling GET /api/projects/testcases request (%s)", strings.Join(projects, ", "))
+
+	testCases := []projectTestCase{}
+	for _, project := range projects {
+		list, _, err := listTestCases(c, project)
+		if err != nil {
+			log.Printf("Error fetching test cases of %s: %v", project, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch test cases",
+				"details": fmt.Sprintf("project %s: %v", project, err),
+				"project": project,
+			})
+			return
+		}
+		for _, tc := range list {
+			testCases = append(testCases, projectTestCase{Project: project, TestCase: tc})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"testCases": testCases,
+		"count":     len(testCases),
+		"projects":  projects,
+		"message":   "Test cases retrieved successfully",
+	})
+}
+
+// List test executions across the projects the caller may read
+func getProjectsTestExecutions(c *gin.Context) {
+	projects := requestProjects(c)
+	log.Printf("Handling GET /api/projects/testexecutions request (%s)", strings.Join(projects, ", "))
+
+	testExecutions := []projectTestExecution{}
+	for _, project := range projects {
+		list, _, err := listTestExecutions(c, project)
+		if err != nil {
+			log.Printf("Error fetching test executions of %s: %v", project, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to fetch test executions",
+				"details": fmt.Sprintf("project %s: %v", project, err),
+				"project": project,
+			})
+			return
+		}
+		for _, te := range list {
+			testExecutions = append(testExecutions, projectTestExecution{Project: project, TestExecution: te})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"testExecutions": testExecutions,
+		"count":          len(testExecutions),
+		"projects":       projects,
+		"message":        "Test executions retrieved successfully",
+	})
+}
+
+// listTestCases returns the test cases of a project, from the issue cache
+// when it can serve them, and whether they came from the cache
+func listTestCases(c *gin.Context, project string) ([]jira.TestCase, bool, error) {
+	t := requestTenant(c)
+	settings := t.projectConfig(project)
+	if t.isDefaultProject(project) && serveFromCache(c, settings.IssueTypes.Test) {
+		testCases, err := cachedTestCases(t, settings)
+		return testCases, true, err
+	}
+	testCases, err := jiraForProject(c, project).ListTestCases()
+	return testCases, false, err
+}
+
+// listTestExecutions returns the test executions of a project, from the issue
+// cache when it can serve them, and whether they came from the cache
+func listTestExecutions(c *gin.Context, project string) ([]jira.TestExecution, bool, error) {
+	t := requestTenant(c)
+	settings := t.projectConfig(project)
+	if t.isDefaultProject(project) && serveFromCache(c, settings.IssueTypes.TestExecution) {
+		testExecutions, err := cachedTestExecutions(t, settings)
+		return testExecutions, true, err
+	}
+	testExecutions, err := jiraForProject(c, project).ListTestExecutions()
+	return testExecutions, false, err
+}
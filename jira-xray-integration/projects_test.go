@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/jira"
+)
+
+// addPayProject serves a second project, PAY, whose tests are "QA Test"
+// issues with their test type in a custom field
+func addPayProject(t *testing.T, env *testEnv) {
+	t.Helper()
+	env.jira.Backend.AddProject("PAY")
+	currentConfig().Projects = []jira.ProjectConfig{
+		{Key: "TEST", IssueTypes: jira.DefaultIssueTypes},
+		{
+			Key:        "PAY",
+			IssueTypes: jira.IssueTypes{Test: "QA Test", TestExecution: "QA Run"},
+			Fields:     jira.FieldMapping{jira.FieldTestType: "customfield_10100"},
+		},
+	}
+	env.reload(t)
+}
+
+// createPayTestCase creates PAY-1 through the project routes
+func createPayTestCase(t *testing.T, env *testEnv) {
+	t.Helper()
+	addPayProject(t, env)
+	env.mustDo(t, http.StatusCreated, http.MethodPost, "/api/projects/PAY/testcases",
+		`{"summary":"Pay by card","testType":"Automated"}`)
+}
+
+func TestProjects(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "list projects",
+			setup:  addPayProject,
+			method: http.MethodGet, path: "/api/projects",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := decodeBody(t, rec)
+				if body["count"] != 2.0 || body["defaultProject"] != "TEST" {
+					t.Errorf("got %v", body)
+				}
+			},
+		},
+		{
+			name:   "create test case in project",
+			setup:  addPayProject,
+			method: http.MethodPost, path: "/api/projects/pay/testcases",
+			body:   `{"summary":"Pay by card","testType":"Automated"}`,
+			status: http.StatusCreated,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				issue, err := env.jira.Backend.Issue("PAY-1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if issue.Fields.IssueType.Name != "QA Test" {
+					t.Errorf("created issue type %q, want QA Test", issue.Fields.IssueType.Name)
+				}
+				if issue.Fields.Custom["customfield_10100"] != "Automated" {
+					t.Errorf("test type was not written to its field: %v", issue.Fields.Custom)
+				}
+			},
+		},
+		{
+			name:   "list test cases of project",
+			setup:  createPayTestCase,
+			method: http.MethodGet, path: "/api/projects/PAY/testcases",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				var body struct {
+					TestCases []jira.TestCase `json:"testCases"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if len(body.TestCases) != 1 || body.TestCases[0].Key != "PAY-1" || body.TestCases[0].TestType != "Automated" {
+					t.Errorf("got %+v", body.TestCases)
+				}
+			},
+		},
+		{
+			name:   "default routes stay on the default project",
+			setup:  createPayTestCase,
+			method: http.MethodGet, path: "/api/testcases",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if body := decodeBody(t, rec); body["count"] != 3.0 {
+					t.Errorf("got %v test cases, want the 3 of TEST", body["count"])
+				}
+			},
+		},
+		{
+			name:   "unknown project",
+			setup:  addPayProject,
+			method: http.MethodGet, path: "/api/projects/OPS/testcases",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "issue of another project",
+			setup:  createPayTestCase,
+			method: http.MethodGet, path: "/api/projects/PAY/testcases/TEST-1",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "issue of another project on default route",
+			setup:  createPayTestCase,
+			method: http.MethodGet, path: "/api/testcases/PAY-1",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "get test case of project",
+			setup:  createPayTestCase,
+			method: http.MethodGet, path: "/api/projects/PAY/testcases/PAY-1",
+			status: http.StatusOK,
+		},
+		{
+			name:   "list test cases across projects",
+			setup:  createPayTestCase,
+			method: http.MethodGet, path: "/api/projects/testcases",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				var body struct {
+					TestCases []projectTestCase `json:"testCases"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				perProject := map[string]int{}
+				for _, tc := range body.TestCases {
+					perProject[tc.Project]++
+				}
+				if perProject["TEST"] != 3 || perProject["PAY"] != 1 {
+					t.Errorf("got test cases per project %v", perProject)
+				}
+			},
+		},
+		{
+			name:   "list test cases of named projects",
+			setup:  createPayTestCase,
+			method: http.MethodGet, path: "/api/projects/testcases?projects=pay",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if body := decodeBody(t, rec); body["count"] != 1.0 {
+					t.Errorf("got %v", body)
+				}
+			},
+		},
+		{
+			name:   "list across unknown project",
+			setup:  addPayProject,
+			method: http.MethodGet, path: "/api/projects/testexecutions?projects=TEST,OPS",
+			status: http.StatusNotFound,
+		},
+	})
+}
+
+// queueTestAndPayTestCases queues a test case of TEST, outbox entry 1, and
+// one of PAY, outbox entry 2, while Jira is unavailable
+func queueTestAndPayTestCases(t *testing.T, env *testEnv) {
+	t.Helper()
+	addPayProject(t, env)
+	queueTestCase(t, env)
+	env.jira.FailNext(1, http.StatusServiceUnavailable)
+	env.mustDo(t, http.StatusAccepted, http.MethodPost, "/api/projects/PAY/testcases",
+		`{"summary":"Queued pay test","testType":"Automated"}`, "Idempotency-Key", "queued-pay")
+}
+
+// withQueuedWrites queues writes to TEST and PAY, then binds role on project
+func withQueuedWrites(role auth.Role, project string) func(t *testing.T, env *testEnv) {
+	return func(t *testing.T, env *testEnv) {
+		queueTestAndPayTestCases(t, env)
+		grant(role, project)(t, env)
+	}
+}
+
+func TestProjectsRoleBasedAccess(t *testing.T) {
+	grantPay := func(t *testing.T, env *testEnv) {
+		createPayTestCase(t, env)
+		grant(auth.RoleViewer, "PAY")(t, env)
+	}
+
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "viewer of one project",
+			setup:  grantPay,
+			method: http.MethodGet, path: "/api/projects/PAY/testcases",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusOK,
+		},
+		{
+			name:   "viewer cannot read other project",
+			setup:  grantPay,
+			method: http.MethodGet, path: "/api/testcases",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:   "cross-project listing leaves out other projects",
+			setup:  grantPay,
+			method: http.MethodGet, path: "/api/projects/testcases",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := decodeBody(t, rec)
+				if body["count"] != 1.0 {
+					t.Errorf("got %v", body)
+				}
+				if projects, _ := body["projects"].([]interface{}); len(projects) != 1 || projects[0] != "PAY" {
+					t.Errorf("got projects %v, want [PAY]", body["projects"])
+				}
+			},
+		},
+		{
+			name:   "cross-project listing of unpermitted project",
+			setup:  grantPay,
+			method: http.MethodGet, path: "/api/projects/testcases?projects=PAY,TEST",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name: "JQL is kept to the permitted project",
+			setup: func(t *testing.T, env *testEnv) {
+				createPayTestCase(t, env)
+				grant(auth.RoleViewer, "TEST")(t, env)
+			},
+			method: http.MethodGet, path: "/api/traceability?jql=key+%3D+PAY-1",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if body := decodeBody(t, rec); body["count"] != 0.0 {
+					t.Errorf("got requirements of another project: %v", body)
+				}
+			},
+		},
+		{
+			name:   "outbox lists entries of permitted projects",
+			setup:  withQueuedWrites(auth.RoleViewer, "TEST"),
+			method: http.MethodGet, path: "/api/outbox",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := decodeBody(t, rec)
+				entries, _ := body["entries"].([]interface{})
+				if len(entries) != 1 || entries[0].(map[string]interface{})["idempotencyKey"] != "queued-1" {
+					t.Errorf("got entries %v, want only the one of TEST", entries)
+				}
+				if counts := body["counts"].(map[string]interface{}); counts["pending"] != 1.0 {
+					t.Errorf("got counts %v", counts)
+				}
+			},
+		},
+		{
+			name:   "outbox entry of another project",
+			setup:  withQueuedWrites(auth.RoleViewer, "TEST"),
+			method: http.MethodGet, path: "/api/outbox/2",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:   "lead cannot retry entries of another project",
+			setup:  withQueuedWrites(auth.RoleLead, "TEST"),
+			method: http.MethodPost, path: "/api/outbox/2/retry",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:   "lead retries entries of their project",
+			setup:  withQueuedWrites(auth.RoleLead, "PAY"),
+			method: http.MethodPost, path: "/api/outbox/2/retry",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusAccepted,
+		},
+		{
+			name:   "sync needs permission on the synced project",
+			setup:  grant(auth.RoleLead, "PAY"),
+			method: http.MethodPost, path: "/api/sync",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name: "evidence for a result of another project",
+			setup: func(t *testing.T, env *testEnv) {
+				recordPassAndFail(t, env)
+				grantPay(t, env)
+				grant(auth.RoleTester, "PAY")(t, env)
+			},
+			method: http.MethodPost, path: "/api/projects/PAY/results/1/evidence",
+			body:    `{"filename":"screenshot.png","url":"https://files.example.com/screenshot.png"}`,
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusNotFound,
+		},
+		{
+			name: "issue of a project no tenant serves",
+			setup: func(t *testing.T, env *testEnv) {
+				env.jira.Backend.AddProject("OTHER")
+				if _, err := env.jira.Backend.CreateIssue(jira.IssueFields{Summary: "Other test", IssueType: jira.IssueType{Name: jira.IssueTypeTest}, Project: jira.Project{Key: "OTHER"}}); err != nil {
+					t.Fatal(err)
+				}
+				grant(auth.RoleViewer, "TEST")(t, env)
+			},
+			method: http.MethodGet, path: "/api/testcases/OTHER-1",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusNotFound,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !strings.Contains(rec.Body.String(), "OTHER-1 is not an issue of project TEST") {
+					t.Errorf("got body %s", rec.Body.String())
+				}
+			},
+		},
+		{
+			name: "evidence for a result of a project no tenant serves",
+			setup: func(t *testing.T, env *testEnv) {
+				if err := resultStore.SaveExecution(&jira.TestExecution{Key: "OTHER-2", TestCases: []string{"OTHER-1"}}); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := resultStore.AddResults("OTHER-2", []jira.TestResult{{TestCaseKey: "OTHER-1", Status: jira.StatusPass}}); err != nil {
+					t.Fatal(err)
+				}
+				grant(auth.RoleTester, "TEST")(t, env)
+			},
+			method: http.MethodPost, path: "/api/results/1/evidence",
+			body:    `{"filename":"screenshot.png","url":"https://files.example.com/screenshot.png"}`,
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusNotFound,
+		},
+		{
+			name:   "projects lists permitted projects",
+			setup:  grantPay,
+			method: http.MethodGet, path: "/api/projects",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if body := decodeBody(t, rec); body["count"] != 1.0 {
+					t.Errorf("got %v", body)
+				}
+			},
+		},
+	})
+}
+
+func TestLoadProjects(t *testing.T) {
+	t.Setenv("JIRA_PROJECTS", "pay, OPS,TEST")
+	t.Setenv("JIRA_PROJECT_TEST_TEST_ISSUE_TYPE", "Xray Test")
+	t.Setenv("JIRA_PROJECT_PAY_EXECUTION_ISSUE_TYPE", "QA Run")
+	t.Setenv("JIRA_PROJECT_PAY_FIELDS", "testType=customfield_10100")
+
+	c := TenantConfig{ProjectKey: "TEST"}
+	var err error
+	if c.Projects, err = (settings{}).loadProjects(c.ProjectKey, "JIRA_"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(projectKeysOf(c), ","); got != "TEST,PAY,OPS" {
+		t.Fatalf("got projects %s, want TEST,PAY,OPS", got)
+	}
+	if c.DefaultProject().IssueTypes.Test != "Xray Test" {
+		t.Errorf("got default project %+v", c.DefaultProject())
+	}
+	pay, ok := c.Project("pay")
+	if !ok || pay.IssueTypes.TestExecution != "QA Run" || pay.Fields[jira.FieldTestType] != "customfield_10100" {
+		t.Errorf("got PAY project %+v", pay)
+	}
+
+	t.Setenv("JIRA_PROJECTS", "pay-ments")
+	if _, err := (settings{}).loadProjects(c.ProjectKey, "JIRA_"); err == nil {
+		t.Error("expected an error for an invalid project key")
+	}
+}
+
+func TestCachedReadsUseProjectSettings(t *testing.T) {
+	env := newTestEnv(t)
+	addPayProject(t, env)
+	env.setSyncInterval(t, time.Hour)
+	tenant := defaultTenant()
+
+	pay := tenant.projectConfig("pay")
+	if pay.Key != "PAY" || pay.IssueTypes.TestExecution != "QA Run" {
+		t.Fatalf("got settings %+v for PAY", pay)
+	}
+	if got := tenant.projectConfig("OPS"); got.Key != "TEST" {
+		t.Errorf("got settings %+v for a project not served, want the default project", got)
+	}
+
+	// The issue cache only holds the default project, whatever lands in it
+	if _, err := tenant.syncEngine.Sync(false); err != nil {
+		t.Fatal(err)
+	}
+	issues := []jira.JiraIssue{{Key: "TEST-9"}, {Key: "OPS-9"}}
+	issues[0].Fields.IssueType.Name = "Test Execution"
+	issues[1].Fields.IssueType.Name = "Test Execution"
+	if err := tenant.cache.PutIssues(issues); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cachedTestExecution(tenant, tenant.DefaultProject(), "TEST-9", false); err != nil || got == nil || got.Key != "TEST-9" {
+		t.Errorf("got %+v, %v from the cache for TEST", got, err)
+	}
+	if got, err := cachedTestExecution(tenant, tenant.DefaultProject(), "TEST-1", false); !jira.IsNotFound(err) {
+		t.Errorf("got %+v, %v from the cache for a test, want it not found", got, err)
+	}
+	// A project sharing the issue types of the default project is read from Jira
+	ops := jira.ProjectConfig{Key: "OPS", IssueTypes: jira.DefaultIssueTypes}
+	if got, err := cachedTestExecution(tenant, ops, "OPS-9", false); err != nil || got != nil {
+		t.Errorf("got %+v, %v from the cache for OPS", got, err)
+	}
+}
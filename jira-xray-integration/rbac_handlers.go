@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roleBindingRequest is the body of POST /api/admin/roles/bindings
+type roleBindingRequest struct {
+	Role    string `json:"role" binding:"required"`
+	Project string `json:"project" binding:"required"`
+	Subject string `json:"subject"`
+	Claim   string `json:"claim"`
+	Value   string `json:"value"`
+}
+
+// roleBindings returns the stored role bindings for authorization
+func roleBindings() ([]auth.Binding, error) {
+	stored, err := resultStore.ListRoleBindings()
+	if err != nil {
+		return nil, err
+	}
+	bindings := make([]auth.Binding, 0, len(stored))
+	for _, b := range stored {
+		bindings = append(bindings, toAuthBinding(b))
+	}
+	return bindings, nil
+}
+
+func toAuthBinding(b store.RoleBinding) auth.Binding {
+	return auth.Binding{
+		Role:    auth.Role(b.Role),
+		Project: b.Project,
+		Subject: b.Subject,
+		Claim:   b.Claim,
+		Value:   b.Value,
+	}
+}
+
+// List roles and role bindings
+func getRoles(c *gin.Context) {
+	log.Println("Handling GET /api/admin/roles request")
+
+	bindings, err := resultStore.ListRoleBindings()
+	if err != nil {
+		log.Printf("Error listing role bindings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list role bindings",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	roles := make([]gin.H, 0, len(auth.AllRoles))
+	for _, role := range auth.AllRoles {
+		roles = append(roles, gin.H{
+			"name":        role,
+			"permissions": role.Permissions(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"roles":       roles,
+		"bindings":    bindings,
+		"rbacEnabled": currentConfig().RBACEnabled,
+		"message":     "Roles retrieved successfully",
+	})
+}
+
+// Grant a role
+func createRoleBinding(c *gin.Context) {
+	log.Println("Handling POST /api/admin/roles/bindings request")
+
+	var req roleBindingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	binding := store.RoleBinding{
+		Role:    req.Role,
+		Project: strings.ToUpper(strings.TrimSpace(req.Project)),
+		Subject: strings.TrimSpace(req.Subject),
+		Claim:   strings.TrimSpace(req.Claim),
+		Value:   req.Value,
+	}
+	if err := toAuthBinding(binding).Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid role binding",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	created, err := resultStore.CreateRoleBinding(binding)
+	if err != nil {
+		log.Printf("Error creating role binding: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create role binding",
+			"details": err.Error(),
+		})
+		return
+	}
+	if principal := currentPrincipal(c); principal != nil {
+		log.Printf("Role %s on %s granted to %s%s by %s:%s", created.Role, created.Project,
+			created.Subject, claimMatcher(created), principal.Method, principal.Subject)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"roleBinding": created,
+		"message":     "Role binding created successfully",
+	})
+}
+
+// Revoke a role
+func deleteRoleBinding(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Role binding id must be a number",
+		})
+		return
+	}
+	log.Printf("Handling DELETE /api/admin/roles/bindings/%d request", id)
+
+	if err := resultStore.DeleteRoleBinding(id); err != nil {
+		log.Printf("Error deleting role binding: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to delete role binding",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role binding deleted successfully",
+	})
+}
+
+func claimMatcher(b *store.RoleBinding) string {
+	if b.Claim == "" {
+		return ""
+	}
+	return b.Claim + "=" + b.Value
+}
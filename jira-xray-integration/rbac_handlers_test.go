@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/store"
+)
+
+// enableRBAC requires API keys and role bindings. Besides the static keys of
+// enableAuth, a write and import key "team" gets a role in each test.
+func enableRBAC(t *testing.T, env *testEnv) {
+	t.Helper()
+	enableAuth(t, env)
+	currentConfig().APIKeys = append(currentConfig().APIKeys, auth.Key{
+		Name: "team", Hash: auth.HashKey(teamKey), Scopes: []auth.Scope{auth.ScopeWrite, auth.ScopeImport},
+	})
+	currentConfig().RBACEnabled = true
+	env.reload(t)
+}
+
+const teamKey = "xik_team"
+
+// grant binds a role on a project to the team key
+func grant(role auth.Role, project string) func(t *testing.T, env *testEnv) {
+	return func(t *testing.T, env *testEnv) {
+		enableRBAC(t, env)
+		if _, err := resultStore.CreateRoleBinding(store.RoleBinding{Role: string(role), Project: project, Subject: "apikey:team"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	expectDenied := func(permission, project string) func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+		return func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+			body := decodeBody(t, rec)
+			if body["error"] != "Insufficient permission" || body["permission"] != permission || body["project"] != project {
+				t.Errorf("got %v", body)
+			}
+		}
+	}
+
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "no role",
+			setup:  enableRBAC,
+			method: http.MethodGet, path: "/api/testcases",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+			check:   expectDenied("testcases:read", "TEST"),
+		},
+		{
+			name:   "viewer reads",
+			setup:  grant(auth.RoleViewer, "TEST"),
+			method: http.MethodGet, path: "/api/testcases",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusOK,
+		},
+		{
+			name:   "viewer cannot record results",
+			setup:  grant(auth.RoleViewer, "TEST"),
+			method: http.MethodPost, path: "/api/testexecutions",
+			body:    `{"summary":"Nightly","testCases":["TEST-1"]}`,
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+			check:   expectDenied("executions:write", "TEST"),
+		},
+		{
+			name:   "tester creates executions",
+			setup:  grant(auth.RoleTester, "TEST"),
+			method: http.MethodPost, path: "/api/testexecutions",
+			body:    `{"summary":"Nightly","testCases":["TEST-1"]}`,
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusCreated,
+		},
+		{
+			name:   "tester cannot write test cases",
+			setup:  grant(auth.RoleTester, "TEST"),
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Login works"}`,
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+			check:   expectDenied("testcases:write", "TEST"),
+		},
+		{
+			name:   "lead writes test cases",
+			setup:  grant(auth.RoleLead, "TEST"),
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Login works"}`,
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusCreated,
+		},
+		{
+			name:   "role on another project",
+			setup:  grant(auth.RoleLead, "PAY"),
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Login works"}`,
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+			check:   expectDenied("testcases:write", "TEST"),
+		},
+		{
+			name:   "role on every project",
+			setup:  grant(auth.RoleLead, auth.AllProjects),
+			method: http.MethodPost, path: "/api/testcases",
+			body:    `{"summary":"Login works"}`,
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusCreated,
+		},
+		{
+			name:   "scope still caps the role",
+			setup:  grant(auth.RoleAdmin, auth.AllProjects),
+			method: http.MethodDelete, path: "/api/outbox/1",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := decodeBody(t, rec)
+				if body["error"] != "Insufficient scope" || body["permission"] != "outbox:delete" {
+					t.Errorf("got %v", body)
+				}
+			},
+		},
+		{
+			name:   "project admin cannot manage roles",
+			setup:  grant(auth.RoleAdmin, "TEST"),
+			method: http.MethodGet, path: "/api/admin/roles",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:   "caller lists its roles",
+			setup:  grant(auth.RoleTester, "TEST"),
+			method: http.MethodGet, path: "/api/auth/me",
+			headers: []string{auth.APIKeyHeader, teamKey},
+			status:  http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				roles, _ := decodeBody(t, rec)["roles"].([]interface{})
+				if len(roles) != 1 || roles[0].(map[string]interface{})["role"] != "tester" {
+					t.Errorf("got roles %v", roles)
+				}
+			},
+		},
+		{
+			name:   "invalid binding",
+			setup:  enableRBAC,
+			method: http.MethodPost, path: "/api/admin/roles/bindings",
+			body:    `{"role":"owner","project":"TEST","subject":"apikey:team"}`,
+			headers: []string{auth.APIKeyHeader, adminKey},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:   "delete unknown binding",
+			setup:  enableRBAC,
+			method: http.MethodDelete, path: "/api/admin/roles/bindings/7",
+			headers: []string{auth.APIKeyHeader, adminKey},
+			status:  http.StatusNotFound,
+		},
+	})
+}
+
+func TestRoleBindingLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+	enableRBAC(t, env)
+
+	env.mustDo(t, http.StatusForbidden, http.MethodGet, "/api/testexecutions", "", auth.APIKeyHeader, teamKey)
+
+	created := env.mustDo(t, http.StatusCreated, http.MethodPost, "/api/admin/roles/bindings",
+		`{"role":"viewer","project":"test","subject":"apikey:team"}`, auth.APIKeyHeader, adminKey)
+	binding, _ := created["roleBinding"].(map[string]interface{})
+	if binding["project"] != "TEST" {
+		t.Errorf("project key was not normalized: %v", binding)
+	}
+	env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testexecutions", "", auth.APIKeyHeader, teamKey)
+
+	roles := env.mustDo(t, http.StatusOK, http.MethodGet, "/api/admin/roles", "", auth.APIKeyHeader, adminKey)
+	if list, _ := roles["roles"].([]interface{}); len(list) != len(auth.AllRoles) {
+		t.Errorf("got roles %v", list)
+	}
+	if list, _ := roles["bindings"].([]interface{}); len(list) != 1 {
+		t.Errorf("got bindings %v", list)
+	}
+
+	env.mustDo(t, http.StatusOK, http.MethodDelete, fmt.Sprintf("/api/admin/roles/bindings/%v", binding["id"]), "", auth.APIKeyHeader, adminKey)
+	env.mustDo(t, http.StatusForbidden, http.MethodGet, "/api/testexecutions", "", auth.APIKeyHeader, teamKey)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"jira-xray-integration/auth"
+)
+
+// serverState is the configuration in effect and what was built from it:
+// the tenants with their Jira clients and the authenticator. A reload builds
+// a new state and swaps it in whole, so requests that are already running
+// finish with the tenant, and so the Jira client, they started with.
+type serverState struct {
+	config        *Config
+	tenants       []*tenant
+	authenticator auth.Authenticator // nil when authentication is disabled
+}
+
+// state holds the current serverState
+var state atomic.Pointer[serverState]
+
+// currentConfig returns the configuration in effect
+func currentConfig() *Config {
+	return state.Load().config
+}
+
+// currentTenants returns every Jira connection, the default one first
+func currentTenants() []*tenant {
+	return state.Load().tenants
+}
+
+// currentAuthenticator returns the authenticator of API callers, or nil if
+// authentication is disabled
+func currentAuthenticator() auth.Authenticator {
+	return state.Load().authenticator
+}
+
+// newServerState sets up authentication and connects to every Jira of c,
+// checking that Jira has the issue types, link types and statuses configured.
+// previous is the state being replaced on reload, nil at startup.
+func newServerState(c *Config, previous *serverState) (*serverState, error) {
+	authenticator, err := newAuthenticator(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up authentication: %w", err)
+	}
+	var previousTenants []*tenant
+	if previous != nil {
+		previousTenants = previous.tenants
+	}
+	tenants, err := newTenants(c, resultStore, previousTenants)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up Jira tenants: %w", err)
+	}
+	if err := verifyTenants(tenants, c.JiraVerify); err != nil {
+		return nil, fmt.Errorf("jira does not match the configuration: %w", err)
+	}
+	return &serverState{config: c, tenants: tenants, authenticator: authenticator}, nil
+}
+
+// reloader replaces the server state when the configuration changes. Reloads
+// run one at a time, from SIGHUP or from a change to a watched file.
+type reloader struct {
+	configPath string // the -config flag; CONFIG_FILE is read again on reload
+	mu         sync.Mutex
+	stopSync   func()
+	modTimes   map[string]time.Time
+}
+
+// newReloader starts the sync engines of the current tenants and remembers
+// the files the configuration was read from
+func newReloader(configPath string) *reloader {
+	r := &reloader{configPath: configPath}
+	r.stopSync = startSync(currentTenants())
+	r.modTimes = fileModTimes(watchedFiles(currentConfig()))
+	return r
+}
+
+// StopSync stops the sync engines of the current tenants, waiting for a
+// sync in progress to finish
+func (r *reloader) StopSync() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopSync()
+}
+
+// startSync runs the sync engine of each tenant in the background. The
+// function returned stops them and waits for a sync in progress to finish.
+func startSync(tenants []*tenant) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, t := range tenants {
+		wg.Add(1)
+		go func(t *tenant) {
+			defer wg.Done()
+			t.syncEngine.Run(ctx)
+		}(t)
+	}
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Run reloads the configuration on SIGHUP and, every CONFIG_WATCH_INTERVAL,
+// when the .env file, the configuration file or a secret file changed
+func (r *reloader) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		var tick <-chan time.Time
+		if interval := currentConfig().ConfigWatchInterval; interval > 0 {
+			tick = time.After(interval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.Reload("SIGHUP")
+		case <-tick:
+			if changed := r.changedFiles(); len(changed) > 0 {
+				r.Reload(strings.Join(changed, ", ") + " changed")
+			}
+		}
+	}
+}
+
+// Reload loads the configuration again and swaps in a state built from it.
+// An invalid configuration, or one Jira does not match, is logged and
+// rejected, and the server keeps running with the old one.
+func (r *reloader) Reload(reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	log.Printf("🔄 Reloading configuration: %s", reason)
+
+	old := currentConfig()
+	next, err := r.load(old)
+	if err != nil {
+		// Wait for the files to change again rather than retrying them
+		r.modTimes = fileModTimes(watchedFiles(old))
+		log.Printf("❌ Configuration reload rejected, keeping the current configuration: %v", err)
+		return err
+	}
+
+	state.Store(next)
+	r.modTimes = fileModTimes(watchedFiles(next.config))
+	next.config.ValidateConfig()
+	log.Printf("✅ Configuration reloaded")
+
+	// The old sync engines finish a sync in progress before the new ones
+	// start on the same issue caches
+	r.stopSync()
+	r.stopSync = startSync(next.tenants)
+	return nil
+}
+
+// load reads the configuration and builds a state from it, keeping the
+// settings of old that only take effect on restart
+func (r *reloader) load(old *Config) (*serverState, error) {
+	c, err := LoadConfig(r.configPath)
+	if err != nil {
+		return nil, err
+	}
+	keepStartupSettings(old, c)
+	return newServerState(c, state.Load())
+}
+
+// keepStartupSettings copies the settings used once at startup from old to
+// next, logging those that changed. They need a restart to take effect.
+// SHUTDOWN_TIMEOUT, MAX_BODY_SIZE and MAX_UPLOAD_SIZE are not among them:
+// they are read from the current configuration when used, so they reload.
+func keepStartupSettings(old, next *Config) {
+	startup := []struct {
+		name    string
+		changed bool
+	}{
+		{"PORT", next.Port != old.Port},
+		{"BACKEND", next.Backend != old.Backend},
+		{"cassette settings", next.CassetteMode != old.CassetteMode || next.CassettePath != old.CassettePath},
+		{"STORAGE_DRIVER", next.StorageDriver != old.StorageDriver},
+		{"STORAGE_PATH", next.StoragePath != old.StoragePath},
+		{"REPORT_TEMPLATE", next.ReportTemplate != old.ReportTemplate},
+		{"OUTBOX_INTERVAL", next.OutboxInterval != old.OutboxInterval},
+		{"OUTBOX_MAX_BACKOFF", next.OutboxMaxBackoff != old.OutboxMaxBackoff},
+		{"HTTP timeouts", next.HTTPReadTimeout != old.HTTPReadTimeout || next.HTTPWriteTimeout != old.HTTPWriteTimeout || next.HTTPIdleTimeout != old.HTTPIdleTimeout},
+		{"TLS settings", next.TLSCertFile != old.TLSCertFile || next.TLSKeyFile != old.TLSKeyFile || next.TLSClientCAFile != old.TLSClientCAFile || next.TLSClientAuth != old.TLSClientAuth},
+		{"tracing settings", next.TracingExporter != old.TracingExporter || next.TracingEndpoint != old.TracingEndpoint || next.TracingSampleRatio != old.TracingSampleRatio},
+	}
+	for _, s := range startup {
+		if s.changed {
+			log.Printf("⚠️  %s changed, restart the server to apply it", s.name)
+		}
+	}
+	next.Port, next.StorageDriver, next.StoragePath = old.Port, old.StorageDriver, old.StoragePath
+	next.Backend, next.CassetteMode, next.CassettePath = old.Backend, old.CassetteMode, old.CassettePath
+	next.ReportTemplate = old.ReportTemplate
+	next.OutboxInterval, next.OutboxMaxBackoff = old.OutboxInterval, old.OutboxMaxBackoff
+	next.HTTPReadTimeout, next.HTTPWriteTimeout, next.HTTPIdleTimeout = old.HTTPReadTimeout, old.HTTPWriteTimeout, old.HTTPIdleTimeout
+	next.TLSCertFile, next.TLSKeyFile, next.TLSClientCAFile, next.TLSClientAuth = old.TLSCertFile, old.TLSKeyFile, old.TLSClientCAFile, old.TLSClientAuth
+	next.TracingExporter, next.TracingEndpoint, next.TracingSampleRatio = old.TracingExporter, old.TracingEndpoint, old.TracingSampleRatio
+}
+
+// watchedFiles returns the files the configuration c was read from: the .env
+// file, the configuration file and files secrets were read from
+func watchedFiles(c *Config) []string {
+	files := []string{".env"}
+	if c.ConfigFile != "" {
+		files = append(files, c.ConfigFile)
+	}
+	for _, source := range c.SecretSources {
+		if path, ok := strings.CutPrefix(source, secretSourceFile); ok {
+			files = append(files, path)
+		}
+	}
+	if c.KeyringFile != "" {
+		files = append(files, c.KeyringFile)
+	}
+	return files
+}
+
+// fileModTimes returns the modification time of each file, the zero time
+// for files that do not exist
+func fileModTimes(files []string) map[string]time.Time {
+	times := make(map[string]time.Time, len(files))
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil {
+			times[path] = info.ModTime()
+		} else {
+			times[path] = time.Time{}
+		}
+	}
+	return times
+}
+
+// changedFiles returns the watched files created, changed or removed since
+// the configuration was last loaded
+func (r *reloader) changedFiles() []string {
+	var changed []string
+	for path, modTime := range fileModTimes(watchedFiles(currentConfig())) {
+		if !modTime.Equal(r.modTimes[path]) {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
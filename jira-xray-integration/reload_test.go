@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"jira-xray-integration/auth"
+	"jira-xray-integration/cassette"
+	"jira-xray-integration/jiratest"
+)
+
+// reloadYAML is a configuration file for the fake Jira of env, with one API
+// key and a custom field for test types
+func reloadYAML(env *testEnv, key, testTypeField string) string {
+	return fmt.Sprintf(`
+backend: jira
+storage:
+  driver: memory
+sync:
+  interval: 0s
+jira:
+  baseUrl: %s
+  username: %s
+  apiToken: %s
+  verify: "off"
+  projects:
+    - key: TEST
+      fields:
+        testType: %s
+auth:
+  methods: apikey
+  apiKeys:
+    - name: ci
+      hash: %s
+      scopes: [read]
+`, env.jira.URL, jiratest.Username, jiratest.APIToken, testTypeField, auth.HashKey(key))
+}
+
+// rewriteFile replaces the content of a watched file and moves its
+// modification time forward, as file systems with coarse times may not
+func rewriteFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	env := newTestEnv(t)
+	chdirWithoutDotEnv(t)
+	path := writeConfigFile(t, "config.yaml", reloadYAML(env, "xik_old", "customfield_10100"))
+
+	r := newReloader(path)
+	t.Cleanup(func() { r.stopSync() })
+	if err := r.Reload("test"); err != nil {
+		t.Fatal(err)
+	}
+	listWithKey := func(key string) int {
+		return env.do(http.MethodGet, "/api/testcases", "", auth.APIKeyHeader, key).Code
+	}
+	if got := listWithKey("xik_old"); got != http.StatusOK {
+		t.Fatalf("got status %d with the configured API key", got)
+	}
+	before := defaultTenant()
+
+	// A changed file is noticed and swaps in the new API keys and field mappings
+	rewriteFile(t, path, reloadYAML(env, "xik_new", "customfield_10200"))
+	if changed := r.changedFiles(); len(changed) != 1 || changed[0] != path {
+		t.Fatalf("got changed files %v, want %s", changed, path)
+	}
+	if err := r.Reload("file changed"); err != nil {
+		t.Fatal(err)
+	}
+	if listWithKey("xik_old") != http.StatusUnauthorized || listWithKey("xik_new") != http.StatusOK {
+		t.Error("the API keys of the reloaded configuration should apply")
+	}
+	if got := currentConfig().Projects[0].Fields["testType"]; got != "customfield_10200" {
+		t.Errorf("got testType field %q after reload", got)
+	}
+	if defaultTenant() == before {
+		t.Error("reload should connect to Jira with new clients")
+	}
+	if got := before.DefaultProject().Fields["testType"]; got != "customfield_10100" {
+		t.Errorf("requests started before the reload should keep their client, got testType field %q", got)
+	}
+	if len(r.changedFiles()) != 0 {
+		t.Error("files should not count as changed after a reload")
+	}
+
+	// An invalid file is rejected and the old configuration stays in effect
+	reloaded := currentConfig()
+	rewriteFile(t, path, "sync:\n  interval: often\n")
+	if err := r.Reload("file changed"); err == nil || !strings.Contains(err.Error(), "sync.interval") {
+		t.Errorf("got error %v, want the invalid interval", err)
+	}
+	if currentConfig() != reloaded || listWithKey("xik_new") != http.StatusOK {
+		t.Error("a rejected reload should keep the current configuration")
+	}
+	if !strings.Contains(currentConfig().settings.file["API_KEYS"], auth.HashKey("xik_new")) {
+		t.Errorf("a rejected reload should keep the file settings, got %v", currentConfig().settings.file)
+	}
+	if changed := r.changedFiles(); len(changed) != 0 {
+		t.Errorf("a rejected file should not be retried until it changes again, got %v", changed)
+	}
+
+	// Settings read once at startup keep their values
+	rewriteFile(t, path, reloadYAML(env, "xik_new", "customfield_10200")+"server:\n  port: 9999\n")
+	if err := r.Reload("file changed"); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentConfig().Port; got != reloaded.Port {
+		t.Errorf("got port %q after reload, want %q until restart", got, reloaded.Port)
+	}
+}
+
+func TestReloadDotEnv(t *testing.T) {
+	env := newTestEnv(t)
+	chdirWithoutDotEnv(t)
+	path := writeConfigFile(t, "config.yaml", reloadYAML(env, "xik_ci", "customfield_10100"))
+	t.Setenv("JIRA_RATE_BURST", "3")
+
+	r := newReloader(path)
+	t.Cleanup(func() { r.stopSync() })
+	rewriteFile(t, ".env", "CORS_ALLOWED_ORIGINS=https://qa.example.com\nJIRA_RATE_BURST=20\n")
+	if changed := r.changedFiles(); len(changed) != 1 || changed[0] != ".env" {
+		t.Fatalf("got changed files %v, want .env", changed)
+	}
+	if err := r.Reload(".env changed"); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentConfig().CORSAllowedOrigins; len(got) != 1 || got[0] != "https://qa.example.com" {
+		t.Errorf("got CORS origins %v from .env", got)
+	}
+	if got := currentConfig().JiraRateBurst; got != 3 {
+		t.Errorf("got rate burst %d, the environment should win over .env", got)
+	}
+	if _, set := os.LookupEnv("CORS_ALLOWED_ORIGINS"); set {
+		t.Error("a reload should not set variables of .env in the environment")
+	}
+
+	if err := os.Remove(".env"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(".env removed"); err != nil {
+		t.Fatal(err)
+	}
+	if got := currentConfig().CORSAllowedOrigins; len(got) != 0 {
+		t.Errorf("got CORS origins %v, variables of a removed .env file should no longer apply", got)
+	}
+	if got := currentConfig().JiraRateBurst; got != 3 {
+		t.Errorf("got rate burst %d, variables of the environment should still apply", got)
+	}
+}
+
+// TestReloadServerLimits checks that the shutdown timeout and request size
+// limits, unlike the other server settings, take effect on reload
+func TestReloadServerLimits(t *testing.T) {
+	large := `{"summary":"` + strings.Repeat("x", 2<<10) + `"}`
+	tests := []struct {
+		setting string
+		check   func(t *testing.T, env *testEnv)
+	}{
+		{"SHUTDOWN_TIMEOUT=45s", func(t *testing.T, env *testEnv) {
+			if got := currentConfig().ShutdownTimeout; got != 45*time.Second {
+				t.Errorf("got shutdown timeout %s after reload", got)
+			}
+		}},
+		{"MAX_BODY_SIZE=1KB", func(t *testing.T, env *testEnv) {
+			rec := env.do(http.MethodPost, "/api/testcases", large)
+			if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "MAX_BODY_SIZE") {
+				t.Errorf("got status %d: %s", rec.Code, rec.Body.String())
+			}
+		}},
+		{"MAX_UPLOAD_SIZE=1KB", func(t *testing.T, env *testEnv) {
+			rec := env.do(http.MethodPost, "/api/import/gotest", large)
+			if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "MAX_UPLOAD_SIZE") {
+				t.Errorf("got status %d: %s", rec.Code, rec.Body.String())
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.setting, func(t *testing.T) {
+			env := newTestEnv(t)
+			chdirWithoutDotEnv(t)
+			path := writeConfigFile(t, "config.yaml", reloadYAML(env, "xik_ci", "customfield_10100"))
+
+			r := newReloader(path)
+			t.Cleanup(func() { r.stopSync() })
+			rewriteFile(t, ".env", tt.setting+"\n")
+			if err := r.Reload(".env changed"); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, env)
+		})
+	}
+}
+
+func TestReloadKeepsTransport(t *testing.T) {
+	env := newTestEnv(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	currentConfig().CassetteMode, currentConfig().CassettePath = cassette.ModeRecord, path
+	env.reload(t)
+	before := defaultTenant().transport
+	env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases/TEST-1", "")
+
+	// The recording carries on rather than starting a new cassette
+	env.reload(t)
+	if after := defaultTenant().transport; after.limiter != before.limiter || after.cassette != before.cassette {
+		t.Error("a reload with the same Jira settings should keep the rate limiter and cassette")
+	}
+	env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases/TEST-2", "")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded cassette.Cassette
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded.Interactions) != 2 {
+		t.Errorf("got %d recorded interactions after a reload, want 2", len(recorded.Interactions))
+	}
+
+	// A new rate gets a new rate limiter, still recording to the same cassette
+	currentConfig().JiraRateBurst = 50
+	env.reload(t)
+	if after := defaultTenant().transport; after.limiter == before.limiter || after.cassette == nil {
+		t.Error("a reload with a new rate should replace the rate limiter and keep recording")
+	}
+	env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases/TEST-1", "")
+	if data, _ := os.ReadFile(path); json.Unmarshal(data, &recorded) != nil || len(recorded.Interactions) != 3 {
+		t.Errorf("got %d recorded interactions, want 3", len(recorded.Interactions))
+	}
+}
+
+func TestReloadKeepsMemoryBackend(t *testing.T) {
+	env := newTestEnv(t)
+	currentConfig().Backend = "memory"
+	env.reload(t)
+
+	created := env.mustDo(t, http.StatusCreated, http.MethodPost, "/api/testcases", `{"summary":"Kept across reloads"}`)
+	key := created["testCase"].(map[string]interface{})["key"].(string)
+	env.reload(t)
+	env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases/"+key, "")
+}
+
+func TestKeepStartupSettings(t *testing.T) {
+	old := &Config{Backend: "jira", CassetteMode: cassette.ModeRecord, CassettePath: "data/a.json"}
+	next := &Config{Backend: "memory", CassetteMode: cassette.ModeOff, CassettePath: "data/b.json"}
+	keepStartupSettings(old, next)
+	if next.Backend != "jira" || next.CassetteMode != cassette.ModeRecord || next.CassettePath != "data/a.json" {
+		t.Errorf("got backend %q and cassette %s %s, want the settings of startup", next.Backend, next.CassetteMode, next.CassettePath)
+	}
+}
@@ -0,0 +1,159 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A minimal PDF writer for text reports. It lays out lines top to bottom on
+// A4 pages using the standard Helvetica fonts, so no external service or font
+// files are needed.
+
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	pageMargin   = 50
+	maxLineChars = 95
+)
+
+type pdfLine struct {
+	text string
+	size int
+	bold bool
+}
+
+type pdfDocument struct {
+	lines []pdfLine
+}
+
+func newPDFDocument() *pdfDocument {
+	return &pdfDocument{}
+}
+
+func (d *pdfDocument) heading(text string)    { d.add(text, 16, true) }
+func (d *pdfDocument) subheading(text string) { d.add(text, 12, true) }
+func (d *pdfDocument) bold(text string)       { d.add(text, 10, true) }
+func (d *pdfDocument) text(text string)       { d.add(text, 10, false) }
+func (d *pdfDocument) blank()                 { d.lines = append(d.lines, pdfLine{size: 10}) }
+
+// add appends text, splitting it on newlines and wrapping long lines
+func (d *pdfDocument) add(text string, size int, bold bool) {
+	width := maxLineChars * 10 / size
+	for _, paragraph := range strings.Split(text, "\n") {
+		for _, line := range wrapText(paragraph, width) {
+			d.lines = append(d.lines, pdfLine{text: line, size: size, bold: bold})
+		}
+	}
+}
+
+// pages splits the lines into page content streams
+func (d *pdfDocument) pages() []string {
+	var pages []string
+	var content bytes.Buffer
+	y := pageHeight - pageMargin
+
+	for _, line := range d.lines {
+		leading := line.size + line.size/2
+		if y-leading < pageMargin {
+			pages = append(pages, content.String())
+			content.Reset()
+			y = pageHeight - pageMargin
+		}
+		y -= leading
+		if line.text == "" {
+			continue
+		}
+		font := "F1"
+		if line.bold {
+			font = "F2"
+		}
+		fmt.Fprintf(&content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, line.size, pageMargin, y, escapePDFText(line.text))
+	}
+	return append(pages, content.String())
+}
+
+// write serializes the document with a cross-reference table
+func (d *pdfDocument) write(w io.Writer) error {
+	pages := d.pages()
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// objects 1-4: catalog, page tree, regular and bold fonts; then a page and content stream per page
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// wrapText breaks a line into chunks of at most width characters, preferring word boundaries
+func wrapText(text string, width int) []string {
+	runes := []rune(strings.ReplaceAll(text, "\t", "    "))
+	if len(runes) <= width {
+		return []string{string(runes)}
+	}
+
+	var lines []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > 0; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		lines = append(lines, strings.TrimRight(string(runes[:cut]), " "))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+	}
+	if len(runes) > 0 {
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// escapePDFText escapes a string for a PDF literal, replacing characters the
+// standard fonts cannot show
+func escapePDFText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,218 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/requirements"
+)
+
+//go:embed templates/execution.html templates/traceability.html
+var defaultTemplates embed.FS
+
+// Summary holds result counts for an execution
+type Summary struct {
+	Total     int     `json:"total"`
+	Passed    int     `json:"passed"`
+	Failed    int     `json:"failed"`
+	Skipped   int     `json:"skipped"`
+	Todo      int     `json:"todo"`
+	Executing int     `json:"executing"`
+	PassRate  float64 `json:"passRate"` // percentage of executed tests that passed
+}
+
+// ExecutionReport is the data passed to report templates
+type ExecutionReport struct {
+	Execution   *jira.TestExecution `json:"execution"`
+	Results     []jira.TestResult   `json:"results"`
+	Summary     Summary             `json:"summary"`
+	Defects     []string            `json:"defects"`
+	GeneratedAt time.Time           `json:"generatedAt"`
+}
+
+// NewExecutionReport computes summary counts, pass rate and defects for an execution.
+// Test cases in the execution without a recorded result are reported as TODO.
+func NewExecutionReport(te *jira.TestExecution) *ExecutionReport {
+	results := make([]jira.TestResult, 0, len(te.TestCases))
+	recorded := make(map[string]bool)
+	for _, result := range te.TestResults {
+		results = append(results, result)
+		recorded[result.TestCaseKey] = true
+	}
+	for _, key := range te.TestCases {
+		if !recorded[key] {
+			results = append(results, jira.TestResult{TestCaseKey: key, Status: jira.StatusTodo})
+			recorded[key] = true
+		}
+	}
+
+	var summary Summary
+	defects := make(map[string]bool)
+	for _, result := range results {
+		summary.Total++
+		switch result.Status {
+		case jira.StatusPass:
+			summary.Passed++
+		case jira.StatusFail:
+			summary.Failed++
+		case jira.StatusSkipped:
+			summary.Skipped++
+		case jira.StatusExecuting:
+			summary.Executing++
+		default:
+			summary.Todo++
+		}
+		for _, defect := range result.Defects {
+			defects[defect] = true
+		}
+	}
+	if executed := summary.Passed + summary.Failed; executed > 0 {
+		summary.PassRate = float64(summary.Passed) * 100 / float64(executed)
+	}
+
+	defectKeys := make([]string, 0, len(defects))
+	for key := range defects {
+		defectKeys = append(defectKeys, key)
+	}
+	sort.Strings(defectKeys)
+
+	return &ExecutionReport{
+		Execution:   te,
+		Results:     results,
+		Summary:     summary,
+		Defects:     defectKeys,
+		GeneratedAt: time.Now(),
+	}
+}
+
+// templateFuncs are available to every report template
+var templateFuncs = template.FuncMap{
+	"formatTime":     formatTime,
+	"formatDuration": formatDuration,
+	"statusClass":    func(status string) string { return strings.ToLower(status) },
+	"join":           strings.Join,
+}
+
+// LoadTemplate parses a custom HTML report template, or the built-in one when path is empty
+func LoadTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.New("execution.html").Funcs(templateFuncs).ParseFS(defaultTemplates, "templates/execution.html")
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// RenderHTML renders the report with the given template
+func RenderHTML(w io.Writer, tmpl *template.Template, r *ExecutionReport) error {
+	if err := tmpl.Execute(w, r); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+// traceabilityTemplate renders traceability matrices
+var traceabilityTemplate = template.Must(template.New("traceability.html").Funcs(templateFuncs).ParseFS(defaultTemplates, "templates/traceability.html"))
+
+// RenderTraceabilityHTML renders a traceability matrix as an HTML page
+func RenderTraceabilityHTML(w io.Writer, m *requirements.Matrix) error {
+	if err := traceabilityTemplate.Execute(w, m); err != nil {
+		return fmt.Errorf("failed to render traceability matrix: %w", err)
+	}
+	return nil
+}
+
+// RenderPDF renders the report as a PDF document
+func RenderPDF(w io.Writer, r *ExecutionReport) error {
+	doc := newPDFDocument()
+	te := r.Execution
+
+	doc.heading(fmt.Sprintf("Test Execution Report: %s", te.Key))
+	doc.text(te.Summary)
+	doc.blank()
+
+	doc.subheading("Execution")
+	doc.text(fmt.Sprintf("Status: %s", valueOr(te.Status, "-")))
+	doc.text(fmt.Sprintf("Execution status: %s", valueOr(te.ExecutionStatus, "-")))
+	doc.text(fmt.Sprintf("Environment: %s", valueOr(te.Environment, "-")))
+	doc.text(fmt.Sprintf("Executed by: %s", valueOr(te.ExecutedBy, "-")))
+	doc.text(fmt.Sprintf("Started: %s", formatTime(te.StartDate)))
+	doc.text(fmt.Sprintf("Finished: %s", formatTime(te.EndDate)))
+	if te.Description != "" {
+		doc.text(fmt.Sprintf("Description: %s", te.Description))
+	}
+	doc.blank()
+
+	doc.subheading("Summary")
+	doc.text(fmt.Sprintf("Total: %d   Passed: %d   Failed: %d   Skipped: %d   To do: %d   Executing: %d",
+		r.Summary.Total, r.Summary.Passed, r.Summary.Failed, r.Summary.Skipped, r.Summary.Todo, r.Summary.Executing))
+	doc.text(fmt.Sprintf("Pass rate: %.1f%%", r.Summary.PassRate))
+	doc.blank()
+
+	doc.subheading("Results")
+	for _, result := range r.Results {
+		doc.bold(fmt.Sprintf("%s  %s  %s", result.TestCaseKey, result.Status, formatDuration(result.ExecutionTime)))
+		if result.ExecutedBy != "" {
+			doc.text(fmt.Sprintf("Executed by %s on %s", result.ExecutedBy, formatTime(result.ExecutedOn)))
+		}
+		if len(result.Defects) > 0 {
+			doc.text(fmt.Sprintf("Defects: %s", strings.Join(result.Defects, ", ")))
+		}
+		if result.Comment != "" {
+			doc.text(result.Comment)
+		}
+		doc.blank()
+	}
+
+	doc.subheading("Defects")
+	if len(r.Defects) == 0 {
+		doc.text("No defects linked to this execution.")
+	} else {
+		doc.text(strings.Join(r.Defects, ", "))
+	}
+	doc.blank()
+	doc.text(fmt.Sprintf("Generated %s", formatTime(r.GeneratedAt)))
+	doc.blank()
+	doc.text("Sign-off: ______________________________    Date: ______________")
+
+	return doc.write(w)
+}
+
+// formatTime formats a time.Time or *time.Time, showing "-" when unset
+func formatTime(value interface{}) string {
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v != nil {
+			t = *v
+		}
+	}
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04 MST")
+}
+
+// formatDuration formats an execution time in milliseconds
+func formatDuration(ms int) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
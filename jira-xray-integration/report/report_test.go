@@ -0,0 +1,158 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/jira"
+)
+
+func TestNewExecutionReport(t *testing.T) {
+	r := NewExecutionReport(&jira.TestExecution{
+		Key:       "TEST-9",
+		TestCases: []string{"TEST-1", "TEST-2", "TEST-3"},
+		TestResults: []jira.TestResult{
+			{TestCaseKey: "TEST-1", Status: jira.StatusPass},
+			{TestCaseKey: "TEST-2", Status: jira.StatusFail, Defects: []string{"TEST-8", "TEST-7"}},
+			{TestCaseKey: "TEST-4", Status: jira.StatusSkipped, Defects: []string{"TEST-7"}},
+		},
+	})
+
+	want := Summary{Total: 4, Passed: 1, Failed: 1, Skipped: 1, Todo: 1, PassRate: 50}
+	if r.Summary != want {
+		t.Errorf("got summary %+v, want %+v", r.Summary, want)
+	}
+	if last := r.Results[len(r.Results)-1]; last.TestCaseKey != "TEST-3" || last.Status != jira.StatusTodo {
+		t.Errorf("got %+v, want TEST-3 without a result reported as TODO", last)
+	}
+	if strings.Join(r.Defects, ",") != "TEST-7,TEST-8" {
+		t.Errorf("got defects %v, want each once in order", r.Defects)
+	}
+}
+
+func TestEscapePDFText(t *testing.T) {
+	tests := map[string]string{
+		"plain text":            "plain text",
+		`Retry (twice) \ fail`:  `Retry \(twice\) \\ fail`,
+		"Grüße, café":           `Gr\374\337e, caf\351`,
+		"non-breaking\u00a0gap": `non-breaking\240gap`,
+		"日本語 €":                 "??? ?",
+		"tab\tand\x01control":   "tab?and?control",
+	}
+	for text, want := range tests {
+		if got := escapePDFText(text); got != want {
+			t.Errorf("escapePDFText(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"wrap at the word boundary", 10, []string{"wrap at", "the word", "boundary"}},
+		{"abcdefghijklmnop", 6, []string{"abcdef", "ghijkl", "mnop"}},
+		{"ééééé ééééé", 6, []string{"ééééé", "ééééé"}},
+	}
+	for _, tt := range tests {
+		if got := wrapText(tt.text, tt.width); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrapText(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
+
+// checkPDF checks that the cross-reference table of a PDF points at its
+// objects and that each stream is as long as its /Length says
+func checkPDF(t *testing.T, pdf []byte) {
+	t.Helper()
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF document")
+	}
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if startxref == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, offset := range offsets {
+		at, _ := strconv.Atoi(string(offset[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(pdf[at:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, pdf[at:at+10])
+		}
+	}
+
+	for _, stream := range regexp.MustCompile(`(?s)/Length (\d+) >>\nstream\n(.*?)endstream`).FindAllSubmatch(pdf, -1) {
+		if length, _ := strconv.Atoi(string(stream[1])); length != len(stream[2]) {
+			t.Errorf("stream has /Length %d but %d bytes", length, len(stream[2]))
+		}
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewExecutionReport(&jira.TestExecution{
+		Key:         "TEST-9",
+		Summary:     "Nightly (main) run",
+		TestResults: []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusFail, Comment: "Größe ändert sich (nicht)"}},
+	})
+	if err := RenderPDF(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	checkPDF(t, buf.Bytes())
+
+	pdf := buf.String()
+	for _, want := range []string{`(Nightly \(main\) run) Tj`, `(Gr\366\337e \344ndert sich \(nicht\)) Tj`, "/Count 1 >>"} {
+		if !strings.Contains(pdf, want) {
+			t.Errorf("PDF does not contain %s", want)
+		}
+	}
+}
+
+func TestRenderPDFMultiplePages(t *testing.T) {
+	execution := &jira.TestExecution{Key: "TEST-9", Summary: "Regression"}
+	for i := 1; i <= 150; i++ {
+		execution.TestResults = append(execution.TestResults, jira.TestResult{
+			TestCaseKey: fmt.Sprintf("TEST-%d", 100+i),
+			Status:      jira.StatusPass,
+			Comment:     fmt.Sprintf("Result %d", i),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := RenderPDF(&buf, NewExecutionReport(execution)); err != nil {
+		t.Fatal(err)
+	}
+	checkPDF(t, buf.Bytes())
+
+	pdf := buf.String()
+	pages := strings.Count(pdf, "/Type /Page /Parent")
+	if pages < 2 || !strings.Contains(pdf, fmt.Sprintf("/Count %d >>", pages)) {
+		t.Fatalf("got %d pages, want the page tree to count more than one", pages)
+	}
+	// Every result is on some page, in order
+	last := 0
+	for i := 1; i <= 150; i++ {
+		at := strings.Index(pdf, fmt.Sprintf("(Result %d) Tj", i))
+		if at < last {
+			t.Fatalf("result %d is missing or out of order", i)
+		}
+		last = at
+	}
+	// Lines stay within the margins of each page
+	for _, y := range regexp.MustCompile(`Tf \d+ (-?\d+) Td`).FindAllStringSubmatch(pdf, -1) {
+		if n, _ := strconv.Atoi(y[1]); n < pageMargin || n > pageHeight-pageMargin {
+			t.Errorf("line at y=%d is outside the margins", n)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/report"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Render a sign-off report for a test execution
+func getTestExecutionReport(c *gin.Context) {
+	key := c.Param("key")
+	format := c.DefaultQuery("format", "html")
+	log.Printf("Handling GET /api/testexecutions/%s/report request (format=%s)", key, format)
+
+	if format != "html" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported report format, use html or pdf",
+		})
+		return
+	}
+
+	testExecution, err := loadTestExecution(requestTenant(c), requestProjectConfig(c), jiraFor(c), key, freshRead(c))
+	if err != nil {
+		log.Printf("Error fetching test execution: %v", err)
+		status := http.StatusInternalServerError
+		if jira.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to fetch test execution",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	executionReport := report.NewExecutionReport(testExecution)
+
+	// Render into a buffer so template errors still produce a JSON error response
+	var buf bytes.Buffer
+	contentType := "text/html; charset=utf-8"
+	if format == "pdf" {
+		contentType = "application/pdf"
+		err = report.RenderPDF(&buf, executionReport)
+	} else {
+		err = report.RenderHTML(&buf, reportTemplate, executionReport)
+	}
+	if err != nil {
+		log.Printf("Error rendering report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to render report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "pdf" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", key+"-report.pdf"))
+	}
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTestExecutionReport(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "html",
+			setup:  recordPassAndFail,
+			method: http.MethodGet, path: "/api/testexecutions/TEST-9/report",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+					t.Errorf("got content type %q", ct)
+				}
+				for _, want := range []string{"TEST-9", "Sprint 1 Test Execution", "TEST-2", "Reset email never arrived"} {
+					if !strings.Contains(rec.Body.String(), want) {
+						t.Errorf("report does not mention %q", want)
+					}
+				}
+			},
+		},
+		{
+			name:   "pdf",
+			setup:  recordPassAndFail,
+			method: http.MethodGet, path: "/api/testexecutions/TEST-9/report?format=pdf",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !bytes.HasPrefix(rec.Body.Bytes(), []byte("%PDF-")) {
+					t.Errorf("response is not a PDF")
+				}
+				if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "TEST-9-report.pdf") {
+					t.Errorf("got Content-Disposition %q", cd)
+				}
+			},
+		},
+		{
+			name:   "unsupported format",
+			method: http.MethodGet, path: "/api/testexecutions/TEST-9/report?format=docx",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown execution",
+			method: http.MethodGet, path: "/api/testexecutions/TEST-999/report",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "unknown execution as PDF",
+			method: http.MethodGet, path: "/api/testexecutions/TEST-999/report?format=pdf",
+			status: http.StatusNotFound,
+		},
+	})
+}
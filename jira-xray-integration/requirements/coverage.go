@@ -0,0 +1,106 @@
+package requirements
+
+import "jira-xray-integration/jira"
+
+// Requirement coverage statuses
+const (
+	CoverageOK        = "OK"        // every linked test passed
+	CoverageNOK       = "NOK"       // at least one linked test failed
+	CoverageNotRun    = "NOT RUN"   // no failures, but some linked tests have no final result
+	CoverageUncovered = "UNCOVERED" // no tests linked in scope
+)
+
+// Coverage is the computed coverage status of a requirement
+type Coverage struct {
+	Key     string         `json:"key"`
+	Summary string         `json:"summary"`
+	Type    string         `json:"type"`
+	Status  string         `json:"status"`
+	Counts  CoverageCounts `json:"counts"`
+	Tests   []TestRow      `json:"tests"`
+}
+
+// CoverageCounts tallies the latest results of a requirement's tests
+type CoverageCounts struct {
+	Total  int `json:"total"`
+	Passed int `json:"passed"`
+	Failed int `json:"failed"`
+	NotRun int `json:"notRun"`
+}
+
+// CoverageSummary counts requirements per coverage status
+type CoverageSummary struct {
+	Total     int `json:"total"`
+	OK        int `json:"ok"`
+	NOK       int `json:"nok"`
+	NotRun    int `json:"notRun"`
+	Uncovered int `json:"uncovered"`
+}
+
+// Coverage computes the coverage status of every requirement matched by jql
+func (b *Builder) Coverage(jql string, scope Scope) ([]Coverage, error) {
+	matrix, err := b.Build(jql, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := make([]Coverage, len(matrix.Requirements))
+	for i, req := range matrix.Requirements {
+		coverage[i] = ComputeCoverage(req)
+	}
+	return coverage, nil
+}
+
+// ComputeCoverage derives a requirement's status from the latest results of its
+// tests. A failure anywhere makes it NOK; otherwise any test without a pass
+// (not run, to do, executing or skipped) makes it NOT RUN.
+func ComputeCoverage(req RequirementRow) Coverage {
+	coverage := Coverage{
+		Key:     req.Key,
+		Summary: req.Summary,
+		Type:    req.Type,
+		Tests:   req.Tests,
+	}
+
+	for _, test := range req.Tests {
+		coverage.Counts.Total++
+		switch test.LatestResult {
+		case jira.StatusPass:
+			coverage.Counts.Passed++
+		case jira.StatusFail:
+			coverage.Counts.Failed++
+		default:
+			coverage.Counts.NotRun++
+		}
+	}
+
+	switch {
+	case coverage.Counts.Total == 0:
+		coverage.Status = CoverageUncovered
+	case coverage.Counts.Failed > 0:
+		coverage.Status = CoverageNOK
+	case coverage.Counts.NotRun > 0:
+		coverage.Status = CoverageNotRun
+	default:
+		coverage.Status = CoverageOK
+	}
+	return coverage
+}
+
+// Summarize counts requirements per coverage status
+func Summarize(coverage []Coverage) CoverageSummary {
+	summary := CoverageSummary{Total: len(coverage)}
+	for _, c := range coverage {
+		switch c.Status {
+		case CoverageOK:
+			summary.OK++
+		case CoverageNOK:
+			summary.NOK++
+		case CoverageNotRun:
+			summary.NotRun++
+		default:
+			summary.Uncovered++
+		}
+	}
+	return summary
+}
@@ -0,0 +1,308 @@
+// Package requirements links requirements (stories, epics) to the tests that
+// cover them, their latest results and the defects raised against them.
+package requirements
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"jira-xray-integration/jira"
+)
+
+// NotRun is reported as the latest result of a test that has never been executed
+const NotRun = "NOT RUN"
+
+// IssueSearcher runs JQL searches against Jira
+type IssueSearcher interface {
+	SearchIssues(jql string) ([]jira.JiraIssue, error)
+}
+
+// ResultProvider looks up the most recent result recorded for a test case
+// within a scope. It returns nil when the test has no results in scope.
+type ResultProvider interface {
+	LatestResult(testKey string, scope Scope) (*jira.TestResult, error)
+}
+
+// Scope narrows which tests and results count towards a requirement.
+// Empty fields don't restrict anything.
+type Scope struct {
+	FixVersion  string `json:"fixVersion,omitempty"`  // only results from executions for this version
+	TestPlan    string `json:"testPlan,omitempty"`    // only tests in, and executions linked to, this test plan
+	Environment string `json:"environment,omitempty"` // only results from executions in this environment
+}
+
+// Options controls which linked issues are treated as tests and defects
+type Options struct {
+	TestIssueTypes   []string
+	DefectIssueTypes []string
+	TestLinkType     string // link type from requirements to tests; empty follows any link
+	DefectLinkType   string // link type from tests to defects; empty follows any link
+	TestPlanLinkType string // link type from test plans to tests; empty follows any link
+}
+
+// DefaultOptions matches the issue types created by this integration
+var DefaultOptions = Options{
+	TestIssueTypes:   []string{"Test"},
+	DefectIssueTypes: []string{"Bug", "Defect"},
+}
+
+// Matrix is a traceability matrix of requirements, tests, results and defects
+type Matrix struct {
+	JQL          string           `json:"jql"`
+	Scope        Scope            `json:"scope"`
+	Requirements []RequirementRow `json:"requirements"`
+	GeneratedAt  time.Time        `json:"generatedAt"`
+}
+
+// RequirementRow is a requirement and the tests linked to it
+type RequirementRow struct {
+	Key     string    `json:"key"`
+	Summary string    `json:"summary"`
+	Type    string    `json:"type"`
+	Status  string    `json:"status"`
+	Tests   []TestRow `json:"tests"`
+}
+
+// TestRow is a test linked to a requirement with its latest result and defects
+type TestRow struct {
+	Key          string           `json:"key"`
+	Summary      string           `json:"summary"`
+	Status       string           `json:"status"`
+	LatestResult string           `json:"latestResult"` // result status, or NOT RUN
+	ExecutedOn   *time.Time       `json:"executedOn,omitempty"`
+	Result       *jira.TestResult `json:"result,omitempty"`
+	Defects      []Defect         `json:"defects"`
+}
+
+// Defect is a defect linked to a test or reported in its latest result
+type Defect struct {
+	Key     string `json:"key"`
+	Summary string `json:"summary,omitempty"`
+	Status  string `json:"status,omitempty"`
+}
+
+// Builder builds traceability matrices
+type Builder struct {
+	Searcher IssueSearcher
+	Results  ResultProvider
+	Options  Options
+}
+
+// Build searches requirements with jql and resolves their linked tests, the
+// latest result of each test within scope and the defects linked to each test
+func (b *Builder) Build(jql string, scope Scope) (*Matrix, error) {
+	issues, err := b.Searcher.SearchIssues(jql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search requirements: %w", err)
+	}
+
+	var planTests map[string]bool
+	if scope.TestPlan != "" {
+		if planTests, err = b.testPlanTests(scope.TestPlan); err != nil {
+			return nil, err
+		}
+	}
+
+	matrix := &Matrix{JQL: jql, Scope: scope, Requirements: []RequirementRow{}, GeneratedAt: time.Now()}
+	var testKeys []string
+	seen := make(map[string]bool)
+
+	for _, issue := range issues {
+		row := RequirementRow{
+			Key:     issue.Key,
+			Summary: issue.Fields.Summary,
+			Type:    issue.Fields.IssueType.Name,
+			Status:  issue.Fields.Status.Name,
+			Tests:   []TestRow{},
+		}
+		for _, linked := range b.linkedIssues(issue, b.Options.TestIssueTypes, b.Options.TestLinkType) {
+			if planTests != nil && !planTests[linked.Key] {
+				continue
+			}
+			row.Tests = append(row.Tests, TestRow{
+				Key:     linked.Key,
+				Summary: linked.Fields.Summary,
+				Status:  linked.Fields.Status.Name,
+				Defects: []Defect{},
+			})
+			if !seen[linked.Key] {
+				seen[linked.Key] = true
+				testKeys = append(testKeys, linked.Key)
+			}
+		}
+		matrix.Requirements = append(matrix.Requirements, row)
+	}
+
+	defects, err := b.testDefects(testKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*jira.TestResult, len(testKeys))
+	for _, key := range testKeys {
+		result, err := b.Results.LatestResult(key, scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch latest result for %s: %w", key, err)
+		}
+		results[key] = result
+	}
+
+	for i := range matrix.Requirements {
+		for j := range matrix.Requirements[i].Tests {
+			test := &matrix.Requirements[i].Tests[j]
+			test.LatestResult = NotRun
+			test.Defects = append(test.Defects, defects[test.Key]...)
+
+			if result := results[test.Key]; result != nil {
+				test.Result = result
+				test.LatestResult = result.Status
+				if !result.ExecutedOn.IsZero() {
+					executedOn := result.ExecutedOn
+					test.ExecutedOn = &executedOn
+				}
+				test.Defects = mergeDefects(test.Defects, result.Defects)
+			}
+		}
+	}
+
+	return matrix, nil
+}
+
+// testPlanTests returns the keys of the tests linked to a test plan
+func (b *Builder) testPlanTests(planKey string) (map[string]bool, error) {
+	if !jira.IsIssueKey(planKey) {
+		return nil, fmt.Errorf("test plan %q is not an issue key", planKey)
+	}
+	plans, err := b.Searcher.SearchIssues(fmt.Sprintf("key = %s", planKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch test plan %s: %w", planKey, err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("test plan %s not found", planKey)
+	}
+
+	tests := make(map[string]bool)
+	for _, linked := range b.linkedIssues(plans[0], b.Options.TestIssueTypes, b.Options.TestPlanLinkType) {
+		tests[linked.Key] = true
+	}
+	return tests, nil
+}
+
+// testDefects fetches the tests and collects the defects linked to each one
+func (b *Builder) testDefects(linkedKeys []string) (map[string][]Defect, error) {
+	defects := make(map[string][]Defect, len(linkedKeys))
+	// Keys come from Jira links, but are still checked before they go into JQL
+	var testKeys []string
+	for _, key := range linkedKeys {
+		if jira.IsIssueKey(key) {
+			testKeys = append(testKeys, key)
+		}
+	}
+	if len(testKeys) == 0 {
+		return defects, nil
+	}
+
+	// Keep each query comfortably below Jira's URL length limits
+	const batchSize = 50
+	for start := 0; start < len(testKeys); start += batchSize {
+		end := start + batchSize
+		if end > len(testKeys) {
+			end = len(testKeys)
+		}
+
+		jql := fmt.Sprintf("key in (%s)", strings.Join(testKeys[start:end], ","))
+		tests, err := b.Searcher.SearchIssues(jql)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch linked tests: %w", err)
+		}
+
+		for _, test := range tests {
+			for _, linked := range b.linkedIssues(test, b.Options.DefectIssueTypes, b.Options.DefectLinkType) {
+				defects[test.Key] = append(defects[test.Key], Defect{
+					Key:     linked.Key,
+					Summary: linked.Fields.Summary,
+					Status:  linked.Fields.Status.Name,
+				})
+			}
+		}
+	}
+	return defects, nil
+}
+
+// linkedIssues returns the issues linked to issue whose type is one of
+// issueTypes, through links of linkType unless it is empty
+func (b *Builder) linkedIssues(issue jira.JiraIssue, issueTypes []string, linkType string) []*jira.LinkedIssue {
+	var linked []*jira.LinkedIssue
+	seen := make(map[string]bool)
+	for _, link := range issue.Fields.IssueLinks {
+		other := link.LinkedIssue()
+		if other == nil || seen[other.Key] || !containsFold(issueTypes, other.Fields.IssueType.Name) {
+			continue
+		}
+		if linkType != "" && !strings.EqualFold(link.Type.Name, linkType) {
+			continue
+		}
+		seen[other.Key] = true
+		linked = append(linked, other)
+	}
+	sort.Slice(linked, func(i, j int) bool { return linked[i].Key < linked[j].Key })
+	return linked
+}
+
+// Rows flattens the matrix into a table with one row per requirement and test
+func (m *Matrix) Rows() [][]string {
+	rows := [][]string{{
+		"Requirement", "Requirement Summary", "Requirement Type", "Requirement Status",
+		"Test", "Test Summary", "Latest Result", "Executed On", "Defects",
+	}}
+
+	for _, req := range m.Requirements {
+		prefix := []string{req.Key, req.Summary, req.Type, req.Status}
+		if len(req.Tests) == 0 {
+			rows = append(rows, append(prefix, "", "", "", "", ""))
+			continue
+		}
+		for _, test := range req.Tests {
+			executedOn := ""
+			if test.ExecutedOn != nil {
+				executedOn = test.ExecutedOn.Format(time.RFC3339)
+			}
+			defectKeys := make([]string, len(test.Defects))
+			for i, defect := range test.Defects {
+				defectKeys[i] = defect.Key
+			}
+			row := append(append([]string{}, prefix...),
+				test.Key, test.Summary, test.LatestResult, executedOn, strings.Join(defectKeys, ", "))
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// mergeDefects adds defect keys reported in a result that aren't already linked
+func mergeDefects(defects []Defect, keys []string) []Defect {
+	for _, key := range keys {
+		found := false
+		for _, defect := range defects {
+			if defect.Key == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			defects = append(defects, Defect{Key: key})
+		}
+	}
+	return defects
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/outbox"
+	"jira-xray-integration/requirements"
+	"jira-xray-integration/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordResultsRequest is the body of POST /api/testexecutions/:key/results
+type recordResultsRequest struct {
+	TestResults []jira.TestResult `json:"testResults" binding:"required"`
+}
+
+// storeResultProvider serves latest results for traceability and coverage from
+// the run history of a tenant
+type storeResultProvider struct {
+	store store.Results
+}
+
+// LatestResult implements requirements.ResultProvider
+func (p *storeResultProvider) LatestResult(testKey string, scope requirements.Scope) (*jira.TestResult, error) {
+	run, err := p.store.LatestResult(testKey, store.ResultFilter{
+		FixVersion:  scope.FixVersion,
+		TestPlan:    scope.TestPlan,
+		Environment: scope.Environment,
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run.TestResult, nil
+}
+
+// saveTestExecution stores an execution created in the Jira of t along with any results it carries
+func saveTestExecution(t *tenant, te *jira.TestExecution) error {
+	if err := t.results.SaveExecution(te); err != nil {
+		return err
+	}
+	if len(te.TestResults) == 0 {
+		return nil
+	}
+	_, err := t.results.AddResults(te.Key, te.TestResults)
+	return err
+}
+
+// loadTestExecution fetches an execution from the issue cache, or from Jira when
+// fresh is set or the cache cannot serve it, and fills in the results and run
+// details kept in the local store
+func loadTestExecution(t *tenant, project jira.ProjectConfig, b jira.TestManagementBackend, key string, fresh bool) (*jira.TestExecution, error) {
+	testExecution, err := cachedTestExecution(t, project, key, fresh)
+	if err != nil {
+		return nil, err
+	}
+	if testExecution == nil {
+		if testExecution, err = b.GetTestExecution(key); err != nil {
+			return nil, err
+		}
+	}
+
+	stored, err := t.results.GetExecution(key)
+	if errors.Is(err, store.ErrNotFound) {
+		return testExecution, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mergeStoredExecution(testExecution, stored)
+	if len(stored.TestResults) > 0 {
+		testExecution.TestResults = stored.TestResults
+	}
+	return testExecution, nil
+}
+
+// mergeStoredExecution fills in the run details Jira does not hold from the stored execution
+func mergeStoredExecution(testExecution, stored *jira.TestExecution) {
+	if len(testExecution.TestCases) == 0 {
+		testExecution.TestCases = stored.TestCases
+	}
+	if stored.ExecutionStatus != "" {
+		// Recorded results are more current than the status of the Jira issue
+		testExecution.ExecutionStatus = stored.ExecutionStatus
+	}
+	if testExecution.Environment == "" {
+		testExecution.Environment = stored.Environment
+	}
+	if testExecution.FixVersion == "" {
+		testExecution.FixVersion = stored.FixVersion
+	}
+	if testExecution.TestPlan == "" {
+		testExecution.TestPlan = stored.TestPlan
+	}
+	if testExecution.ExecutedBy == "" {
+		testExecution.ExecutedBy = stored.ExecutedBy
+	}
+	if testExecution.StartDate.IsZero() {
+		testExecution.StartDate = stored.StartDate
+	}
+	if testExecution.EndDate.IsZero() {
+		testExecution.EndDate = stored.EndDate
+	}
+}
+
+// ensureStoredExecution stores an execution created outside this service so
+// results can be recorded for it, reading it from the issue cache or from Jira through b
+func ensureStoredExecution(t *tenant, project jira.ProjectConfig, b jira.TestManagementBackend, key string) error {
+	if _, err := t.results.GetExecution(key); !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	testExecution, err := cachedTestExecution(t, project, key, false)
+	if err != nil {
+		return err
+	}
+	if testExecution == nil {
+		if testExecution, err = b.GetTestExecution(key); err != nil {
+			return err
+		}
+	}
+	testExecution.TestResults = nil
+	return t.results.SaveExecution(testExecution)
+}
+
+// invalidTestResult describes what is wrong with a result recorded by a
+// request, or returns "" if nothing is. Results name a test of the request's
+// project and one of the known statuses, for the result and each step.
+func invalidTestResult(c *gin.Context, result jira.TestResult) string {
+	allowed := strings.Join(jira.TestStatuses, ", ")
+	switch {
+	case result.TestCaseKey == "" || result.Status == "":
+		return "every test result needs a testCaseKey and status"
+	case !jira.IsIssueKey(result.TestCaseKey):
+		return fmt.Sprintf("%q is not an issue key such as TEST-12", result.TestCaseKey)
+	case !issueInProject(c, result.TestCaseKey):
+		return fmt.Sprintf("%s is not an issue of project %s", result.TestCaseKey, requestProject(c))
+	case !jira.IsTestStatus(result.Status):
+		return fmt.Sprintf("unknown status %q, use one of %s", result.Status, allowed)
+	}
+	for _, step := range result.StepResults {
+		if !jira.IsTestStatus(step.Status) {
+			return fmt.Sprintf("unknown status %q of step %d, use one of %s", step.Status, step.Index, allowed)
+		}
+	}
+	return ""
+}
+
+// Record results for a test execution
+func recordTestResults(c *gin.Context) {
+	key := c.Param("key")
+	log.Printf("Handling POST /api/testexecutions/%s/results request", key)
+
+	var req recordResultsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Error binding JSON: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	for i, result := range req.TestResults {
+		if problem := invalidTestResult(c, result); problem != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid test result",
+				"details": fmt.Sprintf("testResults[%d]: %s", i, problem),
+				"index":   i,
+			})
+			return
+		}
+	}
+
+	if respondWithQueuedWrite(c, outbox.OpRecordResults, key, req) {
+		return
+	}
+
+	if err := ensureStoredExecution(requestTenant(c), requestProjectConfig(c), jiraFor(c), key); err != nil {
+		if queueWrite(c, outbox.OpRecordResults, key, req, err, "test results") {
+			return
+		}
+		log.Printf("Error fetching test execution: %v", err)
+		status := http.StatusInternalServerError
+		if jira.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to fetch test execution",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	runs, err := requestTenant(c).results.AddResults(key, req.TestResults)
+	if err != nil {
+		log.Printf("Error storing test results: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to store test results",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"results": runs,
+		"count":   len(runs),
+		"message": "Test results recorded successfully",
+	})
+}
+
+// Get the run history of a test case
+func getTestCaseHistory(c *gin.Context) {
+	key := c.Param("key")
+	log.Printf("Handling GET /api/testcases/%s/history request", key)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be a non-negative number",
+		})
+		return
+	}
+
+	runs, err := requestTenant(c).results.TestHistory(key, limit)
+	if err != nil {
+		log.Printf("Error fetching test history: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch test history",
+			"details": err.Error(),
+		})
+		return
+	}
+	if runs == nil {
+		runs = []store.Run{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"history": runs,
+		"count":   len(runs),
+		"message": "Test history retrieved successfully",
+	})
+}
+
+// Add evidence metadata to a recorded result
+func addResultEvidence(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Result id must be a number",
+		})
+		return
+	}
+	log.Printf("Handling POST /api/results/%d/evidence request", id)
+
+	var evidence store.Evidence
+	if err := c.ShouldBindJSON(&evidence); err != nil {
+		log.Printf("Error binding JSON: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	if evidence.Filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Filename is required",
+		})
+		return
+	}
+
+	// The run must belong to an execution of the project of the route
+	run, err := requestTenant(c).results.GetRun(id)
+	if err != nil {
+		log.Printf("Error fetching result: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to store evidence",
+			"details": err.Error(),
+		})
+		return
+	}
+	if !issueInProject(c, run.ExecutionKey) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Result not found in project",
+			"details": fmt.Sprintf("result %d belongs to execution %s, not to project %s", id, run.ExecutionKey, requestProject(c)),
+		})
+		return
+	}
+
+	saved, err := requestTenant(c).results.AddEvidence(id, evidence)
+	if err != nil {
+		log.Printf("Error storing evidence: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, store.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to store evidence",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"evidence": saved,
+		"message":  "Evidence recorded successfully",
+	})
+}
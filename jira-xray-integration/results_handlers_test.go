@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recordPassAndFail records a passing TEST-1 and a failing TEST-2 in TEST-9
+func recordPassAndFail(t *testing.T, env *testEnv) {
+	t.Helper()
+	env.mustDo(t, http.StatusCreated, http.MethodPost, "/api/testexecutions/TEST-9/results",
+		`{"testResults":[{"testCaseKey":"TEST-1","status":"PASS","executionTime":1200},{"testCaseKey":"TEST-2","status":"FAIL","comment":"Reset email never arrived","defects":["TEST-7"]}]}`)
+}
+
+// wantInvalidResult checks that a request was rejected for the test result at index
+func wantInvalidResult(index int, problem string) func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+		body := decodeBody(t, rec)
+		details, _ := body["details"].(string)
+		if body["index"] != float64(index) || !strings.Contains(details, problem) {
+			t.Errorf("got %v, want result %d rejected with %q", body, index, problem)
+		}
+	}
+}
+
+func TestRecordTestResults(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "record",
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:   `{"testResults":[{"testCaseKey":"TEST-1","status":"PASS","stepResults":[{"index":1,"status":"PASS"},{"index":2,"status":"PASS"}]}]}`,
+			status: http.StatusCreated,
+			golden: "results_record",
+		},
+		{
+			name:   "record without status",
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:   `{"testResults":[{"testCaseKey":"TEST-1"}]}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "record an unknown status",
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:   `{"testResults":[{"testCaseKey":"TEST-1","status":"PASS"},{"testCaseKey":"TEST-2","status":"GREEN"}]}`,
+			status: http.StatusBadRequest,
+			check:  wantInvalidResult(1, `unknown status "GREEN"`),
+		},
+		{
+			name:   "record an unknown step status",
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:   `{"testResults":[{"testCaseKey":"TEST-1","status":"PASS","stepResults":[{"index":1,"status":"OK"}]}]}`,
+			status: http.StatusBadRequest,
+			check:  wantInvalidResult(0, `unknown status "OK" of step 1`),
+		},
+		{
+			name:   "record for an invalid key",
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:   `{"testResults":[{"testCaseKey":"TEST-1) OR (1=1","status":"PASS"}]}`,
+			status: http.StatusBadRequest,
+			check:  wantInvalidResult(0, "is not an issue key"),
+		},
+		{
+			name:   "record for a test of another project",
+			setup:  createPayTestCase,
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:   `{"testResults":[{"testCaseKey":"TEST-1","status":"PASS"},{"testCaseKey":"PAY-1","status":"FAIL"}]}`,
+			status: http.StatusBadRequest,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				wantInvalidResult(1, "PAY-1 is not an issue of project TEST")(t, env, rec)
+				if history, _ := resultStore.TestHistory("TEST-1", 0); len(history) != 0 {
+					t.Errorf("recorded %+v from a rejected request", history)
+				}
+			},
+		},
+		{
+			name:   "record without results",
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:   `{}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "record for an unknown execution",
+			method: http.MethodPost, path: "/api/testexecutions/TEST-999/results",
+			body:   `{"testResults":[{"testCaseKey":"TEST-1","status":"PASS"}]}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "record queued while Jira is unavailable",
+			setup:  func(t *testing.T, env *testEnv) { env.jira.FailNext(1, http.StatusServiceUnavailable) },
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:    `{"testResults":[{"testCaseKey":"TEST-1","status":"PASS"}]}`,
+			headers: []string{"Idempotency-Key", "results-1"},
+			status:  http.StatusAccepted,
+			golden:  "results_record_queued",
+		},
+		{
+			name: "record again for a stored execution without Jira",
+			setup: func(t *testing.T, env *testEnv) {
+				recordPassAndFail(t, env)
+				env.jira.FailNext(1, http.StatusServiceUnavailable)
+			},
+			method: http.MethodPost, path: "/api/testexecutions/TEST-9/results",
+			body:   `{"testResults":[{"testCaseKey":"TEST-2","status":"PASS"}]}`,
+			status: http.StatusCreated,
+		},
+	})
+}
+
+func TestTestCaseHistory(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "history",
+			setup:  recordPassAndFail,
+			method: http.MethodGet, path: "/api/testcases/TEST-2/history",
+			status: http.StatusOK,
+			golden: "history",
+		},
+		{
+			name: "history with limit",
+			setup: func(t *testing.T, env *testEnv) {
+				recordPassAndFail(t, env)
+				recordPassAndFail(t, env)
+			},
+			method: http.MethodGet, path: "/api/testcases/TEST-1/history?limit=1",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if count := decodeBody(t, rec)["count"]; count != 1.0 {
+					t.Errorf("got %v runs, want 1", count)
+				}
+			},
+		},
+		{
+			name:   "history of a test that never ran",
+			method: http.MethodGet, path: "/api/testcases/TEST-3/history",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if history, ok := decodeBody(t, rec)["history"].([]interface{}); !ok || len(history) != 0 {
+					t.Errorf("got history %v, want an empty list", history)
+				}
+			},
+		},
+		{
+			name:   "history with an invalid limit",
+			method: http.MethodGet, path: "/api/testcases/TEST-1/history?limit=-1",
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestAddResultEvidence(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "add",
+			setup:  recordPassAndFail,
+			method: http.MethodPost, path: "/api/results/2/evidence",
+			body:   `{"filename":"screenshot.png","contentType":"image/png","size":2048,"url":"https://files.example.com/screenshot.png"}`,
+			status: http.StatusCreated,
+			golden: "evidence_add",
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				evidence, err := resultStore.ListEvidence(2)
+				if err != nil || len(evidence) != 1 {
+					t.Errorf("got %d evidence records (%v), want 1", len(evidence), err)
+				}
+			},
+		},
+		{
+			name:   "add to an unknown result",
+			method: http.MethodPost, path: "/api/results/42/evidence",
+			body:   `{"filename":"log.txt"}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "add without filename",
+			setup:  recordPassAndFail,
+			method: http.MethodPost, path: "/api/results/1/evidence",
+			body:   `{"url":"https://files.example.com/log.txt"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "add with an invalid id",
+			method: http.MethodPost, path: "/api/results/first/evidence",
+			body:   `{"filename":"log.txt"}`,
+			status: http.StatusBadRequest,
+		},
+	})
+}
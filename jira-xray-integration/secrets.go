@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"jira-xray-integration/secrets"
+)
+
+// Sources of secrets, as reported in /api/info
+const (
+	secretSourceEnv        = "environment"
+	secretSourceConfigFile = "config file"
+	secretSourceFile       = "file " // followed by the path
+)
+
+// registeredSecretProviders are asked for secrets after the keyring and the
+// secret command. Builds add providers for further secret stores by calling
+// registerSecretProvider from an init function.
+var registeredSecretProviders []secrets.Provider
+
+// registerSecretProvider adds a provider for secrets that the environment,
+// the configuration file, the keyring and the secret command do not set
+func registerSecretProvider(p secrets.Provider) {
+	registeredSecretProviders = append(registeredSecretProviders, p)
+}
+
+// loadSecretProviders opens the keyring of KEYRING_FILE and sets up the
+// command of SECRETS_COMMAND, followed by the registered providers
+func loadSecretProviders(s settings) ([]secrets.Provider, error) {
+	var providers []secrets.Provider
+	if path := s.getEnvOrDefault("KEYRING_FILE", ""); path != "" {
+		keyring, err := openKeyring(s, path)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, keyring)
+	}
+	if command := s.getEnvOrDefault("SECRETS_COMMAND", ""); command != "" {
+		provider, err := secrets.NewCommand(command)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SECRETS_COMMAND: %w", err)
+		}
+		providers = append(providers, provider)
+	}
+	return append(providers, registeredSecretProviders...), nil
+}
+
+// openKeyring opens the keyring at path with KEYRING_PASSPHRASE, which may
+// itself come from a file through KEYRING_PASSPHRASE_FILE
+func openKeyring(s settings, path string) (*secrets.Keyring, error) {
+	passphrase, _, err := lookupSecret(s, "KEYRING_PASSPHRASE", nil)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("KEYRING_PASSPHRASE or KEYRING_PASSPHRASE_FILE is required with KEYRING_FILE")
+	}
+	keyring, err := secrets.OpenKeyring(path, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyring %s: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// secret reads a secret setting and records where it was found
+func (c *Config) secret(key string) (string, error) {
+	value, source, err := lookupSecret(c.settings, key, c.secretProviders)
+	if err != nil {
+		return "", err
+	}
+	if source != "" {
+		if c.SecretSources == nil {
+			c.SecretSources = make(map[string]string)
+		}
+		c.SecretSources[key] = source
+	}
+	return value, nil
+}
+
+// lookupSecret finds a secret setting in, by priority, the environment
+// variable key, the file named by key_FILE as Docker and Kubernetes mount
+// secrets, the configuration file and then providers. It returns the value
+// and where it was found, or two empty strings if it is not set anywhere.
+func lookupSecret(s settings, key string, providers []secrets.Provider) (value, source string, err error) {
+	value = s.getenv(key)
+	path := s.getEnvOrDefault(key+"_FILE", "")
+	switch {
+	case value != "" && path != "":
+		return "", "", fmt.Errorf("%s and %s_FILE are both set, use one of them", key, key)
+	case value != "":
+		return value, secretSourceEnv, nil
+	case path != "":
+		if value, err = secrets.ReadFile(path); err != nil {
+			return "", "", fmt.Errorf("%s_FILE: %w", key, err)
+		}
+		return value, secretSourceFile + path, nil
+	}
+	if value := s.file[key]; value != "" {
+		return value, secretSourceConfigFile, nil
+	}
+	for _, p := range providers {
+		value, ok, err := p.Lookup(key)
+		if err != nil {
+			return "", "", fmt.Errorf("%s from %s: %w", key, p.Name(), err)
+		}
+		if ok {
+			return value, p.Name(), nil
+		}
+	}
+	return "", "", nil
+}
+
+// secretSourceLines describes where each secret was found, never its value
+func (c *Config) secretSourceLines() []string {
+	var lines []string
+	for key, source := range c.SecretSources {
+		lines = append(lines, key+" from "+source)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// redactURL hides a password in the user info of a URL before it is shown
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// keyringCommand manages the secrets of the keyring at KEYRING_FILE:
+// set reads the value of a secret from in, delete removes one and list
+// prints the names stored. Values are never printed.
+func keyringCommand(configPath string, args []string, in io.Reader, out io.Writer) int {
+	s, _, err := loadSettings(configPath)
+	if err != nil {
+		fmt.Fprintf(out, "❌ %v\n", err)
+		return 1
+	}
+	path := s.getEnvOrDefault("KEYRING_FILE", "")
+	if path == "" {
+		fmt.Fprintln(out, "❌ KEYRING_FILE is not set")
+		return 1
+	}
+	keyring, err := openKeyring(s, path)
+	if err != nil {
+		fmt.Fprintf(out, "❌ %v\n", err)
+		return 1
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		for _, name := range keyring.Names() {
+			fmt.Fprintln(out, name)
+		}
+		return 0
+	case len(args) == 2 && args[0] == "set":
+		data, err := io.ReadAll(in)
+		value := strings.TrimRight(string(data), "\r\n")
+		if err != nil || value == "" {
+			fmt.Fprintf(out, "❌ Pipe the value of %s to stdin\n", args[1])
+			return 1
+		}
+		keyring.Set(args[1], value)
+	case len(args) == 2 && args[0] == "delete":
+		if !keyring.Delete(args[1]) {
+			fmt.Fprintf(out, "❌ %s is not in the keyring\n", args[1])
+			return 1
+		}
+	default:
+		fmt.Fprintf(out, "Unknown command %q\n", "keyring "+strings.Join(args, " "))
+		return 2
+	}
+	if err := keyring.Save(); err != nil {
+		fmt.Fprintf(out, "❌ %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(out, "✅ Keyring %s updated\n", path)
+	return 0
+}
@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandTimeout bounds how long a secret command may run
+const commandTimeout = 10 * time.Second
+
+// Command looks up secrets by running a command, such as the CLI of Vault
+// or a cloud secret manager. It implements Provider.
+type Command struct {
+	args []string
+}
+
+// NewCommand creates a provider running command, split on spaces without a
+// shell, with {name} replaced by the name of the secret:
+//
+//	vault kv get -field={name} secret/jira-xray
+//
+// The command's output is the secret; no output means it has none. A
+// command that fails is an error.
+func NewCommand(command string) (*Command, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty secret command")
+	}
+	if !strings.Contains(command, "{name}") {
+		return nil, fmt.Errorf("secret command %q must contain {name}", command)
+	}
+	return &Command{args: args}, nil
+}
+
+// Name implements Provider
+func (c *Command) Name() string {
+	return "command " + c.args[0]
+}
+
+// Lookup implements Provider
+func (c *Command) Lookup(name string) (string, bool, error) {
+	args := make([]string, len(c.args))
+	for i, arg := range c.args {
+		args[i] = strings.ReplaceAll(arg, "{name}", name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", false, fmt.Errorf("secret command %s failed for %s: %w: %s", args[0], name, err, strings.TrimSpace(stderr.String()))
+	}
+	value := strings.TrimRight(stdout.String(), "\r\n")
+	return value, value != "", nil
+}
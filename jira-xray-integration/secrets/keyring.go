@@ -0,0 +1,185 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// keyringVersion is the version of the keyring file format
+const keyringVersion = 1
+
+// scrypt parameters deriving the encryption key from the passphrase
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	keyLength    = 32 // AES-256
+	saltLength   = 16
+	keyringPerms = 0o600
+)
+
+// ErrWrongPassphrase is returned when a keyring cannot be decrypted with
+// the passphrase given, or its file was tampered with
+var ErrWrongPassphrase = errors.New("wrong keyring passphrase, or the keyring file is corrupt")
+
+// keyringFile is the file format: the secrets as a JSON object, encrypted
+// with AES-256-GCM under a key derived from the passphrase with scrypt
+type keyringFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Keyring is an encrypted file of named secrets, unlocked with a
+// passphrase. It implements Provider.
+type Keyring struct {
+	path       string
+	passphrase string
+	secrets    map[string]string
+}
+
+// OpenKeyring decrypts the keyring at path. A keyring that does not exist
+// yet opens empty and is created by Save.
+func OpenKeyring(path, passphrase string) (*Keyring, error) {
+	if passphrase == "" {
+		return nil, errors.New("a keyring passphrase is required")
+	}
+	k := &Keyring{path: path, passphrase: passphrase, secrets: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	if file.Version != keyringVersion || file.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported keyring %s: version %d, kdf %q", path, file.Version, file.KDF)
+	}
+	// The parameters decide how much memory and time deriving the key takes,
+	// so a file is not trusted to choose them
+	if file.N != scryptN || file.R != scryptR || file.P != scryptP {
+		return nil, fmt.Errorf("unsupported keyring %s: scrypt parameters n=%d, r=%d, p=%d", path, file.N, file.R, file.P)
+	}
+
+	aead, err := newAEAD(passphrase, file.Salt, file.N, file.R, file.P)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err := json.Unmarshal(plaintext, &k.secrets); err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return k, nil
+}
+
+// Name implements Provider
+func (k *Keyring) Name() string {
+	return "keyring"
+}
+
+// Lookup implements Provider
+func (k *Keyring) Lookup(name string) (string, bool, error) {
+	value, ok := k.secrets[name]
+	return value, ok, nil
+}
+
+// Set stores a secret; Save writes it to the file
+func (k *Keyring) Set(name, value string) {
+	k.secrets[name] = value
+}
+
+// Delete removes a secret and reports whether it was there; Save writes
+// the change to the file
+func (k *Keyring) Delete(name string) bool {
+	_, ok := k.secrets[name]
+	delete(k.secrets, name)
+	return ok
+}
+
+// Names returns the names of the secrets in the keyring, sorted
+func (k *Keyring) Names() []string {
+	names := make([]string, 0, len(k.secrets))
+	for name := range k.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the secrets with a fresh salt and nonce and replaces the
+// keyring file, readable by its owner only
+func (k *Keyring) Save() error {
+	plaintext, err := json.Marshal(k.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to encode keyring: %w", err)
+	}
+	file := keyringFile{Version: keyringVersion, KDF: "scrypt", N: scryptN, R: scryptR, P: scryptP}
+	file.Salt = make([]byte, saltLength)
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := newAEAD(k.passphrase, file.Salt, file.N, file.R, file.P)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode keyring: %w", err)
+	}
+	if dir := filepath.Dir(k.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create keyring directory: %w", err)
+		}
+	}
+	// Write next to the keyring and rename, so a failed write leaves the old one
+	tmp := k.path + ".tmp"
+	if err := os.WriteFile(tmp, data, keyringPerms); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := os.Rename(tmp, k.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	return nil
+}
+
+// newAEAD derives the keyring key from the passphrase
+func newAEAD(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyring key parameters: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,34 @@
+// Package secrets looks up credentials such as Jira API tokens outside the
+// environment: in files mounted by Docker or Kubernetes, in an encrypted
+// keyring file, or in an external secret store behind the Provider
+// interface.
+//
+// Secret values are never part of an error message returned by this
+// package, so errors can be logged as they are.
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Provider looks up secrets by the name of the setting they stand in for,
+// such as JIRA_API_TOKEN
+type Provider interface {
+	// Name describes the provider in logs and /api/info, e.g. "keyring"
+	Name() string
+	// Lookup returns the secret called name; ok is false if the provider
+	// does not have it
+	Lookup(name string) (value string, ok bool, err error)
+}
+
+// ReadFile reads a secret from a file such as /run/secrets/jira_api_token,
+// without the trailing newline editors and echo add
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
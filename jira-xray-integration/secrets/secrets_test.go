@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "keyring.json")
+	keyring, err := OpenKeyring(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := keyring.Names(); len(names) != 0 {
+		t.Fatalf("new keyring has secrets %v", names)
+	}
+	keyring.Set("JIRA_API_TOKEN", "s3cr3t-token")
+	keyring.Set("JIRA_TENANT_DC_ACCESS_TOKEN", "pat")
+	if err := keyring.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t-token") || strings.Contains(string(data), "JIRA_API_TOKEN") {
+		t.Errorf("keyring file is not encrypted:\n%s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("keyring file has mode %v, want 0600", info.Mode().Perm())
+	}
+
+	reopened, err := OpenKeyring(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := reopened.Lookup("JIRA_API_TOKEN"); err != nil || !ok || value != "s3cr3t-token" {
+		t.Errorf("got %q, %v, %v", value, ok, err)
+	}
+	if _, ok, _ := reopened.Lookup("JIRA_ACCESS_TOKEN"); ok {
+		t.Error("found a secret that was never set")
+	}
+	if !reopened.Delete("JIRA_TENANT_DC_ACCESS_TOKEN") || reopened.Delete("JIRA_TENANT_DC_ACCESS_TOKEN") {
+		t.Error("delete should report whether the secret was there")
+	}
+
+	if _, err := OpenKeyring(path, "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("got error %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := OpenKeyring(path, ""); err == nil {
+		t.Error("expected an error without a passphrase")
+	}
+}
+
+func TestKeyringRejectsOtherScryptParameters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	keyring, err := OpenKeyring(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, edit := range map[string]func(*keyringFile){
+		"huge n":  func(f *keyringFile) { f.N = 1 << 40 },
+		"small n": func(f *keyringFile) { f.N = 2 },
+		"huge r":  func(f *keyringFile) { f.R = 1 << 20 },
+		"huge p":  func(f *keyringFile) { f.P = 1 << 20 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var file keyringFile
+			if err := json.Unmarshal(data, &file); err != nil {
+				t.Fatal(err)
+			}
+			edit(&file)
+			edited, _ := json.Marshal(file)
+			if err := os.WriteFile(path, edited, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := OpenKeyring(path, "correct horse"); err == nil || !strings.Contains(err.Error(), "scrypt parameters") {
+				t.Errorf("got error %v, want the scrypt parameters rejected", err)
+			}
+		})
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jira_api_token")
+	if err := os.WriteFile(path, []byte("token with spaces \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := ReadFile(path); err != nil || value != "token with spaces " {
+		t.Errorf("got %q, %v", value, err)
+	}
+	if _, err := ReadFile(path + ".missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if _, err := NewCommand("vault kv get secret/jira"); err == nil {
+		t.Error("expected an error for a command without {name}")
+	}
+
+	command, err := NewCommand("sh -c {name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := command.Lookup("echo token"); err != nil || !ok || value != "token" {
+		t.Errorf("got %q, %v, %v", value, ok, err)
+	}
+	if _, ok, err := command.Lookup("true"); err != nil || ok {
+		t.Errorf("no output: got %v, %v, want no secret", ok, err)
+	}
+	if _, _, err := command.Lookup("exit 3"); err == nil {
+		t.Error("expected an error for a failing command")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TLS client authentication modes
+const (
+	tlsClientAuthRequire  = "require"  // clients must present a certificate signed by TLS_CLIENT_CA_FILE
+	tlsClientAuthOptional = "optional" // a certificate is verified if the client presents one
+)
+
+// readHeaderTimeout bounds how long a client may take to send request headers
+const readHeaderTimeout = 10 * time.Second
+
+// newHTTPServer creates the API server with the timeouts of c and, with
+// TLS_CERT_FILE, TLS
+func newHTTPServer(c *Config, handler http.Handler) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:              ":" + c.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       c.HTTPReadTimeout,
+		WriteTimeout:      c.HTTPWriteTimeout,
+		IdleTimeout:       c.HTTPIdleTimeout,
+	}
+	if c.TLSCertFile == "" {
+		return srv, nil
+	}
+	tlsConfig, err := serverTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = tlsConfig
+	return srv, nil
+}
+
+// serverTLSConfig loads the certificate of the server and, for mutual TLS,
+// the CAs client certificates must be signed by
+func serverTLSConfig(c *Config) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.TLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(c.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS_CLIENT_CA_FILE: %w", err)
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE %s holds no PEM certificates", c.TLSClientCAFile)
+	}
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if c.TLSClientAuth == tlsClientAuthOptional {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// serve accepts connections until the server is shut down
+func serve(srv *http.Server) error {
+	var err error
+	if srv.TLSConfig != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// shutdown stops accepting requests and waits for those in flight, then stops
+// the background work and replays what the outbox queued meanwhile. It gives
+// up waiting once ctx is done.
+func shutdown(ctx context.Context, srv *http.Server, stopBackground func()) {
+	log.Println("🛑 Shutting down, waiting for requests in flight")
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("⚠️  Requests still running were cut off: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stopBackground()
+		if outboxWorker.Enabled() {
+			if replayed, err := outboxWorker.Replay(); err != nil {
+				log.Printf("⚠️  Final outbox replay failed: %v", err)
+			} else if replayed > 0 {
+				log.Printf("Replayed %d outbox entries before shutdown", replayed)
+			}
+		}
+	}()
+	select {
+	case <-done:
+		log.Println("✅ Shutdown complete")
+	case <-ctx.Done():
+		log.Println("⚠️  Gave up waiting for the sync engines and the outbox")
+	}
+}
+
+// limitBody rejects request bodies over MAX_BODY_SIZE, or MAX_UPLOAD_SIZE
+// for the routes that take file uploads
+func limitBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := currentConfig()
+		limit, setting := config.MaxBodySize, "MAX_BODY_SIZE"
+		if isUploadRoute(c.FullPath()) {
+			limit, setting = config.MaxUploadSize, "MAX_UPLOAD_SIZE"
+		}
+		if limit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error":   "Request body too large",
+				"details": fmt.Sprintf("the body has %d bytes, at most %d are accepted; see %s", c.Request.ContentLength, limit, setting),
+			})
+			return
+		}
+		// Bodies of unknown length fail to read once they pass the limit
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// isUploadRoute reports whether a route takes file uploads, which may be
+// larger than other request bodies
+func isUploadRoute(route string) bool {
+	return strings.HasSuffix(route, "/testcases/import") || strings.HasSuffix(route, "/import/gotest")
+}
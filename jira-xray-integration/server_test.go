@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitBody(t *testing.T) {
+	env := newTestEnv(t)
+	currentConfig().MaxBodySize = 64
+	currentConfig().MaxUploadSize = 1 << 20
+
+	large := `{"summary":"` + strings.Repeat("x", 100) + `"}`
+	rec := env.do(http.MethodPost, "/api/testcases", large)
+	if rec.Code != http.StatusRequestEntityTooLarge || decodeBody(t, rec)["error"] != "Request body too large" {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	output := strings.Repeat(`{"Action":"output","Package":"example.com/pkg","Output":"ok\n"}`+"\n", 10)
+	if rec := env.do(http.MethodPost, "/api/import/gotest", output); rec.Code == http.StatusRequestEntityTooLarge {
+		t.Errorf("uploads should be allowed up to MAX_UPLOAD_SIZE: %s", rec.Body.String())
+	}
+
+	currentConfig().MaxBodySize = 0
+	if rec := env.do(http.MethodPost, "/api/testcases", large); rec.Code == http.StatusRequestEntityTooLarge {
+		t.Errorf("0 should turn the limit off: %s", rec.Body.String())
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for value, want := range map[string]int64{"0": 0, "512": 512, "10B": 10, "512KB": 512 << 10, "1mb": 1 << 20, "2 GB": 2 << 30} {
+		if got, err := parseSize(value); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "MB", "1.5MB", "-1", "10 TB"} {
+		if _, err := parseSize(value); err == nil {
+			t.Errorf("parseSize(%q): expected an error", value)
+		}
+	}
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	chdirWithoutDotEnv(t)
+	t.Setenv("BACKEND", "memory")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "10m")
+	t.Setenv("MAX_UPLOAD_SIZE", "100MB")
+
+	c, err := LoadConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.HTTPReadTimeout != time.Minute || c.HTTPWriteTimeout != 10*time.Minute || c.ShutdownTimeout != 30*time.Second {
+		t.Errorf("got timeouts read %s, write %s, shutdown %s", c.HTTPReadTimeout, c.HTTPWriteTimeout, c.ShutdownTimeout)
+	}
+	if c.MaxBodySize != 1<<20 || c.MaxUploadSize != 100<<20 {
+		t.Errorf("got body size %d, upload size %d", c.MaxBodySize, c.MaxUploadSize)
+	}
+
+	for name, env := range map[string][]string{
+		"TLS_CERT_FILE and TLS_KEY_FILE must be set together": {"TLS_CERT_FILE", "server.crt"},
+		"TLS_CLIENT_CA_FILE needs TLS_CERT_FILE":              {"TLS_CLIENT_CA_FILE", "ca.crt"},
+		"invalid TLS_CLIENT_AUTH":                             {"TLS_CLIENT_AUTH", "sometimes"},
+		"invalid MAX_BODY_SIZE":                               {"MAX_BODY_SIZE", "big"},
+	} {
+		t.Run(env[0], func(t *testing.T) {
+			t.Setenv(env[0], env[1])
+			if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("got error %v, want %q", err, name)
+			}
+		})
+	}
+}
+
+// testCA is a certificate authority issuing certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for name, valid for localhost
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTestFile writes content to name in dir and returns its path
+func writeTestFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMutualTLS(t *testing.T) {
+	env := newTestEnv(t)
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	serverCert, serverKey := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	c := currentConfig()
+	c.TLSCertFile = writeTestFile(t, dir, "server.crt", serverCert)
+	c.TLSKeyFile = writeTestFile(t, dir, "server.key", serverKey)
+	c.TLSClientCAFile = writeTestFile(t, dir, "ca.crt", ca.pem)
+	c.TLSClientAuth = tlsClientAuthRequire
+
+	srv, err := newHTTPServer(c, tenantPaths(env.router))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(tls.NewListener(listener, srv.TLSConfig))
+	t.Cleanup(func() { srv.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.pem)
+	get := func(certificates ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates}}}
+		return client.Get("https://" + listener.Addr().String() + "/api/health")
+	}
+
+	if resp, err := get(); err == nil {
+		resp.Body.Close()
+		t.Error("a client without a certificate should be refused")
+	}
+	clientCert, clientKey := ca.issue(t, "ci", x509.ExtKeyUsageClientAuth)
+	pair, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := get(pair)
+	if err != nil {
+		t.Fatalf("a client with a certificate from the CA should connect: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d", resp.StatusCode)
+	}
+
+	c.TLSClientAuth = tlsClientAuthOptional
+	if tlsConfig, err := serverTLSConfig(c); err != nil || tlsConfig.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("optional client auth: got %v, %v", tlsConfig, err)
+	}
+	c.TLSClientCAFile = writeTestFile(t, dir, "empty.crt", []byte("not a certificate"))
+	if _, err := serverTLSConfig(c); err == nil {
+		t.Error("expected an error for a CA file without certificates")
+	}
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	newTestEnv(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("imported"))
+	})
+	srv := &http.Server{Handler: handler}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(listener)
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Post("http://"+listener.Addr().String()+"/api/import/gotest", "application/json", nil)
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body := make([]byte, 8)
+		n, _ := resp.Body.Read(body)
+		responses <- string(body[:n])
+	}()
+	<-started
+
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		shutdown(context.Background(), srv, func() { close(stopped) })
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("background work stopped before the request in flight finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if got := <-responses; got != "imported" {
+		t.Errorf("the request in flight got %q", got)
+	}
+	<-done
+	select {
+	case <-stopped:
+	default:
+		t.Error("shutdown should stop the background work")
+	}
+}
@@ -0,0 +1,102 @@
+// Package spreadsheet reads and writes tabular data as CSV or Excel (xlsx) files.
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format identifies a spreadsheet file format
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// ParseFormat validates a format name such as "csv" or "xlsx"
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case FormatCSV, FormatXLSX:
+		return Format(name), nil
+	default:
+		return "", fmt.Errorf("unsupported format %q, use csv or xlsx", name)
+	}
+}
+
+// ContentType returns the MIME type for the format
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Read reads all rows from r in the given format
+func Read(r io.Reader, format Format) ([][]string, error) {
+	if format == FormatXLSX {
+		return ReadXLSX(r)
+	}
+	return ReadCSV(r)
+}
+
+// Write writes rows to w in the given format
+func Write(w io.Writer, format Format, rows [][]string) error {
+	if format == FormatXLSX {
+		return WriteXLSX(w, "Sheet1", rows)
+	}
+	return WriteCSV(w, rows)
+}
+
+// ReadCSV reads all rows of a CSV file. Rows may have differing numbers of columns.
+func ReadCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %w", err)
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = unescapeFormula(row[i])
+		}
+	}
+	return rows, nil
+}
+
+// WriteCSV writes rows as CSV. Cells that a spreadsheet would run as a
+// formula are written as text.
+func WriteCSV(w io.Writer, rows [][]string) error {
+	escaped := make([][]string, len(rows))
+	for i, row := range rows {
+		escaped[i] = make([]string, len(row))
+		for j, value := range row {
+			escaped[i][j] = escapeFormula(value)
+		}
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(escaped); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
+
+// escapeFormula prefixes a cell that starts like a formula with a quote, which
+// spreadsheets take as a text marker, so that exported Jira fields cannot run
+// as formulas when the file is opened. Cells already starting with a quote get
+// one more so that reading them back is lossless.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@'", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeFormula removes the text marker added by escapeFormula
+func unescapeFormula(value string) string {
+	return strings.TrimPrefix(value, "'")
+}
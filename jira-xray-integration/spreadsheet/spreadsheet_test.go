@@ -0,0 +1,175 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// zipFiles builds a zip archive of the given file names and contents
+func zipFiles(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// workbook returns the files of an xlsx workbook whose first sheet has the
+// given sheetData
+func workbook(sheetData string) map[string]string {
+	return map[string]string{
+		"xl/workbook.xml": xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheets><sheet name="Sheet1" sheetId="1"/></sheets></workbook>`,
+		"xl/worksheets/sheet1.xml": xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+			sheetData + `</sheetData></worksheet>`,
+	}
+}
+
+var roundTripRows = [][]string{
+	{"Key", "Summary", "Description"},
+	{"TEST-1", "Grüße & <tags>", "line one\nline two"},
+	{"TEST-2", "", "  spaced  "},
+	{"TEST-3", "=HYPERLINK(\"http://example.com\")", "+1", "-1", "@SUM(A1)", "'quoted"},
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, roundTripRows); err != nil {
+		t.Fatal(err)
+	}
+	for _, escaped := range []string{`"'=HYPERLINK(""http://example.com"")"`, ",'+1,'-1,'@SUM(A1),''quoted"} {
+		if !strings.Contains(buf.String(), escaped) {
+			t.Errorf("output does not contain %s:\n%s", escaped, buf.String())
+		}
+	}
+
+	rows, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, roundTripRows) {
+		t.Errorf("got rows %q, want %q", rows, roundTripRows)
+	}
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, "Tests & more", roundTripRows); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, _ := f.Open()
+		var sheet bytes.Buffer
+		sheet.ReadFrom(rc)
+		rc.Close()
+		if !strings.Contains(sheet.String(), `<t xml:space="preserve">&#39;=HYPERLINK(`) {
+			t.Errorf("formula cell is not escaped:\n%s", sheet.String())
+		}
+	}
+
+	rows, err := ReadXLSX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, roundTripRows) {
+		t.Errorf("got rows %q, want %q", rows, roundTripRows)
+	}
+}
+
+func TestReadXLSXSparseCells(t *testing.T) {
+	files := workbook(`<row r="2"><c r="C2" t="inlineStr"><is><t>c</t></is></c><c r="A2" t="s"><v>0</v></c></row><row><c><v>7</v></c></row>`)
+	files["xl/sharedStrings.xml"] = xml.Header + `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><r><t>rich </t></r><r><t>text</t></r></si></sst>`
+	rows, err := ReadXLSX(bytes.NewReader(zipFiles(t, files)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{nil, {"rich text", "", "c"}, {"7"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got rows %q, want %q", rows, want)
+	}
+}
+
+func TestReadXLSXRejectsMalformedFiles(t *testing.T) {
+	noSheets := workbook("")
+	noSheets["xl/workbook.xml"] = xml.Header + `<workbook><sheets/></workbook>`
+	noWorksheet := workbook("")
+	delete(noWorksheet, "xl/worksheets/sheet1.xml")
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"not a zip", []byte("Key,Summary\n"), "invalid xlsx file"},
+		{"truncated zip", zipFiles(t, workbook(""))[:40], "invalid xlsx file"},
+		{"missing workbook", zipFiles(t, map[string]string{"xl/worksheets/sheet1.xml": ""}), "missing xl/workbook.xml"},
+		{"no sheets", zipFiles(t, noSheets), "has no sheets"},
+		{"missing worksheet", zipFiles(t, noWorksheet), "missing xl/worksheets/sheet1.xml"},
+		{"invalid XML", zipFiles(t, workbook(`<row><c>`)), "failed to parse xl/worksheets/sheet1.xml"},
+		{"shared string out of range", zipFiles(t, workbook(`<row><c r="A1" t="s"><v>3</v></c></row>`)), "invalid shared string reference in cell A1"},
+		{"column past XFD", zipFiles(t, workbook(`<row><c r="XFE1"><v>1</v></c></row>`)), `invalid cell reference "XFE1"`},
+		{"reference without column", zipFiles(t, workbook(`<row><c r="12"><v>1</v></c></row>`)), `invalid cell reference "12"`},
+		{"row past the last", zipFiles(t, workbook(`<row r="1048577"><c><v>1</v></c></row>`)), "invalid row number 1048577"},
+		{"negative row", zipFiles(t, workbook(`<row r="-1"><c><v>1</v></c></row>`)), "invalid row number -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadXLSX(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadXLSXRejectsLargeEntries(t *testing.T) {
+	// Compresses to well under the upload limit
+	files := workbook(strings.Repeat(" ", maxXLSXEntrySize+1))
+	data := zipFiles(t, files)
+	if len(data) > maxXLSXSize {
+		t.Fatalf("test workbook is %d bytes, too large to upload", len(data))
+	}
+
+	_, err := ReadXLSX(bytes.NewReader(data))
+	if err == nil || !strings.Contains(err.Error(), "xlsx entry xl/worksheets/sheet1.xml exceeds") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestReadXLSXRejectsLargeUploads(t *testing.T) {
+	if _, err := ReadXLSX(bytes.NewReader(make([]byte, maxXLSXSize+1))); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", maxXLSXColumns - 1: "XFD"} {
+		if got := columnName(index); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", index, got, want)
+		}
+		if got, err := columnIndex(want + "1"); err != nil || got != index {
+			t.Errorf("columnIndex(%s1) = %d, %v, want %d", want, got, err, index)
+		}
+	}
+}
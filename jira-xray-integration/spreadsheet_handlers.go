@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"jira-xray-integration/importer"
+	"jira-xray-integration/jira"
+	"jira-xray-integration/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCaseImportResult reports what happened to one imported test case
+type testCaseImportResult struct {
+	Row      int            `json:"row"`
+	Action   string         `json:"action"` // create, update
+	TestCase *jira.TestCase `json:"testCase"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// Export test cases as CSV or Excel
+func exportTestCases(c *gin.Context) {
+	log.Println("Handling GET /api/testcases/export request")
+
+	format, err := spreadsheet.ParseFormat(c.DefaultQuery("format", "csv"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid export format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	mapping, err := parseColumnMapping(c.Query("mapping"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid column mapping",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	testCases, err := jiraFor(c).ListTestCases()
+	if err != nil {
+		log.Printf("Error fetching test cases: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to fetch test cases",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rows, err := importer.TestCaseSheet(testCases, mapping)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid column mapping",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := spreadsheet.Write(&buf, format, rows); err != nil {
+		log.Printf("Error writing spreadsheet: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to export test cases",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "testcases."+string(format)))
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+// Import test cases from CSV or Excel, creating new ones and updating existing ones by key
+func importTestCases(c *gin.Context) {
+	log.Println("Handling POST /api/testcases/import request")
+	started := time.Now()
+
+	body, filename, err := readUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid upload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	formatName := c.Query("format")
+	if formatName == "" {
+		formatName = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+	if formatName == "" {
+		formatName = string(spreadsheet.FormatCSV)
+	}
+	format, err := spreadsheet.ParseFormat(formatName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid import format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	mappingParam := c.Query("mapping")
+	if mappingParam == "" {
+		mappingParam = c.PostForm("mapping")
+	}
+	mapping, err := parseColumnMapping(mappingParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid column mapping",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rows, err := spreadsheet.Read(bytes.NewReader(body), format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read spreadsheet",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	parsed, rowErrors := importer.ParseTestCaseRows(rows, mapping)
+	dryRun := c.Query("dryRun") == "true"
+	headers, _ := mapping.Resolve()
+
+	// Resolve which rows update existing test cases before writing anything
+	plan := make([]testCaseImportResult, 0, len(parsed))
+	for _, row := range parsed {
+		tc := row.TestCase
+		result := testCaseImportResult{Row: row.Row, Action: "create", TestCase: &tc}
+		if tc.Key != "" {
+			if !issueInProject(c, tc.Key) {
+				rowErrors = append(rowErrors, importer.RowError{Row: row.Row, Column: headers[importer.FieldKey],
+					Message: fmt.Sprintf("%s is not an issue of project %s", tc.Key, requestProject(c))})
+				continue
+			}
+			existing, err := jiraFor(c).GetTestCase(tc.Key)
+			if err != nil {
+				message := err.Error()
+				var typeErr *jira.IssueTypeError
+				switch {
+				case errors.As(err, &typeErr):
+					// Only tests are updated, never the project's stories or bugs
+				case jira.IsNotFound(err):
+					message = fmt.Sprintf("test case %s does not exist", tc.Key)
+				}
+				rowErrors = append(rowErrors, importer.RowError{Row: row.Row, Column: headers[importer.FieldKey], Message: message})
+				continue
+			}
+			merged := importer.MergeTestCase(*existing, tc)
+			result.Action = "update"
+			result.TestCase = &merged
+		}
+		plan = append(plan, result)
+	}
+
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+
+	if dryRun || len(rowErrors) > 0 {
+		status := http.StatusOK
+		message := "Dry run completed, no changes were made"
+		if len(rowErrors) > 0 {
+			status = http.StatusUnprocessableEntity
+			message = "Validation failed, no changes were made"
+		}
+		c.JSON(status, gin.H{
+			"dryRun":    dryRun,
+			"valid":     len(rowErrors) == 0,
+			"testCases": plan,
+			"errors":    rowErrors,
+			"message":   message,
+		})
+		return
+	}
+
+	created, updated, failed := 0, 0, 0
+	for i := range plan {
+		var saved *jira.TestCase
+		var err error
+		if plan[i].Action == "update" {
+			saved, err = jiraFor(c).UpdateTestCase(plan[i].TestCase)
+		} else {
+			saved, err = jiraFor(c).CreateTestCase(plan[i].TestCase)
+		}
+		if err != nil {
+			log.Printf("Error importing row %d: %v", plan[i].Row, err)
+			plan[i].Error = err.Error()
+			failed++
+			continue
+		}
+		plan[i].TestCase = saved
+		if plan[i].Action == "update" {
+			updated++
+		} else {
+			created++
+		}
+	}
+
+	if created+updated > 0 {
+		requestTenant(c).syncEngine.Trigger()
+	}
+	observeImport(importSourceTestCases, started, map[string]int{"created": created, "updated": updated, "failed": failed})
+
+	status := http.StatusOK
+	message := "Test cases imported successfully"
+	if failed > 0 {
+		status = http.StatusMultiStatus
+		message = "Some test cases could not be imported"
+	}
+	c.JSON(status, gin.H{
+		"testCases": plan,
+		"created":   created,
+		"updated":   updated,
+		"failed":    failed,
+		"message":   message,
+	})
+}
+
+// readUpload returns the uploaded file from a multipart "file" field, or the raw request body
+func readUpload(c *gin.Context) ([]byte, string, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", fmt.Errorf("multipart upload must contain a \"file\" field: %w", err)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+
+		body, err := io.ReadAll(file)
+		return body, fileHeader.Filename, err
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(body) == 0 {
+		return nil, "", fmt.Errorf("request body is empty")
+	}
+	return body, "", nil
+}
+
+// parseColumnMapping decodes a JSON object mapping test case fields to column headers
+func parseColumnMapping(value string) (importer.ColumnMapping, error) {
+	mapping := importer.ColumnMapping{}
+	if value == "" {
+		return mapping, nil
+	}
+	if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+		return nil, fmt.Errorf("mapping must be a JSON object of field to column header: %w", err)
+	}
+	if _, err := mapping.Resolve(); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
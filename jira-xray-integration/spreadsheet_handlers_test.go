@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/jira"
+)
+
+const importHeader = "Key,Summary,Description,Priority,Labels,Components,Test Type,Step Action,Step Data,Step Expected Result\n"
+
+func TestExportTestCases(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "csv",
+			method: http.MethodGet, path: "/api/testcases/export",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+				if lines[0]+"\n" != importHeader {
+					t.Errorf("got header %q", lines[0])
+				}
+				// TEST-1 has two steps, so it takes two rows
+				if len(lines) != 5 || !strings.HasPrefix(lines[1], "TEST-1,Login functionality test") {
+					t.Errorf("got rows:\n%s", rec.Body.String())
+				}
+				if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "testcases.csv") {
+					t.Errorf("got Content-Disposition %q", cd)
+				}
+			},
+		},
+		{
+			name:   "xlsx",
+			method: http.MethodGet, path: "/api/testcases/export?format=xlsx",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !bytes.HasPrefix(rec.Body.Bytes(), []byte("PK")) {
+					t.Errorf("response is not a zip archive")
+				}
+			},
+		},
+		{
+			name:   "custom mapping",
+			method: http.MethodGet, path: `/api/testcases/export?mapping={"summary":"Title"}`,
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !strings.HasPrefix(rec.Body.String(), "Key,Title,") {
+					t.Errorf("got header %q", strings.SplitN(rec.Body.String(), "\n", 2)[0])
+				}
+			},
+		},
+		{
+			name:   "unsupported format",
+			method: http.MethodGet, path: "/api/testcases/export?format=ods",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown mapped field",
+			method: http.MethodGet, path: `/api/testcases/export?mapping={"owner":"Owner"}`,
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestImportTestCases(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "dry run",
+			method: http.MethodPost, path: "/api/testcases/import?dryRun=true",
+			body:   importHeader + ",Search by name,,Low,search,,Manual,Type a name,Ada,Matches are listed\n",
+			status: http.StatusOK,
+			golden: "import_dry_run",
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if _, err := env.jira.Backend.Issue("TEST-11"); err == nil {
+					t.Errorf("dry run created a test case")
+				}
+			},
+		},
+		{
+			name:   "create and update",
+			method: http.MethodPost, path: "/api/testcases/import",
+			body:   importHeader + ",Search by name,,Low,search,,Manual,,,\nTEST-2,Password reset by email,,,,,,,,\n",
+			status: http.StatusOK,
+			golden: "import_create_update",
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				issue, err := env.jira.Backend.Issue("TEST-2")
+				if err != nil || issue.Fields.Summary != "Password reset by email" {
+					t.Errorf("TEST-2 was not updated: %v", err)
+				}
+				if _, err := env.jira.Backend.Issue("TEST-11"); err != nil {
+					t.Errorf("new test case was not created: %v", err)
+				}
+			},
+		},
+		{
+			name:   "unknown key",
+			method: http.MethodPost, path: "/api/testcases/import",
+			body:   importHeader + "TEST-99,Missing test,,,,,,,,\n",
+			status: http.StatusUnprocessableEntity,
+			golden: "import_unknown_key",
+		},
+		{
+			name:   "key of another project",
+			setup:  addPayProject,
+			method: http.MethodPost, path: "/api/testcases/import",
+			body:   importHeader + "PAY-1,Pay by card,,,,,,,,\n",
+			status: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !strings.Contains(rec.Body.String(), "PAY-1 is not an issue of project TEST") {
+					t.Errorf("got body %s", rec.Body.String())
+				}
+			},
+		},
+		{
+			name: "issue that is not a test",
+			setup: func(t *testing.T, env *testEnv) {
+				if _, err := env.jira.Backend.CreateIssue(jira.IssueFields{Summary: "User can sign out", IssueType: jira.IssueType{Name: "Story"}}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			method: http.MethodPost, path: "/api/testcases/import",
+			body:   importHeader + "TEST-11,Overwritten story,,,,,,,,\n",
+			status: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !strings.Contains(rec.Body.String(), "TEST-11 is a Story, not a Test") {
+					t.Errorf("got body %s", rec.Body.String())
+				}
+				if issue, _ := env.jira.Backend.Issue("TEST-11"); issue.Fields.Summary != "User can sign out" {
+					t.Errorf("the story was overwritten: %q", issue.Fields.Summary)
+				}
+			},
+		},
+		{
+			name:   "row without summary",
+			method: http.MethodPost, path: "/api/testcases/import",
+			body:   importHeader + ",,Only a description,,,,,,,\n",
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "partial failure",
+			setup: func(t *testing.T, env *testEnv) {
+				env.jira.InjectFault(jiratestFault(http.MethodPost, "/issue", http.StatusBadRequest))
+			},
+			method: http.MethodPost, path: "/api/testcases/import",
+			body:   importHeader + ",Rejected test,,,,,,,,\nTEST-1,Login with valid credentials,,,,,,,,\n",
+			status: http.StatusMultiStatus,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := decodeBody(t, rec)
+				if body["failed"] != 1.0 || body["updated"] != 1.0 {
+					t.Errorf("got %v failed and %v updated, want 1 each", body["failed"], body["updated"])
+				}
+			},
+		},
+		{
+			name:   "empty body",
+			method: http.MethodPost, path: "/api/testcases/import",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unsupported format",
+			method: http.MethodPost, path: "/api/testcases/import?format=ods",
+			body:   importHeader,
+			status: http.StatusBadRequest,
+		},
+	})
+}
+
+func TestImportTestCasesMultipart(t *testing.T) {
+	env := newTestEnv(t)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "tests.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("Title\nSearch by name\n"))
+	form.WriteField("mapping", `{"summary":"Title"}`)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/testcases/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body.String())
+	}
+	if created := decodeBody(t, rec)["created"]; created != 1.0 {
+		t.Errorf("got %v created, want 1", created)
+	}
+}
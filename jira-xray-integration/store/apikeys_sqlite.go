@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const apiKeyColumns = `id, name, prefix, hash, scopes, created_at, last_used_at`
+
+// CreateAPIKey implements APIKeys
+func (s *SQLiteStore) CreateAPIKey(key APIKey) (*APIKey, error) {
+	scopes, err := json.Marshal(nonNil(key.Scopes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scopes: %w", err)
+	}
+	res, err := s.db.Exec(`
+		INSERT INTO api_keys (name, prefix, hash, scopes, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		key.Name, key.Prefix, key.Hash, string(scopes), formatTime(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return s.apiKey(`id = ?`, id)
+}
+
+// ListAPIKeys implements APIKeys
+func (s *SQLiteStore) ListAPIKeys() ([]APIKey, error) {
+	rows, err := s.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list API keys: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// APIKeyByHash implements APIKeys
+func (s *SQLiteStore) APIKeyByHash(hash string) (*APIKey, error) {
+	return s.apiKey(`hash = ?`, hash)
+}
+
+// TouchAPIKey implements APIKeys
+func (s *SQLiteStore) TouchAPIKey(id int64, usedAt time.Time) error {
+	if _, err := s.db.Exec(`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, formatTime(usedAt), id); err != nil {
+		return fmt.Errorf("failed to update API key %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteAPIKey implements APIKeys
+func (s *SQLiteStore) DeleteAPIKey(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM api_keys WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("API key %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) apiKey(where string, arg interface{}) (*APIKey, error) {
+	key, err := scanAPIKey(s.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE `+where, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("API key: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key: %w", err)
+	}
+	return key, nil
+}
+
+func scanAPIKey(row scanner) (*APIKey, error) {
+	var key APIKey
+	var scopes, createdAt, lastUsedAt string
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &createdAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("invalid scopes of API key %d: %w", key.ID, err)
+	}
+	key.CreatedAt = parseTime(createdAt)
+	key.LastUsedAt = parseTime(lastUsedAt)
+	return &key, nil
+}
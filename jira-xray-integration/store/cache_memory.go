@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"jira-xray-integration/jira"
+)
+
+// memoryCache is the in-memory issue cache of one Jira tenant
+type memoryCache struct {
+	mu         sync.RWMutex
+	issues     map[string]jira.JiraIssue
+	syncStates map[string]SyncState
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		issues:     make(map[string]jira.JiraIssue),
+		syncStates: make(map[string]SyncState),
+	}
+}
+
+// PutIssues implements IssueCache
+func (s *memoryCache) PutIssues(issues []jira.JiraIssue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, issue := range issues {
+		s.issues[issue.Key] = issue
+	}
+	return nil
+}
+
+// DeleteIssues implements IssueCache
+func (s *memoryCache) DeleteIssues(keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.issues, key)
+	}
+	return nil
+}
+
+// CachedIssue implements IssueCache
+func (s *memoryCache) CachedIssue(key string) (*jira.JiraIssue, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	issue, ok := s.issues[key]
+	if !ok {
+		return nil, fmt.Errorf("cached issue %s: %w", key, ErrNotFound)
+	}
+	return &issue, nil
+}
+
+// CachedIssues implements IssueCache
+func (s *memoryCache) CachedIssues(issueType string) ([]jira.JiraIssue, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var issues []jira.JiraIssue
+	for _, issue := range s.issues {
+		if issue.Fields.IssueType.Name == issueType {
+			issues = append(issues, issue)
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Key < issues[j].Key })
+	return issues, nil
+}
+
+// SyncStates implements IssueCache
+func (s *memoryCache) SyncStates() ([]SyncState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := make([]SyncState, 0, len(s.syncStates))
+	for _, state := range s.syncStates {
+		state.IssueCount = 0
+		for _, issue := range s.issues {
+			if issue.Fields.IssueType.Name == state.IssueType {
+				state.IssueCount++
+			}
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].IssueType < states[j].IssueType })
+	return states, nil
+}
+
+// SaveSyncState implements IssueCache
+func (s *memoryCache) SaveSyncState(state SyncState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.syncStates[state.IssueType] = state
+	return nil
+}
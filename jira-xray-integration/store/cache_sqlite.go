@@ -0,0 +1,147 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"jira-xray-integration/jira"
+)
+
+// sqliteCache is the issue cache of one Jira tenant in the issues and
+// sync_state tables
+type sqliteCache struct {
+	db     *sql.DB
+	tenant string
+}
+
+// TenantCache implements Store
+func (s *SQLiteStore) TenantCache(tenant string) IssueCache {
+	return &sqliteCache{db: s.db, tenant: tenant}
+}
+
+// PutIssues implements IssueCache
+func (s *sqliteCache) PutIssues(issues []jira.JiraIssue) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to cache issues: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := formatTime(time.Now())
+	for _, issue := range issues {
+		data, err := json.Marshal(issue)
+		if err != nil {
+			return fmt.Errorf("failed to encode issue %s: %w", issue.Key, err)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO issues (tenant, key, issue_type, updated, data, cached_at) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (tenant, key) DO UPDATE SET
+				issue_type = excluded.issue_type,
+				updated = excluded.updated,
+				data = excluded.data,
+				cached_at = excluded.cached_at`,
+			s.tenant, issue.Key, issue.Fields.IssueType.Name, issue.Fields.Updated, string(data), now); err != nil {
+			return fmt.Errorf("failed to cache issue %s: %w", issue.Key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteIssues implements IssueCache
+func (s *sqliteCache) DeleteIssues(keys []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to delete cached issues: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, key := range keys {
+		if _, err := tx.Exec(`DELETE FROM issues WHERE tenant = ? AND key = ?`, s.tenant, key); err != nil {
+			return fmt.Errorf("failed to delete cached issue %s: %w", key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// CachedIssue implements IssueCache
+func (s *sqliteCache) CachedIssue(key string) (*jira.JiraIssue, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM issues WHERE tenant = ? AND key = ?`, s.tenant, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("cached issue %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached issue %s: %w", key, err)
+	}
+
+	var issue jira.JiraIssue
+	if err := json.Unmarshal([]byte(data), &issue); err != nil {
+		return nil, fmt.Errorf("failed to decode cached issue %s: %w", key, err)
+	}
+	return &issue, nil
+}
+
+// CachedIssues implements IssueCache
+func (s *sqliteCache) CachedIssues(issueType string) ([]jira.JiraIssue, error) {
+	rows, err := s.db.Query(`SELECT data FROM issues WHERE tenant = ? AND issue_type = ? ORDER BY key`, s.tenant, issueType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached issues: %w", err)
+	}
+	defer rows.Close()
+
+	var issues []jira.JiraIssue
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read cached issues: %w", err)
+		}
+		var issue jira.JiraIssue
+		if err := json.Unmarshal([]byte(data), &issue); err != nil {
+			return nil, fmt.Errorf("failed to decode cached issue: %w", err)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+// SyncStates implements IssueCache
+func (s *sqliteCache) SyncStates() ([]SyncState, error) {
+	rows, err := s.db.Query(`
+		SELECT st.issue_type, st.last_sync, st.last_full_sync,
+			(SELECT COUNT(*) FROM issues i WHERE i.tenant = st.tenant AND i.issue_type = st.issue_type)
+		FROM sync_state st WHERE st.tenant = ? ORDER BY st.issue_type`, s.tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	defer rows.Close()
+
+	var states []SyncState
+	for rows.Next() {
+		var state SyncState
+		var lastSync, lastFullSync string
+		if err := rows.Scan(&state.IssueType, &lastSync, &lastFullSync, &state.IssueCount); err != nil {
+			return nil, fmt.Errorf("failed to read sync state: %w", err)
+		}
+		state.LastSync = parseTime(lastSync)
+		state.LastFullSync = parseTime(lastFullSync)
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+// SaveSyncState implements IssueCache
+func (s *sqliteCache) SaveSyncState(state SyncState) error {
+	_, err := s.db.Exec(`
+		INSERT INTO sync_state (tenant, issue_type, last_sync, last_full_sync) VALUES (?, ?, ?, ?)
+		ON CONFLICT (tenant, issue_type) DO UPDATE SET
+			last_sync = excluded.last_sync,
+			last_full_sync = excluded.last_full_sync`,
+		s.tenant, state.IssueType, formatTime(state.LastSync), formatTime(state.LastFullSync))
+	if err != nil {
+		return fmt.Errorf("failed to save sync state for %s: %w", state.IssueType, err)
+	}
+	return nil
+}
@@ -0,0 +1,560 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"jira-xray-integration/jira"
+)
+
+// MemoryStore is an in-memory Store for tests and demo mode. Data is lost on restart.
+type MemoryStore struct {
+	*memoryResults // executions and runs of the default tenant
+	*memoryCache   // issue cache of the default tenant
+
+	mu         sync.RWMutex
+	ids        *memoryIDs
+	results    map[string]*memoryResults // executions and runs of tenants other than the default
+	tenants    map[string]*memoryCache   // issue caches of tenants other than the default
+	outbox     []OutboxEntry
+	nextOutbox int64
+	apiKeys    []APIKey
+	nextAPIKey int64
+	bindings   []RoleBinding
+	nextBindID int64
+}
+
+// memoryResults are the executions and runs of one Jira tenant
+type memoryResults struct {
+	mu         sync.RWMutex
+	ids        *memoryIDs
+	executions map[string]*memoryExecution
+	runs       []Run
+	evidence   []Evidence
+	batches    map[string]bool // result batches recorded by AddResultsOnce
+}
+
+// memoryIDs hands out run and evidence IDs, unique across tenants as in SQLite
+type memoryIDs struct {
+	runs     atomic.Int64
+	evidence atomic.Int64
+}
+
+type memoryExecution struct {
+	execution jira.TestExecution
+	createdAt time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	ids := &memoryIDs{}
+	return &MemoryStore{
+		memoryResults: newMemoryResults(ids),
+		memoryCache:   newMemoryCache(),
+		ids:           ids,
+		results:       make(map[string]*memoryResults),
+		tenants:       make(map[string]*memoryCache),
+	}
+}
+
+func newMemoryResults(ids *memoryIDs) *memoryResults {
+	return &memoryResults{
+		ids:        ids,
+		executions: make(map[string]*memoryExecution),
+		batches:    make(map[string]bool),
+	}
+}
+
+// SaveExecution implements Results
+func (s *memoryResults) SaveExecution(execution *jira.TestExecution) error {
+	if execution.Key == "" {
+		return fmt.Errorf("execution key is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *execution
+	saved.TestResults = nil
+	saved.TestCases = append([]string(nil), execution.TestCases...)
+
+	if existing, ok := s.executions[execution.Key]; ok {
+		existing.execution = saved
+		return nil
+	}
+	s.executions[execution.Key] = &memoryExecution{execution: saved, createdAt: time.Now()}
+	return nil
+}
+
+// GetExecution implements Results
+func (s *memoryResults) GetExecution(key string) (*jira.TestExecution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.executions[key]
+	if !ok {
+		return nil, fmt.Errorf("execution %s: %w", key, ErrNotFound)
+	}
+
+	execution := stored.execution
+	var runs []Run
+	for _, run := range s.runs {
+		if run.ExecutionKey == key {
+			runs = append(runs, run)
+		}
+	}
+	execution.TestResults = latestPerTest(runs)
+	return &execution, nil
+}
+
+// ListExecutions implements Results
+func (s *memoryResults) ListExecutions() ([]jira.TestExecution, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored := make([]*memoryExecution, 0, len(s.executions))
+	for _, e := range s.executions {
+		stored = append(stored, e)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].createdAt.After(stored[j].createdAt) })
+
+	executions := make([]jira.TestExecution, len(stored))
+	for i, e := range stored {
+		executions[i] = e.execution
+	}
+	return executions, nil
+}
+
+// AddResults implements Results
+func (s *memoryResults) AddResults(executionKey string, results []jira.TestResult) ([]Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addResults(executionKey, results)
+}
+
+// AddResultsOnce implements Results
+func (s *memoryResults) AddResultsOnce(executionKey, batch string, results []jira.TestResult) ([]Run, error) {
+	if batch == "" {
+		return nil, fmt.Errorf("result batch is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.batches[batch] {
+		return nil, nil
+	}
+	added, err := s.addResults(executionKey, results)
+	if err == nil {
+		s.batches[batch] = true
+	}
+	return added, err
+}
+
+// addResults records results; s.mu must be held
+func (s *memoryResults) addResults(executionKey string, results []jira.TestResult) ([]Run, error) {
+	stored, ok := s.executions[executionKey]
+	if !ok {
+		return nil, fmt.Errorf("execution %s: %w", executionKey, ErrNotFound)
+	}
+
+	now := time.Now()
+	added := make([]Run, 0, len(results))
+	for _, result := range results {
+		run := Run{
+			ID:           s.ids.runs.Add(1),
+			ExecutionKey: executionKey,
+			Environment:  stored.execution.Environment,
+			FixVersion:   stored.execution.FixVersion,
+			TestPlan:     stored.execution.TestPlan,
+			CreatedAt:    now,
+			TestResult:   result,
+		}
+		if run.ExecutedOn.IsZero() {
+			run.ExecutedOn = now
+		}
+		for _, evidence := range evidenceFromURLs(result.Evidence) {
+			evidence.ID = s.ids.evidence.Add(1)
+			evidence.RunID = run.ID
+			evidence.CreatedAt = now
+			s.evidence = append(s.evidence, evidence)
+			run.EvidenceFiles = append(run.EvidenceFiles, evidence)
+		}
+		s.runs = append(s.runs, run)
+		added = append(added, run)
+	}
+	return added, nil
+}
+
+// TestHistory implements Results
+func (s *memoryResults) TestHistory(testCaseKey string, limit int) ([]Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var runs []Run
+	for _, run := range s.runs {
+		if run.TestCaseKey == testCaseKey {
+			run.EvidenceFiles = s.runEvidence(run.ID)
+			run.Evidence = evidenceURLs(run.EvidenceFiles)
+			runs = append(runs, run)
+		}
+	}
+	sortNewestFirst(runs)
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// GetRun implements Results
+func (s *memoryResults) GetRun(id int64) (*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, run := range s.runs {
+		if run.ID == id {
+			run.EvidenceFiles = s.runEvidence(run.ID)
+			run.Evidence = evidenceURLs(run.EvidenceFiles)
+			return &run, nil
+		}
+	}
+	return nil, fmt.Errorf("run %d: %w", id, ErrNotFound)
+}
+
+// LatestResult implements Results
+func (s *memoryResults) LatestResult(testCaseKey string, filter ResultFilter) (*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest *Run
+	for i := range s.runs {
+		run := &s.runs[i]
+		if run.TestCaseKey != testCaseKey || !filter.matches(run) {
+			continue
+		}
+		if latest == nil || newer(run, latest) {
+			latest = run
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("result for %s: %w", testCaseKey, ErrNotFound)
+	}
+
+	result := *latest
+	result.EvidenceFiles = s.runEvidence(result.ID)
+	result.Evidence = evidenceURLs(result.EvidenceFiles)
+	return &result, nil
+}
+
+// AddEvidence implements Results
+func (s *memoryResults) AddEvidence(runID int64, evidence Evidence) (*Evidence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := false
+	for _, run := range s.runs {
+		if run.ID == runID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("run %d: %w", runID, ErrNotFound)
+	}
+
+	evidence.ID = s.ids.evidence.Add(1)
+	evidence.RunID = runID
+	evidence.CreatedAt = time.Now()
+	s.evidence = append(s.evidence, evidence)
+	return &evidence, nil
+}
+
+// ListEvidence implements Results
+func (s *memoryResults) ListEvidence(runID int64) ([]Evidence, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.runEvidence(runID), nil
+}
+
+// TenantCache implements Store
+func (s *MemoryStore) TenantCache(tenant string) IssueCache {
+	if tenant == "" {
+		return s.memoryCache
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cache, ok := s.tenants[tenant]
+	if !ok {
+		cache = newMemoryCache()
+		s.tenants[tenant] = cache
+	}
+	return cache
+}
+
+// TenantResults implements Store
+func (s *MemoryStore) TenantResults(tenant string) Results {
+	if tenant == "" {
+		return s.memoryResults
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results, ok := s.results[tenant]
+	if !ok {
+		results = newMemoryResults(s.ids)
+		s.results[tenant] = results
+	}
+	return results
+}
+
+// EnqueueOutbox implements Outbox
+func (s *MemoryStore) EnqueueOutbox(entry OutboxEntry) (*OutboxEntry, bool, error) {
+	if entry.IdempotencyKey == "" {
+		return nil, false, fmt.Errorf("outbox entry needs an idempotency key")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.outbox {
+		if existing.IdempotencyKey == entry.IdempotencyKey {
+			return &existing, false, nil
+		}
+	}
+
+	now := time.Now()
+	s.nextOutbox++
+	entry.ID = s.nextOutbox
+	entry.Status = OutboxPending
+	entry.Attempts = 0
+	entry.ResultKey = ""
+	if entry.NextAttemptAt.IsZero() {
+		entry.NextAttemptAt = now
+	}
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	s.outbox = append(s.outbox, entry)
+	return &entry, true, nil
+}
+
+// GetOutboxEntry implements Outbox
+func (s *MemoryStore) GetOutboxEntry(id int64) (*OutboxEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.outbox {
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+	return nil, fmt.Errorf("outbox entry %d: %w", id, ErrNotFound)
+}
+
+// FindOutboxEntry implements Outbox
+func (s *MemoryStore) FindOutboxEntry(idempotencyKey string) (*OutboxEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.outbox {
+		if entry.IdempotencyKey == idempotencyKey {
+			return &entry, nil
+		}
+	}
+	return nil, fmt.Errorf("outbox entry %q: %w", idempotencyKey, ErrNotFound)
+}
+
+// ListOutbox implements Outbox
+func (s *MemoryStore) ListOutbox(status string) ([]OutboxEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := []OutboxEntry{}
+	for _, entry := range s.outbox {
+		if status == "" || entry.Status == status {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// CountOutbox implements Outbox
+func (s *MemoryStore) CountOutbox() ([]OutboxCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var counts []OutboxCount
+	index := make(map[OutboxCount]int)
+	for _, entry := range s.outbox {
+		group := OutboxCount{Tenant: entry.Tenant, Project: entry.Project, Status: entry.Status}
+		i, ok := index[group]
+		if !ok {
+			i = len(counts)
+			index[group] = i
+			counts = append(counts, group)
+		}
+		counts[i].Count++
+	}
+	return counts, nil
+}
+
+// UpdateOutboxEntry implements Outbox
+func (s *MemoryStore) UpdateOutboxEntry(entry OutboxEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.outbox {
+		if s.outbox[i].ID == entry.ID {
+			stored := &s.outbox[i]
+			stored.Status = entry.Status
+			stored.Attempts = entry.Attempts
+			stored.LastError = entry.LastError
+			stored.ResultKey = entry.ResultKey
+			stored.NextAttemptAt = entry.NextAttemptAt
+			stored.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return fmt.Errorf("outbox entry %d: %w", entry.ID, ErrNotFound)
+}
+
+// DeleteOutboxEntry implements Outbox
+func (s *MemoryStore) DeleteOutboxEntry(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.outbox {
+		if s.outbox[i].ID == id {
+			s.outbox = append(s.outbox[:i], s.outbox[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("outbox entry %d: %w", id, ErrNotFound)
+}
+
+// CreateAPIKey implements APIKeys
+func (s *MemoryStore) CreateAPIKey(key APIKey) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.apiKeys {
+		if existing.Hash == key.Hash {
+			return nil, fmt.Errorf("failed to create API key: hash already exists")
+		}
+	}
+	s.nextAPIKey++
+	key.ID = s.nextAPIKey
+	key.Scopes = append([]string{}, key.Scopes...)
+	key.CreatedAt = time.Now()
+	key.LastUsedAt = time.Time{}
+	s.apiKeys = append(s.apiKeys, key)
+	return &key, nil
+}
+
+// ListAPIKeys implements APIKeys
+func (s *MemoryStore) ListAPIKeys() ([]APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]APIKey{}, s.apiKeys...), nil
+}
+
+// APIKeyByHash implements APIKeys
+func (s *MemoryStore) APIKeyByHash(hash string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, key := range s.apiKeys {
+		if key.Hash == hash {
+			return &key, nil
+		}
+	}
+	return nil, fmt.Errorf("API key: %w", ErrNotFound)
+}
+
+// TouchAPIKey implements APIKeys
+func (s *MemoryStore) TouchAPIKey(id int64, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.apiKeys {
+		if s.apiKeys[i].ID == id {
+			s.apiKeys[i].LastUsedAt = usedAt
+		}
+	}
+	return nil
+}
+
+// DeleteAPIKey implements APIKeys
+func (s *MemoryStore) DeleteAPIKey(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.apiKeys {
+		if s.apiKeys[i].ID == id {
+			s.apiKeys = append(s.apiKeys[:i], s.apiKeys[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("API key %d: %w", id, ErrNotFound)
+}
+
+// CreateRoleBinding implements RoleBindings
+func (s *MemoryStore) CreateRoleBinding(binding RoleBinding) (*RoleBinding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextBindID++
+	binding.ID = s.nextBindID
+	binding.CreatedAt = time.Now()
+	s.bindings = append(s.bindings, binding)
+	return &binding, nil
+}
+
+// ListRoleBindings implements RoleBindings
+func (s *MemoryStore) ListRoleBindings() ([]RoleBinding, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]RoleBinding{}, s.bindings...), nil
+}
+
+// DeleteRoleBinding implements RoleBindings
+func (s *MemoryStore) DeleteRoleBinding(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.bindings {
+		if s.bindings[i].ID == id {
+			s.bindings = append(s.bindings[:i], s.bindings[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("role binding %d: %w", id, ErrNotFound)
+}
+
+// Ping implements Store
+func (s *MemoryStore) Ping() error {
+	return nil
+}
+
+// Close implements Store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+func (s *memoryResults) runEvidence(runID int64) []Evidence {
+	var evidence []Evidence
+	for _, e := range s.evidence {
+		if e.RunID == runID {
+			evidence = append(evidence, e)
+		}
+	}
+	return evidence
+}
+
+func (f ResultFilter) matches(run *Run) bool {
+	return (f.FixVersion == "" || f.FixVersion == run.FixVersion) &&
+		(f.TestPlan == "" || f.TestPlan == run.TestPlan) &&
+		(f.Environment == "" || f.Environment == run.Environment)
+}
+
+// newer orders runs by execution time, then by insertion
+func newer(a, b *Run) bool {
+	if a.ExecutedOn.Equal(b.ExecutedOn) {
+		return a.ID > b.ID
+	}
+	return a.ExecutedOn.After(b.ExecutedOn)
+}
+
+func sortNewestFirst(runs []Run) {
+	sort.SliceStable(runs, func(i, j int) bool { return newer(&runs[i], &runs[j]) })
+}
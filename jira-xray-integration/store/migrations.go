@@ -0,0 +1,309 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migrations are applied in order, each in its own transaction. Append new
+// migrations to the end; never edit one that has been released.
+var migrations = []string{
+	// 1: executions, results, step results and evidence metadata
+	`
+	CREATE TABLE executions (
+		key              TEXT PRIMARY KEY,
+		summary          TEXT NOT NULL DEFAULT '',
+		description      TEXT NOT NULL DEFAULT '',
+		status           TEXT NOT NULL DEFAULT '',
+		execution_status TEXT NOT NULL DEFAULT '',
+		environment      TEXT NOT NULL DEFAULT '',
+		fix_version      TEXT NOT NULL DEFAULT '',
+		test_plan        TEXT NOT NULL DEFAULT '',
+		executed_by      TEXT NOT NULL DEFAULT '',
+		test_cases       TEXT NOT NULL DEFAULT '[]',
+		start_date       TEXT NOT NULL DEFAULT '',
+		end_date         TEXT NOT NULL DEFAULT '',
+		created_at       TEXT NOT NULL,
+		updated_at       TEXT NOT NULL
+	);
+
+	CREATE TABLE results (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		execution_key  TEXT NOT NULL REFERENCES executions(key) ON DELETE CASCADE,
+		test_case_key  TEXT NOT NULL,
+		status         TEXT NOT NULL,
+		comment        TEXT NOT NULL DEFAULT '',
+		execution_time INTEGER NOT NULL DEFAULT 0,
+		executed_by    TEXT NOT NULL DEFAULT '',
+		executed_on    TEXT NOT NULL,
+		defects        TEXT NOT NULL DEFAULT '[]',
+		created_at     TEXT NOT NULL
+	);
+	CREATE INDEX results_test_case ON results(test_case_key, executed_on);
+	CREATE INDEX results_execution ON results(execution_key);
+
+	CREATE TABLE step_results (
+		result_id     INTEGER NOT NULL REFERENCES results(id) ON DELETE CASCADE,
+		step_index    INTEGER NOT NULL,
+		status        TEXT NOT NULL,
+		actual_result TEXT NOT NULL DEFAULT '',
+		comment       TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (result_id, step_index)
+	);
+
+	CREATE TABLE evidence (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		result_id    INTEGER NOT NULL REFERENCES results(id) ON DELETE CASCADE,
+		filename     TEXT NOT NULL,
+		content_type TEXT NOT NULL DEFAULT '',
+		size         INTEGER NOT NULL DEFAULT 0,
+		url          TEXT NOT NULL DEFAULT '',
+		created_at   TEXT NOT NULL
+	);
+	CREATE INDEX evidence_result ON evidence(result_id);
+	`,
+	// 2: issue cache and sync state for the Jira sync engine
+	`
+	CREATE TABLE issues (
+		key        TEXT PRIMARY KEY,
+		issue_type TEXT NOT NULL,
+		updated    TEXT NOT NULL DEFAULT '',
+		data       TEXT NOT NULL,
+		cached_at  TEXT NOT NULL
+	);
+	CREATE INDEX issues_type ON issues(issue_type, key);
+
+	CREATE TABLE sync_state (
+		issue_type     TEXT PRIMARY KEY,
+		last_sync      TEXT NOT NULL,
+		last_full_sync TEXT NOT NULL DEFAULT '',
+		issue_count    INTEGER NOT NULL DEFAULT 0
+	);
+	`,
+	// 3: outbox of writes waiting to be replayed to Jira
+	`
+	CREATE TABLE outbox (
+		id              INTEGER PRIMARY KEY AUTOINCREMENT,
+		idempotency_key TEXT NOT NULL UNIQUE,
+		operation       TEXT NOT NULL,
+		target          TEXT NOT NULL DEFAULT '',
+		payload         TEXT NOT NULL,
+		status          TEXT NOT NULL,
+		attempts        INTEGER NOT NULL DEFAULT 0,
+		last_error      TEXT NOT NULL DEFAULT '',
+		result_key      TEXT NOT NULL DEFAULT '',
+		next_attempt_at TEXT NOT NULL,
+		created_at      TEXT NOT NULL,
+		updated_at      TEXT NOT NULL
+	);
+	CREATE INDEX outbox_status ON outbox(status, id);
+	`,
+	// 4: API keys for inbound authentication
+	`
+	CREATE TABLE api_keys (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		name         TEXT NOT NULL,
+		prefix       TEXT NOT NULL,
+		hash         TEXT NOT NULL UNIQUE,
+		scopes       TEXT NOT NULL DEFAULT '[]',
+		created_at   TEXT NOT NULL,
+		last_used_at TEXT NOT NULL DEFAULT ''
+	);
+	`,
+	// 5: role bindings for access control per project
+	`
+	CREATE TABLE role_bindings (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		role       TEXT NOT NULL,
+		project    TEXT NOT NULL,
+		subject    TEXT NOT NULL DEFAULT '',
+		claim      TEXT NOT NULL DEFAULT '',
+		value      TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL
+	);
+	`,
+	// 6: project of queued writes, for servers with several projects
+	`
+	ALTER TABLE outbox ADD COLUMN project TEXT NOT NULL DEFAULT '';
+	`,
+	// 7: issue cache and sync state per Jira tenant, and tenant of queued writes.
+	// Cached issues so far belong to the default tenant.
+	`
+	ALTER TABLE issues RENAME TO issues_v2;
+	CREATE TABLE issues (
+		tenant     TEXT NOT NULL DEFAULT '',
+		key        TEXT NOT NULL,
+		issue_type TEXT NOT NULL,
+		updated    TEXT NOT NULL DEFAULT '',
+		data       TEXT NOT NULL,
+		cached_at  TEXT NOT NULL,
+		PRIMARY KEY (tenant, key)
+	);
+	INSERT INTO issues (key, issue_type, updated, data, cached_at)
+		SELECT key, issue_type, updated, data, cached_at FROM issues_v2;
+	DROP TABLE issues_v2;
+	CREATE INDEX issues_type ON issues(tenant, issue_type, key);
+
+	ALTER TABLE sync_state RENAME TO sync_state_v2;
+	CREATE TABLE sync_state (
+		tenant         TEXT NOT NULL DEFAULT '',
+		issue_type     TEXT NOT NULL,
+		last_sync      TEXT NOT NULL,
+		last_full_sync TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (tenant, issue_type)
+	);
+	INSERT INTO sync_state (issue_type, last_sync, last_full_sync)
+		SELECT issue_type, last_sync, last_full_sync FROM sync_state_v2;
+	DROP TABLE sync_state_v2;
+
+	ALTER TABLE outbox ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+	`,
+	// 8: batches of results recorded once, so a replay that crashed after
+	// recording its results does not record them again
+	`
+	CREATE TABLE result_batches (
+		batch         TEXT PRIMARY KEY,
+		execution_key TEXT NOT NULL,
+		created_at    TEXT NOT NULL
+	);
+	`,
+	// 9: executions and their runs per Jira tenant, as tenants may use the
+	// same issue keys. Those stored so far belong to the default tenant.
+	`
+	CREATE TABLE executions_v9 (
+		tenant           TEXT NOT NULL DEFAULT '',
+		key              TEXT NOT NULL,
+		summary          TEXT NOT NULL DEFAULT '',
+		description      TEXT NOT NULL DEFAULT '',
+		status           TEXT NOT NULL DEFAULT '',
+		execution_status TEXT NOT NULL DEFAULT '',
+		environment      TEXT NOT NULL DEFAULT '',
+		fix_version      TEXT NOT NULL DEFAULT '',
+		test_plan        TEXT NOT NULL DEFAULT '',
+		executed_by      TEXT NOT NULL DEFAULT '',
+		test_cases       TEXT NOT NULL DEFAULT '[]',
+		start_date       TEXT NOT NULL DEFAULT '',
+		end_date         TEXT NOT NULL DEFAULT '',
+		created_at       TEXT NOT NULL,
+		updated_at       TEXT NOT NULL,
+		PRIMARY KEY (tenant, key)
+	);
+	INSERT INTO executions_v9 (key, summary, description, status, execution_status, environment, fix_version,
+		test_plan, executed_by, test_cases, start_date, end_date, created_at, updated_at)
+		SELECT key, summary, description, status, execution_status, environment, fix_version,
+			test_plan, executed_by, test_cases, start_date, end_date, created_at, updated_at FROM executions;
+
+	CREATE TABLE results_v9 (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant         TEXT NOT NULL DEFAULT '',
+		execution_key  TEXT NOT NULL,
+		test_case_key  TEXT NOT NULL,
+		status         TEXT NOT NULL,
+		comment        TEXT NOT NULL DEFAULT '',
+		execution_time INTEGER NOT NULL DEFAULT 0,
+		executed_by    TEXT NOT NULL DEFAULT '',
+		executed_on    TEXT NOT NULL,
+		defects        TEXT NOT NULL DEFAULT '[]',
+		created_at     TEXT NOT NULL,
+		UNIQUE (tenant, id),
+		FOREIGN KEY (tenant, execution_key) REFERENCES executions_v9(tenant, key) ON DELETE CASCADE
+	);
+	INSERT INTO results_v9 (id, execution_key, test_case_key, status, comment, execution_time,
+		executed_by, executed_on, defects, created_at)
+		SELECT id, execution_key, test_case_key, status, comment, execution_time,
+			executed_by, executed_on, defects, created_at FROM results;
+
+	CREATE TABLE step_results_v9 (
+		tenant        TEXT NOT NULL DEFAULT '',
+		result_id     INTEGER NOT NULL,
+		step_index    INTEGER NOT NULL,
+		status        TEXT NOT NULL,
+		actual_result TEXT NOT NULL DEFAULT '',
+		comment       TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (tenant, result_id, step_index),
+		FOREIGN KEY (tenant, result_id) REFERENCES results_v9(tenant, id) ON DELETE CASCADE
+	);
+	INSERT INTO step_results_v9 (result_id, step_index, status, actual_result, comment)
+		SELECT result_id, step_index, status, actual_result, comment FROM step_results;
+
+	CREATE TABLE evidence_v9 (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		tenant       TEXT NOT NULL DEFAULT '',
+		result_id    INTEGER NOT NULL,
+		filename     TEXT NOT NULL,
+		content_type TEXT NOT NULL DEFAULT '',
+		size         INTEGER NOT NULL DEFAULT 0,
+		url          TEXT NOT NULL DEFAULT '',
+		created_at   TEXT NOT NULL,
+		UNIQUE (tenant, id),
+		FOREIGN KEY (tenant, result_id) REFERENCES results_v9(tenant, id) ON DELETE CASCADE
+	);
+	INSERT INTO evidence_v9 (id, result_id, filename, content_type, size, url, created_at)
+		SELECT id, result_id, filename, content_type, size, url, created_at FROM evidence;
+
+	CREATE TABLE result_batches_v9 (
+		tenant        TEXT NOT NULL DEFAULT '',
+		batch         TEXT NOT NULL,
+		execution_key TEXT NOT NULL,
+		created_at    TEXT NOT NULL,
+		PRIMARY KEY (tenant, batch)
+	);
+	INSERT INTO result_batches_v9 (batch, execution_key, created_at)
+		SELECT batch, execution_key, created_at FROM result_batches;
+
+	DROP TABLE evidence;
+	DROP TABLE step_results;
+	DROP TABLE results;
+	DROP TABLE executions;
+	DROP TABLE result_batches;
+	ALTER TABLE executions_v9 RENAME TO executions;
+	ALTER TABLE results_v9 RENAME TO results;
+	ALTER TABLE step_results_v9 RENAME TO step_results;
+	ALTER TABLE evidence_v9 RENAME TO evidence;
+	ALTER TABLE result_batches_v9 RENAME TO result_batches;
+	CREATE INDEX results_test_case ON results(tenant, test_case_key, executed_on);
+	CREATE INDEX results_execution ON results(tenant, execution_key);
+	CREATE INDEX evidence_result ON evidence(tenant, result_id);
+	`,
+}
+
+// migrate brings the schema up to date, recording applied versions in schema_migrations
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, len(migrations))
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to start migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			version, formatTime(time.Now())); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", version, err)
+		}
+		log.Printf("Applied storage migration %d", version)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"jira-xray-integration/jira"
+)
+
+// schemaVersion returns the latest migration recorded in a database file
+func schemaVersion(t *testing.T, path string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestMigrateNewDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "store.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if version := schemaVersion(t, path); version != len(migrations) {
+		t.Errorf("got schema version %d, want %d", version, len(migrations))
+	}
+}
+
+func TestMigrateKeepsDataOnReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveExecution(&jira.TestExecution{Key: "TEST-10", TestCases: []string{"TEST-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddResultsOnce("TEST-10", "outbox-1", []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if version := schemaVersion(t, path); version != len(migrations) {
+		t.Errorf("got schema version %d after reopening, want %d", version, len(migrations))
+	}
+	execution, err := s.GetExecution("TEST-10")
+	if err != nil || len(execution.TestResults) != 1 {
+		t.Fatalf("got execution %+v, %v after reopening", execution, err)
+	}
+	if runs, err := s.AddResultsOnce("TEST-10", "outbox-1", execution.TestResults); err != nil || len(runs) != 0 {
+		t.Errorf("got runs %+v, %v for a batch recorded before reopening", runs, err)
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, '')`, len(migrations)+1); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if _, err := OpenSQLite(path); err == nil {
+		t.Error("expected an error for a schema newer than the build")
+	}
+}
+
+func TestMigrateMovesResultsToDefaultTenant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A database as version 8 left it
+	statements := append([]string{`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)`}, migrations[:8]...)
+	statements = append(statements,
+		`INSERT INTO schema_migrations (version, applied_at) VALUES (1, ''), (2, ''), (3, ''), (4, ''), (5, ''), (6, ''), (7, ''), (8, '')`,
+		`INSERT INTO executions (key, environment, created_at, updated_at) VALUES ('EXEC-1', 'staging', '', '')`,
+		`INSERT INTO results (id, execution_key, test_case_key, status, executed_on, created_at) VALUES (7, 'EXEC-1', 'TEST-1', 'FAIL', '', '')`,
+		`INSERT INTO step_results (result_id, step_index, status) VALUES (7, 1, 'FAIL')`,
+		`INSERT INTO evidence (result_id, filename, created_at) VALUES (7, 'log.txt', '')`,
+		`INSERT INTO result_batches (batch, execution_key, created_at) VALUES ('outbox-1', 'EXEC-1', '')`,
+	)
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%v in:\n%s", err, statement)
+		}
+	}
+	db.Close()
+
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	run, err := s.GetRun(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ExecutionKey != "EXEC-1" || run.Environment != "staging" || len(run.StepResults) != 1 || len(run.EvidenceFiles) != 1 {
+		t.Errorf("got run %+v", run)
+	}
+	if runs, err := s.AddResultsOnce("EXEC-1", "outbox-1", []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass}}); err != nil || len(runs) != 0 {
+		t.Errorf("got runs %+v, %v for a batch recorded before the migration", runs, err)
+	}
+	if _, err := s.TenantResults("dc").GetExecution("EXEC-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, want the execution to stay with the default tenant", err)
+	}
+	// New runs do not reuse the IDs of migrated ones
+	added, err := s.AddResults("EXEC-1", []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass}})
+	if err != nil || added[0].ID <= 7 {
+		t.Errorf("got runs %+v, %v", added, err)
+	}
+}
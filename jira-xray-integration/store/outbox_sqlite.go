@@ -0,0 +1,156 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const outboxColumns = `id, idempotency_key, operation, tenant, project, target, payload, status, attempts,
+	last_error, result_key, next_attempt_at, created_at, updated_at`
+
+// EnqueueOutbox implements Outbox
+func (s *SQLiteStore) EnqueueOutbox(entry OutboxEntry) (*OutboxEntry, bool, error) {
+	if entry.IdempotencyKey == "" {
+		return nil, false, fmt.Errorf("outbox entry needs an idempotency key")
+	}
+
+	now := time.Now()
+	if entry.NextAttemptAt.IsZero() {
+		entry.NextAttemptAt = now
+	}
+	res, err := s.db.Exec(`
+		INSERT INTO outbox (idempotency_key, operation, tenant, project, target, payload, status, attempts,
+			last_error, result_key, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, '', ?, ?, ?)
+		ON CONFLICT (idempotency_key) DO NOTHING`,
+		entry.IdempotencyKey, entry.Operation, entry.Tenant, entry.Project, entry.Target, string(entry.Payload), OutboxPending,
+		entry.LastError, formatTime(entry.NextAttemptAt), formatTime(now), formatTime(now))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to queue write: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		existing, err := s.FindOutboxEntry(entry.IdempotencyKey)
+		return existing, false, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to queue write: %w", err)
+	}
+	saved, err := s.GetOutboxEntry(id)
+	return saved, true, err
+}
+
+// GetOutboxEntry implements Outbox
+func (s *SQLiteStore) GetOutboxEntry(id int64) (*OutboxEntry, error) {
+	entry, err := scanOutboxEntry(s.db.QueryRow(`SELECT `+outboxColumns+` FROM outbox WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("outbox entry %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox entry %d: %w", id, err)
+	}
+	return entry, nil
+}
+
+// FindOutboxEntry implements Outbox
+func (s *SQLiteStore) FindOutboxEntry(idempotencyKey string) (*OutboxEntry, error) {
+	entry, err := scanOutboxEntry(s.db.QueryRow(`SELECT `+outboxColumns+` FROM outbox WHERE idempotency_key = ?`, idempotencyKey))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("outbox entry %q: %w", idempotencyKey, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox entry %q: %w", idempotencyKey, err)
+	}
+	return entry, nil
+}
+
+// ListOutbox implements Outbox
+func (s *SQLiteStore) ListOutbox(status string) ([]OutboxEntry, error) {
+	query := `SELECT ` + outboxColumns + ` FROM outbox`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	rows, err := s.db.Query(query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list outbox: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []OutboxEntry{}
+	for rows.Next() {
+		entry, err := scanOutboxEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list outbox: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// CountOutbox implements Outbox
+func (s *SQLiteStore) CountOutbox() ([]OutboxCount, error) {
+	rows, err := s.db.Query(`SELECT tenant, project, status, COUNT(*) FROM outbox GROUP BY tenant, project, status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []OutboxCount
+	for rows.Next() {
+		var count OutboxCount
+		if err := rows.Scan(&count.Tenant, &count.Project, &count.Status, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to count outbox: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// UpdateOutboxEntry implements Outbox
+func (s *SQLiteStore) UpdateOutboxEntry(entry OutboxEntry) error {
+	res, err := s.db.Exec(`
+		UPDATE outbox SET status = ?, attempts = ?, last_error = ?, result_key = ?,
+			next_attempt_at = ?, updated_at = ?
+		WHERE id = ?`,
+		entry.Status, entry.Attempts, entry.LastError, entry.ResultKey,
+		formatTime(entry.NextAttemptAt), formatTime(time.Now()), entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox entry %d: %w", entry.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("outbox entry %d: %w", entry.ID, ErrNotFound)
+	}
+	return nil
+}
+
+// DeleteOutboxEntry implements Outbox
+func (s *SQLiteStore) DeleteOutboxEntry(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM outbox WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete outbox entry %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("outbox entry %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func scanOutboxEntry(row scanner) (*OutboxEntry, error) {
+	var entry OutboxEntry
+	var payload, nextAttemptAt, createdAt, updatedAt string
+	if err := row.Scan(&entry.ID, &entry.IdempotencyKey, &entry.Operation, &entry.Tenant, &entry.Project, &entry.Target, &payload,
+		&entry.Status, &entry.Attempts, &entry.LastError, &entry.ResultKey,
+		&nextAttemptAt, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	entry.Payload = []byte(payload)
+	entry.NextAttemptAt = parseTime(nextAttemptAt)
+	entry.CreatedAt = parseTime(createdAt)
+	entry.UpdatedAt = parseTime(updatedAt)
+	return &entry, nil
+}
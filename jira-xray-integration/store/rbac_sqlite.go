@@ -0,0 +1,55 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// CreateRoleBinding implements RoleBindings
+func (s *SQLiteStore) CreateRoleBinding(binding RoleBinding) (*RoleBinding, error) {
+	binding.CreatedAt = time.Now()
+	res, err := s.db.Exec(`
+		INSERT INTO role_bindings (role, project, subject, claim, value, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		binding.Role, binding.Project, binding.Subject, binding.Claim, binding.Value, formatTime(binding.CreatedAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role binding: %w", err)
+	}
+	if binding.ID, err = res.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("failed to create role binding: %w", err)
+	}
+	return &binding, nil
+}
+
+// ListRoleBindings implements RoleBindings
+func (s *SQLiteStore) ListRoleBindings() ([]RoleBinding, error) {
+	rows, err := s.db.Query(`SELECT id, role, project, subject, claim, value, created_at FROM role_bindings ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	defer rows.Close()
+
+	bindings := []RoleBinding{}
+	for rows.Next() {
+		var b RoleBinding
+		var createdAt string
+		if err := rows.Scan(&b.ID, &b.Role, &b.Project, &b.Subject, &b.Claim, &b.Value, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to list role bindings: %w", err)
+		}
+		b.CreatedAt = parseTime(createdAt)
+		bindings = append(bindings, b)
+	}
+	return bindings, rows.Err()
+}
+
+// DeleteRoleBinding implements RoleBindings
+func (s *SQLiteStore) DeleteRoleBinding(id int64) error {
+	res, err := s.db.Exec(`DELETE FROM role_bindings WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete role binding %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("role binding %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,479 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"jira-xray-integration/jira"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore is a Store backed by a SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+	sqliteResults
+	sqliteCache
+}
+
+// sqliteResults are the executions and runs of one Jira tenant in the
+// executions, results, step_results, evidence and result_batches tables
+type sqliteResults struct {
+	db     *sql.DB
+	tenant string
+}
+
+// OpenSQLite opens (creating if needed) the database at path and applies migrations
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite storage requires a database path")
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite allows a single writer; serialize access instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db, sqliteResults: sqliteResults{db: db}, sqliteCache: sqliteCache{db: db}}, nil
+}
+
+// TenantResults implements Store
+func (s *SQLiteStore) TenantResults(tenant string) Results {
+	return &sqliteResults{db: s.db, tenant: tenant}
+}
+
+// SaveExecution implements Results
+func (s *sqliteResults) SaveExecution(execution *jira.TestExecution) error {
+	if execution.Key == "" {
+		return fmt.Errorf("execution key is required")
+	}
+
+	testCases, err := json.Marshal(nonNil(execution.TestCases))
+	if err != nil {
+		return fmt.Errorf("failed to encode test cases: %w", err)
+	}
+
+	now := formatTime(time.Now())
+	_, err = s.db.Exec(`
+		INSERT INTO executions (tenant, key, summary, description, status, execution_status, environment,
+			fix_version, test_plan, executed_by, test_cases, start_date, end_date, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (tenant, key) DO UPDATE SET
+			summary = excluded.summary,
+			description = excluded.description,
+			status = excluded.status,
+			execution_status = excluded.execution_status,
+			environment = excluded.environment,
+			fix_version = excluded.fix_version,
+			test_plan = excluded.test_plan,
+			executed_by = excluded.executed_by,
+			test_cases = excluded.test_cases,
+			start_date = excluded.start_date,
+			end_date = excluded.end_date,
+			updated_at = excluded.updated_at`,
+		s.tenant, execution.Key, execution.Summary, execution.Description, execution.Status, execution.ExecutionStatus,
+		execution.Environment, execution.FixVersion, execution.TestPlan, execution.ExecutedBy, string(testCases),
+		formatTime(execution.StartDate), formatTime(execution.EndDate), now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save execution %s: %w", execution.Key, err)
+	}
+	return nil
+}
+
+// GetExecution implements Results
+func (s *sqliteResults) GetExecution(key string) (*jira.TestExecution, error) {
+	row := s.db.QueryRow(`SELECT `+executionColumns+` FROM executions WHERE tenant = ? AND key = ?`, s.tenant, key)
+	execution, err := scanExecution(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("execution %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load execution %s: %w", key, err)
+	}
+
+	runs, err := s.queryRuns(`AND r.execution_key = ? ORDER BY r.id`, key)
+	if err != nil {
+		return nil, err
+	}
+	execution.TestResults = latestPerTest(runs)
+	return execution, nil
+}
+
+// ListExecutions implements Results
+func (s *sqliteResults) ListExecutions() ([]jira.TestExecution, error) {
+	rows, err := s.db.Query(`SELECT `+executionColumns+` FROM executions WHERE tenant = ? ORDER BY created_at DESC, key`, s.tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list executions: %w", err)
+	}
+	defer rows.Close()
+
+	var executions []jira.TestExecution
+	for rows.Next() {
+		execution, err := scanExecution(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list executions: %w", err)
+		}
+		executions = append(executions, *execution)
+	}
+	return executions, rows.Err()
+}
+
+// AddResults implements Results
+func (s *sqliteResults) AddResults(executionKey string, results []jira.TestResult) ([]Run, error) {
+	return s.addResults(executionKey, "", results)
+}
+
+// AddResultsOnce implements Results
+func (s *sqliteResults) AddResultsOnce(executionKey, batch string, results []jira.TestResult) ([]Run, error) {
+	if batch == "" {
+		return nil, fmt.Errorf("result batch is required")
+	}
+	return s.addResults(executionKey, batch, results)
+}
+
+// addResults records results in one transaction, together with batch unless it is empty
+func (s *sqliteResults) addResults(executionKey, batch string, results []jira.TestResult) ([]Run, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record results: %w", err)
+	}
+	defer tx.Rollback()
+
+	var environment, fixVersion, testPlan string
+	err = tx.QueryRow(`SELECT environment, fix_version, test_plan FROM executions WHERE tenant = ? AND key = ?`, s.tenant, executionKey).
+		Scan(&environment, &fixVersion, &testPlan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("execution %s: %w", executionKey, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record results: %w", err)
+	}
+
+	now := time.Now()
+	if batch != "" {
+		res, err := tx.Exec(`INSERT INTO result_batches (tenant, batch, execution_key, created_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (tenant, batch) DO NOTHING`, s.tenant, batch, executionKey, formatTime(now))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record results: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil, nil
+		}
+	}
+
+	added := make([]Run, 0, len(results))
+	for _, result := range results {
+		if result.ExecutedOn.IsZero() {
+			result.ExecutedOn = now
+		}
+		defects, err := json.Marshal(nonNil(result.Defects))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode defects: %w", err)
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO results (tenant, execution_key, test_case_key, status, comment, execution_time,
+				executed_by, executed_on, defects, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			s.tenant, executionKey, result.TestCaseKey, result.Status, result.Comment, result.ExecutionTime,
+			result.ExecutedBy, formatTime(result.ExecutedOn), string(defects), formatTime(now))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record result for %s: %w", result.TestCaseKey, err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to record result for %s: %w", result.TestCaseKey, err)
+		}
+
+		for _, step := range result.StepResults {
+			if _, err := tx.Exec(`
+				INSERT INTO step_results (tenant, result_id, step_index, status, actual_result, comment)
+				VALUES (?, ?, ?, ?, ?, ?)`,
+				s.tenant, id, step.Index, step.Status, step.ActualResult, step.Comment); err != nil {
+				return nil, fmt.Errorf("failed to record step %d for %s: %w", step.Index, result.TestCaseKey, err)
+			}
+		}
+
+		run := Run{
+			ID:           id,
+			ExecutionKey: executionKey,
+			Environment:  environment,
+			FixVersion:   fixVersion,
+			TestPlan:     testPlan,
+			CreatedAt:    now,
+			TestResult:   result,
+		}
+		for _, evidence := range evidenceFromURLs(result.Evidence) {
+			saved, err := s.insertEvidence(tx, id, evidence, now)
+			if err != nil {
+				return nil, err
+			}
+			run.EvidenceFiles = append(run.EvidenceFiles, *saved)
+		}
+		added = append(added, run)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to record results: %w", err)
+	}
+	return added, nil
+}
+
+// TestHistory implements Results
+func (s *sqliteResults) TestHistory(testCaseKey string, limit int) ([]Run, error) {
+	query := `AND r.test_case_key = ? ORDER BY r.executed_on DESC, r.id DESC`
+	args := []interface{}{testCaseKey}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	return s.queryRuns(query, args...)
+}
+
+// LatestResult implements Results
+func (s *sqliteResults) LatestResult(testCaseKey string, filter ResultFilter) (*Run, error) {
+	conditions := []string{"r.test_case_key = ?"}
+	args := []interface{}{testCaseKey}
+	if filter.FixVersion != "" {
+		conditions = append(conditions, "e.fix_version = ?")
+		args = append(args, filter.FixVersion)
+	}
+	if filter.TestPlan != "" {
+		conditions = append(conditions, "e.test_plan = ?")
+		args = append(args, filter.TestPlan)
+	}
+	if filter.Environment != "" {
+		conditions = append(conditions, "e.environment = ?")
+		args = append(args, filter.Environment)
+	}
+
+	runs, err := s.queryRuns(`AND `+strings.Join(conditions, " AND ")+` ORDER BY r.executed_on DESC, r.id DESC LIMIT 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("result for %s: %w", testCaseKey, ErrNotFound)
+	}
+
+	return &runs[0], nil
+}
+
+// GetRun implements Results
+func (s *sqliteResults) GetRun(id int64) (*Run, error) {
+	runs, err := s.queryRuns(`AND r.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("run %d: %w", id, ErrNotFound)
+	}
+	return &runs[0], nil
+}
+
+// AddEvidence implements Results
+func (s *sqliteResults) AddEvidence(runID int64, evidence Evidence) (*Evidence, error) {
+	var exists int
+	err := s.db.QueryRow(`SELECT 1 FROM results WHERE tenant = ? AND id = ?`, s.tenant, runID).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("run %d: %w", runID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add evidence: %w", err)
+	}
+	return s.insertEvidence(s.db, runID, evidence, time.Now())
+}
+
+// ListEvidence implements Results
+func (s *sqliteResults) ListEvidence(runID int64) ([]Evidence, error) {
+	rows, err := s.db.Query(`
+		SELECT id, result_id, filename, content_type, size, url, created_at
+		FROM evidence WHERE tenant = ? AND result_id = ? ORDER BY id`, s.tenant, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list evidence: %w", err)
+	}
+	defer rows.Close()
+
+	var evidence []Evidence
+	for rows.Next() {
+		var e Evidence
+		var createdAt string
+		if err := rows.Scan(&e.ID, &e.RunID, &e.Filename, &e.ContentType, &e.Size, &e.URL, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to list evidence: %w", err)
+		}
+		e.CreatedAt = parseTime(createdAt)
+		evidence = append(evidence, e)
+	}
+	return evidence, rows.Err()
+}
+
+// Ping implements Store
+func (s *SQLiteStore) Ping() error {
+	var one int
+	return s.db.QueryRow(`SELECT 1`).Scan(&one)
+}
+
+// Close implements Store
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// queryRuns loads the runs of the tenant with their step results and
+// evidence. conditions are appended to a join of results and executions
+// that selects the tenant, and start with AND.
+func (s *sqliteResults) queryRuns(conditions string, args ...interface{}) ([]Run, error) {
+	rows, err := s.db.Query(`
+		SELECT r.id, r.execution_key, e.environment, e.fix_version, e.test_plan, r.test_case_key, r.status,
+			r.comment, r.execution_time, r.executed_by, r.executed_on, r.defects, r.created_at
+		FROM results r JOIN executions e ON e.tenant = r.tenant AND e.key = r.execution_key
+		WHERE r.tenant = ? `+conditions, append([]interface{}{s.tenant}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query results: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var run Run
+		var executedOn, defects, createdAt string
+		if err := rows.Scan(&run.ID, &run.ExecutionKey, &run.Environment, &run.FixVersion, &run.TestPlan,
+			&run.TestCaseKey, &run.Status, &run.Comment, &run.ExecutionTime, &run.ExecutedBy,
+			&executedOn, &defects, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to query results: %w", err)
+		}
+		run.ExecutedOn = parseTime(executedOn)
+		run.CreatedAt = parseTime(createdAt)
+		if err := json.Unmarshal([]byte(defects), &run.Defects); err != nil {
+			return nil, fmt.Errorf("failed to decode defects of result %d: %w", run.ID, err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query results: %w", err)
+	}
+	rows.Close()
+
+	for i := range runs {
+		if runs[i].StepResults, err = s.stepResults(runs[i].ID); err != nil {
+			return nil, err
+		}
+		if runs[i].EvidenceFiles, err = s.ListEvidence(runs[i].ID); err != nil {
+			return nil, err
+		}
+		runs[i].Evidence = evidenceURLs(runs[i].EvidenceFiles)
+	}
+	return runs, nil
+}
+
+func (s *sqliteResults) stepResults(resultID int64) ([]jira.TestStepResult, error) {
+	rows, err := s.db.Query(`
+		SELECT step_index, status, actual_result, comment
+		FROM step_results WHERE tenant = ? AND result_id = ? ORDER BY step_index`, s.tenant, resultID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query step results: %w", err)
+	}
+	defer rows.Close()
+
+	var steps []jira.TestStepResult
+	for rows.Next() {
+		var step jira.TestStepResult
+		if err := rows.Scan(&step.Index, &step.Status, &step.ActualResult, &step.Comment); err != nil {
+			return nil, fmt.Errorf("failed to query step results: %w", err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *sqliteResults) insertEvidence(db execer, runID int64, evidence Evidence, now time.Time) (*Evidence, error) {
+	res, err := db.Exec(`
+		INSERT INTO evidence (tenant, result_id, filename, content_type, size, url, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.tenant, runID, evidence.Filename, evidence.ContentType, evidence.Size, evidence.URL, formatTime(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add evidence: %w", err)
+	}
+	if evidence.ID, err = res.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("failed to add evidence: %w", err)
+	}
+	evidence.RunID = runID
+	evidence.CreatedAt = now
+	return &evidence, nil
+}
+
+const executionColumns = `key, summary, description, status, execution_status, environment,
+	fix_version, test_plan, executed_by, test_cases, start_date, end_date`
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanExecution(row scanner) (*jira.TestExecution, error) {
+	var execution jira.TestExecution
+	var testCases, startDate, endDate string
+	if err := row.Scan(&execution.Key, &execution.Summary, &execution.Description, &execution.Status,
+		&execution.ExecutionStatus, &execution.Environment, &execution.FixVersion, &execution.TestPlan,
+		&execution.ExecutedBy, &testCases, &startDate, &endDate); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(testCases), &execution.TestCases); err != nil {
+		return nil, fmt.Errorf("failed to decode test cases of %s: %w", execution.Key, err)
+	}
+	execution.StartDate = parseTime(startDate)
+	execution.EndDate = parseTime(endDate)
+	return &execution, nil
+}
+
+// Times are stored as fixed-width UTC RFC 3339 text, so they sort correctly as
+// strings and the database stays readable with the sqlite3 shell
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
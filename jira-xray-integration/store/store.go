@@ -0,0 +1,291 @@
+// Package store persists test executions and their results locally. Jira
+// remains the system of record for issues; the store keeps the per-test run
+// history, step results and evidence metadata that Jira fields cannot hold.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"jira-xray-integration/jira"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// Store persists executions, results, step results and evidence metadata
+type Store interface {
+	// Results are the executions and run history of the default Jira tenant
+	Results
+
+	// IssueCache is the issue cache of the default Jira tenant
+	IssueCache
+	Outbox
+	APIKeys
+	RoleBindings
+
+	// TenantCache returns the issue cache of a Jira tenant, kept apart from
+	// the caches of other tenants. The empty name is the default tenant.
+	TenantCache(tenant string) IssueCache
+	// TenantResults returns the executions and run history of a Jira tenant,
+	// kept apart from those of other tenants, whose issue keys may be the
+	// same. The empty name is the default tenant.
+	TenantResults(tenant string) Results
+
+	// Ping checks that the store can be read
+	Ping() error
+	Close() error
+}
+
+// Results holds the executions of one Jira tenant with the runs of their tests
+type Results interface {
+	// SaveExecution creates or updates an execution by key. Its TestResults are ignored;
+	// use AddResults to record results.
+	SaveExecution(execution *jira.TestExecution) error
+	// GetExecution returns an execution with the latest result of each of its tests
+	GetExecution(key string) (*jira.TestExecution, error)
+	// ListExecutions returns all executions, newest first, without results
+	ListExecutions() ([]jira.TestExecution, error)
+
+	// AddResults records a new run of each result in an existing execution
+	AddResults(executionKey string, results []jira.TestResult) ([]Run, error)
+	// AddResultsOnce records results like AddResults, once per batch: if
+	// results were already recorded under batch, nothing is added and no runs
+	// are returned. Replays of queued results name the outbox entry as batch.
+	AddResultsOnce(executionKey, batch string, results []jira.TestResult) ([]Run, error)
+	// TestHistory returns the runs of a test case, newest first. A limit of 0 returns all runs.
+	TestHistory(testCaseKey string, limit int) ([]Run, error)
+	// LatestResult returns the most recent run of a test case matching filter
+	LatestResult(testCaseKey string, filter ResultFilter) (*Run, error)
+	// GetRun returns a run by ID
+	GetRun(id int64) (*Run, error)
+
+	// AddEvidence records evidence metadata for a run
+	AddEvidence(runID int64, evidence Evidence) (*Evidence, error)
+	// ListEvidence returns the evidence metadata of a run
+	ListEvidence(runID int64) ([]Evidence, error)
+}
+
+// IssueCache holds local copies of Jira issues kept up to date by the sync engine
+type IssueCache interface {
+	// PutIssues creates or replaces cached issues
+	PutIssues(issues []jira.JiraIssue) error
+	// DeleteIssues removes cached issues by key
+	DeleteIssues(keys []string) error
+	// CachedIssue returns a cached issue by key
+	CachedIssue(key string) (*jira.JiraIssue, error)
+	// CachedIssues returns the cached issues of an issue type, ordered by key
+	CachedIssues(issueType string) ([]jira.JiraIssue, error)
+
+	// SyncStates returns the sync state of every issue type synced so far
+	SyncStates() ([]SyncState, error)
+	// SaveSyncState creates or updates the sync state of an issue type
+	SaveSyncState(state SyncState) error
+}
+
+// SyncState records how far the cache of one issue type has been synced
+type SyncState struct {
+	IssueType    string    `json:"issueType"`
+	LastSync     time.Time `json:"lastSync"`               // start of the last successful sync
+	LastFullSync time.Time `json:"lastFullSync,omitempty"` // start of the last sync that reconciled deletes
+	IssueCount   int       `json:"issueCount"`             // cached issues of this type; ignored by SaveSyncState
+}
+
+// Outbox durably queues writes that could not be sent to Jira
+type Outbox interface {
+	// EnqueueOutbox adds a pending entry. If an entry with the same idempotency key
+	// exists it is returned instead and created is false.
+	EnqueueOutbox(entry OutboxEntry) (saved *OutboxEntry, created bool, err error)
+	// GetOutboxEntry returns an entry by ID
+	GetOutboxEntry(id int64) (*OutboxEntry, error)
+	// FindOutboxEntry returns the entry with an idempotency key
+	FindOutboxEntry(idempotencyKey string) (*OutboxEntry, error)
+	// ListOutbox returns the entries with a status, or all entries if status is
+	// empty, oldest first
+	ListOutbox(status string) ([]OutboxEntry, error)
+	// CountOutbox returns the number of entries of each status, per tenant and project
+	CountOutbox() ([]OutboxCount, error)
+	// UpdateOutboxEntry saves the status, attempts, error and result of an entry
+	UpdateOutboxEntry(entry OutboxEntry) error
+	// DeleteOutboxEntry removes an entry
+	DeleteOutboxEntry(id int64) error
+}
+
+// APIKeys holds the API keys created through the API. Only hashes of the keys are stored.
+type APIKeys interface {
+	// CreateAPIKey saves a new key
+	CreateAPIKey(key APIKey) (*APIKey, error)
+	// ListAPIKeys returns all keys, oldest first
+	ListAPIKeys() ([]APIKey, error)
+	// APIKeyByHash returns the key with a hash
+	APIKeyByHash(hash string) (*APIKey, error)
+	// TouchAPIKey records that a key was used at a time
+	TouchAPIKey(id int64, usedAt time.Time) error
+	// DeleteAPIKey revokes a key
+	DeleteAPIKey(id int64) error
+}
+
+// APIKey is a stored API key
+type APIKey struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"` // first characters of the key, to tell keys apart
+	Hash       string    `json:"-"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// RoleBindings holds the roles granted to API callers per project
+type RoleBindings interface {
+	// CreateRoleBinding saves a new binding
+	CreateRoleBinding(binding RoleBinding) (*RoleBinding, error)
+	// ListRoleBindings returns all bindings, oldest first
+	ListRoleBindings() ([]RoleBinding, error)
+	// DeleteRoleBinding removes a binding
+	DeleteRoleBinding(id int64) error
+}
+
+// RoleBinding grants a role on a project to an API key, a JWT subject, or the
+// JWT callers whose claim holds a value
+type RoleBinding struct {
+	ID        int64     `json:"id"`
+	Role      string    `json:"role"`
+	Project   string    `json:"project"`           // Jira project key, or * for every project
+	Subject   string    `json:"subject,omitempty"` // apikey:<name> or jwt:<subject>
+	Claim     string    `json:"claim,omitempty"`
+	Value     string    `json:"value,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Outbox entry statuses
+const (
+	OutboxPending = "pending"
+	OutboxDone    = "done"
+	OutboxFailed  = "failed"
+)
+
+// OutboxEntry is a queued write to Jira
+type OutboxEntry struct {
+	ID             int64           `json:"id"`
+	IdempotencyKey string          `json:"idempotencyKey"`
+	Operation      string          `json:"operation"`
+	Tenant         string          `json:"tenant,omitempty"`  // Jira tenant the write goes to, empty for the default tenant
+	Project        string          `json:"project,omitempty"` // Jira project the write goes to, empty for the default project
+	Target         string          `json:"target,omitempty"`  // key of the issue the write applies to, if it exists
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"lastError,omitempty"`
+	ResultKey      string          `json:"resultKey,omitempty"` // key of the issue the replay created or updated
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+// OutboxCount is the number of entries with a status queued for a tenant and project
+type OutboxCount struct {
+	Tenant  string
+	Project string
+	Status  string
+	Count   int
+}
+
+// Run is a stored test result together with the execution it belongs to
+type Run struct {
+	ID            int64      `json:"id"`
+	ExecutionKey  string     `json:"executionKey"`
+	Environment   string     `json:"environment,omitempty"`
+	FixVersion    string     `json:"fixVersion,omitempty"`
+	TestPlan      string     `json:"testPlan,omitempty"`
+	EvidenceFiles []Evidence `json:"evidenceFiles,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	jira.TestResult
+}
+
+// Evidence is metadata about a file attached to a run. The file itself lives
+// in Jira or another file store at URL.
+type Evidence struct {
+	ID          int64     `json:"id"`
+	RunID       int64     `json:"runId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType,omitempty"`
+	Size        int64     `json:"size,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ResultFilter restricts LatestResult to runs from matching executions.
+// Empty fields match everything.
+type ResultFilter struct {
+	FixVersion  string
+	TestPlan    string
+	Environment string
+}
+
+// Config selects and configures a store implementation
+type Config struct {
+	Driver string // sqlite or memory
+	Path   string // database file for the sqlite driver
+}
+
+// Open creates the store selected by cfg
+func Open(cfg Config) (Store, error) {
+	switch cfg.Driver {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "sqlite", "":
+		return OpenSQLite(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, use sqlite or memory", cfg.Driver)
+	}
+}
+
+// latestPerTest keeps the newest run of each test case, preserving first-seen order
+func latestPerTest(runs []Run) []jira.TestResult {
+	index := make(map[string]int)
+	var results []jira.TestResult
+	for _, run := range runs {
+		i, ok := index[run.TestCaseKey]
+		if !ok {
+			index[run.TestCaseKey] = len(results)
+			results = append(results, run.TestResult)
+			continue
+		}
+		if !run.ExecutedOn.Before(results[i].ExecutedOn) {
+			results[i] = run.TestResult
+		}
+	}
+	return results
+}
+
+// evidenceFromURLs builds evidence metadata for the attachment URLs of a result
+func evidenceFromURLs(urls []string) []Evidence {
+	evidence := make([]Evidence, 0, len(urls))
+	for _, url := range urls {
+		evidence = append(evidence, Evidence{Filename: filenameFromURL(url), URL: url})
+	}
+	return evidence
+}
+
+// evidenceURLs lists the URLs of evidence files that have one
+func evidenceURLs(evidence []Evidence) []string {
+	var urls []string
+	for _, e := range evidence {
+		if e.URL != "" {
+			urls = append(urls, e.URL)
+		}
+	}
+	return urls
+}
+
+func filenameFromURL(url string) string {
+	for i := len(url) - 1; i >= 0; i-- {
+		if url[i] == '/' {
+			return url[i+1:]
+		}
+	}
+	return url
+}
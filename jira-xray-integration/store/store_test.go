@@ -0,0 +1,468 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"jira-xray-integration/jira"
+)
+
+// forEachDriver runs a test against a fresh store of every driver
+func forEachDriver(t *testing.T, test func(t *testing.T, s Store)) {
+	drivers := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"sqlite": func(t *testing.T) Store {
+			s, err := OpenSQLite(filepath.Join(t.TempDir(), "store.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	}
+	for _, name := range []string{"memory", "sqlite"} {
+		t.Run(name, func(t *testing.T) {
+			s := drivers[name](t)
+			t.Cleanup(func() { s.Close() })
+			test(t, s)
+		})
+	}
+}
+
+// saveExecution stores an execution and fails the test on error
+func saveExecution(t *testing.T, s Store, execution jira.TestExecution) {
+	t.Helper()
+	if err := s.SaveExecution(&execution); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecutionRoundTrip(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		saveExecution(t, s, jira.TestExecution{
+			Key:             "TEST-10",
+			Summary:         "Nightly",
+			TestCases:       []string{"TEST-1", "TEST-2"},
+			ExecutionStatus: jira.StatusExecuting,
+			Environment:     "staging",
+			FixVersion:      "1.0",
+			TestPlan:        "TEST-5",
+			TestResults:     []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass}},
+		})
+
+		got, err := s.GetExecution("TEST-10")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Summary != "Nightly" || len(got.TestCases) != 2 || got.Environment != "staging" || got.TestPlan != "TEST-5" {
+			t.Errorf("got execution %+v", got)
+		}
+		if len(got.TestResults) != 0 {
+			t.Errorf("SaveExecution stored results %+v", got.TestResults)
+		}
+
+		saveExecution(t, s, jira.TestExecution{Key: "TEST-10", Summary: "Nightly run", TestCases: []string{"TEST-1"}})
+		executions, err := s.ListExecutions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(executions) != 1 || executions[0].Summary != "Nightly run" {
+			t.Errorf("got executions %+v", executions)
+		}
+
+		if _, err := s.GetExecution("TEST-404"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v for a missing execution", err)
+		}
+		if err := s.SaveExecution(&jira.TestExecution{Summary: "No key"}); err == nil {
+			t.Error("expected an error for an execution without a key")
+		}
+	})
+}
+
+func TestResultsRoundTrip(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		saveExecution(t, s, jira.TestExecution{Key: "TEST-10", TestCases: []string{"TEST-1"}, Environment: "staging", FixVersion: "1.0"})
+		saveExecution(t, s, jira.TestExecution{Key: "TEST-11", TestCases: []string{"TEST-1"}, Environment: "prod", FixVersion: "1.0"})
+
+		first := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+		runs, err := s.AddResults("TEST-10", []jira.TestResult{{
+			TestCaseKey:   "TEST-1",
+			Status:        jira.StatusFail,
+			Comment:       "timeout",
+			ExecutionTime: 1500,
+			ExecutedOn:    first,
+			Defects:       []string{"BUG-1"},
+			Evidence:      []string{"https://files.example.com/run/screenshot.png"},
+			StepResults: []jira.TestStepResult{
+				{Index: 1, Status: jira.StatusPass},
+				{Index: 2, Status: jira.StatusFail, ActualResult: "spinner", Comment: "hangs"},
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) != 1 || runs[0].ExecutionKey != "TEST-10" || runs[0].Environment != "staging" {
+			t.Fatalf("got runs %+v", runs)
+		}
+		if _, err := s.AddResults("TEST-11", []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass, ExecutedOn: first.Add(time.Hour)}}); err != nil {
+			t.Fatal(err)
+		}
+
+		run, err := s.GetRun(runs[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run.Comment != "timeout" || run.ExecutionTime != 1500 || !run.ExecutedOn.Equal(first) || len(run.Defects) != 1 {
+			t.Errorf("got run %+v", run)
+		}
+		if len(run.StepResults) != 2 || run.StepResults[1].ActualResult != "spinner" || run.StepResults[1].Comment != "hangs" {
+			t.Errorf("got step results %+v", run.StepResults)
+		}
+		if len(run.EvidenceFiles) != 1 || run.EvidenceFiles[0].Filename != "screenshot.png" || len(run.Evidence) != 1 {
+			t.Errorf("got evidence %+v and files %+v", run.Evidence, run.EvidenceFiles)
+		}
+
+		history, err := s.TestHistory("TEST-1", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 2 || history[0].ExecutionKey != "TEST-11" || history[1].ExecutionKey != "TEST-10" {
+			t.Errorf("got history %+v, want newest first", history)
+		}
+		if history, _ := s.TestHistory("TEST-1", 1); len(history) != 1 {
+			t.Errorf("got %d runs with a limit of 1", len(history))
+		}
+
+		latest, err := s.LatestResult("TEST-1", ResultFilter{})
+		if err != nil || latest.ExecutionKey != "TEST-11" {
+			t.Errorf("got latest %+v, %v", latest, err)
+		}
+		latest, err = s.LatestResult("TEST-1", ResultFilter{Environment: "staging", FixVersion: "1.0"})
+		if err != nil || latest.ExecutionKey != "TEST-10" {
+			t.Errorf("got latest staging %+v, %v", latest, err)
+		}
+		if _, err := s.LatestResult("TEST-1", ResultFilter{TestPlan: "TEST-5"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v for a filter no run matches", err)
+		}
+
+		execution, err := s.GetExecution("TEST-10")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(execution.TestResults) != 1 || execution.TestResults[0].Status != jira.StatusFail {
+			t.Errorf("got execution results %+v", execution.TestResults)
+		}
+
+		if _, err := s.AddResults("TEST-404", []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass}}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v for results of a missing execution", err)
+		}
+		if _, err := s.GetRun(9999); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v for a missing run", err)
+		}
+	})
+}
+
+func TestLatestResultPerExecution(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		saveExecution(t, s, jira.TestExecution{Key: "TEST-10", TestCases: []string{"TEST-1"}})
+		start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+		for i, status := range []string{jira.StatusFail, jira.StatusPass} {
+			if _, err := s.AddResults("TEST-10", []jira.TestResult{{TestCaseKey: "TEST-1", Status: status, ExecutedOn: start.Add(time.Duration(i) * time.Minute)}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		execution, err := s.GetExecution("TEST-10")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(execution.TestResults) != 1 || execution.TestResults[0].Status != jira.StatusPass {
+			t.Errorf("got results %+v, want the newest run only", execution.TestResults)
+		}
+	})
+}
+
+func TestAddResultsOnce(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		saveExecution(t, s, jira.TestExecution{Key: "TEST-10", TestCases: []string{"TEST-1"}})
+		results := []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass}}
+
+		runs, err := s.AddResultsOnce("TEST-10", "outbox-1", results)
+		if err != nil || len(runs) != 1 {
+			t.Fatalf("got runs %+v, %v", runs, err)
+		}
+		runs, err = s.AddResultsOnce("TEST-10", "outbox-1", results)
+		if err != nil || len(runs) != 0 {
+			t.Errorf("got runs %+v, %v for a batch already recorded", runs, err)
+		}
+		if history, _ := s.TestHistory("TEST-1", 0); len(history) != 1 {
+			t.Errorf("got %d runs, want 1", len(history))
+		}
+
+		if _, err := s.AddResultsOnce("TEST-404", "outbox-2", results); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("got error %v for a missing execution", err)
+		}
+		// A batch that failed is not marked as recorded
+		saveExecution(t, s, jira.TestExecution{Key: "TEST-404", TestCases: []string{"TEST-1"}})
+		if runs, err := s.AddResultsOnce("TEST-404", "outbox-2", results); err != nil || len(runs) != 1 {
+			t.Errorf("got runs %+v, %v after the execution was stored", runs, err)
+		}
+
+		if _, err := s.AddResultsOnce("TEST-10", "", results); err == nil {
+			t.Error("expected an error for an empty batch")
+		}
+	})
+}
+
+func TestEvidenceRoundTrip(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		saveExecution(t, s, jira.TestExecution{Key: "TEST-10", TestCases: []string{"TEST-1"}})
+		runs, err := s.AddResults("TEST-10", []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		saved, err := s.AddEvidence(runs[0].ID, Evidence{Filename: "log.txt", ContentType: "text/plain", Size: 42, URL: "https://files.example.com/log.txt"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.ID == 0 || saved.RunID != runs[0].ID || saved.CreatedAt.IsZero() {
+			t.Errorf("got evidence %+v", saved)
+		}
+
+		evidence, err := s.ListEvidence(runs[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(evidence) != 1 || evidence[0].Filename != "log.txt" || evidence[0].Size != 42 || evidence[0].ContentType != "text/plain" {
+			t.Errorf("got evidence %+v", evidence)
+		}
+
+		if _, err := s.AddEvidence(9999, Evidence{Filename: "log.txt"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v for evidence of a missing run", err)
+		}
+	})
+}
+
+func TestOutboxRoundTrip(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		entry := OutboxEntry{
+			IdempotencyKey: "key-1",
+			Operation:      "createTestCase",
+			Tenant:         "acme",
+			Project:        "PAY",
+			Payload:        []byte(`{"summary":"Login"}`),
+		}
+		saved, created, err := s.EnqueueOutbox(entry)
+		if err != nil || !created {
+			t.Fatalf("got %+v, created %v, %v", saved, created, err)
+		}
+		if saved.Status != OutboxPending || saved.NextAttemptAt.IsZero() || string(saved.Payload) != `{"summary":"Login"}` {
+			t.Errorf("got entry %+v", saved)
+		}
+
+		again, created, err := s.EnqueueOutbox(OutboxEntry{IdempotencyKey: "key-1", Operation: "updateTestCase"})
+		if err != nil || created || again.ID != saved.ID || again.Operation != "createTestCase" {
+			t.Errorf("got %+v, created %v, %v for a reused idempotency key", again, created, err)
+		}
+		if _, _, err := s.EnqueueOutbox(OutboxEntry{Operation: "createTestCase"}); err == nil {
+			t.Error("expected an error for an entry without an idempotency key")
+		}
+		if _, _, err := s.EnqueueOutbox(OutboxEntry{IdempotencyKey: "key-2", Operation: "createTestPlan", Tenant: "acme", Project: "PAY"}); err != nil {
+			t.Fatal(err)
+		}
+
+		saved.Status = OutboxDone
+		saved.Attempts = 2
+		saved.ResultKey = "PAY-7"
+		if err := s.UpdateOutboxEntry(*saved); err != nil {
+			t.Fatal(err)
+		}
+		found, err := s.FindOutboxEntry("key-1")
+		if err != nil || found.Status != OutboxDone || found.Attempts != 2 || found.ResultKey != "PAY-7" {
+			t.Errorf("got %+v, %v", found, err)
+		}
+
+		pending, err := s.ListOutbox(OutboxPending)
+		if err != nil || len(pending) != 1 || pending[0].IdempotencyKey != "key-2" {
+			t.Errorf("got pending %+v, %v", pending, err)
+		}
+		all, err := s.ListOutbox("")
+		if err != nil || len(all) != 2 || all[0].ID != saved.ID {
+			t.Errorf("got entries %+v, %v", all, err)
+		}
+
+		counts, err := s.CountOutbox()
+		if err != nil {
+			t.Fatal(err)
+		}
+		byStatus := map[string]int{}
+		for _, count := range counts {
+			if count.Tenant != "acme" || count.Project != "PAY" {
+				t.Errorf("got count %+v", count)
+			}
+			byStatus[count.Status] += count.Count
+		}
+		if byStatus[OutboxPending] != 1 || byStatus[OutboxDone] != 1 {
+			t.Errorf("got counts %+v", counts)
+		}
+
+		if err := s.DeleteOutboxEntry(saved.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetOutboxEntry(saved.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v for a deleted entry", err)
+		}
+		if err := s.DeleteOutboxEntry(saved.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v deleting a deleted entry", err)
+		}
+		if err := s.UpdateOutboxEntry(*saved); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v updating a deleted entry", err)
+		}
+	})
+}
+
+func TestIssueCacheRoundTrip(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		issues := []jira.JiraIssue{{Key: "TEST-2"}, {Key: "TEST-1"}}
+		for i := range issues {
+			issues[i].Fields.IssueType.Name = "Test"
+			issues[i].Fields.Summary = "Summary of " + issues[i].Key
+		}
+		if err := s.PutIssues(issues); err != nil {
+			t.Fatal(err)
+		}
+
+		cached, err := s.CachedIssues("Test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cached) != 2 || cached[0].Key != "TEST-1" || cached[1].Fields.Summary != "Summary of TEST-2" {
+			t.Errorf("got issues %+v, want them ordered by key", cached)
+		}
+		if other, _ := s.TenantCache("acme").CachedIssues("Test"); len(other) != 0 {
+			t.Errorf("got issues %+v in the cache of another tenant", other)
+		}
+
+		if err := s.DeleteIssues([]string{"TEST-1"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CachedIssue("TEST-1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v for a deleted issue", err)
+		}
+
+		lastSync := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+		if err := s.SaveSyncState(SyncState{IssueType: "Test", LastSync: lastSync, LastFullSync: lastSync}); err != nil {
+			t.Fatal(err)
+		}
+		states, err := s.SyncStates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(states) != 1 || !states[0].LastSync.Equal(lastSync) || states[0].IssueCount != 1 {
+			t.Errorf("got sync states %+v", states)
+		}
+	})
+}
+
+func TestAPIKeysAndRoleBindingsRoundTrip(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		key, err := s.CreateAPIKey(APIKey{Name: "ci", Prefix: "jxi_ab", Hash: "hash-1", Scopes: []string{"read", "write"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		usedAt := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+		if err := s.TouchAPIKey(key.ID, usedAt); err != nil {
+			t.Fatal(err)
+		}
+		found, err := s.APIKeyByHash("hash-1")
+		if err != nil || found.Name != "ci" || len(found.Scopes) != 2 || !found.LastUsedAt.Equal(usedAt) {
+			t.Errorf("got key %+v, %v", found, err)
+		}
+		if err := s.DeleteAPIKey(key.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.APIKeyByHash("hash-1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v for a revoked key", err)
+		}
+
+		binding, err := s.CreateRoleBinding(RoleBinding{Role: "reader", Project: "PAY", Subject: "apikey:ci"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bindings, err := s.ListRoleBindings()
+		if err != nil || len(bindings) != 1 || bindings[0].Subject != "apikey:ci" || bindings[0].CreatedAt.IsZero() {
+			t.Errorf("got bindings %+v, %v", bindings, err)
+		}
+		if err := s.DeleteRoleBinding(binding.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteRoleBinding(binding.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v deleting a deleted binding", err)
+		}
+	})
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open(Config{Driver: "postgres"}); err == nil {
+		t.Error("expected an error for an unknown driver")
+	}
+	if _, err := Open(Config{Driver: "sqlite"}); err == nil {
+		t.Error("expected an error for sqlite without a path")
+	}
+	s, err := Open(Config{Driver: "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Ping(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTenantResultsAreSeparate(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Store) {
+		tenants := map[string]Results{"": s, "dc": s.TenantResults("dc")}
+		runs := map[string]Run{}
+		for name, results := range tenants {
+			if err := results.SaveExecution(&jira.TestExecution{Key: "EXEC-1", Summary: "Run of " + name, TestCases: []string{"TEST-1"}, Environment: name}); err != nil {
+				t.Fatal(err)
+			}
+			added, err := results.AddResultsOnce("EXEC-1", "outbox-1", []jira.TestResult{{TestCaseKey: "TEST-1", Status: jira.StatusPass, Comment: name}})
+			if err != nil || len(added) != 1 {
+				t.Fatalf("tenant %q: got runs %+v, %v", name, added, err)
+			}
+			runs[name] = added[0]
+		}
+		if runs[""].ID == runs["dc"].ID {
+			t.Errorf("tenants got the same run ID %d", runs[""].ID)
+		}
+
+		for name, results := range tenants {
+			execution, err := results.GetExecution("EXEC-1")
+			if err != nil || execution.Summary != "Run of "+name || len(execution.TestResults) != 1 || execution.TestResults[0].Comment != name {
+				t.Errorf("tenant %q: got execution %+v, %v", name, execution, err)
+			}
+			if executions, _ := results.ListExecutions(); len(executions) != 1 {
+				t.Errorf("tenant %q: got executions %+v", name, executions)
+			}
+			if history, _ := results.TestHistory("TEST-1", 0); len(history) != 1 || history[0].Comment != name {
+				t.Errorf("tenant %q: got history %+v", name, history)
+			}
+			if latest, err := results.LatestResult("TEST-1", ResultFilter{Environment: name}); err != nil || latest.ID != runs[name].ID {
+				t.Errorf("tenant %q: got latest %+v, %v", name, latest, err)
+			}
+		}
+
+		// Runs of one tenant cannot be read or given evidence through another
+		if _, err := s.GetRun(runs["dc"].ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v reading a run of another tenant", err)
+		}
+		if _, err := s.AddEvidence(runs["dc"].ID, Evidence{Filename: "log.txt"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("got error %v adding evidence to a run of another tenant", err)
+		}
+		if evidence, _ := s.TenantResults("dc").ListEvidence(runs[""].ID); len(evidence) != 0 {
+			t.Errorf("got evidence %+v of another tenant", evidence)
+		}
+	})
+}
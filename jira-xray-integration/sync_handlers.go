@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/metrics"
+	"jira-xray-integration/store"
+	"jira-xray-integration/syncer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveFromCache reports whether a read of issueType should come from the issue
+// cache of the request's tenant, which holds its default project
+func serveFromCache(c *gin.Context, issueType string) bool {
+	if freshRead(c) {
+		metrics.CacheReads.WithLabelValues(issueType, metrics.CacheBypass).Inc()
+		return false
+	}
+	ready := requestTenant(c).syncEngine.Ready(issueType)
+	metrics.CacheReads.WithLabelValues(issueType, cacheResult(ready)).Inc()
+	return ready
+}
+
+// cacheResult names the result of a cache read for metrics
+func cacheResult(hit bool) string {
+	if hit {
+		return metrics.CacheHit
+	}
+	return metrics.CacheMiss
+}
+
+// freshRead reports whether a read must go to Jira: with ?fresh=true, for
+// projects other than the default one, and for requests with the caller's
+// Jira credentials so that Jira decides what the caller may see
+func freshRead(c *gin.Context) bool {
+	return c.Query("fresh") == "true" || !requestTenant(c).isDefaultProject(requestProject(c)) || delegated(c)
+}
+
+// responseSource names where a read was served from
+func responseSource(cached bool) string {
+	if cached {
+		return "cache"
+	}
+	return "jira"
+}
+
+// cachedTestCases returns the cached test cases of a tenant, parsed with the
+// settings of project
+func cachedTestCases(t *tenant, project jira.ProjectConfig) ([]jira.TestCase, error) {
+	issues, err := t.cache.CachedIssues(project.IssueTypes.Test)
+	if err != nil {
+		return nil, err
+	}
+	testCases := make([]jira.TestCase, len(issues))
+	for i, issue := range issues {
+		testCases[i] = *project.TestCaseFromIssue(&issue)
+	}
+	return testCases, nil
+}
+
+// cachedTestExecutions returns the cached test executions of a tenant, parsed
+// with the settings of project, with the run details from the local store
+func cachedTestExecutions(t *tenant, project jira.ProjectConfig) ([]jira.TestExecution, error) {
+	issues, err := t.cache.CachedIssues(project.IssueTypes.TestExecution)
+	if err != nil {
+		return nil, err
+	}
+	storedExecutions, err := t.results.ListExecutions()
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]*jira.TestExecution, len(storedExecutions))
+	for i := range storedExecutions {
+		stored[storedExecutions[i].Key] = &storedExecutions[i]
+	}
+
+	testExecutions := make([]jira.TestExecution, len(issues))
+	for i, issue := range issues {
+		testExecutions[i] = *project.TestExecutionFromIssue(&issue)
+		if s, ok := stored[issue.Key]; ok {
+			mergeStoredExecution(&testExecutions[i], s)
+		}
+	}
+	return testExecutions, nil
+}
+
+// cachedTestExecution returns a test execution of project from the issue cache
+// of a tenant, or nil when fresh is set, the cache does not hold the project,
+// the cache is not ready or the issue has not been synced yet. A cached issue
+// of another type is a *jira.IssueTypeError, as it is when read from Jira.
+func cachedTestExecution(t *tenant, project jira.ProjectConfig, key string, fresh bool) (*jira.TestExecution, error) {
+	issueType := project.IssueTypes.TestExecution
+	if fresh {
+		metrics.CacheReads.WithLabelValues(issueType, metrics.CacheBypass).Inc()
+		return nil, nil
+	}
+	if !t.isDefaultProject(project.Key) || !t.syncEngine.Ready(issueType) {
+		metrics.CacheReads.WithLabelValues(issueType, metrics.CacheMiss).Inc()
+		return nil, nil
+	}
+	issue, err := t.cache.CachedIssue(key)
+	if errors.Is(err, store.ErrNotFound) {
+		metrics.CacheReads.WithLabelValues(issueType, metrics.CacheMiss).Inc()
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	metrics.CacheReads.WithLabelValues(issueType, metrics.CacheHit).Inc()
+	if issue.Fields.IssueType.Name != issueType {
+		return nil, &jira.IssueTypeError{Key: key, IssueType: issue.Fields.IssueType.Name, Want: issueType}
+	}
+	return project.TestExecutionFromIssue(issue), nil
+}
+
+// Get the sync engine status
+func getSyncStatus(c *gin.Context) {
+	log.Println("Handling GET /api/sync request")
+
+	status, err := requestTenant(c).syncEngine.Status()
+	if err != nil {
+		log.Printf("Error reading sync status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read sync status",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sync":    status,
+		"message": "Sync status retrieved successfully",
+	})
+}
+
+// scopeSyncProject sets the project of the sync routes to the one the sync
+// engine of the tenant keeps in the issue cache, its default project, so
+// that callers need permission on that project to read or run syncs
+func scopeSyncProject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(projectContextKey, requestTenant(c).syncEngine.ProjectKey())
+		c.Next()
+	}
+}
+
+// Run a sync now
+func runSync(c *gin.Context) {
+	full := c.Query("full") == "true"
+	log.Printf("Handling POST /api/sync request (full=%t)", full)
+
+	results, err := requestTenant(c).syncEngine.Sync(full)
+	if errors.Is(err, syncer.ErrSyncInProgress) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A sync is already in progress",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Error syncing with Jira: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Sync with Jira failed",
+			"details": err.Error(),
+			"results": results,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"message": "Sync completed successfully",
+	})
+}
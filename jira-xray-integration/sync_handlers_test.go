@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSyncHandlers(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "status when disabled",
+			method: http.MethodGet, path: "/api/sync",
+			status: http.StatusOK,
+			golden: "sync_status_disabled",
+		},
+		{
+			name:   "sync",
+			setup:  func(t *testing.T, env *testEnv) { env.setSyncInterval(t, time.Hour) },
+			method: http.MethodPost, path: "/api/sync",
+			status: http.StatusOK,
+			golden: "sync_run",
+		},
+		{
+			name: "status after a sync",
+			setup: func(t *testing.T, env *testEnv) {
+				env.setSyncInterval(t, time.Hour)
+				env.mustDo(t, http.StatusOK, http.MethodPost, "/api/sync", "")
+			},
+			method: http.MethodGet, path: "/api/sync",
+			status: http.StatusOK,
+			golden: "sync_status",
+		},
+		{
+			name: "full sync removes deleted issues",
+			setup: func(t *testing.T, env *testEnv) {
+				env.setSyncInterval(t, time.Hour)
+				env.mustDo(t, http.StatusOK, http.MethodPost, "/api/sync", "")
+				if err := env.jira.Backend.DeleteIssue("TEST-3"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			method: http.MethodPost, path: "/api/sync?full=true",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases", "")
+				if body["source"] != "cache" || body["count"] != 2.0 {
+					t.Errorf("got %v test cases from %v, want 2 from the cache", body["count"], body["source"])
+				}
+			},
+		},
+		{
+			name: "cached issue of another type",
+			setup: func(t *testing.T, env *testEnv) {
+				env.setSyncInterval(t, time.Hour)
+				env.mustDo(t, http.StatusOK, http.MethodPost, "/api/sync", "")
+			},
+			method: http.MethodGet, path: "/api/testcases/TEST-9",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "issue of another type from Jira",
+			method: http.MethodGet, path: "/api/testcases/TEST-9",
+			status: http.StatusNotFound,
+		},
+		{
+			name: "sync when Jira fails",
+			setup: func(t *testing.T, env *testEnv) {
+				env.setSyncInterval(t, time.Hour)
+				env.jira.FailNext(1, http.StatusInternalServerError)
+			},
+			method: http.MethodPost, path: "/api/sync",
+			status: http.StatusInternalServerError,
+		},
+	})
+}
@@ -0,0 +1,317 @@
+// Package syncer keeps the local issue cache in step with Jira. It pulls
+// Test, Test Execution and Test Plan issues incrementally with
+// "updated >= last sync" JQL and periodically runs a full sync that also
+// removes issues deleted in Jira.
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/store"
+)
+
+// ErrSyncInProgress is returned by Sync when another sync is already running
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// DefaultIssueTypes are the issue types synced when Options.IssueTypes is empty
+var DefaultIssueTypes = []string{jira.IssueTypeTest, jira.IssueTypeTestExecution, jira.IssueTypeTestPlan}
+
+// syncOverlap is subtracted from the last sync time in incremental queries. It
+// absorbs clock skew between this server and Jira; re-fetched issues are
+// simply overwritten in the cache.
+const syncOverlap = time.Minute
+
+// jqlTimeLayout is the date format accepted by JQL, which has minute precision
+const jqlTimeLayout = "2006/01/02 15:04"
+
+// IssueSearcher runs JQL queries against Jira
+type IssueSearcher interface {
+	SearchIssues(jql string) ([]jira.JiraIssue, error)
+}
+
+// Options configures an Engine
+type Options struct {
+	ProjectKey string
+	IssueTypes []string
+	// Interval between background syncs. Zero disables background sync and
+	// serving reads from the cache.
+	Interval time.Duration
+	// ReconcileInterval is how often a sync fetches every issue to remove
+	// deleted ones from the cache. Zero reconciles only on explicit full syncs.
+	ReconcileInterval time.Duration
+	// Location is the time zone Jira uses to interpret dates in JQL, which is
+	// the time zone of the Jira user the client signs in as
+	Location *time.Location
+}
+
+// Result describes what a sync changed for one issue type
+type Result struct {
+	IssueType string `json:"issueType"`
+	Full      bool   `json:"full"`
+	Updated   int    `json:"updated"`
+	Deleted   int    `json:"deleted"`
+}
+
+// Status describes the engine and the cached issue types
+type Status struct {
+	Enabled      bool              `json:"enabled"`
+	Interval     string            `json:"interval,omitempty"`
+	Running      bool              `json:"running"`
+	LastRun      time.Time         `json:"lastRun,omitempty"`
+	LastDuration string            `json:"lastDuration,omitempty"`
+	LastError    string            `json:"lastError,omitempty"`
+	LastResults  []Result          `json:"lastResults,omitempty"`
+	IssueTypes   []store.SyncState `json:"issueTypes"`
+}
+
+// Engine syncs Jira issues into an IssueCache
+type Engine struct {
+	searcher IssueSearcher
+	cache    store.IssueCache
+	opts     Options
+	trigger  chan struct{}
+
+	running atomic.Bool // set for the duration of a sync
+
+	mu           sync.RWMutex
+	synced       map[string]bool
+	lastRun      time.Time
+	lastDuration time.Duration
+	lastError    string
+	lastResults  []Result
+}
+
+// NewEngine creates an engine, loading the sync state left by earlier runs from cache
+func NewEngine(searcher IssueSearcher, cache store.IssueCache, opts Options) (*Engine, error) {
+	if len(opts.IssueTypes) == 0 {
+		opts.IssueTypes = DefaultIssueTypes
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	states, err := cache.SyncStates()
+	if err != nil {
+		return nil, err
+	}
+	synced := make(map[string]bool)
+	for _, state := range states {
+		synced[state.IssueType] = !state.LastSync.IsZero()
+	}
+
+	return &Engine{
+		searcher: searcher,
+		cache:    cache,
+		opts:     opts,
+		trigger:  make(chan struct{}, 1),
+		synced:   synced,
+	}, nil
+}
+
+// Enabled reports whether background sync is configured
+func (e *Engine) Enabled() bool {
+	return e.opts.Interval > 0
+}
+
+// ProjectKey returns the project whose issues the engine keeps in the cache
+func (e *Engine) ProjectKey() string {
+	return e.opts.ProjectKey
+}
+
+// Ready reports whether reads of issueType can be served from the cache: background
+// sync is enabled and the issue type has been synced at least once
+func (e *Engine) Ready(issueType string) bool {
+	if !e.Enabled() {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.synced[issueType]
+}
+
+// Run syncs immediately and then every Interval, or sooner when triggered,
+// until ctx is cancelled. It returns at once if background sync is disabled.
+func (e *Engine) Run(ctx context.Context) {
+	if !e.Enabled() {
+		log.Println("Background sync disabled")
+		return
+	}
+	log.Printf("Background sync every %s", e.opts.Interval)
+
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := e.Sync(false); err != nil && !errors.Is(err, ErrSyncInProgress) {
+			log.Printf("Background sync failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-e.trigger:
+		}
+	}
+}
+
+// Trigger asks the background loop to sync soon, for example after an issue was
+// created through the API. It never blocks.
+func (e *Engine) Trigger() {
+	select {
+	case e.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Sync brings every issue type up to date. Issue types that have never been
+// synced, or whose last reconciliation is older than ReconcileInterval, get a
+// full sync; full forces one for all of them.
+func (e *Engine) Sync(full bool) ([]Result, error) {
+	if !e.running.CompareAndSwap(false, true) {
+		return nil, ErrSyncInProgress
+	}
+	defer e.running.Store(false)
+
+	started := time.Now()
+	states, err := e.cache.SyncStates()
+	if err != nil {
+		e.finish(started, nil, err)
+		return nil, err
+	}
+	byType := make(map[string]store.SyncState, len(states))
+	for _, state := range states {
+		byType[state.IssueType] = state
+	}
+
+	var results []Result
+	for _, issueType := range e.opts.IssueTypes {
+		state, ok := byType[issueType]
+		if !ok {
+			state = store.SyncState{IssueType: issueType}
+		}
+		result, err := e.syncIssueType(state, full)
+		if err != nil {
+			err = fmt.Errorf("failed to sync %s issues: %w", issueType, err)
+			e.finish(started, results, err)
+			return results, err
+		}
+		results = append(results, *result)
+	}
+
+	e.finish(started, results, nil)
+	return results, nil
+}
+
+func (e *Engine) syncIssueType(state store.SyncState, full bool) (*Result, error) {
+	started := time.Now()
+	full = full || state.LastSync.IsZero() ||
+		(e.opts.ReconcileInterval > 0 && started.Sub(state.LastFullSync) >= e.opts.ReconcileInterval)
+
+	jql := fmt.Sprintf("project = %s AND issuetype = %q", e.opts.ProjectKey, state.IssueType)
+	if !full {
+		since := state.LastSync.Add(-syncOverlap).In(e.opts.Location)
+		jql += fmt.Sprintf(" AND updated >= %q", since.Format(jqlTimeLayout))
+	}
+
+	issues, err := e.searcher.SearchIssues(jql)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.cache.PutIssues(issues); err != nil {
+		return nil, err
+	}
+
+	result := &Result{IssueType: state.IssueType, Full: full, Updated: len(issues)}
+	if full {
+		deleted, err := e.reconcile(state.IssueType, issues)
+		if err != nil {
+			return nil, err
+		}
+		result.Deleted = deleted
+		state.LastFullSync = started
+	}
+
+	state.LastSync = started
+	if err := e.cache.SaveSyncState(state); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.synced[state.IssueType] = true
+	e.mu.Unlock()
+	return result, nil
+}
+
+// reconcile removes cached issues of issueType that are missing from a full fetch
+func (e *Engine) reconcile(issueType string, current []jira.JiraIssue) (int, error) {
+	keep := make(map[string]bool, len(current))
+	for _, issue := range current {
+		keep[issue.Key] = true
+	}
+
+	cached, err := e.cache.CachedIssues(issueType)
+	if err != nil {
+		return 0, err
+	}
+	var deleted []string
+	for _, issue := range cached {
+		if !keep[issue.Key] {
+			deleted = append(deleted, issue.Key)
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	if err := e.cache.DeleteIssues(deleted); err != nil {
+		return 0, err
+	}
+	log.Printf("Removed %d deleted %s issues from the cache", len(deleted), issueType)
+	return len(deleted), nil
+}
+
+func (e *Engine) finish(started time.Time, results []Result, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastRun = started
+	e.lastDuration = time.Since(started)
+	e.lastResults = results
+	e.lastError = ""
+	if err != nil {
+		e.lastError = err.Error()
+	}
+}
+
+// Status returns the engine status and the sync state of each issue type
+func (e *Engine) Status() (*Status, error) {
+	states, err := e.cache.SyncStates()
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	status := &Status{
+		Enabled:     e.Enabled(),
+		Running:     e.running.Load(),
+		LastRun:     e.lastRun,
+		LastError:   e.lastError,
+		LastResults: e.lastResults,
+		IssueTypes:  states,
+	}
+	if status.Enabled {
+		status.Interval = e.opts.Interval.String()
+	}
+	if !e.lastRun.IsZero() {
+		status.LastDuration = e.lastDuration.String()
+	}
+	if status.IssueTypes == nil {
+		status.IssueTypes = []store.SyncState{}
+	}
+	return status, nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/store"
+	"jira-xray-integration/syncer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tenant is a Jira connection in use: the backends of its projects, the
+// clients of callers who send their own credentials, and an issue cache and
+// sync engine of its own. Requests to its Jira share one rate limiter.
+type tenant struct {
+	TenantConfig
+
+	transport       jiraTransport                         // requests to its Jira, kept across reloads
+	backend         jira.TestManagementBackend            // service account backend of the default project
+	projectBackends map[string]jira.TestManagementBackend // service account backends by upper-case project key
+	jiraClients     *jira.ClientCache                     // clients with caller credentials; nil when delegation is off
+	cache           store.IssueCache
+	results         store.Results // executions and run history
+	syncEngine      *syncer.Engine
+}
+
+// Header and context key selecting the tenant of a request
+const (
+	tenantHeader     = "X-Jira-Tenant"
+	tenantContextKey = "tenant"
+)
+
+// tenantPathPrefix starts paths that select a tenant, /api/tenants/<name>/...
+const tenantPathPrefix = "/api/tenants/"
+
+// newTenants connects to every configured Jira. The default tenant keeps its
+// issue cache and run history in the store's own so that those from before
+// tenants existed stay valid. On reload, previous are the tenants in use: the transports and
+// in-memory backends of those with the same name carry over.
+func newTenants(c *Config, s store.Store, previous []*tenant) ([]*tenant, error) {
+	var list []*tenant
+	for i, settings := range c.AllTenants() {
+		var before *tenant
+		for _, p := range previous {
+			if p.Name == settings.Name {
+				before = p
+			}
+		}
+		transport, err := newJiraTransport(c, settings, before)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", settings.Name, err)
+		}
+		b := newTenantBackend(c, settings, transport, before)
+		storeName := settings.Name
+		if i == 0 {
+			storeName = ""
+		}
+		t, err := newTenant(c, settings, b, s.TenantCache(storeName), before)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", settings.Name, err)
+		}
+		t.transport = transport
+		t.results = s.TenantResults(storeName)
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// newTenant sets up a tenant around the backend of its default project
+func newTenant(c *Config, settings TenantConfig, b jira.TestManagementBackend, cache store.IssueCache, previous *tenant) (*tenant, error) {
+	engine, err := syncer.NewEngine(b, cache, syncer.Options{
+		ProjectKey:        settings.ProjectKey,
+		IssueTypes:        syncIssueTypes(settings.DefaultProject()),
+		Interval:          c.SyncInterval,
+		ReconcileInterval: c.SyncReconcileInterval,
+		Location:          c.SyncTimezone,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tenant{
+		TenantConfig:    settings,
+		backend:         b,
+		projectBackends: newProjectBackends(settings, b, previous),
+		jiraClients:     newJiraClientCache(c, settings, b),
+		cache:           cache,
+		syncEngine:      engine,
+	}, nil
+}
+
+// memoryBackend returns the in-memory backend t had for a project, if any.
+// t may be nil.
+func (t *tenant) memoryBackend(project string) (*jira.MemoryBackend, bool) {
+	if t == nil {
+		return nil, false
+	}
+	memory, ok := t.projectBackends[strings.ToUpper(project)].(*jira.MemoryBackend)
+	return memory, ok
+}
+
+// defaultTenant returns the tenant of requests that do not select one
+func defaultTenant() *tenant {
+	return currentTenants()[0]
+}
+
+// tenantByName finds a tenant by name, ignoring case
+func tenantByName(name string) (*tenant, bool) {
+	for _, t := range currentTenants() {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// tenantNames returns the names of the tenants, the default one first
+func tenantNames() []string {
+	tenants := currentTenants()
+	names := make([]string, len(tenants))
+	for i, t := range tenants {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// projectTenant finds the tenant serving a project
+func projectTenant(project string) (*tenant, bool) {
+	for _, t := range currentTenants() {
+		if _, ok := t.Project(project); ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// selectTenant resolves the tenant named in the X-Jira-Tenant header, or the
+// default tenant without one. Unknown tenants are rejected.
+func selectTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := strings.TrimSpace(c.GetHeader(tenantHeader))
+		if name == "" {
+			c.Set(tenantContextKey, defaultTenant())
+			c.Next()
+			return
+		}
+		t, ok := tenantByName(name)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error":   "Unknown tenant",
+				"details": fmt.Sprintf("tenant %s is not configured, add it to JIRA_TENANTS", name),
+			})
+			return
+		}
+		c.Set(tenantContextKey, t)
+		c.Next()
+	}
+}
+
+// requestTenant returns the tenant a request works with
+func requestTenant(c *gin.Context) *tenant {
+	if value, ok := c.Get(tenantContextKey); ok {
+		return value.(*tenant)
+	}
+	return defaultTenant()
+}
+
+// tenantPaths serves /api/tenants/<name>/... as the same path without the
+// prefix, with <name> in the X-Jira-Tenant header. A different tenant in the
+// header is rejected rather than silently overridden.
+func tenantPaths(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, tenantPathPrefix)
+		name, path, found := strings.Cut(rest, "/")
+		if !ok || !found || name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if header := r.Header.Get(tenantHeader); header != "" && !strings.EqualFold(header, name) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(gin.H{
+				"error":   "Conflicting tenant",
+				"details": fmt.Sprintf("the path selects tenant %s and the %s header tenant %s", name, tenantHeader, header),
+			})
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = "/api/" + path
+		r2.URL.RawPath = ""
+		r2.Header = r.Header.Clone()
+		r2.Header.Set(tenantHeader, name)
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// List the configured Jira tenants
+func getTenants(c *gin.Context) {
+	log.Println("Handling GET /api/tenants request")
+
+	tenants := currentTenants()
+	list := make([]gin.H, 0, len(tenants))
+	for _, t := range tenants {
+		list = append(list, gin.H{
+			"name":           t.Name,
+			"baseUrl":        redactURL(t.BaseURL),
+			"defaultProject": t.ProjectKey,
+			"projects":       projectKeysOf(t.TenantConfig),
+			"rateLimit":      t.RateLimit,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tenants":       list,
+		"count":         len(list),
+		"defaultTenant": defaultTenant().Name,
+		"message":       "Tenants retrieved successfully",
+	})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/jiratest"
+	"jira-xray-integration/store"
+)
+
+// addDCTenant connects a second Jira, tenant dc, serving project DC, and
+// creates DC-1 there
+func addDCTenant(t *testing.T, env *testEnv) {
+	t.Helper()
+	srv := jiratest.NewServer("DC")
+	t.Cleanup(srv.Close)
+	currentConfig().Tenants = []TenantConfig{{
+		Name:       "dc",
+		BaseURL:    srv.URL,
+		Username:   jiratest.Username,
+		APIToken:   jiratest.APIToken,
+		ProjectKey: "DC",
+	}}
+	env.reload(t)
+	env.mustDo(t, http.StatusCreated, http.MethodPost, "/api/testcases",
+		`{"summary":"Replicate to standby"}`, tenantHeader, "dc")
+}
+
+// expectTestCases checks the keys of the listed test cases
+func expectTestCases(keys ...string) func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+		body := decodeBody(t, rec)
+		list, _ := body["testCases"].([]interface{})
+		var got []string
+		for _, tc := range list {
+			got = append(got, tc.(map[string]interface{})["key"].(string))
+		}
+		if strings.Join(got, ",") != strings.Join(keys, ",") {
+			t.Errorf("got test cases %v, want %v", got, keys)
+		}
+	}
+}
+
+func TestTenants(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "list tenants",
+			setup:  addDCTenant,
+			method: http.MethodGet, path: "/api/tenants",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				body := decodeBody(t, rec)
+				if body["count"] != 2.0 || body["defaultTenant"] != "default" {
+					t.Errorf("got %v", body)
+				}
+			},
+		},
+		{
+			name:   "tenant selected by header",
+			setup:  addDCTenant,
+			method: http.MethodGet, path: "/api/testcases",
+			headers: []string{tenantHeader, "DC"},
+			status:  http.StatusOK,
+			check:   expectTestCases("DC-1"),
+		},
+		{
+			name:   "tenant selected by path",
+			setup:  addDCTenant,
+			method: http.MethodGet, path: "/api/tenants/dc/testcases/DC-1",
+			status: http.StatusOK,
+		},
+		{
+			name:   "default tenant without selection",
+			setup:  addDCTenant,
+			method: http.MethodGet, path: "/api/testcases",
+			status: http.StatusOK,
+			check:  expectTestCases("TEST-1", "TEST-2", "TEST-3"),
+		},
+		{
+			name:   "conflicting tenant in path and header",
+			setup:  addDCTenant,
+			method: http.MethodGet, path: "/api/tenants/dc/testcases",
+			headers: []string{tenantHeader, "default"},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:   "unknown tenant",
+			setup:  addDCTenant,
+			method: http.MethodGet, path: "/api/tenants/cloud/testcases",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "project of another tenant",
+			setup:  addDCTenant,
+			method: http.MethodGet, path: "/api/projects/DC/testcases",
+			status: http.StatusNotFound,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if details, _ := decodeBody(t, rec)["details"].(string); !strings.Contains(details, "tenant dc") {
+					t.Errorf("got details %q, want a hint at tenant dc", details)
+				}
+			},
+		},
+		{
+			name:   "issue of another tenant",
+			setup:  addDCTenant,
+			method: http.MethodGet, path: "/api/testcases/DC-1",
+			status: http.StatusNotFound,
+		},
+		{
+			name: "caches are kept per tenant",
+			setup: func(t *testing.T, env *testEnv) {
+				addDCTenant(t, env)
+				env.setSyncInterval(t, time.Hour)
+				env.mustDo(t, http.StatusOK, http.MethodPost, "/api/sync", "")
+				env.mustDo(t, http.StatusOK, http.MethodPost, "/api/tenants/dc/sync", "")
+			},
+			method: http.MethodGet, path: "/api/tenants/dc/testcases",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if source := decodeBody(t, rec)["source"]; source != "cache" {
+					t.Errorf("got test cases from %v, want the cache", source)
+				}
+				expectTestCases("DC-1")(t, env, rec)
+				expectTestCases("TEST-1", "TEST-2", "TEST-3")(t, env, env.do(http.MethodGet, "/api/testcases", ""))
+			},
+		},
+		{
+			name: "outbox entry records its tenant",
+			setup: func(t *testing.T, env *testEnv) {
+				addDCTenant(t, env)
+				tenantByNameOrFail(t, "dc").backend.(*jira.Client).HTTPClient.Transport = failingTransport{}
+			},
+			method: http.MethodPost, path: "/api/tenants/dc/testcases",
+			body:   `{"summary":"Queued test"}`,
+			status: http.StatusAccepted,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				entries, err := resultStore.ListOutbox(store.OutboxPending)
+				if err != nil || len(entries) != 1 || entries[0].Tenant != "dc" {
+					t.Fatalf("got outbox entries %+v (%v), want one of tenant dc", entries, err)
+				}
+			},
+		},
+	})
+}
+
+// tenantByNameOrFail returns a configured tenant
+func tenantByNameOrFail(t *testing.T, name string) *tenant {
+	t.Helper()
+	tenant, ok := tenantByName(name)
+	if !ok {
+		t.Fatalf("tenant %s is not configured", name)
+	}
+	return tenant
+}
+
+// failingTransport answers every request as an unavailable Jira
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	rec.WriteHeader(http.StatusServiceUnavailable)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func TestLoadTenants(t *testing.T) {
+	t.Setenv("JIRA_TENANTS", "dc, qa")
+	t.Setenv("JIRA_TENANT_DC_BASE_URL", "https://jira.example.com")
+	t.Setenv("JIRA_TENANT_DC_ACCESS_TOKEN", "pat")
+	t.Setenv("JIRA_TENANT_DC_PROJECT_KEY", "dc")
+	t.Setenv("JIRA_TENANT_DC_RATE_LIMIT", "5")
+	t.Setenv("JIRA_TENANT_QA_BASE_URL", "https://qa.atlassian.net")
+	t.Setenv("JIRA_TENANT_QA_USERNAME", "qa@example.com")
+	t.Setenv("JIRA_TENANT_QA_API_TOKEN", "token")
+	t.Setenv("JIRA_TENANT_QA_PROJECT_KEY", "QA")
+	t.Setenv("JIRA_TENANT_QA_PROJECTS", "OPS")
+
+	c := &Config{Backend: "jira", JiraTenant: "default", JiraProjectKey: "TEST"}
+	if err := loadTenants(c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.AllTenants()) != 3 || c.DefaultTenant().ProjectKey != "TEST" {
+		t.Fatalf("got tenants %+v", c.AllTenants())
+	}
+	dc, qa := c.Tenants[0], c.Tenants[1]
+	if dc.ProjectKey != "DC" || dc.AccessToken != "pat" || dc.RateLimit != 5 {
+		t.Errorf("got tenant dc %+v", dc)
+	}
+	if got := strings.Join(projectKeysOf(qa), ","); got != "QA,OPS" {
+		t.Errorf("got projects of qa %s, want QA,OPS", got)
+	}
+
+	t.Setenv("JIRA_TENANT_QA_PROJECTS", "DC")
+	if err := loadTenants(c); err == nil {
+		t.Error("expected an error for a project served by two tenants")
+	}
+	t.Setenv("JIRA_TENANT_QA_PROJECTS", "")
+	t.Setenv("JIRA_TENANT_QA_API_TOKEN", "")
+	if err := loadTenants(c); err == nil {
+		t.Error("expected an error for a tenant without credentials")
+	}
+}
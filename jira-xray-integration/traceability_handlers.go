@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/report"
+	"jira-xray-integration/requirements"
+	"jira-xray-integration/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRequirementsBuilder creates a builder for traceability and coverage in
+// the project of a request
+func newRequirementsBuilder(c *gin.Context) *requirements.Builder {
+	project := requestProjectConfig(c)
+	options := requirements.DefaultOptions
+	options.TestIssueTypes = []string{project.IssueTypes.Test}
+	options.DefectIssueTypes = project.IssueTypes.WithDefaults().Defects
+	options.TestLinkType = project.LinkTypes.Tests
+	options.DefectLinkType = project.LinkTypes.Defects
+	options.TestPlanLinkType = project.LinkTypes.TestPlans
+	return &requirements.Builder{
+		Searcher: jiraFor(c),
+		Results:  &storeResultProvider{store: requestTenant(c).results},
+		Options:  options,
+	}
+}
+
+// requirementsJQL returns the query selecting requirements: jql, or the
+// issues of the project's requirement issue types. The query is always kept
+// to the project of the route, the default project on routes outside
+// /api/projects, as that is the project the caller's permission was checked on.
+func requirementsJQL(c *gin.Context, jql string) (string, error) {
+	project := requestProject(c)
+	if jql == "" {
+		return fmt.Sprintf("project = %s AND issuetype in (%s)", project, jqlList(requestProjectConfig(c).IssueTypes.WithDefaults().Requirements)), nil
+	}
+	if err := checkJQLClause(jql); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("project = %s AND (%s)", project, jql), nil
+}
+
+// checkJQLClause checks that jql can be put in parentheses as one clause:
+// its quotes are closed, its parentheses balanced and it has no ORDER BY.
+// Otherwise a query such as "x) OR (project = PAY" would leave the project
+// it is kept to.
+func checkJQLClause(jql string) error {
+	depth := 0
+	var quote rune
+	escaped := false
+	var unquoted strings.Builder
+	for i, r := range jql {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth--; depth < 0 {
+				return fmt.Errorf("unbalanced ) at position %d", i+1)
+			}
+		}
+		if quote != 0 {
+			r = ' '
+		}
+		unquoted.WriteRune(r)
+	}
+	if quote != 0 {
+		return errors.New("unterminated quoted value")
+	}
+	if depth > 0 {
+		return errors.New("unbalanced (")
+	}
+	if jqlOrderBy.MatchString(unquoted.String()) {
+		return errors.New("ORDER BY is not supported")
+	}
+	return nil
+}
+
+// jqlOrderBy finds an ORDER BY outside quoted values
+var jqlOrderBy = regexp.MustCompile(`(?i)\border\s+by\b`)
+
+// jqlList formats names as the values of a JQL list, quoting those that
+// are not a single word
+func jqlList(names []string) string {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = name
+		if strings.IndexFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0 {
+			values[i] = strconv.Quote(name)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// scopeFromQuery reads the fixVersion, testPlan and environment query
+// parameters. The test plan must be an issue key, as it is put in JQL.
+func scopeFromQuery(c *gin.Context) (requirements.Scope, error) {
+	scope := requirements.Scope{
+		FixVersion:  c.Query("fixVersion"),
+		TestPlan:    c.Query("testPlan"),
+		Environment: c.Query("environment"),
+	}
+	if scope.TestPlan != "" && !jira.IsIssueKey(scope.TestPlan) {
+		return scope, fmt.Errorf("testPlan %q is not an issue key such as PLAN-1", scope.TestPlan)
+	}
+	return scope, nil
+}
+
+// rejectInvalidScope answers 400 for a query whose scope cannot be used
+func rejectInvalidScope(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "Invalid scope",
+		"details": err.Error(),
+	})
+}
+
+// Build a traceability matrix of requirements, linked tests, latest results and defects
+func getTraceabilityMatrix(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	log.Printf("Handling GET /api/traceability request (format=%s): %s", format, c.Query("jql"))
+	jql, err := requirementsJQL(c, c.Query("jql"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid JQL",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format != "json" && format != "csv" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported format, use json, csv or html",
+		})
+		return
+	}
+
+	scope, err := scopeFromQuery(c)
+	if err != nil {
+		rejectInvalidScope(c, err)
+		return
+	}
+
+	matrix, err := newRequirementsBuilder(c).Build(jql, scope)
+	if err != nil {
+		log.Printf("Error building traceability matrix: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build traceability matrix",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+		if err := spreadsheet.WriteCSV(&buf, matrix.Rows()); err != nil {
+			log.Printf("Error writing traceability matrix: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to export traceability matrix",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="traceability.csv"`)
+		c.Data(http.StatusOK, spreadsheet.FormatCSV.ContentType(), buf.Bytes())
+	case "html":
+		var buf bytes.Buffer
+		if err := report.RenderTraceabilityHTML(&buf, matrix); err != nil {
+			log.Printf("Error rendering traceability matrix: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to render traceability matrix",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"matrix":  matrix,
+			"count":   len(matrix.Requirements),
+			"message": "Traceability matrix built successfully",
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/jira"
+	"jira-xray-integration/jiratest"
+)
+
+// jiratestFault fails the next request matching method and path
+func jiratestFault(method, path string, status int) jiratest.Fault {
+	return jiratest.Fault{Method: method, Path: path, Status: status, Times: 1}
+}
+
+func TestTraceabilityMatrix(t *testing.T) {
+	runHandlerTests(t, []handlerTest{
+		{
+			name:   "json",
+			setup:  recordPassAndFail,
+			method: http.MethodGet, path: "/api/traceability",
+			status: http.StatusOK,
+			golden: "traceability",
+		},
+		{
+			name:   "scoped to a test plan",
+			setup:  recordPassAndFail,
+			method: http.MethodGet, path: "/api/traceability?testPlan=TEST-8",
+			status: http.StatusOK,
+			golden: "traceability_test_plan",
+		},
+		{
+			name:   "csv",
+			setup:  recordPassAndFail,
+			method: http.MethodGet, path: "/api/traceability?format=csv",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !strings.Contains(rec.Body.String(), "TEST-4") || !strings.Contains(rec.Body.String(), "TEST-7") {
+					t.Errorf("CSV is missing rows:\n%s", rec.Body.String())
+				}
+			},
+		},
+		{
+			name: "only configured link types are followed",
+			setup: func(t *testing.T, env *testEnv) {
+				currentConfig().Projects = []jira.ProjectConfig{{
+					Key:        "TEST",
+					IssueTypes: jira.DefaultIssueTypes,
+					LinkTypes:  jira.LinkTypes{Tests: "Test", Defects: "Defect"},
+				}}
+				env.reload(t)
+			},
+			method: http.MethodGet, path: "/api/traceability?format=csv",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !strings.Contains(rec.Body.String(), "TEST-2") || strings.Contains(rec.Body.String(), "TEST-7") {
+					t.Errorf("expected tests but no defects linked by Blocks:\n%s", rec.Body.String())
+				}
+			},
+		},
+		{
+			name:   "html",
+			method: http.MethodGet, path: "/api/traceability?format=html",
+			status: http.StatusOK,
+			check: func(t *testing.T, env *testEnv, rec *httptest.ResponseRecorder) {
+				if !strings.Contains(rec.Body.String(), "<html") {
+					t.Errorf("response is not HTML")
+				}
+			},
+		},
+		{
+			name:   "unsupported format",
+			method: http.MethodGet, path: "/api/traceability?format=xml",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "JQL closing the project scope",
+			method: http.MethodGet, path: "/api/projects/TEST/traceability?jql=key+%3D+TEST-4)+OR+(project+%3D+PAY",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "JQL with ORDER BY",
+			method: http.MethodGet, path: "/api/traceability?jql=issuetype+%3D+Story+ORDER+BY+key",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "JQL with unterminated quote",
+			method: http.MethodGet, path: "/api/requirements/coverage?jql=summary+~+%22sign)",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid JQL",
+			method: http.MethodGet, path: "/api/traceability?jql=key+%3D+TEST-4+OR+key+%3D+TEST-5",
+			status: http.StatusInternalServerError,
+		},
+	})
+}
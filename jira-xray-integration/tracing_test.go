@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"jira-xray-integration/outbox"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans makes the global tracer provider record every span until the
+// test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return recorder
+}
+
+// spanNamed returns the ended span called name
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	var names []string
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+		names = append(names, span.Name())
+	}
+	t.Fatalf("no span %q among %v", name, names)
+	return nil
+}
+
+// spanAttribute returns the value of an attribute of span
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	env := newTestEnv(t)
+	recorder := recordSpans(t)
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	env.mustDo(t, http.StatusOK, http.MethodGet, "/api/testcases/TEST-1", "", "traceparent", traceparent)
+
+	server := spanNamed(t, recorder, "GET /api/testcases/:key")
+	if got := server.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("request span is in trace %s, want the caller's", got)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("request span has parent %s, want the caller's span", got)
+	}
+	if server.SpanKind() != trace.SpanKindServer || spanAttribute(server, "http.response.status_code").AsInt64() != http.StatusOK {
+		t.Errorf("got request span kind %v with attributes %v", server.SpanKind(), server.Attributes())
+	}
+
+	client := spanNamed(t, recorder, "jira GET issue/{key}")
+	if client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("Jira span is not a child of the request span")
+	}
+	for key, want := range map[string]interface{}{
+		"http.request.method":       "GET",
+		"jira.endpoint":             "issue/{key}",
+		"jira.retries":              int64(0),
+		"http.response.status_code": int64(http.StatusOK),
+	} {
+		if got := spanAttribute(client, key).AsInterface(); got != want {
+			t.Errorf("Jira span %s: got %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestTracingOutboxReplay(t *testing.T) {
+	env := newTestEnv(t)
+	queueTestCase(t, env)
+	recorder := recordSpans(t)
+
+	replayOutbox(t, env)
+
+	replay := spanNamed(t, recorder, "outbox replay "+outbox.OpCreateTestCase)
+	if got := spanAttribute(replay, "outbox.attempt").AsInt64(); got != 1 {
+		t.Errorf("got attempt %d, want 1", got)
+	}
+	create := spanNamed(t, recorder, "jira POST issue")
+	if create.Parent().SpanID() != replay.SpanContext().SpanID() {
+		t.Errorf("Jira span is not a child of the replay span")
+	}
+	if got := spanAttribute(create, "jira.retries").AsInt64(); got != 1 {
+		t.Errorf("got %d retries, want 1", got)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"jira-xray-integration/jira"
+)
+
+// Startup verification modes
+const (
+	verifyOff  = "off"  // trust the configured names
+	verifyWarn = "warn" // log names missing from Jira
+	verifyFail = "fail" // refuse to start when names are missing from Jira
+)
+
+// verifyTenants checks that the issue types, link types and statuses every
+// project is configured with exist in its tenant's Jira. In fail mode names
+// missing from Jira are returned as an error; a Jira that cannot be reached
+// is only logged, so an outage does not keep the service from starting.
+// Projects of the in-memory backend are not checked.
+func verifyTenants(list []*tenant, mode string) error {
+	if mode == verifyOff {
+		return nil
+	}
+	var problems []string
+	for _, t := range list {
+		keys := make([]string, 0, len(t.projectBackends))
+		for key := range t.projectBackends {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			client, ok := t.projectBackends[key].(*jira.Client)
+			if !ok {
+				continue
+			}
+			err := client.VerifyProject()
+			var mismatch *jira.ProjectMismatchError
+			switch {
+			case err == nil:
+				log.Printf("✅ Tenant %s, project %s matches Jira", t.Name, key)
+			case errors.As(err, &mismatch):
+				for _, missing := range mismatch.Missing {
+					log.Printf("❌ Tenant %s, project %s: %s", t.Name, key, missing)
+				}
+				problems = append(problems, fmt.Sprintf("tenant %s: %v", t.Name, mismatch))
+			default:
+				log.Printf("⚠️  Could not verify tenant %s, project %s against Jira: %v", t.Name, key, err)
+			}
+		}
+	}
+	if len(problems) > 0 && mode == verifyFail {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
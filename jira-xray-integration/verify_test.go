@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"jira-xray-integration/jiratest"
+)
+
+func TestVerifyTenants(t *testing.T) {
+	env := newTestEnv(t)
+	if err := verifyTenants(currentTenants(), verifyFail); err != nil {
+		t.Fatalf("default project: %v", err)
+	}
+
+	// PAY keeps its tests in "QA Test" issues, which this Jira lacks
+	addPayProject(t, env)
+	err := verifyTenants(currentTenants(), verifyFail)
+	if err == nil || !strings.Contains(err.Error(), `project PAY does not match Jira: issue type "QA Test" not found`) {
+		t.Errorf("got error %v, want the missing issue type of PAY", err)
+	}
+	if err := verifyTenants(currentTenants(), verifyWarn); err != nil {
+		t.Errorf("warn mode: got %v", err)
+	}
+	if err := verifyTenants(currentTenants(), verifyOff); err != nil {
+		t.Errorf("off mode: got %v", err)
+	}
+
+	// A Jira that cannot be reached does not keep the service from starting
+	env.jira.IssueTypes = append(env.jira.IssueTypes, "QA Test", "QA Run")
+	env.jira.InjectFault(jiratest.Fault{Method: http.MethodGet, Path: "/project", Status: http.StatusServiceUnavailable})
+	if err := verifyTenants(currentTenants(), verifyFail); err != nil {
+		t.Errorf("unavailable Jira: got %v", err)
+	}
+	env.jira.ClearFaults()
+	if err := verifyTenants(currentTenants(), verifyFail); err != nil {
+		t.Errorf("after adding the issue types: got %v", err)
+	}
+}